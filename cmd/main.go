@@ -23,9 +23,11 @@
 package main
 
 import (
+	"log"
 	_ "whotterre/argent/docs"
 	"whotterre/argent/internal/config"
 	"whotterre/argent/internal/initializers"
+	"whotterre/argent/internal/middleware"
 	"whotterre/argent/internal/routes"
 
 	"github.com/gin-contrib/cors"
@@ -39,16 +41,23 @@ func main() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-API-Key", "X-Paystack-Signature"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-API-Key", "X-Paystack-Signature", "X-Argent-Key-Id", "X-Argent-Timestamp", "X-Argent-Nonce", "X-Argent-Signature", "X-Step-Up-Token", "X-Request-ID"},
+		ExposeHeaders:    []string{"X-Request-ID"},
 		AllowCredentials: true,
 		MaxAge:           300, // 5 minutes
 	}))
+	app.Use(middleware.RequestID())
 
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		return
 	}
 
+	// Only honour X-Forwarded-For from known proxies when resolving client IPs
+	if err := app.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	// Connect to database
 	initializers.ConnectToDB(cfg.DatabaseURL)
 	db := initializers.DB
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -14,8 +17,90 @@ type Config struct {
 	GoogleClientID     string
 	GoogleClientSecret string
 	GoogleRedirectURL  string
-	JWTSecret          string
+	AccessTokenTTL     time.Duration
+	RefreshTokenTTL    time.Duration
+	JWTSigningAlg      string
+	JWTKeyRotation     time.Duration
 	PaystackSecret     string
+	PaystackTestSecret string
+	TrustedProxies     []string
+
+	// Sign-in identity providers, keyed by the name used in /auth/:provider
+	OAuthProviders []OAuthProvider
+	// Front-end URLs a login may redirect back to with a one-time code
+	OAuthRedirectAllowlist []string
+
+	// API key lifecycle policy
+	APIKeyMaxLifetime     time.Duration
+	APIKeyAllowNoExpiry   bool
+	APIKeyRolloverGrace   time.Duration
+	APIKeyExpiryWarningIn time.Duration
+
+	// Email/password and magic-link sign-in
+	AuthLinkBaseURL string // where emailed links point, defaults to BaseURL
+	MailDriver      string // smtp, file or log
+	MailFrom        string
+	MailFileDir     string
+	SMTPHost        string
+	SMTPPort        string
+	SMTPUsername    string
+	SMTPPassword    string
+
+	// Two-factor step-up for sensitive actions
+	StepUpTransferThreshold float64       // transfers above this need step-up
+	StepUpTTL               time.Duration // lifetime of a step-up token
+	TwoFactorRequired       bool          // refuse sensitive actions until 2FA is enrolled
+
+	// Verified users with these emails are made admins at startup
+	AdminEmails []string
+
+	// Transaction PIN
+	PINMaxAttempts int           // wrong PINs before the PIN locks
+	PINLockout     time.Duration // how long a locked PIN stays locked
+
+	// Request signing
+	SecretsEncryptionKey string
+	SignatureMaxSkew     time.Duration
+
+	// Scheduled transfers
+	ScheduleRetryInterval time.Duration // wait before retrying an occurrence that lacked funds
+	ScheduleMaxRetries    int           // retries before the occurrence is skipped
+	ScheduleMaxPerUser    int           // active and paused schedules a user may have
+
+	// Batch transfers
+	BatchMaxItems int // transfers a batch may contain
+
+	// Outgoing webhooks
+	WebhookMaxAttempts   int           // attempts before a delivery is given up on
+	WebhookRetryBase     time.Duration // wait after the first failure, doubled after each one
+	WebhookRetryMax      time.Duration // longest wait between attempts
+	WebhookTimeout       time.Duration // how long an endpoint has to answer
+	WebhookMaxEndpoints  int           // endpoints a user may register
+	WebhookAllowInsecure bool          // accept http:// and private-network URLs, for local development
+
+	// Domain events, published to the in-process bus and these external sinks
+	EventSinks         []string // postgres, nats and/or kafka
+	EventNotifyChannel string   // LISTEN/NOTIFY channel for the postgres sink
+	NATSURL            string
+	EventNATSSubject   string // events go to <subject>.<event type>
+	KafkaRESTURL       string // Kafka REST proxy used by the kafka sink
+	EventKafkaTopic    string
+
+	// Real-time wallet updates
+	RealtimeChannel    string // LISTEN/NOTIFY channel that carries updates between replicas
+	RealtimeMaxStreams int    // event streams one user, or one deposit, may hold open per server
+	DepositCompleteURL string // where the deposit callback sends browsers, defaults to the built-in page
+}
+
+// OAuthProvider configures an external identity provider users can sign in with
+type OAuthProvider struct {
+	Name         string
+	Type         string // "oidc" or "github"
+	Issuer       string // OIDC issuer, discovered via /.well-known/openid-configuration
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	ResponseMode string // "form_post" makes the provider POST the callback
 }
 
 func LoadConfig() (config Config, err error) {
@@ -29,11 +114,200 @@ func LoadConfig() (config Config, err error) {
 	config.GoogleClientID = os.Getenv("GOOGLE_CLIENT_ID")
 	config.GoogleClientSecret = os.Getenv("GOOGLE_CLIENT_SECRET")
 	config.GoogleRedirectURL = os.Getenv("GOOGLE_REDIRECT_URL")
-	config.JWTSecret = os.Getenv("JWT_SECRET")
+	config.AccessTokenTTL = getDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+	config.RefreshTokenTTL = getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	config.JWTSigningAlg = os.Getenv("JWT_SIGNING_ALG")
+	if config.JWTSigningAlg == "" {
+		config.JWTSigningAlg = "EdDSA"
+	}
+	config.JWTKeyRotation = getDuration("JWT_KEY_ROTATION", 30*24*time.Hour)
 	config.PaystackSecret = os.Getenv("PAYSTACK_SECRET")
+	config.PaystackTestSecret = os.Getenv("PAYSTACK_TEST_SECRET")
+	config.TrustedProxies = splitList(os.Getenv("TRUSTED_PROXIES"))
+	config.APIKeyMaxLifetime = getDuration("API_KEY_MAX_LIFETIME", 365*24*time.Hour)
+	config.APIKeyAllowNoExpiry = os.Getenv("API_KEY_ALLOW_NO_EXPIRY") == "true"
+	config.APIKeyRolloverGrace = getDuration("API_KEY_ROLLOVER_GRACE", 24*time.Hour)
+	config.APIKeyExpiryWarningIn = getDuration("API_KEY_EXPIRY_WARNING", 7*24*time.Hour)
+	config.SecretsEncryptionKey = os.Getenv("SECRETS_ENCRYPTION_KEY")
+	config.StepUpTransferThreshold = getFloat("STEP_UP_TRANSFER_THRESHOLD", 50000)
+	config.StepUpTTL = getDuration("STEP_UP_TTL", 5*time.Minute)
+	config.TwoFactorRequired = os.Getenv("TWO_FACTOR_REQUIRED") == "true"
+	config.AdminEmails = splitList(os.Getenv("ADMIN_EMAILS"))
+	config.PINMaxAttempts = getInt("PIN_MAX_ATTEMPTS", 5)
+	config.PINLockout = getDuration("PIN_LOCKOUT", 30*time.Minute)
+	config.SignatureMaxSkew = getDuration("SIGNATURE_MAX_SKEW", 5*time.Minute)
+	config.ScheduleRetryInterval = getDuration("SCHEDULE_RETRY_INTERVAL", 6*time.Hour)
+	config.ScheduleMaxRetries = getInt("SCHEDULE_MAX_RETRIES", 3)
+	config.ScheduleMaxPerUser = getInt("SCHEDULE_MAX_PER_USER", 25)
+	config.BatchMaxItems = getInt("BATCH_MAX_ITEMS", 500)
+	config.WebhookMaxAttempts = getInt("WEBHOOK_MAX_ATTEMPTS", 10)
+	config.WebhookRetryBase = getDuration("WEBHOOK_RETRY_BASE", 30*time.Second)
+	config.WebhookRetryMax = getDuration("WEBHOOK_RETRY_MAX", 6*time.Hour)
+	config.WebhookTimeout = getDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+	config.WebhookMaxEndpoints = getInt("WEBHOOK_MAX_ENDPOINTS", 10)
+	config.WebhookAllowInsecure = os.Getenv("WEBHOOK_ALLOW_INSECURE") == "true"
+	config.EventSinks = splitList(os.Getenv("EVENT_SINKS"))
+	config.EventNotifyChannel = getString("EVENT_NOTIFY_CHANNEL", "argent_events")
+	config.NATSURL = getString("NATS_URL", "nats://localhost:4222")
+	config.EventNATSSubject = getString("EVENT_NATS_SUBJECT", "argent.events")
+	config.KafkaRESTURL = os.Getenv("KAFKA_REST_URL")
+	config.EventKafkaTopic = getString("EVENT_KAFKA_TOPIC", "argent.events")
+	config.RealtimeChannel = getString("REALTIME_NOTIFY_CHANNEL", "argent_wallet_updates")
+	config.RealtimeMaxStreams = getInt("REALTIME_MAX_STREAMS", 5)
+	config.DepositCompleteURL = getString("DEPOSIT_COMPLETE_URL", strings.TrimSuffix(config.BaseURL, "/")+"/wallet/deposit/complete")
+	config.OAuthProviders = loadOAuthProviders(config)
+	config.OAuthRedirectAllowlist = splitList(os.Getenv("OAUTH_REDIRECT_ALLOWLIST"))
+	config.AuthLinkBaseURL = strings.TrimSuffix(os.Getenv("AUTH_LINK_BASE_URL"), "/")
+	if config.AuthLinkBaseURL == "" {
+		config.AuthLinkBaseURL = config.BaseURL
+	}
+	config.MailDriver = os.Getenv("MAIL_DRIVER")
+	config.MailFrom = os.Getenv("MAIL_FROM")
+	if config.MailFrom == "" {
+		config.MailFrom = "Argent <no-reply@argent.local>"
+	}
+	config.MailFileDir = os.Getenv("MAIL_FILE_DIR")
+	if config.MailFileDir == "" {
+		config.MailFileDir = "mail"
+	}
+	config.SMTPHost = os.Getenv("SMTP_HOST")
+	config.SMTPPort = os.Getenv("SMTP_PORT")
+	if config.SMTPPort == "" {
+		config.SMTPPort = "587"
+	}
+	config.SMTPUsername = os.Getenv("SMTP_USERNAME")
+	config.SMTPPassword = os.Getenv("SMTP_PASSWORD")
 
 	// Debug log
 	log.Printf("Config loaded: PORT=%s, DATABASE_URL=%s, BASE_URL=%s", config.Port, config.DatabaseURL, config.BaseURL)
 
 	return config, nil
 }
+
+// loadOAuthProviders enables each built-in provider that has a client ID set,
+// plus any generic OIDC providers listed in OIDC_PROVIDERS
+func loadOAuthProviders(config Config) []OAuthProvider {
+	var providers []OAuthProvider
+	if config.GoogleClientID != "" {
+		providers = append(providers, OAuthProvider{
+			Name:         "google",
+			Type:         "oidc",
+			Issuer:       "https://accounts.google.com",
+			ClientID:     config.GoogleClientID,
+			ClientSecret: config.GoogleClientSecret,
+		})
+	}
+	if clientID := os.Getenv("MICROSOFT_CLIENT_ID"); clientID != "" {
+		tenant := os.Getenv("MICROSOFT_TENANT")
+		if tenant == "" {
+			tenant = "common"
+		}
+		providers = append(providers, OAuthProvider{
+			Name:         "microsoft",
+			Type:         "oidc",
+			Issuer:       "https://login.microsoftonline.com/" + tenant + "/v2.0",
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("MICROSOFT_CLIENT_SECRET"),
+		})
+	}
+	if clientID := os.Getenv("APPLE_CLIENT_ID"); clientID != "" {
+		providers = append(providers, OAuthProvider{
+			Name:         "apple",
+			Type:         "oidc",
+			Issuer:       "https://appleid.apple.com",
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("APPLE_CLIENT_SECRET"),
+			Scopes:       []string{"openid", "email", "name"},
+			// Apple only releases email and name to form_post callbacks
+			ResponseMode: "form_post",
+		})
+	}
+	if clientID := os.Getenv("GITHUB_CLIENT_ID"); clientID != "" {
+		providers = append(providers, OAuthProvider{
+			Name:         "github",
+			Type:         "github",
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+		})
+	}
+
+	for _, name := range splitList(os.Getenv("OIDC_PROVIDERS")) {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OAuthProvider{
+			Name:         name,
+			Type:         "oidc",
+			Issuer:       strings.TrimSuffix(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       splitList(os.Getenv(prefix + "SCOPES")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			log.Printf("Skipping OIDC provider %q: %sISSUER and %sCLIENT_ID are required", name, prefix, prefix)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// splitList parses a comma-separated env value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getString reads an env value, falling back to def when it is unset
+func getString(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// getInt parses a whole-number env value, falling back to def
+func getInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i <= 0 {
+		log.Printf("Invalid %s %q, using default %d", key, value, def)
+		return def
+	}
+	return i
+}
+
+// getFloat parses a numeric env value, falling back to def
+func getFloat(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid %s %q, using default %v", key, value, def)
+		return def
+	}
+	return f
+}
+
+// getDuration parses a Go duration env value (e.g. "72h"), falling back to def
+func getDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using default %s", key, value, def)
+		return def
+	}
+	return d
+}
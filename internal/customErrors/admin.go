@@ -0,0 +1,13 @@
+package customErrors
+
+import "errors"
+
+var (
+	ErrInvalidRole          = errors.New("role must be user, support, finance or admin")
+	ErrCannotChangeOwnRole  = errors.New("you can't change your own role")
+	ErrCannotFreezeSelf     = errors.New("you can't change the status of your own account")
+	ErrInvalidAccountStatus = errors.New("status must be active, debit_frozen, frozen or closed")
+	ErrInvalidFreezeReason  = errors.New("invalid reason_code")
+	ErrAccountClosed        = errors.New("closed accounts can't be reopened")
+	ErrStatusUnchanged      = errors.New("the account already has this status")
+)
@@ -7,5 +7,17 @@ var (
 	ErrInvalidPermission = errors.New("invalid permission passed")
 	ErrHashingAPIKey = errors.New("failed to hash API key")
 	ErrNonExistentAPIKey = errors.New("API key doesn't exist")
-	ErrRollingOverNotExpiredKey = errors.New("API key being rolled over hasn't expired yet")
+	ErrInvalidIPAllowlist = errors.New("invalid IP allowlist entry")
+	ErrIPNotAllowed = errors.New("API key is not allowed from this IP address")
+	ErrInvalidKeyMode = errors.New("API key mode must be live or test")
+	ErrInvalidExpiry = errors.New("invalid expiry")
+	ErrNoExpiryNotAllowed = errors.New("API keys without expiry are not allowed")
+	ErrExpiryExceedsMaxLifetime = errors.New("API key expiry exceeds the maximum lifetime")
+	ErrAPIKeyAlreadyRolledOver = errors.New("API key has already been rolled over or revoked")
+	ErrInvalidGracePeriod = errors.New("invalid rollover grace period")
+	ErrSigningUnavailable = errors.New("request signing is not available for this API key")
+	ErrSignatureRequired = errors.New("this API key requires signed requests")
+	ErrInvalidSignature = errors.New("invalid request signature")
+	ErrStaleTimestamp = errors.New("request timestamp outside the allowed window")
+	ErrReplayedNonce = errors.New("request nonce has already been used")
 )
\ No newline at end of file
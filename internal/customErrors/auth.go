@@ -0,0 +1,32 @@
+package customErrors
+
+import "errors"
+
+var (
+	ErrInvalidRefreshToken     = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused      = errors.New("refresh token reuse detected")
+	ErrTokenRevoked            = errors.New("token has been revoked")
+	ErrUnknownProvider         = errors.New("unknown sign-in provider")
+	ErrIncompleteIdentity      = errors.New("identity provider did not return an email address")
+	ErrInvalidOAuthState       = errors.New("invalid or expired login session")
+	ErrRedirectNotAllowed      = errors.New("redirect_uri is not in the allowlist")
+	ErrInvalidLoginCode        = errors.New("invalid or expired login code")
+	ErrInvalidEmail            = errors.New("invalid email address")
+	ErrWeakPassword            = errors.New("password must be between 8 and 128 characters")
+	ErrNameRequired            = errors.New("first_name and last_name are required")
+	ErrEmailAlreadyRegistered  = errors.New("an account with this email already exists")
+	ErrInvalidCredentials      = errors.New("invalid email or password")
+	ErrEmailNotVerified        = errors.New("email address has not been verified")
+	ErrInvalidEmailToken       = errors.New("invalid or expired link")
+	ErrTwoFactorUnavailable    = errors.New("two-factor authentication is unavailable until SECRETS_ENCRYPTION_KEY is configured")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotPending     = errors.New("start two-factor enrollment first")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrTwoFactorLocked         = errors.New("too many invalid two-factor codes, try again later")
+	ErrStepUpRequired          = errors.New("step-up verification required")
+	ErrInvalidStepUpToken      = errors.New("invalid or expired step-up token")
+	ErrTwoFactorNotEnrolled    = errors.New("enable two-factor authentication to perform this action")
+	ErrAccountDisabled         = errors.New("this account has been disabled, contact support")
+	ErrUnverifiedEmail         = errors.New("an account with this email already exists and the provider has not verified the email")
+)
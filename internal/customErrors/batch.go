@@ -0,0 +1,10 @@
+package customErrors
+
+import "errors"
+
+var (
+	ErrInvalidBatchPolicy = errors.New("policy must be best_effort or all_or_nothing")
+	ErrEmptyBatch         = errors.New("a batch needs at least one item")
+	ErrBatchTooLarge      = errors.New("too many items in one batch")
+	ErrInvalidBatchItems  = errors.New("some items can't be paid")
+)
@@ -0,0 +1,16 @@
+package customErrors
+
+import "errors"
+
+var (
+	ErrInvalidEscrowExpiry  = errors.New("expires_at must be in the future and within 90 days")
+	ErrInvalidOnExpiry      = errors.New("on_expiry must be release or refund")
+	ErrDescriptionTooLong   = errors.New("description can't be longer than 280 characters")
+	ErrEscrowNotHeld        = errors.New("this escrow is no longer held")
+	ErrEscrowNotDisputed    = errors.New("this escrow is not in dispute")
+	ErrNotEscrowPayer       = errors.New("only the payer can release this escrow")
+	ErrNotEscrowPayee       = errors.New("only the payee can cancel this escrow")
+	ErrInvalidEscrowOutcome = errors.New("outcome must be release or refund")
+	ErrDisputeReasonMissing = errors.New("a reason is required to open a dispute")
+	ErrCannotResolveOwn     = errors.New("you can't settle an escrow you are the payer or payee of")
+)
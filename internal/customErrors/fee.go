@@ -0,0 +1,15 @@
+package customErrors
+
+import "errors"
+
+var (
+	ErrInvalidFeeType      = errors.New("type must be transfer or deposit")
+	ErrInvalidTier         = errors.New("tier must be standard, premium or business")
+	ErrUnsupportedCurrency = errors.New("currency must be NGN")
+	ErrInvalidFeeValues    = errors.New("fees can't be negative and percentages must be between 0 and 100")
+	ErrInvalidFeeCap       = errors.New("max_fee must be 0 for no cap, or at least min_fee")
+	ErrInvalidFeeBands     = errors.New("bands must have rising up_to limits, and only the last may leave up_to out")
+	ErrFeeRuleConflict     = errors.New("an active rule already prices this type, currency and tier")
+	ErrInvalidAmount       = errors.New("amount must be greater than 0")
+	ErrFeeExceedsAmount    = errors.New("the fee is more than the amount")
+)
@@ -0,0 +1,11 @@
+package customErrors
+
+import "errors"
+
+var (
+	ErrInvalidHoldExpiry  = errors.New("expires_at must be in the future and within 30 days")
+	ErrHoldNotActive      = errors.New("this hold is no longer active")
+	ErrNotHoldPayee       = errors.New("only the payee can capture or void this hold")
+	ErrHoldNotCapturable  = errors.New("this hold belongs to an escrow; use the escrow endpoints")
+	ErrCaptureExceedsHold = errors.New("the capture amount and its fee exceed the hold")
+)
@@ -0,0 +1,16 @@
+package customErrors
+
+import "errors"
+
+var (
+	ErrInvalidRequestExpiry = errors.New("expires_at must be in the future and within 90 days")
+	ErrMemoTooLong          = errors.New("memo can't be longer than 140 characters")
+	ErrCannotRequestSelf    = errors.New("you can't request money from yourself")
+	ErrRequestNotOpen       = errors.New("this payment request is no longer open")
+	ErrRequestExpired       = errors.New("this payment request has expired")
+	ErrRequestAlreadyPaid   = errors.New("this payment request has already been paid")
+	ErrNotRequestPayer      = errors.New("this payment request is addressed to someone else")
+	ErrNotRequester         = errors.New("only the requester can do this")
+	ErrRequestModeMismatch  = errors.New("this payment request is for the other mode")
+	ErrInvalidPayerEmail    = errors.New("a valid email is required to pay by card")
+)
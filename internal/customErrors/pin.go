@@ -0,0 +1,12 @@
+package customErrors
+
+import "errors"
+
+var (
+	ErrWeakPIN       = errors.New("PIN must be 4 to 6 digits and not a repeated or sequential pattern")
+	ErrPINAlreadySet = errors.New("a transaction PIN is already set, change or reset it instead")
+	ErrPINNotSet     = errors.New("set a transaction PIN before making transfers")
+	ErrPINRequired   = errors.New("transaction PIN is required")
+	ErrInvalidPIN    = errors.New("incorrect transaction PIN")
+	ErrPINLocked     = errors.New("transaction PIN is locked after too many wrong attempts, try again later or reset it")
+)
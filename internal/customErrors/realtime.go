@@ -0,0 +1,7 @@
+package customErrors
+
+import "errors"
+
+var (
+	ErrTooManyStreams = errors.New("too many open event streams")
+)
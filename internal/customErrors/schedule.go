@@ -0,0 +1,14 @@
+package customErrors
+
+import "errors"
+
+var (
+	ErrInvalidRecurrence   = errors.New("recurrence must be once, daily, weekly, monthly or a cron expression like \"0 9 1 * *\" with a single minute")
+	ErrInvalidScheduleTime = errors.New("start_at must be in the future for one-off transfers, and end_at after start_at")
+	ErrInvalidOnFailure    = errors.New("on_insufficient_funds must be retry or skip")
+	ErrInvalidMaxRuns      = errors.New("max_runs can't be negative")
+	ErrScheduleNotActive   = errors.New("only active schedules can be paused")
+	ErrScheduleNotPaused   = errors.New("only paused schedules can be resumed")
+	ErrScheduleEnded       = errors.New("this schedule has already ended")
+	ErrTooManySchedules    = errors.New("you have too many active schedules")
+)
@@ -4,4 +4,23 @@ import "errors"
 
 var (
 	ErrInsufficientFunds = errors.New("balance less than 0")
-)
\ No newline at end of file
+	ErrDebitsBlocked = errors.New("this account can't send money right now, contact support")
+	ErrCreditsBlocked = errors.New("this account can't receive money right now, contact support")
+	ErrRecipientCannotReceive = errors.New("the recipient can't receive money right now")
+)
+var (
+	ErrRecipientNotFound = errors.New("no account matches this recipient")
+	ErrCannotPaySelf = errors.New("cannot transfer to yourself")
+	ErrInvalidHandle = errors.New("handles are 3-20 lowercase letters, digits or underscores, starting with a letter")
+	ErrHandleTaken = errors.New("this handle is taken")
+)
+var (
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrDuplicateReference = errors.New("a transaction with this reference already exists")
+)
+var (
+	ErrNarrationTooLong = errors.New("narration can be at most 140 characters")
+	ErrInvalidMetadata = errors.New("metadata can have up to 20 keys of at most 40 characters, with values of at most 500 characters")
+	ErrInvalidClientReference = errors.New("client_reference is 1-64 letters, digits, '-', '_', '.' or ':'")
+	ErrDuplicateClientReference = errors.New("a transaction with this client_reference already exists")
+)
@@ -0,0 +1,11 @@
+package customErrors
+
+import "errors"
+
+var (
+	ErrInvalidWebhookURL        = errors.New("url must be an https URL on the public internet")
+	ErrInvalidWebhookEvents     = errors.New("events must list known event types, families such as escrow.*, or *")
+	ErrTooManyWebhookEndpoints  = errors.New("too many webhook endpoints")
+	ErrWebhookEndpointInactive  = errors.New("this webhook endpoint is disabled")
+	ErrDeliveryAlreadyScheduled = errors.New("this delivery is already waiting to be sent")
+)
@@ -0,0 +1,91 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AdminUserFilter struct {
+	Query  string // matches email or name
+	Role   string
+	Limit  int
+	Offset int
+}
+
+type AdminTransactionFilter struct {
+	UserID    *uuid.UUID // sender or receiver
+	Reference string
+	Type      string
+	Status    string
+	Mode      string
+	Limit     int
+	Offset    int
+}
+
+type AdminWebhookFilter struct {
+	Status    string
+	Event     string
+	Reference string
+	Limit     int
+	Offset    int
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// UpdateAccountStatusRequest freezes, unfreezes or closes a user or wallet.
+// Status and ReasonCode are validated against models/freeze.go.
+type UpdateAccountStatusRequest struct {
+	Status     string `json:"status"`
+	ReasonCode string `json:"reason_code"`
+	Note       string `json:"note"`
+}
+
+type AdminAuditFilter struct {
+	ActorID    *uuid.UUID
+	Action     string
+	TargetType string
+	TargetID   string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+// AuditVerifyResponse is the result of re-checking the audit log's hash chain
+type AuditVerifyResponse struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`
+	HeadSeq  int64  `json:"head_seq"`
+	HeadHash string `json:"head_hash"`
+	BrokenAt *int64 `json:"broken_at,omitempty"` // seq of the first entry that doesn't match
+	Problem  string `json:"problem,omitempty"`
+}
+
+// FeeRuleRequest creates or replaces a fee rule. Currency defaults to NGN
+// and an empty tier applies to every tier.
+type FeeRuleRequest struct {
+	TransactionType string    `json:"transaction_type"`
+	Currency        string    `json:"currency"`
+	Tier            string    `json:"tier"`
+	Flat            float64   `json:"flat"`
+	Percent         float64   `json:"percent"`
+	Bands           []FeeBand `json:"bands"`
+	MinFee          float64   `json:"min_fee"`
+	MaxFee          float64   `json:"max_fee"` // 0 for no cap
+	Active          *bool     `json:"active"`  // defaults to true
+}
+
+// FeeBand prices amounts up to UpTo; leave UpTo out on the last band
+type FeeBand struct {
+	UpTo    *float64 `json:"up_to"`
+	Flat    float64  `json:"flat"`
+	Percent float64  `json:"percent"`
+}
+
+type UpdateTierRequest struct {
+	Tier string `json:"tier"`
+}
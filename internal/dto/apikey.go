@@ -1,24 +1,70 @@
 package dto
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type CreateAPIKeyRequest struct {
-	Name        string   `json:"name"`
-	Permissions []string `json:"permissions"`
-	Expiry      string   `json:"expiry"`
+	Name              string   `json:"name"`
+	Permissions       []string `json:"permissions"`
+	Expiry            string   `json:"expiry"`
+	AllowedIPs        []string `json:"allowed_ips"`
+	Mode              string   `json:"mode"`               // "live" (default) or "test"
+	SignatureRequired bool     `json:"signature_required"` // reject unsigned x-api-key use
 }
 
 type CreateAPIKeyResponse struct {
-	APIKey    string    `json:"api_key"`
-	ExpiresAt time.Time `json:"expires_at"`
+	ID         string     `json:"id"`
+	APIKey     string     `json:"api_key"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	Mode       string     `json:"mode"`
 }
 
 type RolloverAPIKeyRequest struct {
-	ExpiredKeyID string `json:"expired_key_id"`
+	KeyID        string `json:"key_id"`
+	ExpiredKeyID string `json:"expired_key_id"` // Deprecated: use key_id
 	Expiry       string `json:"expiry"`
+	GracePeriod  string `json:"grace_period"` // e.g. "2h"; defaults to the configured grace period
 }
 
 type RolloverAPIKeyResponse struct {
-	APIKey    string    `json:"api_key"`
-	ExpiresAt time.Time `json:"expires_at"`
+	ID              string     `json:"id"`
+	APIKey          string     `json:"api_key"`
+	ExpiresAt       *time.Time `json:"expires_at"`
+	OldKeyExpiresAt *time.Time `json:"old_key_expires_at"`
+}
+
+type UpdateAllowedIPsRequest struct {
+	AllowedIPs []string `json:"allowed_ips"`
+}
+
+type APIKeyResponse struct {
+	ID                string     `json:"id"`
+	Name              string     `json:"name"`
+	Permissions       []string   `json:"permissions"`
+	AllowedIPs        []string   `json:"allowed_ips"`
+	Mode              string     `json:"mode"`
+	ExpiresAt         *time.Time `json:"expires_at"`
+	IsRevoked         bool       `json:"is_revoked"`
+	SignatureRequired bool       `json:"signature_required"`
+	ReplacedByID      *uuid.UUID `json:"replaced_by_id"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+type UpdateSignatureRequiredRequest struct {
+	SignatureRequired bool `json:"signature_required"`
+}
+
+// SignedRequest holds what the signature middleware extracted from a signed request
+type SignedRequest struct {
+	KeyID     string
+	Timestamp string
+	Nonce     string
+	Signature string
+	Method    string
+	Path      string
+	BodyHash  string
 }
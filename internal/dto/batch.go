@@ -0,0 +1,28 @@
+package dto
+
+// SubmitBatchRequest pays many recipients from the caller's wallet
+type SubmitBatchRequest struct {
+	Policy string              `json:"policy"` // "best_effort" (default) or "all_or_nothing"
+	Items  []BatchTransferItem `json:"items"`
+	PIN    string              `json:"pin,omitempty"` // required for JWT requests
+}
+
+type BatchTransferItem struct {
+	Recipient string  `json:"recipient"` // account number, @handle or verified email
+	Amount    float64 `json:"amount"`
+	Narration string  `json:"narration,omitempty"`
+}
+
+// BatchTransfer is one transfer made by WalletService.TransferAll
+type BatchTransfer struct {
+	Recipient string
+	Amount    float64
+	Reference string
+	Narration string
+}
+
+// BatchItemError explains why a submitted item was rejected
+type BatchItemError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
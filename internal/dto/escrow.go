@@ -0,0 +1,34 @@
+package dto
+
+import "time"
+
+// CreateEscrowRequest locks a payment for the payee until it is released
+type CreateEscrowRequest struct {
+	Payee       string     `json:"payee"` // account number, @handle or verified email
+	Amount      float64    `json:"amount"`
+	Description string     `json:"description"`
+	ExpiresAt   *time.Time `json:"expires_at"`    // defaults to 14 days from now
+	OnExpiry    string     `json:"on_expiry"`     // "release" (default) or "refund"
+	PIN         string     `json:"pin,omitempty"` // required for JWT requests
+}
+
+type ReleaseEscrowRequest struct {
+	PIN string `json:"pin,omitempty"` // required for JWT requests
+}
+
+type DisputeEscrowRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ResolveEscrowRequest settles a disputed escrow
+type ResolveEscrowRequest struct {
+	Outcome string `json:"outcome"` // "release" or "refund"
+	Note    string `json:"note"`
+}
+
+type EscrowFilter struct {
+	Role   string // "payer" or "payee"
+	Status string
+	Limit  int
+	Offset int
+}
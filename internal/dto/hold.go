@@ -0,0 +1,24 @@
+package dto
+
+import "time"
+
+// PlaceHoldRequest authorizes a payee to take up to Amount from the
+// caller's wallet
+type PlaceHoldRequest struct {
+	Payee       string     `json:"payee"` // account number, @handle or verified email
+	Amount      float64    `json:"amount"`
+	Description string     `json:"description"`
+	ExpiresAt   *time.Time `json:"expires_at"`    // defaults to 7 days from now
+	PIN         string     `json:"pin,omitempty"` // required for JWT requests
+}
+
+type CaptureHoldRequest struct {
+	Amount *float64 `json:"amount"` // defaults to the full hold
+}
+
+type HoldFilter struct {
+	Role   string // "payer" or "payee"
+	Status string
+	Limit  int
+	Offset int
+}
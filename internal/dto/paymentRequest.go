@@ -0,0 +1,37 @@
+package dto
+
+import "time"
+
+// CreatePaymentRequest asks a payer for money, or creates a public payment
+// link when Payer is empty
+type CreatePaymentRequest struct {
+	Payer     string     `json:"payer"` // account number, @handle or verified email
+	Amount    float64    `json:"amount"`
+	Memo      string     `json:"memo"`
+	ExpiresAt *time.Time `json:"expires_at"` // defaults to 7 days from now
+}
+
+type PayRequestRequest struct {
+	PIN string `json:"pin,omitempty"` // required for JWT requests
+}
+
+type PayByCardRequest struct {
+	Email string `json:"email"` // receipt address for the card payment
+}
+
+type PaymentRequestFilter struct {
+	Direction string // "sent" or "received"
+	Status    string
+	Limit     int
+	Offset    int
+}
+
+// PublicPaymentRequest is what anyone holding a payment link may see
+type PublicPaymentRequest struct {
+	Code          string    `json:"code"`
+	RequesterName string    `json:"requester_name"` // masked, e.g. "Ad**** O."
+	Amount        float64   `json:"amount"`
+	Memo          string    `json:"memo,omitempty"`
+	Status        string    `json:"status"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
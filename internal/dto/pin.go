@@ -0,0 +1,22 @@
+package dto
+
+import "time"
+
+type SetPINRequest struct {
+	PIN string `json:"pin"`
+}
+
+type ChangePINRequest struct {
+	CurrentPIN string `json:"current_pin"`
+	NewPIN     string `json:"new_pin"`
+}
+
+type ResetPINRequest struct {
+	Token string `json:"token"`
+	PIN   string `json:"pin"`
+}
+
+type PINStatusResponse struct {
+	Set         bool       `json:"set"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
@@ -0,0 +1,18 @@
+package dto
+
+import "encoding/json"
+
+// WalletUpdate is pushed to a client's open event streams
+type WalletUpdate struct {
+	Event string          `json:"event"` // balance, deposit or transfer
+	Data  json.RawMessage `json:"data"`
+}
+
+// IncomingTransfer tells a receiver about money that has just arrived
+type IncomingTransfer struct {
+	Reference string  `json:"reference"`
+	Amount    float64 `json:"amount"`
+	Narration string  `json:"narration,omitempty"`
+	SenderID  string  `json:"sender_id,omitempty"`
+	CreatedAt string  `json:"created_at"`
+}
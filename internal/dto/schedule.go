@@ -0,0 +1,15 @@
+package dto
+
+import "time"
+
+// CreateScheduleRequest sets up a one-off or recurring transfer
+type CreateScheduleRequest struct {
+	Recipient           string     `json:"recipient"` // account number, @handle or verified email
+	Amount              float64    `json:"amount"`
+	Recurrence          string     `json:"recurrence"` // once, daily, weekly, monthly or a cron expression
+	StartAt             *time.Time `json:"start_at"`   // required for one-off transfers, defaults to now otherwise
+	EndAt               *time.Time `json:"end_at"`
+	MaxRuns             int        `json:"max_runs"`              // stop after this many payments, 0 for no limit
+	OnInsufficientFunds string     `json:"on_insufficient_funds"` // retry (default) or skip
+	PIN                 string     `json:"pin,omitempty"`         // required for JWT requests
+}
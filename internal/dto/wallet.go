@@ -10,23 +10,47 @@ type DepositWalletResponse struct {
 }
 
 type TransferRequest struct {
-	WalletNumber string  `json:"wallet_number"`
-	Amount       float64 `json:"amount"`
+	Recipient       string            `json:"recipient"`     // account number, @handle or verified email
+	WalletNumber    string            `json:"wallet_number"` // Deprecated: read as recipient
+	Amount          float64           `json:"amount"`
+	Narration       string            `json:"narration,omitempty"`        // shown to both parties
+	Metadata        map[string]string `json:"metadata,omitempty"`         // for the sender's own use
+	ClientReference string            `json:"client_reference,omitempty"` // the sender's ID for the transfer, unique per sender
+	PIN             string            `json:"pin,omitempty"`              // required for transfers authenticated with a JWT
+}
+
+// TransferDetails are the optional parts of a transfer kept on its
+// transaction
+type TransferDetails struct {
+	Narration       string
+	Metadata        map[string]string
+	ClientReference string
 }
 
 type TransferResponse struct {
-	Status  string `json:"status"`
-	Message string `json:"message"`
+	Status          string `json:"status"`
+	Message         string `json:"message"`
+	Reference       string `json:"reference,omitempty"`
+	ClientReference string `json:"client_reference,omitempty"`
 }
 
 type BalanceResponse struct {
-	Balance float64 `json:"balance"`
+	Balance          float64 `json:"balance"`           // same as available_balance, kept for older clients
+	AvailableBalance float64 `json:"available_balance"` // what can be spent
+	LedgerBalance    float64 `json:"ledger_balance"`    // available plus held
+	HeldBalance      float64 `json:"held_balance"`      // set aside by holds and escrows
+	AccountNumber    string  `json:"account_number"`
 }
 
 type TransactionResponse struct {
-	Type   string  `json:"type"`
-	Amount float64 `json:"amount"`
-	Status string  `json:"status"`
+	Type            string            `json:"type"`
+	Amount          float64           `json:"amount"`
+	Fee             float64           `json:"fee,omitempty"`
+	Status          string            `json:"status"`
+	Reference       string            `json:"reference"`
+	Narration       string            `json:"narration,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`         // only shown to the sender
+	ClientReference string            `json:"client_reference,omitempty"` // only shown to the sender
 }
 
 type DepositStatusResponse struct {
@@ -34,3 +58,26 @@ type DepositStatusResponse struct {
 	Status    string  `json:"status"`
 	Amount    float64 `json:"amount"`
 }
+
+// ResolveRecipientResponse identifies a recipient just well enough for the
+// sender to confirm it before paying
+type ResolveRecipientResponse struct {
+	Name          string `json:"name"` // masked, e.g. "Ad**** O."
+	AccountNumber string `json:"account_number"`
+	Handle        string `json:"handle,omitempty"`
+}
+
+type SetHandleRequest struct {
+	Handle string `json:"handle"`
+}
+
+// FeeQuote previews what a transaction will cost. Transfer fees are paid on
+// top of the amount; deposit fees come out of it.
+type FeeQuote struct {
+	Type      string  `json:"type"`
+	Currency  string  `json:"currency"`
+	Amount    float64 `json:"amount"`
+	Fee       float64 `json:"fee"`
+	Total     float64 `json:"total"`      // what leaves the payer
+	NetAmount float64 `json:"net_amount"` // what arrives
+}
@@ -0,0 +1,22 @@
+package dto
+
+// CreateWebhookEndpointRequest registers a URL to be sent events
+type CreateWebhookEndpointRequest struct {
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events"` // event types, families such as "escrow.*", or "*"
+}
+
+// UpdateWebhookEndpointRequest changes the fields that are set
+type UpdateWebhookEndpointRequest struct {
+	URL         *string  `json:"url"`
+	Description *string  `json:"description"`
+	Events      []string `json:"events"`
+	Active      *bool    `json:"active"`
+}
+
+type WebhookDeliveryFilter struct {
+	Status string
+	Limit  int
+	Offset int
+}
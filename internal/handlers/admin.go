@@ -0,0 +1,725 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+type AdminHandler struct {
+	adminService     services.AdminService
+	freezeService    services.FreezeService
+	feeService       services.FeeService
+	twoFactorService services.TwoFactorService
+	auditService     services.AuditService
+}
+
+func NewAdminHandler(adminService services.AdminService, freezeService services.FreezeService, feeService services.FeeService, twoFactorService services.TwoFactorService, auditService services.AuditService) *AdminHandler {
+	return &AdminHandler{
+		adminService:     adminService,
+		freezeService:    freezeService,
+		feeService:       feeService,
+		twoFactorService: twoFactorService,
+		auditService:     auditService,
+	}
+}
+
+// SearchUsers godoc
+// @Summary Search users
+// @Description Find users by email or name. Needs the users:read permission.
+// @Tags admin
+// @Produce json
+// @Param q query string false "Email or name fragment"
+// @Param role query string false "Only users with this role"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Results to skip"
+// @Success 200 {object} map[string]interface{} "users and total"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/users [get]
+func (h *AdminHandler) SearchUsers(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	users, total, err := h.adminService.SearchUsers(dto.AdminUserFilter{
+		Query:  c.Query("q"),
+		Role:   c.Query("role"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		writeAdminError(c, err, "Failed to search users")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "total": total, "limit": limit, "offset": offset})
+}
+
+// GetUser godoc
+// @Summary Get a user
+// @Description A user with their wallets in every mode. Needs the users:read permission.
+// @Tags admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]interface{} "user and wallets"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/users/{id} [get]
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	userID, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+
+	user, wallets, err := h.adminService.GetUser(userID)
+	if err != nil {
+		writeAdminError(c, err, "Failed to get user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":        user,
+		"wallets":     wallets,
+		"permissions": models.RolePermissions(user.Role),
+	})
+}
+
+// SetRole godoc
+// @Summary Change a user's role
+// @Description Set the role to user, support, finance or admin. Needs the roles:manage permission and, with two-factor enabled, a step-up token.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param request body dto.UpdateRoleRequest true "New role"
+// @Param X-Step-Up-Token header string false "Step-up token"
+// @Success 200 {object} map[string]string "message"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/users/{id}/role [put]
+func (h *AdminHandler) SetRole(c *gin.Context) {
+	userID, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if !stepUpVerified(c, h.twoFactorService) {
+		return
+	}
+
+	if err := h.adminService.SetRole(auditActor(c), userID, req.Role); err != nil {
+		writeAdminError(c, err, "Failed to change role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated"})
+}
+
+// SetUserStatus godoc
+// @Summary Freeze, unfreeze or close a user
+// @Description Set the account status to active, debit_frozen (money can arrive but not leave), frozen (no money movement, no sign-in) or closed (permanent). Applies to all of the user's wallets. Needs the users:freeze permission; closing also needs accounts:close.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param request body dto.UpdateAccountStatusRequest true "Status, reason code and note"
+// @Success 200 {object} map[string]string "message"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/users/{id}/status [put]
+func (h *AdminHandler) SetUserStatus(c *gin.Context) {
+	userID, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.UpdateAccountStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	actorID := c.MustGet("user_id").(uuid.UUID)
+	if !h.canSetStatus(c, actorID, req.Status) {
+		return
+	}
+
+	if err := h.freezeService.SetUserStatus(auditActor(c), userID, req.Status, req.ReasonCode, req.Note); err != nil {
+		writeAdminError(c, err, "Failed to update user status")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User status updated"})
+}
+
+// SetWalletStatus godoc
+// @Summary Freeze, unfreeze or close a wallet
+// @Description Same statuses as for users, for a single wallet. The stricter of the user's and the wallet's status applies. Needs the users:freeze permission; closing also needs accounts:close.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Wallet ID"
+// @Param request body dto.UpdateAccountStatusRequest true "Status, reason code and note"
+// @Success 200 {object} map[string]string "message"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/wallets/{id}/status [put]
+func (h *AdminHandler) SetWalletStatus(c *gin.Context) {
+	walletID, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.UpdateAccountStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	actorID := c.MustGet("user_id").(uuid.UUID)
+	if !h.canSetStatus(c, actorID, req.Status) {
+		return
+	}
+
+	if err := h.freezeService.SetWalletStatus(auditActor(c), walletID, req.Status, req.ReasonCode, req.Note); err != nil {
+		writeAdminError(c, err, "Failed to update wallet status")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Wallet status updated"})
+}
+
+// GetFreezeEvents godoc
+// @Summary A user's freeze history
+// @Description Every status change of the user and their wallets, newest first, with who made it and why. Needs the users:read permission.
+// @Tags admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]interface{} "events"
+// @Failure 403 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/users/{id}/freeze-events [get]
+func (h *AdminHandler) GetFreezeEvents(c *gin.Context) {
+	userID, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+
+	events, err := h.freezeService.GetFreezeEvents(userID)
+	if err != nil {
+		writeAdminError(c, err, "Failed to get freeze history")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// canSetStatus checks the extra permission closing an account needs, writing
+// a 403 response if it is missing
+func (h *AdminHandler) canSetStatus(c *gin.Context, actorID uuid.UUID, status string) bool {
+	if status != models.AccountClosed {
+		return true
+	}
+	allowed, err := h.adminService.HasPermission(actorID, models.PermissionAccountsClose)
+	if err != nil {
+		writeAdminError(c, err, "Failed to check permissions")
+		return false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to do this"})
+		return false
+	}
+	return true
+}
+
+// GetWallet godoc
+// @Summary Get a wallet
+// @Description Needs the wallets:read permission.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Wallet ID"
+// @Success 200 {object} map[string]interface{} "Wallet"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/wallets/{id} [get]
+func (h *AdminHandler) GetWallet(c *gin.Context) {
+	walletID, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+
+	wallet, err := h.adminService.GetWallet(walletID)
+	if err != nil {
+		writeAdminError(c, err, "Failed to get wallet")
+		return
+	}
+
+	c.JSON(http.StatusOK, wallet)
+}
+
+// ListTransactions godoc
+// @Summary Search transactions
+// @Description Transactions across all users, newest first. Needs the transactions:read permission.
+// @Tags admin
+// @Produce json
+// @Param user_id query string false "Sender or receiver"
+// @Param reference query string false "Transaction reference"
+// @Param type query string false "deposit or transfer"
+// @Param status query string false "pending, held, success or failed"
+// @Param mode query string false "live or test"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Results to skip"
+// @Success 200 {object} map[string]interface{} "transactions and total"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/transactions [get]
+func (h *AdminHandler) ListTransactions(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	filter := dto.AdminTransactionFilter{
+		Reference: c.Query("reference"),
+		Type:      c.Query("type"),
+		Status:    c.Query("status"),
+		Mode:      c.Query("mode"),
+		Limit:     limit,
+		Offset:    offset,
+	}
+	if value := c.Query("user_id"); value != "" {
+		userID, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return
+		}
+		filter.UserID = &userID
+	}
+
+	transactions, total, err := h.adminService.ListTransactions(filter)
+	if err != nil {
+		writeAdminError(c, err, "Failed to list transactions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"transactions": transactions, "total": total, "limit": limit, "offset": offset})
+}
+
+// ListWebhookEvents godoc
+// @Summary List received webhooks
+// @Description Payment provider webhooks that passed signature checks, newest first, without payloads. Needs the webhooks:read permission.
+// @Tags admin
+// @Produce json
+// @Param status query string false "processed, ignored or failed"
+// @Param event query string false "Provider event, e.g. charge.success"
+// @Param reference query string false "Transaction reference"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Results to skip"
+// @Success 200 {object} map[string]interface{} "webhooks and total"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/webhooks [get]
+func (h *AdminHandler) ListWebhookEvents(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	events, total, err := h.adminService.ListWebhookEvents(dto.AdminWebhookFilter{
+		Status:    c.Query("status"),
+		Event:     c.Query("event"),
+		Reference: c.Query("reference"),
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		writeAdminError(c, err, "Failed to list webhooks")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": events, "total": total, "limit": limit, "offset": offset})
+}
+
+// GetWebhookEvent godoc
+// @Summary Get a received webhook
+// @Description A webhook with its payload. Needs the webhooks:read permission.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Webhook event ID"
+// @Success 200 {object} map[string]interface{} "Webhook event"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/webhooks/{id} [get]
+func (h *AdminHandler) GetWebhookEvent(c *gin.Context) {
+	eventID, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+
+	event, err := h.adminService.GetWebhookEvent(eventID)
+	if err != nil {
+		writeAdminError(c, err, "Failed to get webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// ListAuditEvents godoc
+// @Summary Search the audit log
+// @Description Security and financial events, newest first. Needs the audit:read permission.
+// @Tags admin
+// @Produce json
+// @Param actor_id query string false "User who acted"
+// @Param action query string false "Action, e.g. wallet.transfer"
+// @Param target_type query string false "user, wallet, transaction, api_key or webhook_event"
+// @Param target_id query string false "ID or reference of the target"
+// @Param request_id query string false "X-Request-ID of the request"
+// @Param from query string false "Events at or after this time (RFC 3339)"
+// @Param to query string false "Events before this time (RFC 3339)"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Results to skip"
+// @Success 200 {object} map[string]interface{} "events and total"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/audit-events [get]
+func (h *AdminHandler) ListAuditEvents(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	filter := dto.AdminAuditFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		RequestID:  c.Query("request_id"),
+		Limit:      limit,
+		Offset:     offset,
+	}
+	if value := c.Query("actor_id"); value != "" {
+		actorID, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actor_id"})
+			return
+		}
+		filter.ActorID = &actorID
+	}
+	if filter.From, ok = timeParam(c, "from"); !ok {
+		return
+	}
+	if filter.To, ok = timeParam(c, "to"); !ok {
+		return
+	}
+
+	events, total, err := h.auditService.ListEvents(filter)
+	if err != nil {
+		writeAdminError(c, err, "Failed to list audit events")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events, "total": total, "limit": limit, "offset": offset})
+}
+
+// VerifyAuditLog godoc
+// @Summary Verify the audit log
+// @Description Recompute the audit log's hash chain and report the first entry that was changed, removed or reordered. Keep head_hash somewhere safe to detect the log being rewritten from the start. Needs the audit:read permission.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} dto.AuditVerifyResponse "Verification result"
+// @Failure 403 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/audit-events/verify [get]
+func (h *AdminHandler) VerifyAuditLog(c *gin.Context) {
+	result, err := h.auditService.Verify()
+	if err != nil {
+		writeAdminError(c, err, "Failed to verify audit log")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListFeeRules godoc
+// @Summary List fee rules
+// @Description List every fee rule, active or not. Needs the fees:manage permission.
+// @Tags admin
+// @Produce json
+// @Success 200 {array} models.FeeRule "Fee rules"
+// @Failure 403 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/fees [get]
+func (h *AdminHandler) ListFeeRules(c *gin.Context) {
+	rules, err := h.feeService.ListRules()
+	if err != nil {
+		writeAdminError(c, err, "Failed to list fee rules")
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateFeeRule godoc
+// @Summary Create a fee rule
+// @Description Price a transaction type for a currency and, optionally, one user tier. The fee is flat plus percent of the amount, taken from the first band the amount fits when bands are set, then kept between min_fee and max_fee. Only one active rule may cover each type, currency and tier. Needs the fees:manage permission and, with two-factor enabled, a step-up token.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body dto.FeeRuleRequest true "Fee rule"
+// @Param X-Step-Up-Token header string false "Step-up token"
+// @Success 201 {object} models.FeeRule "Fee rule"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/fees [post]
+func (h *AdminHandler) CreateFeeRule(c *gin.Context) {
+	var req dto.FeeRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if !stepUpVerified(c, h.twoFactorService) {
+		return
+	}
+
+	rule, err := h.feeService.CreateRule(auditActor(c), req)
+	if err != nil {
+		writeAdminError(c, err, "Failed to create fee rule")
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateFeeRule godoc
+// @Summary Replace a fee rule
+// @Description Replace every field of a fee rule. Needs the fees:manage permission and, with two-factor enabled, a step-up token.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Fee rule ID"
+// @Param request body dto.FeeRuleRequest true "Fee rule"
+// @Param X-Step-Up-Token header string false "Step-up token"
+// @Success 200 {object} models.FeeRule "Fee rule"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/fees/{id} [put]
+func (h *AdminHandler) UpdateFeeRule(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.FeeRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if !stepUpVerified(c, h.twoFactorService) {
+		return
+	}
+
+	rule, err := h.feeService.UpdateRule(auditActor(c), id, req)
+	if err != nil {
+		writeAdminError(c, err, "Failed to update fee rule")
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteFeeRule godoc
+// @Summary Delete a fee rule
+// @Description Delete a fee rule. Fees already charged are kept. Needs the fees:manage permission and, with two-factor enabled, a step-up token.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Fee rule ID"
+// @Param X-Step-Up-Token header string false "Step-up token"
+// @Success 200 {object} map[string]string "message"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/fees/{id} [delete]
+func (h *AdminHandler) DeleteFeeRule(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+
+	if !stepUpVerified(c, h.twoFactorService) {
+		return
+	}
+
+	if err := h.feeService.DeleteRule(auditActor(c), id); err != nil {
+		writeAdminError(c, err, "Failed to delete fee rule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Fee rule deleted"})
+}
+
+// GetRevenue godoc
+// @Summary Show platform revenue
+// @Description Show the revenue account's wallets, which collect every fee charged. Needs the transactions:read permission.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{} "user_id and wallets"
+// @Failure 403 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/fees/revenue [get]
+func (h *AdminHandler) GetRevenue(c *gin.Context) {
+	wallets, err := h.feeService.GetRevenueWallets()
+	if err != nil {
+		writeAdminError(c, err, "Failed to get revenue")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id": h.feeService.RevenueUserID(),
+		"wallets": wallets,
+	})
+}
+
+// SetTier godoc
+// @Summary Change a user's fee tier
+// @Description Set the tier fee rules are matched against to standard, premium or business. Needs the fees:manage permission.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param request body dto.UpdateTierRequest true "New tier"
+// @Success 200 {object} map[string]string "message"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/users/{id}/tier [put]
+func (h *AdminHandler) SetTier(c *gin.Context) {
+	userID, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.UpdateTierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := h.feeService.SetTier(auditActor(c), userID, req.Tier); err != nil {
+		writeAdminError(c, err, "Failed to change tier")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tier updated"})
+}
+
+// timeParam parses an optional RFC 3339 query parameter, writing a 400
+// response if it is invalid
+func timeParam(c *gin.Context, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be an RFC 3339 time"})
+		return nil, false
+	}
+	return &parsed, true
+}
+
+// pageParams reads limit and offset, writing a 400 response if they are invalid
+func pageParams(c *gin.Context) (int, int, bool) {
+	limit, offset := defaultPageSize, 0
+	var err error
+	if value := c.Query("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+			return 0, 0, false
+		}
+	}
+	if value := c.Query("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset must not be negative"})
+			return 0, 0, false
+		}
+	}
+	return limit, offset, true
+}
+
+// pathUUID parses a UUID path parameter, writing a 400 response if it is invalid
+func pathUUID(c *gin.Context, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// writeAdminError maps admin errors to HTTP responses
+func writeAdminError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	case errors.Is(err, customErrors.ErrInvalidRole),
+		errors.Is(err, customErrors.ErrInvalidAccountStatus),
+		errors.Is(err, customErrors.ErrInvalidFreezeReason),
+		errors.Is(err, customErrors.ErrInvalidFeeType),
+		errors.Is(err, customErrors.ErrInvalidTier),
+		errors.Is(err, customErrors.ErrUnsupportedCurrency),
+		errors.Is(err, customErrors.ErrInvalidFeeValues),
+		errors.Is(err, customErrors.ErrInvalidFeeCap),
+		errors.Is(err, customErrors.ErrInvalidFeeBands):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrAccountClosed),
+		errors.Is(err, customErrors.ErrStatusUnchanged),
+		errors.Is(err, customErrors.ErrFeeRuleConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrCannotChangeOwnRole),
+		errors.Is(err, customErrors.ErrCannotFreezeSelf):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -4,10 +4,11 @@ import (
 	"errors"
 	"log"
 	"net/http"
-	"slices"
+	"time"
 
 	"whotterre/argent/internal/customErrors"
 	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
 	"whotterre/argent/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -15,12 +16,16 @@ import (
 )
 
 type APIKeyHandler struct {
-	apiKeyService services.APIKeyService
+	apiKeyService    services.APIKeyService
+	twoFactorService services.TwoFactorService
+	auditService     services.AuditService
 }
 
-func NewAPIKeyHandler(apiKeyService services.APIKeyService) *APIKeyHandler {
+func NewAPIKeyHandler(apiKeyService services.APIKeyService, twoFactorService services.TwoFactorService, auditService services.AuditService) *APIKeyHandler {
 	return &APIKeyHandler{
-		apiKeyService: apiKeyService,
+		apiKeyService:    apiKeyService,
+		twoFactorService: twoFactorService,
+		auditService:     auditService,
 	}
 }
 
@@ -31,8 +36,10 @@ func NewAPIKeyHandler(apiKeyService services.APIKeyService) *APIKeyHandler {
 // @Accept json
 // @Produce json
 // @Param request body dto.CreateAPIKeyRequest true "API key creation request"
+// @Param X-Step-Up-Token header string false "Step-up token, required for user sessions with two-factor authentication enabled"
 // @Success 200 {object} dto.CreateAPIKeyResponse "API key creation response"
 // @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
 // @Failure 412 {object} map[string]string "error"
 // @Failure 500 {object} map[string]string "error"
 // @Security BearerAuth
@@ -44,53 +51,52 @@ func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Invalid input",
 		})
+		return
 	}
 
-	validExpiryInputs := []string{"1H", "1D", "1M", "1Y"}
-	// Check if the expiry input isn't there
-	if !slices.Contains(validExpiryInputs, req.Expiry) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid expiry format. Supported formats are '1H', '1D', '1M', '1Y'",
-		})
+	if !stepUpVerified(c, h.twoFactorService) {
+		return
 	}
 
 	userID := c.MustGet("user_id").(uuid.UUID)
 
 	response, err := h.apiKeyService.CreateAPIKey(req, userID)
 	if err != nil {
-		if errors.Is(err, customErrors.ErrorActiveAPIKeysExceeded) {
-			c.JSON(http.StatusPreconditionFailed, gin.H{
-				"error": "You can only have five active API keys",
-			})
-			return
-		}
-		if errors.Is(err, customErrors.ErrInvalidPermission) {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Invalid permission provided",
-			})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create API key",
-		})
+		writeAPIKeyError(c, err, "Failed to create API key")
 		return
 	}
+	h.auditService.Record(auditActor(c), models.AuditAPIKeyCreated, "api_key", response.ID, nil, gin.H{
+		"name":               req.Name,
+		"permissions":        req.Permissions,
+		"expires_at":         response.ExpiresAt,
+		"allowed_ips":        response.AllowedIPs,
+		"mode":               response.Mode,
+		"signature_required": req.SignatureRequired,
+	})
 
 	c.JSON(http.StatusOK, gin.H{
-		"api_key":    response.APIKey,
-		"expires_at": response.ExpiresAt,
+		"id":                 response.ID,
+		"api_key":            response.APIKey,
+		"expires_at":         response.ExpiresAt,
+		"allowed_ips":        response.AllowedIPs,
+		"mode":               response.Mode,
+		"signature_required": req.SignatureRequired,
 	})
 }
 
 // RolloverAPIKey godoc
 // @Summary Rollover an existing API key
-// @Description Generate a new API key to replace an existing one
+// @Description Generate a new API key to replace an existing one. An active key keeps working until the grace period ends; an expired key is revoked immediately.
 // @Tags api-keys
 // @Accept json
 // @Produce json
 // @Param request body dto.RolloverAPIKeyRequest true "API key rollover request"
+// @Param X-Step-Up-Token header string false "Step-up token, required for user sessions with two-factor authentication enabled"
 // @Success 200 {object} dto.RolloverAPIKeyResponse "API key rollover response"
 // @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
 // @Failure 500 {object} map[string]string "error"
 // @Security BearerAuth
 // @Router /keys/rollover [post]
@@ -99,8 +105,13 @@ func (h *APIKeyHandler) RolloverAPIKey(c *gin.Context) {
 	if err := c.ShouldBindJSON(&req); err != nil {
 		log.Println("Failed to parse request body because", err.Error())
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Something went wrong while rolling over the API key on our end",
+			"error": "Invalid input",
 		})
+		return
+	}
+
+	if !stepUpVerified(c, h.twoFactorService) {
+		return
 	}
 
 	userID := c.MustGet("user_id").(uuid.UUID)
@@ -108,11 +119,213 @@ func (h *APIKeyHandler) RolloverAPIKey(c *gin.Context) {
 	response, err := h.apiKeyService.RolloverAPIKey(&req, userID)
 	if err != nil {
 		log.Println("Failed to roll over API key because", err.Error())
+		writeAPIKeyError(c, err, "Failed to roll over API key")
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"api_key":    response.APIKey,
-		"expires_at": response.ExpiresAt,
+	oldKeyID := req.KeyID
+	if oldKeyID == "" {
+		oldKeyID = req.ExpiredKeyID
+	}
+	actor := auditActor(c)
+	h.auditService.Record(actor, models.AuditAPIKeyRolledOver, "api_key", oldKeyID, nil, gin.H{
+		"new_key_id":         response.ID,
+		"expires_at":         response.ExpiresAt,
+		"old_key_expires_at": response.OldKeyExpiresAt,
 	})
+	// Expired keys are revoked straight away rather than given a grace period
+	if response.OldKeyExpiresAt != nil && !response.OldKeyExpiresAt.After(time.Now()) {
+		h.auditService.Record(actor, models.AuditAPIKeyRevoked, "api_key", oldKeyID, nil, gin.H{"replaced_by": response.ID})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ListAPIKeys godoc
+// @Summary List API keys
+// @Description List all API keys belonging to the user, including revoked and expired ones
+// @Tags api-keys
+// @Produce json
+// @Success 200 {array} dto.APIKeyResponse "API keys"
+// @Failure 500 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /keys [get]
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	apiKeys, err := h.apiKeyService.ListAPIKeys(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list API keys",
+		})
+		return
+	}
+
+	response := make([]dto.APIKeyResponse, 0, len(apiKeys))
+	for _, key := range apiKeys {
+		response = append(response, toAPIKeyResponse(&key))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateAllowedIPs godoc
+// @Summary Update an API key's IP allowlist
+// @Description Replace the IPs/CIDRs an API key may be used from. An empty list allows any IP.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param id path string true "API key ID"
+// @Param request body dto.UpdateAllowedIPsRequest true "Allowed IPs"
+// @Param X-Step-Up-Token header string false "Step-up token, required for user sessions with two-factor authentication enabled"
+// @Success 200 {object} dto.APIKeyResponse "Updated API key"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 500 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /keys/{id}/allowed-ips [put]
+func (h *APIKeyHandler) UpdateAllowedIPs(c *gin.Context) {
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid API key ID",
+		})
+		return
+	}
+
+	var req dto.UpdateAllowedIPsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid input",
+		})
+		return
+	}
+
+	// Widening or removing the allowlist weakens the key, so it is confirmed
+	// like creating one
+	if !stepUpVerified(c, h.twoFactorService) {
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	apiKey, previous, err := h.apiKeyService.UpdateAllowedIPs(keyID, userID, req.AllowedIPs)
+	if err != nil {
+		if errors.Is(err, customErrors.ErrNonExistentAPIKey) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "API key not found",
+			})
+			return
+		}
+		if errors.Is(err, customErrors.ErrInvalidIPAllowlist) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid IP address or CIDR in allowed_ips",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update API key",
+		})
+		return
+	}
+	h.auditService.Record(auditActor(c), models.AuditAPIKeyUpdated, "api_key", apiKey.ID.String(),
+		gin.H{"allowed_ips": previous},
+		gin.H{"allowed_ips": apiKey.AllowedIPs})
+
+	c.JSON(http.StatusOK, toAPIKeyResponse(apiKey))
+}
+
+// UpdateSignatureRequired godoc
+// @Summary Require signed requests for an API key
+// @Description Toggle whether the key may only be used for HMAC-signed requests
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param id path string true "API key ID"
+// @Param request body dto.UpdateSignatureRequiredRequest true "Signature requirement"
+// @Param X-Step-Up-Token header string false "Step-up token, required for user sessions with two-factor authentication enabled"
+// @Success 200 {object} dto.APIKeyResponse "Updated API key"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 500 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /keys/{id}/signature [put]
+func (h *APIKeyHandler) UpdateSignatureRequired(c *gin.Context) {
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid API key ID",
+		})
+		return
+	}
+
+	var req dto.UpdateSignatureRequiredRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid input",
+		})
+		return
+	}
+
+	// Turning signing off weakens the key, so it is confirmed like creating one
+	if !stepUpVerified(c, h.twoFactorService) {
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	apiKey, previous, err := h.apiKeyService.UpdateSignatureRequired(keyID, userID, req.SignatureRequired)
+	if err != nil {
+		writeAPIKeyError(c, err, "Failed to update API key")
+		return
+	}
+	h.auditService.Record(auditActor(c), models.AuditAPIKeyUpdated, "api_key", apiKey.ID.String(),
+		gin.H{"signature_required": previous},
+		gin.H{"signature_required": apiKey.SignatureRequired})
+
+	c.JSON(http.StatusOK, toAPIKeyResponse(apiKey))
+}
+
+func toAPIKeyResponse(key *models.APIKey) dto.APIKeyResponse {
+	return dto.APIKeyResponse{
+		ID:                key.ID.String(),
+		Name:              key.Name,
+		Permissions:       key.Permissions,
+		AllowedIPs:        key.AllowedIPs,
+		Mode:              key.Mode,
+		ExpiresAt:         key.ExpiresAt,
+		IsRevoked:         key.IsRevoked,
+		SignatureRequired: key.SignatureRequired,
+		ReplacedByID:      key.ReplacedByID,
+		CreatedAt:         key.CreatedAt,
+	}
+}
+
+// writeAPIKeyError maps API key service errors to HTTP responses
+func writeAPIKeyError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, customErrors.ErrorActiveAPIKeysExceeded):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "You can only have five active API keys"})
+	case errors.Is(err, customErrors.ErrInvalidPermission):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid permission provided"})
+	case errors.Is(err, customErrors.ErrInvalidIPAllowlist):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address or CIDR in allowed_ips"})
+	case errors.Is(err, customErrors.ErrInvalidKeyMode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode. Supported modes are 'live' and 'test'"})
+	case errors.Is(err, customErrors.ErrInvalidExpiry):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiry. Use e.g. '12H', '30D', '6M', '1Y', '36h', 'P90D', an ISO-8601 date or 'never'"})
+	case errors.Is(err, customErrors.ErrSigningUnavailable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request signing is unavailable for this key. Keys created before signing was enabled must be rolled over first"})
+	case errors.Is(err, customErrors.ErrNoExpiryNotAllowed),
+		errors.Is(err, customErrors.ErrExpiryExceedsMaxLifetime),
+		errors.Is(err, customErrors.ErrInvalidGracePeriod):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrNonExistentAPIKey):
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+	case errors.Is(err, customErrors.ErrAPIKeyAlreadyRolledOver):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
 }
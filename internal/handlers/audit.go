@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"whotterre/argent/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const maxAuditUserAgent = 512
+
+// auditActor describes the caller for the audit log: who they are, how they
+// authenticated and where the request came from
+func auditActor(c *gin.Context) services.AuditActor {
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > maxAuditUserAgent {
+		userAgent = userAgent[:maxAuditUserAgent]
+	}
+
+	actor := services.AuditActor{
+		AuthMethod: c.GetString("auth_method"),
+		IP:         c.ClientIP(),
+		UserAgent:  userAgent,
+		RequestID:  c.GetString("request_id"),
+	}
+	if userID, ok := c.Get("user_id"); ok {
+		actor.UserID = userID.(uuid.UUID)
+	}
+	if keyID, ok := c.Get("api_key_id"); ok {
+		id := keyID.(uuid.UUID)
+		actor.APIKeyID = &id
+	}
+	return actor
+}
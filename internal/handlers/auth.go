@@ -1,60 +1,105 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"whotterre/argent/internal/config"
+	"whotterre/argent/internal/customErrors"
 	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
 	"whotterre/argent/internal/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+const loginSessionCookie = "oauth_session"
+
 type AuthHandler struct {
-	authService services.AuthService
-	cfg         config.Config
+	authService  services.AuthService
+	auditService services.AuditService
+	cfg          config.Config
 }
 
-func NewAuthHandler(authService services.AuthService, cfg config.Config) *AuthHandler {
+func NewAuthHandler(authService services.AuthService, auditService services.AuditService, cfg config.Config) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
-		cfg:         cfg,
+		authService:  authService,
+		auditService: auditService,
+		cfg:          cfg,
 	}
 }
 
-// HandleGoogleLogin initiates Google OAuth login
-// @Summary Initiate Google OAuth login
-// @Description Redirects to Google for authentication
+// ListProviders godoc
+// @Summary List sign-in providers
+// @Description Names of the identity providers users can sign in with at /auth/{provider}
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string][]string "providers"
+// @Router /auth/providers [get]
+func (h *AuthHandler) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"providers": h.authService.Providers(),
+	})
+}
+
+// HandleLogin initiates sign-in with an identity provider
+// @Summary Initiate OAuth/OIDC login
+// @Description Redirects to the identity provider (e.g. google, microsoft, apple, github) for authentication using PKCE. With redirect_uri (which must be allowlisted), the callback redirects there with a one-time code instead of returning tokens as JSON.
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Success 302 {string} string "Redirect to Google"
-// @Router /auth/google [get]
-func (h *AuthHandler) HandleGoogleLogin(c *gin.Context) {
-	log.Println("Initiating Google login....")
-	// Generate CSRF token
-	stateToken := h.authService.GenerateStateToken()
-	// Store in cookie
-	c.SetCookie("oauth_state", stateToken, 300, "/", "", false, true)
-
-	authURL := h.authService.GetAuthCodeURL(stateToken)
-
-	c.Redirect(http.StatusTemporaryRedirect, authURL)
+// @Param provider path string true "Provider name"
+// @Param redirect_uri query string false "Front-end URL to return to after login"
+// @Success 302 {string} string "Redirect to the provider"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 502 {object} map[string]string "error"
+// @Router /auth/{provider} [get]
+func (h *AuthHandler) HandleLogin(c *gin.Context) {
+	provider := c.Param("provider")
+	log.Printf("Initiating %s login....", provider)
+
+	authURL, sealedSession, err := h.authService.StartLogin(provider, c.Query("redirect_uri"))
+	if err != nil {
+		switch {
+		case errors.Is(err, customErrors.ErrUnknownProvider):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown sign-in provider"})
+		case errors.Is(err, customErrors.ErrRedirectNotAllowed):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "redirect_uri is not allowed"})
+		default:
+			log.Printf("Failed to start %s login: %v", provider, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Sign-in provider is unavailable. Please try again later."})
+		}
+		return
+	}
+
+	h.setSessionCookie(c, sealedSession, int(services.LoginSessionTTL.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
 }
 
-// HandleGoogleCallback handles Google OAuth callback
-// @Summary Handle Google OAuth callback
-// @Description Processes the OAuth callback and returns JWT token
+// HandleCallback handles the identity provider's OAuth callback
+// @Summary Handle OAuth/OIDC callback
+// @Description Processes the provider callback and signs in or creates the linked user. Returns tokens as JSON, or redirects to the login's redirect_uri with a one-time code (or error) for the front end to exchange at /auth/token. Accepts GET and form_post callbacks.
 // @Tags auth
 // @Accept json
 // @Produce json
+// @Param provider path string true "Provider name"
 // @Success 200 {object} map[string]string "token"
+// @Success 303 {string} string "Redirect to the front end with a login code"
 // @Failure 400 {object} map[string]string "error"
-// @Router /auth/google/callback [get]
-func (h *AuthHandler) HandleGoogleCallback(c *gin.Context) {
-	savedState, err := c.Cookie("oauth_state")
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Router /auth/{provider}/callback [get]
+// @Router /auth/{provider}/callback [post]
+func (h *AuthHandler) HandleCallback(c *gin.Context) {
+	provider := c.Param("provider")
+
+	sealedSession, err := c.Cookie(loginSessionCookie)
 	if err != nil {
-		log.Printf("OAuth state cookie not found: %v", err)
+		log.Printf("OAuth session cookie not found: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   "Authentication session expired. Please try again.",
@@ -62,9 +107,9 @@ func (h *AuthHandler) HandleGoogleCallback(c *gin.Context) {
 		return
 	}
 
-	queryState := c.Query("state")
-	if queryState != savedState {
-		log.Printf("State mismatch: expected %s, got %s", savedState, queryState)
+	session, err := h.authService.OpenLoginSession(sealedSession, provider, callbackParam(c, "state"))
+	if err != nil {
+		log.Printf("Invalid OAuth session on %s callback", provider)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   "Invalid authentication request. Possible CSRF attack.",
@@ -72,69 +117,60 @@ func (h *AuthHandler) HandleGoogleCallback(c *gin.Context) {
 		return
 	}
 
-	c.SetCookie("oauth_state", "", -1, "/", "", false, true)
+	h.setSessionCookie(c, "", -1)
 
-	if errorParam := c.Query("error"); errorParam != "" {
-		log.Printf("OAuth error from Google: %s", errorParam)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "Google authentication failed: " + errorParam,
-		})
+	if errorParam := callbackParam(c, "error"); errorParam != "" {
+		log.Printf("OAuth error from %s: %s", provider, errorParam)
+		h.loginFailed(c, session, http.StatusBadRequest, "provider_error", "Authentication with "+provider+" failed: "+errorParam)
 		return
 	}
 
-	code := c.Query("code")
+	code := callbackParam(c, "code")
 	if code == "" {
 		log.Println("No authorization code received")
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "No authorization code received from Google.",
-		})
+		h.loginFailed(c, session, http.StatusBadRequest, "missing_code", "No authorization code received from "+provider+".")
 		return
 	}
 
-	token, err := h.authService.ExchangeCode(code)
+	user, err := h.authService.LoginWithProvider(c.Request.Context(), session, code)
 	if err != nil {
-		log.Printf("Failed to exchange code: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "Failed to complete authentication with Google.",
-		})
+		switch {
+		case errors.Is(err, customErrors.ErrUnknownProvider):
+			h.loginFailed(c, session, http.StatusNotFound, "unknown_provider", "Unknown sign-in provider")
+		case errors.Is(err, customErrors.ErrIncompleteIdentity):
+			h.loginFailed(c, session, http.StatusBadRequest, "incomplete_identity", "Incomplete user information received from "+provider+".")
+		case errors.Is(err, customErrors.ErrUnverifiedEmail):
+			h.loginFailed(c, session, http.StatusConflict, "email_conflict", "An account with this email already exists. Sign in with your original provider, or verify your email with "+provider+" first.")
+		default:
+			log.Printf("Failed to complete %s login: %v", provider, err)
+			h.loginFailed(c, session, http.StatusInternalServerError, "server_error", "Failed to complete authentication with "+provider+".")
+		}
 		return
 	}
-
-	userInfo, err := h.authService.GetGoogleUserInfo(token.AccessToken)
-	if err != nil {
-		log.Printf("Failed to get user info: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "Failed to retrieve user information from Google.",
-		})
+	if !models.CanSignIn(user.Status) {
+		log.Printf("SECURITY: disabled user %s tried to sign in via %s", user.ID, provider)
+		h.loginFailed(c, session, http.StatusForbidden, "account_disabled", customErrors.ErrAccountDisabled.Error())
 		return
 	}
 
-	if userInfo.Email == "" || userInfo.ID == "" {
-		log.Printf("Incomplete user info received: %+v", userInfo)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "Incomplete user information received from Google.",
-		})
-		return
-	}
-
-	newUser := dto.CreateNewUserRequest{
-		GoogleID:  userInfo.ID,
-		Email:     userInfo.Email,
-		FirstName: userInfo.FamilyName,
-		LastName:  userInfo.GivenName,
-	}
+	log.Printf("User authenticated successfully via %s: %s (%s)", provider, user.Email, user.ID)
+	actor := auditActor(c)
+	actor.UserID, actor.AuthMethod = user.ID, models.AuthMethodOAuth
+	h.auditService.Record(actor, models.AuditLogin, "user", user.ID.String(), nil, gin.H{"provider": provider})
 
-	user, err := h.authService.FindOrCreateUser(&newUser)
-	if err != nil {
+	// Front ends get a one-time code rather than tokens in the URL
+	if session.RedirectURI != "" {
+		loginCode, err := h.authService.CreateLoginCode(user)
+		if err != nil {
+			log.Printf("Failed to create login code: %v", err)
+			h.loginFailed(c, session, http.StatusInternalServerError, "server_error", "Failed to generate authentication token.")
+			return
+		}
+		c.Redirect(http.StatusSeeOther, withQuery(session.RedirectURI, "code", loginCode))
 		return
 	}
 
-	jwtToken, err := h.authService.GenerateJWT(user, h.cfg.JWTSecret)
+	tokens, err := h.authService.IssueTokens(user)
 	if err != nil {
 		log.Printf("Failed to generate JWT: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -144,12 +180,13 @@ func (h *AuthHandler) HandleGoogleCallback(c *gin.Context) {
 		return
 	}
 
-	log.Printf("User authenticated successfully: %s (%s)", user.Email, user.ID)
-
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "Authentication successful",
-		"token":   jwtToken,
+		"success":       true,
+		"message":       "Authentication successful",
+		"token":         tokens.Token,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    tokens.ExpiresIn,
 		"user": gin.H{
 			"id":         user.ID,
 			"email":      user.Email,
@@ -159,3 +196,192 @@ func (h *AuthHandler) HandleGoogleCallback(c *gin.Context) {
 		},
 	})
 }
+
+// ExchangeLoginCode godoc
+// @Summary Exchange a login code for tokens
+// @Description Redeem the one-time code a provider login redirected back with. Codes expire after a minute and work once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.ExchangeLoginCodeRequest true "Login code"
+// @Success 200 {object} dto.TokenResponse "Token pair"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 401 {object} map[string]string "error"
+// @Failure 500 {object} map[string]string "error"
+// @Router /auth/token [post]
+func (h *AuthHandler) ExchangeLoginCode(c *gin.Context) {
+	var req dto.ExchangeLoginCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	tokens, err := h.authService.ExchangeLoginCode(req.Code)
+	if err != nil {
+		if errors.Is(err, customErrors.ErrInvalidLoginCode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login code"})
+			return
+		}
+		if errors.Is(err, customErrors.ErrAccountDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Failed to exchange login code: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to exchange login code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// RefreshToken godoc
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token can only be used once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} dto.TokenResponse "New token pair"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 401 {object} map[string]string "error"
+// @Router /auth/refresh [post]
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var req dto.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+
+	tokens, err := h.authService.RefreshTokens(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, customErrors.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected. Please sign in again."})
+			return
+		}
+		if errors.Is(err, customErrors.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			return
+		}
+		if errors.Is(err, customErrors.ErrAccountDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Failed to refresh tokens: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout godoc
+// @Summary Log out
+// @Description Revoke the current access token and the given refresh token's session, or every session with all_sessions
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.LogoutRequest false "Logout request"
+// @Success 200 {object} map[string]string "message"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 500 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req dto.LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+	var accessToken *services.AccessToken
+	if value, ok := c.Get("access_token"); ok {
+		accessToken = value.(*services.AccessToken)
+	}
+
+	if err := h.authService.Logout(userID, accessToken, req); err != nil {
+		if errors.Is(err, customErrors.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+		log.Printf("Failed to log out user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+	h.auditService.Record(auditActor(c), models.AuditLogout, "user", userID.String(), nil, gin.H{"all_sessions": req.AllSessions})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// JWKS godoc
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying Argent access tokens. Select the key by the token's kid header.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} dto.JWKSResponse "Key set"
+// @Failure 500 {object} map[string]string "error"
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	jwks, err := h.authService.JWKS()
+	if err != nil {
+		log.Printf("Failed to load JWKS: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load signing keys"})
+		return
+	}
+
+	// Let verifiers cache briefly; rotations keep the old key published meanwhile
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwks)
+}
+
+// setSessionCookie stores the sealed login session. Over HTTPS it is Secure
+// and SameSite=None so it survives providers that POST the callback
+// cross-site (form_post).
+func (h *AuthHandler) setSessionCookie(c *gin.Context, value string, maxAge int) {
+	secure := strings.HasPrefix(h.cfg.BaseURL, "https://")
+	if secure {
+		c.SetSameSite(http.SameSiteNoneMode)
+	} else {
+		c.SetSameSite(http.SameSiteLaxMode)
+	}
+	c.SetCookie(loginSessionCookie, value, maxAge, "/auth/", "", secure, true)
+}
+
+// loginFailed reports a failed login as JSON, or back to the front end when
+// the login came from one
+func (h *AuthHandler) loginFailed(c *gin.Context, session *services.LoginSession, status int, code, message string) {
+	actor := auditActor(c)
+	actor.AuthMethod = models.AuthMethodOAuth
+	h.auditService.Record(actor, models.AuditLoginFailed, "", "", nil, gin.H{"provider": c.Param("provider"), "reason": code})
+
+	if session.RedirectURI != "" {
+		c.Redirect(http.StatusSeeOther, withQuery(session.RedirectURI, "error", code))
+		return
+	}
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   message,
+	})
+}
+
+// withQuery adds a query parameter to a URL
+func withQuery(rawURL, key, value string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := parsed.Query()
+	query.Set(key, value)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// callbackParam reads a callback parameter from a form_post body or the query
+func callbackParam(c *gin.Context, key string) string {
+	if value := c.PostForm(key); value != "" {
+		return value
+	}
+	return c.Query(key)
+}
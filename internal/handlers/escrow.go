@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type EscrowHandler struct {
+	escrowService    services.EscrowService
+	twoFactorService services.TwoFactorService
+	pinService       services.PINService
+}
+
+func NewEscrowHandler(escrowService services.EscrowService, twoFactorService services.TwoFactorService, pinService services.PINService) *EscrowHandler {
+	return &EscrowHandler{
+		escrowService:    escrowService,
+		twoFactorService: twoFactorService,
+		pinService:       pinService,
+	}
+}
+
+// CreateEscrow godoc
+// @Summary Hold a payment in escrow
+// @Description Lock an amount, plus its transfer fee, in the payer's wallet for a payee (account number, @handle or verified email). The funds leave the available balance but don't reach the payee until the payer releases them. When expires_at passes (14 days by default, at most 90) the escrow is released or refunded as on_expiry says, unless it is disputed. Confirmed like a transfer.
+// @Tags escrow
+// @Accept json
+// @Produce json
+// @Param request body dto.CreateEscrowRequest true "Escrow"
+// @Param X-Step-Up-Token header string false "Step-up token, required for user sessions above STEP_UP_TRANSFER_THRESHOLD when two-factor authentication is enabled"
+// @Success 201 {object} models.Escrow "Escrow"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/escrows [post]
+func (h *EscrowHandler) CreateEscrow(c *gin.Context) {
+	var req dto.CreateEscrowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if !authorizePayment(c, h.twoFactorService, h.pinService, req.Amount, req.PIN) {
+		return
+	}
+
+	escrow, err := h.escrowService.Create(auditActor(c), c.GetString("mode"), req)
+	if err != nil {
+		writeEscrowError(c, err, "Failed to create escrow")
+		return
+	}
+
+	c.JSON(http.StatusCreated, escrow)
+}
+
+// ListEscrows godoc
+// @Summary List escrows
+// @Description List escrows the user pays into, or with role=payee those held for them, in the current mode, newest first
+// @Tags escrow
+// @Produce json
+// @Param role query string false "payer (default) or payee"
+// @Param status query string false "held, released, refunded or disputed"
+// @Param limit query int false "Page size, 1-200 (default 50)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} map[string]interface{} "escrows and total"
+// @Failure 400 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/escrows [get]
+func (h *EscrowHandler) ListEscrows(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	filter := dto.EscrowFilter{
+		Role:   c.DefaultQuery("role", "payer"),
+		Status: c.Query("status"),
+		Limit:  limit,
+		Offset: offset,
+	}
+	if filter.Role != "payer" && filter.Role != "payee" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be payer or payee"})
+		return
+	}
+	if !validEscrowStatus(filter.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	escrows, total, err := h.escrowService.List(userID, c.GetString("mode"), filter)
+	if err != nil {
+		writeEscrowError(c, err, "Failed to list escrows")
+		return
+	}
+	if escrows == nil {
+		escrows = []models.Escrow{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"escrows": escrows, "total": total})
+}
+
+// GetEscrow godoc
+// @Summary Get an escrow
+// @Description Get an escrow the user is the payer or payee of
+// @Tags escrow
+// @Produce json
+// @Param id path string true "Escrow ID"
+// @Success 200 {object} models.Escrow "Escrow"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/escrows/{id} [get]
+func (h *EscrowHandler) GetEscrow(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	escrow, err := h.escrowService.Get(userID, c.GetString("mode"), id)
+	if err != nil {
+		writeEscrowError(c, err, "Failed to get escrow")
+		return
+	}
+
+	c.JSON(http.StatusOK, escrow)
+}
+
+// ReleaseEscrow godoc
+// @Summary Release an escrow
+// @Description Confirm delivery and pay a held escrow to the payee. Only the payer may release; confirmed like a transfer.
+// @Tags escrow
+// @Accept json
+// @Produce json
+// @Param id path string true "Escrow ID"
+// @Param request body dto.ReleaseEscrowRequest false "PIN"
+// @Param X-Step-Up-Token header string false "Step-up token, required for user sessions above STEP_UP_TRANSFER_THRESHOLD when two-factor authentication is enabled"
+// @Success 200 {object} models.Escrow "Released escrow"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/escrows/{id}/release [post]
+func (h *EscrowHandler) ReleaseEscrow(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.ReleaseEscrowRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+	mode := c.GetString("mode")
+
+	// Look the escrow up first, so step-up is judged on its amount
+	escrow, err := h.escrowService.Get(userID, mode, id)
+	if err != nil {
+		writeEscrowError(c, err, "Failed to release escrow")
+		return
+	}
+	if !authorizePayment(c, h.twoFactorService, h.pinService, escrow.Amount, req.PIN) {
+		return
+	}
+
+	escrow, err = h.escrowService.Release(auditActor(c), mode, id)
+	if err != nil {
+		writeEscrowError(c, err, "Failed to release escrow")
+		return
+	}
+
+	c.JSON(http.StatusOK, escrow)
+}
+
+// CancelEscrow godoc
+// @Summary Cancel an escrow
+// @Description Refund a held escrow, fee included, to the payer. Only the payee may cancel.
+// @Tags escrow
+// @Produce json
+// @Param id path string true "Escrow ID"
+// @Success 200 {object} models.Escrow "Refunded escrow"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/escrows/{id}/cancel [post]
+func (h *EscrowHandler) CancelEscrow(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+
+	escrow, err := h.escrowService.Cancel(auditActor(c), c.GetString("mode"), id)
+	if err != nil {
+		writeEscrowError(c, err, "Failed to cancel escrow")
+		return
+	}
+
+	c.JSON(http.StatusOK, escrow)
+}
+
+// DisputeEscrow godoc
+// @Summary Dispute an escrow
+// @Description Either party can dispute a held escrow. It then stays held, past its deadline too, until staff release or refund it.
+// @Tags escrow
+// @Accept json
+// @Produce json
+// @Param id path string true "Escrow ID"
+// @Param request body dto.DisputeEscrowRequest true "Reason"
+// @Success 200 {object} models.Escrow "Disputed escrow"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/escrows/{id}/dispute [post]
+func (h *EscrowHandler) DisputeEscrow(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.DisputeEscrowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	escrow, err := h.escrowService.Dispute(auditActor(c), c.GetString("mode"), id, req.Reason)
+	if err != nil {
+		writeEscrowError(c, err, "Failed to dispute escrow")
+		return
+	}
+
+	c.JSON(http.StatusOK, escrow)
+}
+
+// AdminListEscrows godoc
+// @Summary Search escrows
+// @Description Escrows across all users, newest first. Use status=disputed for the disputes queue. Needs the escrows:resolve permission.
+// @Tags admin
+// @Produce json
+// @Param status query string false "held, released, refunded or disputed"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Results to skip"
+// @Success 200 {object} map[string]interface{} "escrows and total"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/escrows [get]
+func (h *EscrowHandler) AdminListEscrows(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	if !validEscrowStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	escrows, total, err := h.escrowService.AdminList(status, limit, offset)
+	if err != nil {
+		writeEscrowError(c, err, "Failed to list escrows")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"escrows": escrows, "total": total, "limit": limit, "offset": offset})
+}
+
+// ResolveEscrow godoc
+// @Summary Settle a disputed escrow
+// @Description Release a disputed escrow to the payee or refund it to the payer. Needs the escrows:resolve permission and, with two-factor enabled, a step-up token. Staff can't settle escrows they are the payer or payee of.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Escrow ID"
+// @Param request body dto.ResolveEscrowRequest true "Outcome"
+// @Param X-Step-Up-Token header string false "Step-up token"
+// @Success 200 {object} models.Escrow "Settled escrow"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /admin/escrows/{id}/resolve [post]
+func (h *EscrowHandler) ResolveEscrow(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.ResolveEscrowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if !stepUpVerified(c, h.twoFactorService) {
+		return
+	}
+
+	escrow, err := h.escrowService.Resolve(auditActor(c), id, req)
+	if err != nil {
+		writeEscrowError(c, err, "Failed to settle escrow")
+		return
+	}
+
+	c.JSON(http.StatusOK, escrow)
+}
+
+func validEscrowStatus(status string) bool {
+	switch status {
+	case "", models.EscrowHeld, models.EscrowReleased, models.EscrowRefunded, models.EscrowDisputed:
+		return true
+	}
+	return false
+}
+
+// writeEscrowError maps escrow errors to HTTP responses
+func writeEscrowError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Escrow not found"})
+	case errors.Is(err, customErrors.ErrRecipientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "error_code": "recipient_not_found"})
+	case errors.Is(err, customErrors.ErrDebitsBlocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "error_code": "account_frozen"})
+	case errors.Is(err, customErrors.ErrNotEscrowPayer),
+		errors.Is(err, customErrors.ErrNotEscrowPayee),
+		errors.Is(err, customErrors.ErrCannotResolveOwn):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrEscrowNotHeld),
+		errors.Is(err, customErrors.ErrEscrowNotDisputed),
+		errors.Is(err, customErrors.ErrRecipientCannotReceive):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrInvalidAmount),
+		errors.Is(err, customErrors.ErrInvalidEscrowExpiry),
+		errors.Is(err, customErrors.ErrInvalidOnExpiry),
+		errors.Is(err, customErrors.ErrDescriptionTooLong),
+		errors.Is(err, customErrors.ErrDisputeReasonMissing),
+		errors.Is(err, customErrors.ErrInvalidEscrowOutcome),
+		errors.Is(err, customErrors.ErrCannotPaySelf),
+		errors.Is(err, customErrors.ErrInsufficientBalance):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
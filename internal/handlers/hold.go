@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type HoldHandler struct {
+	holdService      services.HoldService
+	twoFactorService services.TwoFactorService
+	pinService       services.PINService
+}
+
+func NewHoldHandler(holdService services.HoldService, twoFactorService services.TwoFactorService, pinService services.PINService) *HoldHandler {
+	return &HoldHandler{
+		holdService:      holdService,
+		twoFactorService: twoFactorService,
+		pinService:       pinService,
+	}
+}
+
+// PlaceHold godoc
+// @Summary Authorize a payment
+// @Description Set an amount, plus its transfer fee, aside in the caller's wallet for a payee (account number, @handle or verified email), who can later capture some or all of it or void the hold. The funds leave the available balance but stay in the ledger balance. Unused holds expire after 7 days unless expires_at is given, at most 30 days ahead. Confirmed like a transfer.
+// @Tags holds
+// @Accept json
+// @Produce json
+// @Param request body dto.PlaceHoldRequest true "Hold"
+// @Param X-Step-Up-Token header string false "Step-up token, required for user sessions above STEP_UP_TRANSFER_THRESHOLD when two-factor authentication is enabled"
+// @Success 201 {object} models.Hold "Hold"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/holds [post]
+func (h *HoldHandler) PlaceHold(c *gin.Context) {
+	var req dto.PlaceHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if !authorizePayment(c, h.twoFactorService, h.pinService, req.Amount, req.PIN) {
+		return
+	}
+
+	hold, err := h.holdService.Place(auditActor(c), c.GetString("mode"), req)
+	if err != nil {
+		writeHoldError(c, err, "Failed to place hold")
+		return
+	}
+
+	c.JSON(http.StatusCreated, hold)
+}
+
+// ListHolds godoc
+// @Summary List holds
+// @Description List holds on the user's wallet, or with role=payee those placed for them, in the current mode, newest first. Escrows show up here with kind escrow.
+// @Tags holds
+// @Produce json
+// @Param role query string false "payer (default) or payee"
+// @Param status query string false "active, captured, voided or expired"
+// @Param limit query int false "Page size, 1-200 (default 50)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} map[string]interface{} "holds and total"
+// @Failure 400 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/holds [get]
+func (h *HoldHandler) ListHolds(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	filter := dto.HoldFilter{
+		Role:   c.DefaultQuery("role", "payer"),
+		Status: c.Query("status"),
+		Limit:  limit,
+		Offset: offset,
+	}
+	if filter.Role != "payer" && filter.Role != "payee" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be payer or payee"})
+		return
+	}
+	switch filter.Status {
+	case "", models.HoldActive, models.HoldCaptured, models.HoldVoided, models.HoldExpired:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	holds, total, err := h.holdService.List(userID, c.GetString("mode"), filter)
+	if err != nil {
+		writeHoldError(c, err, "Failed to list holds")
+		return
+	}
+	if holds == nil {
+		holds = []models.Hold{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"holds": holds, "total": total})
+}
+
+// GetHold godoc
+// @Summary Get a hold
+// @Description Get a hold the user is the payer or payee of
+// @Tags holds
+// @Produce json
+// @Param id path string true "Hold ID"
+// @Success 200 {object} models.Hold "Hold"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/holds/{id} [get]
+func (h *HoldHandler) GetHold(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	hold, err := h.holdService.Get(userID, c.GetString("mode"), id)
+	if err != nil {
+		writeHoldError(c, err, "Failed to get hold")
+		return
+	}
+
+	c.JSON(http.StatusOK, hold)
+}
+
+// CaptureHold godoc
+// @Summary Capture a hold
+// @Description Take some or, without an amount, all of an active hold. Only the payee may capture, and only once; whatever isn't captured goes back to the payer's available balance. The transfer fee is charged on the captured amount.
+// @Tags holds
+// @Accept json
+// @Produce json
+// @Param id path string true "Hold ID"
+// @Param request body dto.CaptureHoldRequest false "Amount to capture"
+// @Success 200 {object} models.Hold "Captured hold"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/holds/{id}/capture [post]
+func (h *HoldHandler) CaptureHold(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.CaptureHoldRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+
+	hold, err := h.holdService.Capture(auditActor(c), c.GetString("mode"), id, req.Amount)
+	if err != nil {
+		writeHoldError(c, err, "Failed to capture hold")
+		return
+	}
+
+	c.JSON(http.StatusOK, hold)
+}
+
+// VoidHold godoc
+// @Summary Void a hold
+// @Description Cancel an active hold, returning everything to the payer's available balance. Only the payee may void; payers wait for the hold to expire.
+// @Tags holds
+// @Produce json
+// @Param id path string true "Hold ID"
+// @Success 200 {object} models.Hold "Voided hold"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/holds/{id}/void [post]
+func (h *HoldHandler) VoidHold(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+
+	hold, err := h.holdService.Void(auditActor(c), c.GetString("mode"), id)
+	if err != nil {
+		writeHoldError(c, err, "Failed to void hold")
+		return
+	}
+
+	c.JSON(http.StatusOK, hold)
+}
+
+// writeHoldError maps hold errors to HTTP responses
+func writeHoldError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Hold not found"})
+	case errors.Is(err, customErrors.ErrRecipientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "error_code": "recipient_not_found"})
+	case errors.Is(err, customErrors.ErrDebitsBlocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "error_code": "account_frozen"})
+	case errors.Is(err, customErrors.ErrNotHoldPayee),
+		errors.Is(err, customErrors.ErrHoldNotCapturable):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrHoldNotActive),
+		errors.Is(err, customErrors.ErrRecipientCannotReceive):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrInvalidAmount),
+		errors.Is(err, customErrors.ErrInvalidHoldExpiry),
+		errors.Is(err, customErrors.ErrDescriptionTooLong),
+		errors.Is(err, customErrors.ErrCaptureExceedsHold),
+		errors.Is(err, customErrors.ErrCannotPaySelf),
+		errors.Is(err, customErrors.ErrInsufficientBalance):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordAuthHandler struct {
+	passwordAuthService services.PasswordAuthService
+	auditService        services.AuditService
+}
+
+func NewPasswordAuthHandler(passwordAuthService services.PasswordAuthService, auditService services.AuditService) *PasswordAuthHandler {
+	return &PasswordAuthHandler{
+		passwordAuthService: passwordAuthService,
+		auditService:        auditService,
+	}
+}
+
+// Register godoc
+// @Summary Register with email and password
+// @Description Create an account and wallet, and email a verification link. The email must be verified before password login.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.RegisterRequest true "Registration details"
+// @Success 201 {object} map[string]interface{} "Created user"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Failure 500 {object} map[string]string "error"
+// @Router /auth/register [post]
+func (h *PasswordAuthHandler) Register(c *gin.Context) {
+	var req dto.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	user, err := h.passwordAuthService.Register(req)
+	if err != nil {
+		writePasswordAuthError(c, err, "Failed to register")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Registration successful. Check your email to verify your address.",
+		"user": gin.H{
+			"id":         user.ID,
+			"email":      user.Email,
+			"first_name": user.FirstName,
+			"last_name":  user.LastName,
+		},
+	})
+}
+
+// Login godoc
+// @Summary Log in with email and password
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.PasswordLoginRequest true "Credentials"
+// @Success 200 {object} dto.TokenResponse "Token pair"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 401 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Router /auth/login [post]
+func (h *PasswordAuthHandler) Login(c *gin.Context) {
+	var req dto.PasswordLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	tokens, err := h.passwordAuthService.Login(req)
+	h.auditLogin(c, models.AuthMethodPassword, tokens, err, gin.H{"email": req.Email})
+	if err != nil {
+		writePasswordAuthError(c, err, "Failed to log in")
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Confirm an email address with the token from the verification email
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.EmailTokenRequest false "Verification token (POST)"
+// @Param token query string false "Verification token (GET, as in the emailed link)"
+// @Success 200 {object} map[string]string "message"
+// @Failure 400 {object} map[string]string "error"
+// @Router /auth/email/verify [get]
+// @Router /auth/email/verify [post]
+func (h *PasswordAuthHandler) VerifyEmail(c *gin.Context) {
+	var req dto.EmailTokenRequest
+	// The emailed link is a plain GET with the token in the query
+	if c.Request.Method == http.MethodGet {
+		req.Token = c.Query("token")
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := h.passwordAuthService.VerifyEmail(req.Token); err != nil {
+		writePasswordAuthError(c, err, "Failed to verify email")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified. You can now log in."})
+}
+
+// ResendVerification godoc
+// @Summary Resend the verification email
+// @Description Always succeeds, so it can't be used to find out which emails are registered
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.EmailRequest true "Email address"
+// @Success 202 {object} map[string]string "message"
+// @Failure 400 {object} map[string]string "error"
+// @Router /auth/email/resend [post]
+func (h *PasswordAuthHandler) ResendVerification(c *gin.Context) {
+	var req dto.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := h.passwordAuthService.ResendVerification(req.Email); err != nil {
+		writePasswordAuthError(c, err, "Failed to resend verification email")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address needs verifying, a new link is on its way."})
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Emails a reset link if the address is registered. Always succeeds, so it can't be used to find out which emails are registered.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.EmailRequest true "Email address"
+// @Success 202 {object} map[string]string "message"
+// @Failure 400 {object} map[string]string "error"
+// @Router /auth/password/forgot [post]
+func (h *PasswordAuthHandler) ForgotPassword(c *gin.Context) {
+	var req dto.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := h.passwordAuthService.RequestPasswordReset(req.Email); err != nil {
+		writePasswordAuthError(c, err, "Failed to request password reset")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address is registered, a reset link is on its way."})
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password with the token from the reset email. Signs the user out of every session.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string "message"
+// @Failure 400 {object} map[string]string "error"
+// @Router /auth/password/reset [post]
+func (h *PasswordAuthHandler) ResetPassword(c *gin.Context) {
+	var req dto.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := h.passwordAuthService.ResetPassword(req); err != nil {
+		writePasswordAuthError(c, err, "Failed to reset password")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated. Please log in again."})
+}
+
+// RequestMagicLink godoc
+// @Summary Request a magic sign-in link
+// @Description Emails a one-time sign-in link. Unregistered addresses get an account when the link is used.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.EmailRequest true "Email address"
+// @Success 202 {object} map[string]string "message"
+// @Failure 400 {object} map[string]string "error"
+// @Router /auth/magic-link [post]
+func (h *PasswordAuthHandler) RequestMagicLink(c *gin.Context) {
+	var req dto.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := h.passwordAuthService.RequestMagicLink(req.Email); err != nil {
+		writePasswordAuthError(c, err, "Failed to send sign-in link")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Check your email for a sign-in link."})
+}
+
+// VerifyMagicLink godoc
+// @Summary Sign in with a magic link
+// @Description Exchange the token from a sign-in email for a token pair. Only POST is accepted, so link scanners can't use up the token.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.EmailTokenRequest true "Sign-in token"
+// @Success 200 {object} dto.TokenResponse "Token pair"
+// @Failure 400 {object} map[string]string "error"
+// @Router /auth/magic-link/verify [post]
+func (h *PasswordAuthHandler) VerifyMagicLink(c *gin.Context) {
+	var req dto.EmailTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	tokens, err := h.passwordAuthService.LoginWithMagicLink(req.Token)
+	h.auditLogin(c, models.AuthMethodMagicLink, tokens, err, nil)
+	if err != nil {
+		writePasswordAuthError(c, err, "Failed to sign in")
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// auditLogin records a sign-in, or a refused one. Server errors aren't
+// attempts by the caller, so they are left out.
+func (h *PasswordAuthHandler) auditLogin(c *gin.Context, method string, tokens *dto.TokenResponse, err error, details gin.H) {
+	actor := auditActor(c)
+	actor.AuthMethod = method
+	switch {
+	case err == nil:
+		actor.UserID = tokens.UserID
+		h.auditService.Record(actor, models.AuditLogin, "user", tokens.UserID.String(), nil, details)
+	case errors.Is(err, customErrors.ErrInvalidCredentials),
+		errors.Is(err, customErrors.ErrEmailNotVerified),
+		errors.Is(err, customErrors.ErrAccountDisabled),
+		errors.Is(err, customErrors.ErrInvalidEmailToken):
+		if details == nil {
+			details = gin.H{}
+		}
+		details["reason"] = err.Error()
+		h.auditService.Record(actor, models.AuditLoginFailed, "", "", nil, details)
+	}
+}
+
+// writePasswordAuthError maps password auth errors to HTTP responses
+func writePasswordAuthError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, customErrors.ErrInvalidEmail),
+		errors.Is(err, customErrors.ErrWeakPassword),
+		errors.Is(err, customErrors.ErrNameRequired),
+		errors.Is(err, customErrors.ErrInvalidEmailToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrEmailAlreadyRegistered):
+		c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists. Log in, or reset your password to set one."})
+	case errors.Is(err, customErrors.ErrInvalidCredentials):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrEmailNotVerified):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email address before logging in"})
+	case errors.Is(err, customErrors.ErrAccountDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
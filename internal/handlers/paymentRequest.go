@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"whotterre/argent/internal/config"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PaymentRequestHandler struct {
+	paymentRequestService services.PaymentRequestService
+	twoFactorService      services.TwoFactorService
+	pinService            services.PINService
+	config                config.Config
+}
+
+func NewPaymentRequestHandler(paymentRequestService services.PaymentRequestService, twoFactorService services.TwoFactorService, pinService services.PINService, cfg config.Config) *PaymentRequestHandler {
+	return &PaymentRequestHandler{
+		paymentRequestService: paymentRequestService,
+		twoFactorService:      twoFactorService,
+		pinService:            pinService,
+		config:                cfg,
+	}
+}
+
+// CreatePaymentRequest godoc
+// @Summary Request money
+// @Description Ask a payer (account number, @handle or verified email) for money, or leave payer out to create a public payment link anyone can pay from their wallet or by card. Requests expire after 7 days unless expires_at is given, at most 90 days ahead.
+// @Tags payment-requests
+// @Accept json
+// @Produce json
+// @Param request body dto.CreatePaymentRequest true "Payment request"
+// @Success 201 {object} map[string]interface{} "request, and link for public requests"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/requests [post]
+func (h *PaymentRequestHandler) CreatePaymentRequest(c *gin.Context) {
+	var req dto.CreatePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	request, err := h.paymentRequestService.Create(auditActor(c), c.GetString("mode"), req)
+	if err != nil {
+		writePaymentRequestError(c, err, "Failed to create payment request")
+		return
+	}
+
+	response := gin.H{"request": request}
+	if request.PayerID == nil {
+		response["link"] = h.config.BaseURL + "/pay/" + request.Code
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListPaymentRequests godoc
+// @Summary List payment requests
+// @Description List requests the user sent, or with direction=received those addressed to them, in the current mode, newest first
+// @Tags payment-requests
+// @Produce json
+// @Param direction query string false "sent (default) or received"
+// @Param status query string false "open, paid, declined, expired or cancelled"
+// @Param limit query int false "Page size, 1-200 (default 50)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} map[string]interface{} "requests and total"
+// @Failure 400 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/requests [get]
+func (h *PaymentRequestHandler) ListPaymentRequests(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	filter := dto.PaymentRequestFilter{
+		Direction: c.DefaultQuery("direction", "sent"),
+		Status:    c.Query("status"),
+		Limit:     limit,
+		Offset:    offset,
+	}
+	if filter.Direction != "sent" && filter.Direction != "received" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "direction must be sent or received"})
+		return
+	}
+	switch filter.Status {
+	case "", models.PaymentRequestOpen, models.PaymentRequestPaid, models.PaymentRequestDeclined,
+		models.PaymentRequestExpired, models.PaymentRequestCancelled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	requests, total, err := h.paymentRequestService.List(userID, c.GetString("mode"), filter)
+	if err != nil {
+		writePaymentRequestError(c, err, "Failed to list payment requests")
+		return
+	}
+	if requests == nil {
+		requests = []models.PaymentRequest{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requests": requests, "total": total})
+}
+
+// GetPaymentRequest godoc
+// @Summary Get a payment request
+// @Description Get a request the user sent or was asked to pay
+// @Tags payment-requests
+// @Produce json
+// @Param id path string true "Payment request ID"
+// @Success 200 {object} models.PaymentRequest "Payment request"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/requests/{id} [get]
+func (h *PaymentRequestHandler) GetPaymentRequest(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	request, err := h.paymentRequestService.Get(userID, id)
+	if err != nil {
+		writePaymentRequestError(c, err, "Failed to get payment request")
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// PayPaymentRequest godoc
+// @Summary Pay a payment request
+// @Description Pay a request addressed to the user from their wallet. Confirmed like a transfer: the PIN for user sessions and the transfer permission for API keys.
+// @Tags payment-requests
+// @Accept json
+// @Produce json
+// @Param id path string true "Payment request ID"
+// @Param request body dto.PayRequestRequest false "PIN"
+// @Param X-Step-Up-Token header string false "Step-up token, required for user sessions above STEP_UP_TRANSFER_THRESHOLD when two-factor authentication is enabled"
+// @Success 200 {object} models.PaymentRequest "Paid request"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/requests/{id}/pay [post]
+func (h *PaymentRequestHandler) PayPaymentRequest(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	// Look the request up first, so step-up is judged on its amount
+	request, err := h.paymentRequestService.Get(userID, id)
+	if err != nil {
+		writePaymentRequestError(c, err, "Failed to pay payment request")
+		return
+	}
+	if !h.confirmPayment(c, request.Amount) {
+		return
+	}
+
+	paid, err := h.paymentRequestService.Pay(auditActor(c), c.GetString("mode"), id)
+	if err != nil {
+		writePaymentRequestError(c, err, "Failed to pay payment request")
+		return
+	}
+
+	c.JSON(http.StatusOK, paid)
+}
+
+// DeclinePaymentRequest godoc
+// @Summary Decline a payment request
+// @Description Turn down an open request addressed to the user
+// @Tags payment-requests
+// @Produce json
+// @Param id path string true "Payment request ID"
+// @Success 200 {object} map[string]string "message"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/requests/{id}/decline [post]
+func (h *PaymentRequestHandler) DeclinePaymentRequest(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.paymentRequestService.Decline(auditActor(c), id); err != nil {
+		writePaymentRequestError(c, err, "Failed to decline payment request")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payment request declined"})
+}
+
+// CancelPaymentRequest godoc
+// @Summary Cancel a payment request
+// @Description Withdraw one of the user's own open requests or payment links
+// @Tags payment-requests
+// @Produce json
+// @Param id path string true "Payment request ID"
+// @Success 200 {object} map[string]string "message"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/requests/{id}/cancel [post]
+func (h *PaymentRequestHandler) CancelPaymentRequest(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.paymentRequestService.Cancel(auditActor(c), id); err != nil {
+		writePaymentRequestError(c, err, "Failed to cancel payment request")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payment request cancelled"})
+}
+
+// GetPaymentLink godoc
+// @Summary View a payment link
+// @Description Show what a public payment link asks for. No authentication is needed; the requester's name is masked.
+// @Tags payment-requests
+// @Produce json
+// @Param code path string true "Payment link code"
+// @Success 200 {object} dto.PublicPaymentRequest "Payment link"
+// @Failure 404 {object} map[string]string "error"
+// @Router /pay/{code} [get]
+func (h *PaymentRequestHandler) GetPaymentLink(c *gin.Context) {
+	link, err := h.paymentRequestService.GetPublic(c.Param("code"))
+	if err != nil {
+		writePaymentRequestError(c, err, "Failed to get payment link")
+		return
+	}
+
+	c.JSON(http.StatusOK, link)
+}
+
+// PayLinkFromWallet godoc
+// @Summary Pay a payment link from a wallet
+// @Description Pay a public payment link from the user's wallet in the link's mode. Confirmed like a transfer.
+// @Tags payment-requests
+// @Accept json
+// @Produce json
+// @Param code path string true "Payment link code"
+// @Param request body dto.PayRequestRequest false "PIN"
+// @Param X-Step-Up-Token header string false "Step-up token, required for user sessions above STEP_UP_TRANSFER_THRESHOLD when two-factor authentication is enabled"
+// @Success 200 {object} models.PaymentRequest "Paid request"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /pay/{code}/wallet [post]
+func (h *PaymentRequestHandler) PayLinkFromWallet(c *gin.Context) {
+	code := c.Param("code")
+	link, err := h.paymentRequestService.GetPublic(code)
+	if err != nil {
+		writePaymentRequestError(c, err, "Failed to pay payment link")
+		return
+	}
+	if !h.confirmPayment(c, link.Amount) {
+		return
+	}
+
+	paid, err := h.paymentRequestService.PayLink(auditActor(c), c.GetString("mode"), code)
+	if err != nil {
+		writePaymentRequestError(c, err, "Failed to pay payment link")
+		return
+	}
+
+	c.JSON(http.StatusOK, paid)
+}
+
+// PayLinkByCard godoc
+// @Summary Pay a payment link by card
+// @Description Start a Paystack checkout for a public payment link, for payers without a wallet. No authentication is needed. The link is marked paid once Paystack confirms the payment; the requester pays the usual deposit fee.
+// @Tags payment-requests
+// @Accept json
+// @Produce json
+// @Param code path string true "Payment link code"
+// @Param request body dto.PayByCardRequest true "Payer email"
+// @Success 200 {object} dto.DepositWalletResponse "Checkout"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Router /pay/{code}/card [post]
+func (h *PaymentRequestHandler) PayLinkByCard(c *gin.Context) {
+	var req dto.PayByCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	checkout, err := h.paymentRequestService.PayByCard(c.Param("code"), req.Email)
+	if err != nil {
+		writePaymentRequestError(c, err, "Failed to start card payment")
+		return
+	}
+
+	c.JSON(http.StatusOK, checkout)
+}
+
+// confirmPayment reads the optional body and authorizes paying amount
+func (h *PaymentRequestHandler) confirmPayment(c *gin.Context, amount float64) bool {
+	var req dto.PayRequestRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return false
+		}
+	}
+
+	return authorizePayment(c, h.twoFactorService, h.pinService, amount, req.PIN)
+}
+
+// writePaymentRequestError maps payment request errors to HTTP responses
+func writePaymentRequestError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment request not found"})
+	case errors.Is(err, customErrors.ErrRecipientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "error_code": "recipient_not_found"})
+	case errors.Is(err, customErrors.ErrDebitsBlocked),
+		errors.Is(err, customErrors.ErrCreditsBlocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "error_code": "account_frozen"})
+	case errors.Is(err, customErrors.ErrNotRequestPayer),
+		errors.Is(err, customErrors.ErrNotRequester):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrRequestNotOpen),
+		errors.Is(err, customErrors.ErrRequestExpired),
+		errors.Is(err, customErrors.ErrRequestAlreadyPaid):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrInvalidAmount),
+		errors.Is(err, customErrors.ErrInvalidRequestExpiry),
+		errors.Is(err, customErrors.ErrMemoTooLong),
+		errors.Is(err, customErrors.ErrCannotRequestSelf),
+		errors.Is(err, customErrors.ErrCannotPaySelf),
+		errors.Is(err, customErrors.ErrRecipientCannotReceive),
+		errors.Is(err, customErrors.ErrRequestModeMismatch),
+		errors.Is(err, customErrors.ErrInvalidPayerEmail),
+		errors.Is(err, customErrors.ErrInsufficientBalance),
+		errors.Is(err, customErrors.ErrFeeExceedsAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
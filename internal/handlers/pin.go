@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PINHandler struct {
+	pinService       services.PINService
+	twoFactorService services.TwoFactorService
+}
+
+func NewPINHandler(pinService services.PINService, twoFactorService services.TwoFactorService) *PINHandler {
+	return &PINHandler{
+		pinService:       pinService,
+		twoFactorService: twoFactorService,
+	}
+}
+
+// Status godoc
+// @Summary Transaction PIN status
+// @Description Whether a transaction PIN is set, and until when it is locked
+// @Tags pin
+// @Produce json
+// @Success 200 {object} dto.PINStatusResponse "Status"
+// @Failure 500 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /auth/pin [get]
+func (h *PINHandler) Status(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	status, err := h.pinService.Status(userID)
+	if err != nil {
+		writePINError(c, err, "Failed to load PIN status")
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SetPIN godoc
+// @Summary Set a transaction PIN
+// @Description Set the 4-6 digit PIN that confirms transfers. Repeated or sequential digits are refused.
+// @Tags pin
+// @Accept json
+// @Produce json
+// @Param request body dto.SetPINRequest true "New PIN"
+// @Success 201 {object} map[string]string "message"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /auth/pin [post]
+func (h *PINHandler) SetPIN(c *gin.Context) {
+	var req dto.SetPINRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	if err := h.pinService.SetPIN(userID, req.PIN); err != nil {
+		writePINError(c, err, "Failed to set PIN")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Transaction PIN set"})
+}
+
+// ChangePIN godoc
+// @Summary Change the transaction PIN
+// @Description Replace the PIN. A wrong current PIN counts towards the lockout.
+// @Tags pin
+// @Accept json
+// @Produce json
+// @Param request body dto.ChangePINRequest true "Current and new PIN"
+// @Success 200 {object} map[string]string "message"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 429 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /auth/pin [put]
+func (h *PINHandler) ChangePIN(c *gin.Context) {
+	var req dto.ChangePINRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := h.pinService.ChangePIN(auditActor(c), req); err != nil {
+		writePINError(c, err, "Failed to change PIN")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transaction PIN changed"})
+}
+
+// RequestPINReset godoc
+// @Summary Request a transaction PIN reset
+// @Description Email a link for choosing a new PIN, for a forgotten or locked PIN
+// @Tags pin
+// @Produce json
+// @Success 202 {object} map[string]string "message"
+// @Failure 403 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /auth/pin/reset/request [post]
+func (h *PINHandler) RequestPINReset(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	if err := h.pinService.RequestReset(userID); err != nil {
+		writePINError(c, err, "Failed to request PIN reset")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Check your email for a link to reset your PIN."})
+}
+
+// ResetPIN godoc
+// @Summary Reset the transaction PIN
+// @Description Set a new PIN with the emailed reset token. Lifts any lockout. Users with two-factor authentication also need a step-up token.
+// @Tags pin
+// @Accept json
+// @Produce json
+// @Param request body dto.ResetPINRequest true "Reset token and new PIN"
+// @Param X-Step-Up-Token header string false "Step-up token, required with two-factor authentication enabled"
+// @Success 200 {object} map[string]string "message"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /auth/pin/reset [post]
+func (h *PINHandler) ResetPIN(c *gin.Context) {
+	var req dto.ResetPINRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if !stepUpVerified(c, h.twoFactorService) {
+		return
+	}
+
+	if err := h.pinService.ResetPIN(auditActor(c), req); err != nil {
+		writePINError(c, err, "Failed to reset PIN")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transaction PIN reset"})
+}
+
+// writePINError maps transaction PIN errors to HTTP responses
+func writePINError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, customErrors.ErrWeakPIN),
+		errors.Is(err, customErrors.ErrInvalidEmailToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrPINAlreadySet):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrPINNotSet):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "error_code": "pin_not_set"})
+	case errors.Is(err, customErrors.ErrPINRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "error_code": "pin_required"})
+	case errors.Is(err, customErrors.ErrInvalidPIN):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "error_code": "invalid_pin"})
+	case errors.Is(err, customErrors.ErrPINLocked):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "error_code": "pin_locked"})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// streamHeartbeat keeps idle streams from being closed by proxies
+const streamHeartbeat = 25 * time.Second
+
+type RealtimeHandler struct {
+	realtimeService services.RealtimeService
+}
+
+func NewRealtimeHandler(realtimeService services.RealtimeService) *RealtimeHandler {
+	return &RealtimeHandler{
+		realtimeService: realtimeService,
+	}
+}
+
+// StreamWalletEvents godoc
+// @Summary Stream wallet updates
+// @Description Server-Sent Events stream of the caller's wallet in the current mode. A balance event with the current balance comes first, then balance events whenever it changes, deposit events when a deposit's status changes, and transfer events for incoming transfers. Comment lines are sent every 25 seconds to keep the connection open. Streams opened with a JWT send an expired event and close when the token expires; reconnect with a fresh token.
+// @Tags wallet
+// @Produce text/event-stream
+// @Success 200 {string} string "event stream"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 429 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/events [get]
+func (h *RealtimeHandler) StreamWalletEvents(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	updates, unsubscribe, err := h.realtimeService.Subscribe(userID, c.GetString("mode"))
+	if err != nil {
+		writeStreamError(c, err)
+		return
+	}
+	defer unsubscribe()
+
+	var expired <-chan time.Time
+	if token, ok := c.Get("access_token"); ok {
+		timer := time.NewTimer(time.Until(token.(*services.AccessToken).ExpiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	streamUpdates(c, updates, expired)
+}
+
+// StreamDepositEvents godoc
+// @Summary Stream a deposit's status
+// @Description Server-Sent Events stream of one deposit's status, for the page a payer returns to after checkout. A deposit event with the current status comes first, then one for each change: pending, success, held or failed. References of other transactions return 404.
+// @Tags wallet
+// @Produce text/event-stream
+// @Param reference path string true "Deposit reference"
+// @Success 200 {string} string "event stream"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 429 {object} map[string]string "error"
+// @Router /wallet/deposit/{reference}/events [get]
+func (h *RealtimeHandler) StreamDepositEvents(c *gin.Context) {
+	updates, unsubscribe, err := h.realtimeService.SubscribeDeposit(c.Param("reference"))
+	if err != nil {
+		writeStreamError(c, err)
+		return
+	}
+	defer unsubscribe()
+
+	streamUpdates(c, updates, nil)
+}
+
+// DepositCompletePage godoc
+// @Summary Deposit completion page
+// @Description A page that shows a deposit's status, given as the reference query parameter, and updates it live until the deposit succeeds, is held or fails. The deposit callback sends browsers here.
+// @Tags wallet
+// @Produce html
+// @Param reference query string true "Deposit reference"
+// @Success 200 {string} string "HTML page"
+// @Router /wallet/deposit/complete [get]
+func (h *RealtimeHandler) DepositCompletePage(c *gin.Context) {
+	c.Header("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(depositCompletePage))
+}
+
+// streamUpdates writes updates to the client as Server-Sent Events until it
+// goes away or expired fires
+func streamUpdates(c *gin.Context, updates <-chan dto.WalletUpdate, expired <-chan time.Time) {
+	c.Header("Cache-Control", "no-cache")
+	// Stop nginx and similar proxies buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+	for {
+		select {
+		case update := <-updates:
+			c.SSEvent(update.Event, update.Data)
+		case <-heartbeat.C:
+			if _, err := io.WriteString(c.Writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-expired:
+			c.SSEvent("expired", gin.H{"message": "Access token expired, reconnect with a fresh one"})
+			c.Writer.Flush()
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// writeStreamError maps errors opening an event stream to HTTP responses
+func writeStreamError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	case errors.Is(err, customErrors.ErrTooManyStreams):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to open event stream: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open event stream"})
+	}
+}
+
+// depositCompletePage listens to the deposit's event stream, which sits
+// next to it at /wallet/deposit/{reference}/events. The final status is
+// also put in body[data-status] for apps showing checkout in a web view.
+const depositCompletePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Argent deposit</title>
+<style>
+body { font-family: system-ui, sans-serif; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; color: #1f2933; }
+main { text-align: center; padding: 2rem; }
+h1 { font-size: 1.5rem; }
+</style>
+</head>
+<body data-status="pending">
+<main>
+<h1 id="title">Confirming your payment…</h1>
+<p id="detail">This page updates by itself, there is no need to refresh it.</p>
+</main>
+<script>
+(function () {
+  var messages = {
+    pending: ["Confirming your payment…", "This page updates by itself, there is no need to refresh it."],
+    success: ["Deposit complete", "The money is in your wallet. You can close this page."],
+    held: ["Payment received", "Your deposit is on hold until your account can receive money."],
+    failed: ["Payment failed", "Your wallet wasn't credited. You can close this page and try again."]
+  };
+  function show(status, amount) {
+    var message = messages[status] || messages.pending;
+    document.body.setAttribute("data-status", status);
+    document.getElementById("title").textContent = message[0];
+    document.getElementById("detail").textContent = message[1] + (status === "success" && amount ? " Amount: " + amount + "." : "");
+  }
+  var reference = new URLSearchParams(window.location.search).get("reference");
+  if (!reference) {
+    document.getElementById("title").textContent = "Deposit not found";
+    document.getElementById("detail").textContent = "";
+    return;
+  }
+  var events = new EventSource(encodeURIComponent(reference) + "/events");
+  events.addEventListener("deposit", function (event) {
+    var deposit = JSON.parse(event.data);
+    show(deposit.status, deposit.amount);
+    if (deposit.status !== "pending") {
+      events.close();
+    }
+  });
+  events.onerror = function () {
+    // The browser only gives up when the stream was refused, e.g. an unknown reference
+    if (events.readyState === EventSource.CLOSED) {
+      document.getElementById("title").textContent = "Deposit not found";
+      document.getElementById("detail").textContent = "";
+    }
+  };
+})();
+</script>
+</body>
+</html>
+`
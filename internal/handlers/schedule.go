@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ScheduleHandler struct {
+	scheduleService  services.ScheduleService
+	twoFactorService services.TwoFactorService
+	pinService       services.PINService
+}
+
+func NewScheduleHandler(scheduleService services.ScheduleService, twoFactorService services.TwoFactorService, pinService services.PINService) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduleService:  scheduleService,
+		twoFactorService: twoFactorService,
+		pinService:       pinService,
+	}
+}
+
+// CreateSchedule godoc
+// @Summary Schedule a transfer
+// @Description Pay a recipient once at start_at, or repeatedly: daily, weekly or monthly from start_at, or on a UTC cron expression such as "0 9 1 * *". The recipient is fixed to their account number when the schedule is created. Needs the transfer permission for API keys and the PIN for user sessions.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param request body dto.CreateScheduleRequest true "Schedule"
+// @Param X-Step-Up-Token header string false "Step-up token, required for user sessions above STEP_UP_TRANSFER_THRESHOLD when two-factor authentication is enabled"
+// @Success 201 {object} models.ScheduledTransfer "Schedule"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/schedules [post]
+func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
+	var req dto.CreateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if !authorizePayment(c, h.twoFactorService, h.pinService, req.Amount, req.PIN) {
+		return
+	}
+
+	schedule, err := h.scheduleService.Create(auditActor(c), c.GetString("mode"), req)
+	if err != nil {
+		writeScheduleError(c, err, "Failed to create schedule")
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// ListSchedules godoc
+// @Summary List scheduled transfers
+// @Description List the user's scheduled transfers in the current mode, newest first
+// @Tags schedules
+// @Produce json
+// @Success 200 {array} models.ScheduledTransfer "Schedules"
+// @Failure 500 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/schedules [get]
+func (h *ScheduleHandler) ListSchedules(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	schedules, err := h.scheduleService.List(userID, c.GetString("mode"))
+	if err != nil {
+		writeScheduleError(c, err, "Failed to list schedules")
+		return
+	}
+	if schedules == nil {
+		schedules = []models.ScheduledTransfer{}
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+// GetSchedule godoc
+// @Summary Get a scheduled transfer
+// @Description Get a schedule with its 50 most recent runs
+// @Tags schedules
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} map[string]interface{} "schedule and runs"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/schedules/{id} [get]
+func (h *ScheduleHandler) GetSchedule(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	schedule, runs, err := h.scheduleService.Get(userID, id)
+	if err != nil {
+		writeScheduleError(c, err, "Failed to get schedule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedule": schedule, "runs": runs})
+}
+
+// PauseSchedule godoc
+// @Summary Pause a scheduled transfer
+// @Description Stop an active schedule from running until it is resumed
+// @Tags schedules
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} map[string]string "message"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/schedules/{id}/pause [post]
+func (h *ScheduleHandler) PauseSchedule(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.scheduleService.Pause(auditActor(c), id); err != nil {
+		writeScheduleError(c, err, "Failed to pause schedule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule paused"})
+}
+
+// ResumeSchedule godoc
+// @Summary Resume a scheduled transfer
+// @Description Restart a paused schedule from its next occurrence. Occurrences missed while paused are not paid; a one-off transfer whose date has passed runs straight away.
+// @Tags schedules
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} map[string]string "message"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/schedules/{id}/resume [post]
+func (h *ScheduleHandler) ResumeSchedule(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.scheduleService.Resume(auditActor(c), id); err != nil {
+		writeScheduleError(c, err, "Failed to resume schedule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule resumed"})
+}
+
+// CancelSchedule godoc
+// @Summary Cancel a scheduled transfer
+// @Description Stop a schedule for good. Runs already made are kept.
+// @Tags schedules
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} map[string]string "message"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/schedules/{id}/cancel [post]
+func (h *ScheduleHandler) CancelSchedule(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.scheduleService.Cancel(auditActor(c), id); err != nil {
+		writeScheduleError(c, err, "Failed to cancel schedule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule cancelled"})
+}
+
+// writeScheduleError maps scheduled transfer errors to HTTP responses
+func writeScheduleError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+	case errors.Is(err, customErrors.ErrRecipientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "error_code": "recipient_not_found"})
+	case errors.Is(err, customErrors.ErrInvalidAmount),
+		errors.Is(err, customErrors.ErrInvalidRecurrence),
+		errors.Is(err, customErrors.ErrInvalidScheduleTime),
+		errors.Is(err, customErrors.ErrInvalidOnFailure),
+		errors.Is(err, customErrors.ErrInvalidMaxRuns),
+		errors.Is(err, customErrors.ErrCannotPaySelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrScheduleNotActive),
+		errors.Is(err, customErrors.ErrScheduleNotPaused),
+		errors.Is(err, customErrors.ErrScheduleEnded),
+		errors.Is(err, customErrors.ErrTooManySchedules):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
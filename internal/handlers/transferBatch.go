@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// batchFileMax caps an uploaded CSV
+const batchFileMax = 2 << 20
+
+type TransferBatchHandler struct {
+	batchService     services.TransferBatchService
+	twoFactorService services.TwoFactorService
+	pinService       services.PINService
+}
+
+func NewTransferBatchHandler(batchService services.TransferBatchService, twoFactorService services.TwoFactorService, pinService services.PINService) *TransferBatchHandler {
+	return &TransferBatchHandler{
+		batchService:     batchService,
+		twoFactorService: twoFactorService,
+		pinService:       pinService,
+	}
+}
+
+// SubmitBatch godoc
+// @Summary Submit a batch transfer
+// @Description Pay up to BATCH_MAX_ITEMS recipients (account number, @handle or verified email) from the wallet, as JSON or as a multipart upload of a CSV "file" with recipient, amount and optional narration columns plus "policy" and "pin" form fields. Every item is checked first; if any can't be paid the batch is refused with the reasons by line. Accepted batches are paid in the background: best_effort (default) pays what it can, all_or_nothing pays everything or nothing and needs the balance to cover the batch up front. Confirmed like a transfer of the batch total.
+// @Tags transfers
+// @Accept json,mpfd
+// @Produce json
+// @Param request body dto.SubmitBatchRequest false "Batch, when sent as JSON"
+// @Param file formData file false "CSV with recipient, amount and optional narration columns"
+// @Param policy formData string false "best_effort or all_or_nothing, with a CSV"
+// @Param pin formData string false "Transaction PIN, with a CSV"
+// @Param X-Step-Up-Token header string false "Step-up token, required for user sessions when the batch total is above STEP_UP_TRANSFER_THRESHOLD and two-factor authentication is enabled"
+// @Success 202 {object} models.TransferBatch "Queued batch"
+// @Failure 400 {object} map[string]interface{} "error, and items with the reason each was refused"
+// @Failure 403 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/transfers/batch [post]
+func (h *TransferBatchHandler) SubmitBatch(c *gin.Context) {
+	var req dto.SubmitBatchRequest
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		items, err := readBatchFile(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req = dto.SubmitBatchRequest{
+			Policy: c.PostForm("policy"),
+			Items:  items,
+			PIN:    c.PostForm("pin"),
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	var total float64
+	for _, item := range req.Items {
+		total += item.Amount
+	}
+	if !authorizePayment(c, h.twoFactorService, h.pinService, total, req.PIN) {
+		return
+	}
+
+	batch, itemErrors, err := h.batchService.Submit(auditActor(c), c.GetString("mode"), req)
+	if errors.Is(err, customErrors.ErrInvalidBatchItems) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "items": itemErrors})
+		return
+	}
+	if err != nil {
+		writeBatchError(c, err, "Failed to submit batch")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, batch)
+}
+
+// ListBatches godoc
+// @Summary List batch transfers
+// @Description List the user's batch transfers in the current mode, newest first
+// @Tags transfers
+// @Produce json
+// @Param limit query int false "Page size, 1-200 (default 50)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} map[string]interface{} "batches and total"
+// @Failure 400 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/transfers/batch [get]
+func (h *TransferBatchHandler) ListBatches(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	batches, total, err := h.batchService.List(userID, c.GetString("mode"), limit, offset)
+	if err != nil {
+		writeBatchError(c, err, "Failed to list batches")
+		return
+	}
+	if batches == nil {
+		batches = []models.TransferBatch{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"batches": batches, "total": total})
+}
+
+// GetBatch godoc
+// @Summary Get a batch transfer
+// @Description Get a batch transfer with the status of every item
+// @Tags transfers
+// @Produce json
+// @Param id path string true "Batch ID"
+// @Success 200 {object} map[string]interface{} "batch and items"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/transfers/batch/{id} [get]
+func (h *TransferBatchHandler) GetBatch(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	batch, items, err := h.batchService.Get(userID, c.GetString("mode"), id)
+	if err != nil {
+		writeBatchError(c, err, "Failed to get batch")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"batch": batch, "items": items})
+}
+
+// GetBatchResult godoc
+// @Summary Download a batch transfer's results
+// @Description Download the items of a batch transfer, with their status, fee, reference and any error, as CSV
+// @Tags transfers
+// @Produce text/csv
+// @Param id path string true "Batch ID"
+// @Success 200 {file} file "CSV"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/transfers/batch/{id}/result [get]
+func (h *TransferBatchHandler) GetBatchResult(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	batch, items, err := h.batchService.Get(userID, c.GetString("mode"), id)
+	if err != nil {
+		writeBatchError(c, err, "Failed to get batch")
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=batch_%s.csv", batch.ID))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"line", "recipient", "account_number", "recipient_name", "amount", "fee", "narration", "status", "reference", "error"})
+	for _, item := range items {
+		w.Write([]string{
+			strconv.Itoa(item.Line),
+			csvText(item.Recipient),
+			item.AccountNumber,
+			csvText(item.RecipientName),
+			strconv.FormatFloat(item.Amount, 'f', 2, 64),
+			strconv.FormatFloat(item.Fee, 'f', 2, 64),
+			csvText(item.Narration),
+			item.Status,
+			item.Reference,
+			csvText(item.Error),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Println("Failed to write batch result:", err)
+	}
+}
+
+// csvText stops a value someone else typed from being read as a formula when
+// the file is opened in a spreadsheet, by prefixing cells that start with a
+// formula character with a quote
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// readBatchFile reads items from an uploaded CSV. The header row names the
+// recipient, amount and optional narration columns; any others are ignored.
+func readBatchFile(c *gin.Context) ([]dto.BatchTransferItem, error) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		return nil, errors.New("file is required")
+	}
+	defer file.Close()
+	if header.Size > batchFileMax {
+		return nil, errors.New("file is too large")
+	}
+
+	r := csv.NewReader(io.LimitReader(file, batchFileMax))
+	r.TrimLeadingSpace = true
+	columns, err := r.Read()
+	if err != nil {
+		return nil, errors.New("file must be CSV with a header row")
+	}
+	recipientCol, amountCol, narrationCol := -1, -1, -1
+	for i, name := range columns {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) {
+		case "recipient":
+			recipientCol = i
+		case "amount":
+			amountCol = i
+		case "narration":
+			narrationCol = i
+		}
+	}
+	if recipientCol < 0 || amountCol < 0 {
+		return nil, errors.New("file needs recipient and amount columns")
+	}
+
+	var items []dto.BatchTransferItem
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		amount, err := strconv.ParseFloat(strings.TrimSpace(record[amountCol]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount", line)
+		}
+		item := dto.BatchTransferItem{
+			Recipient: strings.TrimSpace(record[recipientCol]),
+			Amount:    amount,
+		}
+		if narrationCol >= 0 {
+			item.Narration = record[narrationCol]
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// writeBatchError maps batch transfer errors to HTTP responses
+func writeBatchError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Batch not found"})
+	case errors.Is(err, customErrors.ErrDebitsBlocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "error_code": "account_frozen"})
+	case errors.Is(err, customErrors.ErrInvalidBatchPolicy),
+		errors.Is(err, customErrors.ErrEmptyBatch),
+		errors.Is(err, customErrors.ErrBatchTooLarge),
+		errors.Is(err, customErrors.ErrInsufficientBalance):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// HeaderStepUpToken carries the step-up token for sensitive actions
+const HeaderStepUpToken = "X-Step-Up-Token"
+
+type TwoFactorHandler struct {
+	twoFactorService services.TwoFactorService
+}
+
+func NewTwoFactorHandler(twoFactorService services.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+	}
+}
+
+// Status godoc
+// @Summary Two-factor status
+// @Description Whether TOTP two-factor authentication is enabled, and how many recovery codes are left
+// @Tags two-factor
+// @Produce json
+// @Success 200 {object} dto.TwoFactorStatusResponse "Status"
+// @Failure 500 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /auth/2fa [get]
+func (h *TwoFactorHandler) Status(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	status, err := h.twoFactorService.Status(userID)
+	if err != nil {
+		writeTwoFactorError(c, err, "Failed to load two-factor status")
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Enroll godoc
+// @Summary Start TOTP enrollment
+// @Description Generate a TOTP secret to add to an authenticator app. Two-factor authentication is enabled once a code is confirmed.
+// @Tags two-factor
+// @Produce json
+// @Success 200 {object} dto.TOTPEnrollResponse "Secret and otpauth URL"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /auth/2fa/enroll [post]
+func (h *TwoFactorHandler) Enroll(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	response, err := h.twoFactorService.Enroll(userID)
+	if err != nil {
+		writeTwoFactorError(c, err, "Failed to start two-factor enrollment")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Confirm godoc
+// @Summary Confirm TOTP enrollment
+// @Description Enable two-factor authentication with a code from the authenticator app. Returns recovery codes, which are only shown once.
+// @Tags two-factor
+// @Accept json
+// @Produce json
+// @Param request body dto.TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} dto.RecoveryCodesResponse "Recovery codes"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 401 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Failure 429 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /auth/2fa/confirm [post]
+func (h *TwoFactorHandler) Confirm(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	codes, err := h.twoFactorService.Confirm(auditActor(c), req.Code)
+	if err != nil {
+		writeTwoFactorError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable godoc
+// @Summary Disable two-factor authentication
+// @Description Turn off TOTP and delete recovery codes. Needs a TOTP or recovery code.
+// @Tags two-factor
+// @Accept json
+// @Produce json
+// @Param request body dto.TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} map[string]string "message"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 401 {object} map[string]string "error"
+// @Failure 429 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /auth/2fa/disable [post]
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := h.twoFactorService.Disable(auditActor(c), req.Code); err != nil {
+		writeTwoFactorError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes. Needs a TOTP or recovery code.
+// @Tags two-factor
+// @Accept json
+// @Produce json
+// @Param request body dto.TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} dto.RecoveryCodesResponse "Recovery codes"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 401 {object} map[string]string "error"
+// @Failure 429 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /auth/2fa/recovery-codes [post]
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		writeTwoFactorError(c, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// StepUp godoc
+// @Summary Step up for a sensitive action
+// @Description Exchange a TOTP or recovery code for a short-lived step-up token. Send it in the X-Step-Up-Token header with large transfers and API key creation or rollover.
+// @Tags two-factor
+// @Accept json
+// @Produce json
+// @Param request body dto.TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} dto.StepUpResponse "Step-up token"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 401 {object} map[string]string "error"
+// @Failure 429 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /auth/step-up [post]
+func (h *TwoFactorHandler) StepUp(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	response, err := h.twoFactorService.StepUp(userID, req.Code)
+	if err != nil {
+		writeTwoFactorError(c, err, "Failed to verify two-factor code")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// stepUpVerified enforces step-up on user sessions and writes the error
+// response when it is missing. API keys are exempt: they are already limited
+// by permissions, IP allowlists and request signing.
+func stepUpVerified(c *gin.Context, twoFactorService services.TwoFactorService) bool {
+	if _, ok := c.Get("access_token"); !ok {
+		return true
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+	if err := twoFactorService.VerifyStepUp(userID, c.GetHeader(HeaderStepUpToken)); err != nil {
+		writeTwoFactorError(c, err, "Failed to verify step-up")
+		return false
+	}
+	return true
+}
+
+// authorizePayment confirms anything that moves or sets aside money the way
+// a transfer is confirmed: step-up above STEP_UP_TRANSFER_THRESHOLD, then
+// the transaction PIN for user sessions. API keys act for services, so they
+// skip the PIN and rely on the transfer permission instead. It writes the
+// error response when either check fails.
+func authorizePayment(c *gin.Context, twoFactorService services.TwoFactorService, pinService services.PINService, amount float64, pin string) bool {
+	if twoFactorService.TransferNeedsStepUp(amount) && !stepUpVerified(c, twoFactorService) {
+		return false
+	}
+	if _, ok := c.Get("access_token"); ok {
+		if err := pinService.VerifyPIN(auditActor(c), pin); err != nil {
+			writePINError(c, err, "Failed to verify PIN")
+			return false
+		}
+	}
+	return true
+}
+
+// writeTwoFactorError maps two-factor errors to HTTP responses
+func writeTwoFactorError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, customErrors.ErrTwoFactorUnavailable),
+		errors.Is(err, customErrors.ErrTwoFactorNotEnabled),
+		errors.Is(err, customErrors.ErrTwoFactorNotPending):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrTwoFactorAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrTwoFactorLocked):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrStepUpRequired),
+		errors.Is(err, customErrors.ErrInvalidStepUpToken),
+		errors.Is(err, customErrors.ErrTwoFactorNotEnrolled):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":      err.Error(),
+			"error_code": "step_up_required",
+		})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"whotterre/argent/internal/config"
+	"whotterre/argent/internal/customErrors"
 	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
 	"whotterre/argent/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -11,12 +17,22 @@ import (
 )
 
 type WalletHandler struct {
-	walletService services.WalletService
+	walletService    services.WalletService
+	feeService       services.FeeService
+	twoFactorService services.TwoFactorService
+	pinService       services.PINService
+	auditService     services.AuditService
+	config           config.Config
 }
 
-func NewWalletHandler(walletService services.WalletService) *WalletHandler {
+func NewWalletHandler(walletService services.WalletService, feeService services.FeeService, twoFactorService services.TwoFactorService, pinService services.PINService, auditService services.AuditService, cfg config.Config) *WalletHandler {
 	return &WalletHandler{
-		walletService: walletService,
+		walletService:    walletService,
+		feeService:       feeService,
+		twoFactorService: twoFactorService,
+		pinService:       pinService,
+		auditService:     auditService,
+		config:           cfg,
 	}
 }
 
@@ -29,6 +45,7 @@ func NewWalletHandler(walletService services.WalletService) *WalletHandler {
 // @Param request body dto.DepositWalletRequest true "Deposit request"
 // @Success 200 {object} dto.DepositWalletResponse "Deposit response with reference and authorization URL"
 // @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
 // @Failure 500 {object} map[string]string "error"
 // @Security BearerAuth
 // @Router /wallet/deposit [post]
@@ -41,18 +58,25 @@ func (h *WalletHandler) Deposit(c *gin.Context) {
 
 	userID := c.MustGet("user_id").(uuid.UUID)
 
-	response, err := h.walletService.DepositWallet(req, userID)
+	mode := c.GetString("mode")
+
+	response, err := h.walletService.DepositWallet(req, userID, mode)
+	if errors.Is(err, customErrors.ErrCreditsBlocked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "error_code": "account_frozen"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	h.auditService.Record(auditActor(c), models.AuditDepositStarted, "transaction", response.Reference, nil, gin.H{"amount": req.Amount, "mode": mode})
 
 	c.JSON(http.StatusOK, response)
 }
 
 // GetBalance godoc
 // @Summary Get wallet balance
-// @Description Retrieve the current balance of the user's wallet
+// @Description Retrieve the current balance and account number of the user's wallet
 // @Tags wallet
 // @Accept json
 // @Produce json
@@ -63,24 +87,31 @@ func (h *WalletHandler) Deposit(c *gin.Context) {
 func (h *WalletHandler) GetBalance(c *gin.Context) {
 	userID := c.MustGet("user_id").(uuid.UUID)
 
-	balance, err := h.walletService.GetBalance(userID)
+	mode := c.GetString("mode")
+
+	balance, err := h.walletService.GetBalance(userID, mode)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, dto.BalanceResponse{Balance: balance})
+	c.JSON(http.StatusOK, balance)
 }
 
 // Transfer godoc
 // @Summary Transfer money to another wallet
-// @Description Transfer money from user's wallet to another wallet by wallet number
+// @Description Transfer money to another user's wallet in the same mode. The recipient is a 10-digit account number, an @handle or a verified email; check it first with GET /wallet/resolve. A narration is shown to both parties; metadata and client_reference are only shown to the sender, and a client_reference can be used once per sender. JWT-authenticated transfers must include the user's transaction PIN.
 // @Tags wallet
 // @Accept json
 // @Produce json
 // @Param request body dto.TransferRequest true "Transfer request"
+// @Param X-Step-Up-Token header string false "Step-up token, required for user sessions above STEP_UP_TRANSFER_THRESHOLD when two-factor authentication is enabled"
 // @Success 200 {object} dto.TransferResponse "Transfer response"
 // @Failure 400 {object} map[string]string "error"
+// @Failure 403 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error, and the reference of the transfer that used the client_reference"
+// @Failure 429 {object} map[string]string "error"
 // @Failure 500 {object} map[string]string "error"
 // @Security BearerAuth
 // @Router /wallet/transfer [post]
@@ -91,23 +122,186 @@ func (h *WalletHandler) Transfer(c *gin.Context) {
 		return
 	}
 
+	if !authorizePayment(c, h.twoFactorService, h.pinService, req.Amount, req.PIN) {
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	mode := c.GetString("mode")
+
+	recipient := req.Recipient
+	if recipient == "" {
+		recipient = req.WalletNumber
+	}
+
+	transaction, err := h.walletService.Transfer(userID, recipient, req.Amount, mode, "", dto.TransferDetails{
+		Narration:       req.Narration,
+		Metadata:        req.Metadata,
+		ClientReference: req.ClientReference,
+	})
+	if errors.Is(err, customErrors.ErrDuplicateClientReference) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "reference": transaction.Reference})
+		return
+	}
+	if errors.Is(err, customErrors.ErrDebitsBlocked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "error_code": "account_frozen"})
+		return
+	}
+	if errors.Is(err, customErrors.ErrRecipientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "error_code": "recipient_not_found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.auditService.Record(auditActor(c), models.AuditTransfer, "transaction", transaction.Reference, nil, gin.H{
+		"amount":           transaction.Amount,
+		"fee":              transaction.Fee,
+		"receiver_id":      transaction.ReceiverID,
+		"mode":             transaction.Mode,
+		"client_reference": req.ClientReference,
+	})
+
+	c.JSON(http.StatusOK, dto.TransferResponse{
+		Status:          "success",
+		Message:         "Transfer completed",
+		Reference:       transaction.Reference,
+		ClientReference: req.ClientReference,
+	})
+}
+
+// ResolveRecipient godoc
+// @Summary Look up a transfer recipient
+// @Description Show the masked name, account number and handle a recipient refers to, so the sender can confirm it before paying
+// @Tags wallet
+// @Produce json
+// @Param recipient query string true "Account number, @handle or verified email"
+// @Success 200 {object} dto.ResolveRecipientResponse "Recipient"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/resolve [get]
+func (h *WalletHandler) ResolveRecipient(c *gin.Context) {
+	recipient := c.Query("recipient")
+	if recipient == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "recipient is required"})
+		return
+	}
+
+	mode := c.GetString("mode")
+
+	resolved, err := h.walletService.ResolveRecipient(recipient, mode)
+	if errors.Is(err, customErrors.ErrRecipientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "error_code": "recipient_not_found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to resolve recipient: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve recipient"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resolved)
+}
+
+// Quote godoc
+// @Summary Preview a fee
+// @Description Show the fee for a transfer or deposit of the given amount. Transfer fees are paid on top of the amount; deposit fees are taken out of it.
+// @Tags wallet
+// @Produce json
+// @Param type query string true "transfer or deposit"
+// @Param amount query number true "Amount in naira"
+// @Success 200 {object} dto.FeeQuote "Fee quote"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 500 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/quote [get]
+func (h *WalletHandler) Quote(c *gin.Context) {
+	amount, err := strconv.ParseFloat(c.Query("amount"), 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be a number"})
+		return
+	}
+
 	userID := c.MustGet("user_id").(uuid.UUID)
 
-	err := h.walletService.Transfer(userID, req.WalletNumber, req.Amount)
+	quote, err := h.feeService.Quote(userID, c.Query("type"), amount)
+	if errors.Is(err, customErrors.ErrInvalidFeeType) || errors.Is(err, customErrors.ErrInvalidAmount) || errors.Is(err, customErrors.ErrFeeExceedsAmount) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
+		log.Printf("Failed to quote fee: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to quote fee"})
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
+
+// SetHandle godoc
+// @Summary Claim a handle
+// @Description Set the @handle others can send money to, replacing any previous one. Handles are 3-20 lowercase letters, digits or underscores, starting with a letter.
+// @Tags wallet
+// @Accept json
+// @Produce json
+// @Param request body dto.SetHandleRequest true "Handle, with or without the @"
+// @Success 200 {object} map[string]string "handle"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /wallet/handle [put]
+func (h *WalletHandler) SetHandle(c *gin.Context) {
+	var req dto.SetHandleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	handle, err := h.walletService.SetHandle(auditActor(c), req.Handle)
+	switch {
+	case errors.Is(err, customErrors.ErrInvalidHandle):
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
+	case errors.Is(err, customErrors.ErrHandleTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Failed to set handle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set handle"})
+		return
 	}
 
-	c.JSON(http.StatusOK, dto.TransferResponse{Status: "success", Message: "Transfer completed"})
+	c.JSON(http.StatusOK, gin.H{"handle": "@" + handle})
+}
+
+// ClearHandle godoc
+// @Summary Remove your handle
+// @Description Free the user's @handle. Account number and email transfers keep working.
+// @Tags wallet
+// @Produce json
+// @Success 200 {object} map[string]string "message"
+// @Security BearerAuth
+// @Router /wallet/handle [delete]
+func (h *WalletHandler) ClearHandle(c *gin.Context) {
+	if err := h.walletService.ClearHandle(auditActor(c)); err != nil {
+		log.Printf("Failed to clear handle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove handle"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Handle removed"})
 }
 
 // GetTransactions godoc
 // @Summary Get transaction history
-// @Description Retrieve the user's transaction history
+// @Description Retrieve the user's transaction history, or with client_reference the transfer the user tagged with it. Metadata and client references are only shown on transfers the user sent.
 // @Tags wallet
 // @Accept json
 // @Produce json
+// @Param client_reference query string false "The user's own reference for a transfer they sent"
 // @Success 200 {array} dto.TransactionResponse "Array of transaction responses"
 // @Failure 500 {object} map[string]string "error"
 // @Security BearerAuth
@@ -115,7 +309,9 @@ func (h *WalletHandler) Transfer(c *gin.Context) {
 func (h *WalletHandler) GetTransactions(c *gin.Context) {
 	userID := c.MustGet("user_id").(uuid.UUID)
 
-	transactions, err := h.walletService.GetTransactions(userID)
+	mode := c.GetString("mode")
+
+	transactions, err := h.walletService.GetTransactions(userID, mode, c.Query("client_reference"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -123,11 +319,22 @@ func (h *WalletHandler) GetTransactions(c *gin.Context) {
 
 	var response []dto.TransactionResponse
 	for _, t := range transactions {
-		response = append(response, dto.TransactionResponse{
-			Type:   t.Type,
-			Amount: t.Amount,
-			Status: t.Status,
-		})
+		item := dto.TransactionResponse{
+			Type:      t.Type,
+			Amount:    t.Amount,
+			Fee:       t.Fee,
+			Status:    t.Status,
+			Reference: t.Reference,
+			Narration: t.Narration,
+		}
+		// Metadata and client references are the sender's own
+		if t.SenderID != nil && *t.SenderID == userID {
+			item.Metadata = t.Metadata
+			if t.ClientReference != nil {
+				item.ClientReference = *t.ClientReference
+			}
+		}
+		response = append(response, item)
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -174,7 +381,20 @@ func (h *WalletHandler) Webhook(c *gin.Context) {
 
 	log.Printf("Webhook payload length: %d", len(payload))
 
-	err := h.walletService.ProcessWebhook(payload, signature)
+	record, err := h.walletService.ProcessWebhook(payload, signature)
+	// Anyone can post here, so only authentic webhooks go in the audit log
+	if record == nil {
+		log.Printf("SECURITY: rejected Paystack webhook from %s with an invalid signature (%d bytes)", c.ClientIP(), len(payload))
+	} else {
+		actor := auditActor(c)
+		actor.AuthMethod = models.AuthMethodWebhook
+		h.auditService.Record(actor, models.AuditWebhookReceived, "webhook_event", record.ID.String(), nil, gin.H{
+			"event":     record.Event,
+			"reference": record.Reference,
+			"mode":      record.Mode,
+			"status":    record.Status,
+		})
+	}
 	if err != nil {
 		log.Printf("Webhook processing failed: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -187,12 +407,13 @@ func (h *WalletHandler) Webhook(c *gin.Context) {
 
 // DepositCallback godoc
 // @Summary Handle deposit callback
-// @Description Handle callback from Paystack after deposit payment
+// @Description Handle callback from Paystack after deposit payment. Browsers (Accept: text/html) are redirected to a page that waits for the deposit to complete, DEPOSIT_COMPLETE_URL or the built-in /wallet/deposit/complete, with the reference in the query string. Other clients get the deposit's status.
 // @Tags wallet
 // @Accept json
 // @Produce json
 // @Param reference query string true "Transaction reference"
 // @Success 200 {object} dto.DepositStatusResponse "Deposit status response"
+// @Success 302 {string} string "Redirect to the deposit completion page"
 // @Failure 400 {object} map[string]string "error"
 // @Failure 404 {object} map[string]string "error"
 // @Router /wallet/deposit/callback [get]
@@ -209,5 +430,17 @@ func (h *WalletHandler) DepositCallback(c *gin.Context) {
 		return
 	}
 
+	// The payer's browser is sent to a page that listens for the deposit
+	// to complete, rather than shown a status that may still be pending
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		if target, err := url.Parse(h.config.DepositCompleteURL); err == nil {
+			query := target.Query()
+			query.Set("reference", reference)
+			target.RawQuery = query.Encode()
+			c.Redirect(http.StatusFound, target.String())
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, status)
 }
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebhookEndpointHandler struct {
+	webhookService services.WebhookService
+}
+
+func NewWebhookEndpointHandler(webhookService services.WebhookService) *WebhookEndpointHandler {
+	return &WebhookEndpointHandler{
+		webhookService: webhookService,
+	}
+}
+
+// CreateWebhookEndpoint godoc
+// @Summary Register a webhook endpoint
+// @Description Register an https URL to be sent the listed events in the current mode, such as transfer.received or deposit.succeeded, a family such as escrow.*, or * for everything. Deliveries are signed with the returned secret, which is not shown again: the Argent-Signature header is t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>">.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param request body dto.CreateWebhookEndpointRequest true "Endpoint"
+// @Success 201 {object} map[string]interface{} "endpoint and secret"
+// @Failure 400 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /webhooks/endpoints [post]
+func (h *WebhookEndpointHandler) CreateWebhookEndpoint(c *gin.Context) {
+	var req dto.CreateWebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	endpoint, secret, err := h.webhookService.CreateEndpoint(auditActor(c), c.GetString("mode"), req)
+	if err != nil {
+		writeWebhookError(c, err, "Failed to create webhook endpoint")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"endpoint": endpoint, "secret": secret})
+}
+
+// ListWebhookEndpoints godoc
+// @Summary List webhook endpoints
+// @Description List the user's webhook endpoints in the current mode
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} models.WebhookEndpoint "Endpoints"
+// @Security BearerAuth
+// @Router /webhooks/endpoints [get]
+func (h *WebhookEndpointHandler) ListWebhookEndpoints(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	endpoints, err := h.webhookService.ListEndpoints(userID, c.GetString("mode"))
+	if err != nil {
+		writeWebhookError(c, err, "Failed to list webhook endpoints")
+		return
+	}
+	if endpoints == nil {
+		endpoints = []models.WebhookEndpoint{}
+	}
+
+	c.JSON(http.StatusOK, endpoints)
+}
+
+// GetWebhookEndpoint godoc
+// @Summary Get a webhook endpoint
+// @Description Get one of the user's webhook endpoints
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Endpoint ID"
+// @Success 200 {object} models.WebhookEndpoint "Endpoint"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /webhooks/endpoints/{id} [get]
+func (h *WebhookEndpointHandler) GetWebhookEndpoint(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	endpoint, err := h.webhookService.GetEndpoint(userID, c.GetString("mode"), id)
+	if err != nil {
+		writeWebhookError(c, err, "Failed to get webhook endpoint")
+		return
+	}
+
+	c.JSON(http.StatusOK, endpoint)
+}
+
+// UpdateWebhookEndpoint godoc
+// @Summary Update a webhook endpoint
+// @Description Change an endpoint's URL, events or description, or disable it with active false. Fields left out are unchanged. Disabled endpoints aren't sent new events and their waiting deliveries fail.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Endpoint ID"
+// @Param request body dto.UpdateWebhookEndpointRequest true "Changes"
+// @Success 200 {object} models.WebhookEndpoint "Endpoint"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /webhooks/endpoints/{id} [put]
+func (h *WebhookEndpointHandler) UpdateWebhookEndpoint(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.UpdateWebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	endpoint, err := h.webhookService.UpdateEndpoint(auditActor(c), c.GetString("mode"), id, req)
+	if err != nil {
+		writeWebhookError(c, err, "Failed to update webhook endpoint")
+		return
+	}
+
+	c.JSON(http.StatusOK, endpoint)
+}
+
+// DeleteWebhookEndpoint godoc
+// @Summary Delete a webhook endpoint
+// @Description Delete an endpoint and its delivery log
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Endpoint ID"
+// @Success 200 {object} map[string]string "message"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /webhooks/endpoints/{id} [delete]
+func (h *WebhookEndpointHandler) DeleteWebhookEndpoint(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.webhookService.DeleteEndpoint(auditActor(c), c.GetString("mode"), id); err != nil {
+		writeWebhookError(c, err, "Failed to delete webhook endpoint")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook endpoint deleted"})
+}
+
+// RotateWebhookSecret godoc
+// @Summary Rotate a webhook endpoint's secret
+// @Description Replace the endpoint's signing secret. Every delivery from now on, including retries, is signed with the new secret, which is not shown again.
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Endpoint ID"
+// @Success 200 {object} map[string]interface{} "endpoint and secret"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /webhooks/endpoints/{id}/rotate-secret [post]
+func (h *WebhookEndpointHandler) RotateWebhookSecret(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+
+	endpoint, secret, err := h.webhookService.RotateSecret(auditActor(c), c.GetString("mode"), id)
+	if err != nil {
+		writeWebhookError(c, err, "Failed to rotate webhook secret")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"endpoint": endpoint, "secret": secret})
+}
+
+// PingWebhookEndpoint godoc
+// @Summary Send a test event
+// @Description Queue a ping event for the endpoint, to check it receives deliveries and verifies their signatures
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Endpoint ID"
+// @Success 202 {object} models.WebhookDelivery "Delivery"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /webhooks/endpoints/{id}/ping [post]
+func (h *WebhookEndpointHandler) PingWebhookEndpoint(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	delivery, err := h.webhookService.Ping(userID, c.GetString("mode"), id)
+	if err != nil {
+		writeWebhookError(c, err, "Failed to ping webhook endpoint")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// ListWebhookDeliveries godoc
+// @Summary List an endpoint's deliveries
+// @Description The delivery log of an endpoint, newest first: each event sent to it, the attempts made, the last status code, error and reply, and when the next attempt is due
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Endpoint ID"
+// @Param status query string false "pending, succeeded or failed"
+// @Param limit query int false "Page size, 1-200 (default 50)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} map[string]interface{} "deliveries and total"
+// @Failure 400 {object} map[string]string "error"
+// @Failure 404 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /webhooks/endpoints/{id}/deliveries [get]
+func (h *WebhookEndpointHandler) ListWebhookDeliveries(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	filter := dto.WebhookDeliveryFilter{
+		Status: c.Query("status"),
+		Limit:  limit,
+		Offset: offset,
+	}
+	switch filter.Status {
+	case "", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	deliveries, total, err := h.webhookService.ListDeliveries(userID, c.GetString("mode"), id, filter)
+	if err != nil {
+		writeWebhookError(c, err, "Failed to list webhook deliveries")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries, "total": total})
+}
+
+// RedeliverWebhook godoc
+// @Summary Redeliver an event
+// @Description Send a delivery again straight away, with a fresh set of attempts. The event keeps its id, so receivers can recognise it.
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Endpoint ID"
+// @Param delivery_id path string true "Delivery ID"
+// @Success 202 {object} models.WebhookDelivery "Delivery"
+// @Failure 404 {object} map[string]string "error"
+// @Failure 409 {object} map[string]string "error"
+// @Security BearerAuth
+// @Router /webhooks/endpoints/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *WebhookEndpointHandler) RedeliverWebhook(c *gin.Context) {
+	id, ok := pathUUID(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := pathUUID(c, "delivery_id")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(auditActor(c), c.GetString("mode"), id, deliveryID)
+	if err != nil {
+		writeWebhookError(c, err, "Failed to redeliver webhook")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// writeWebhookError maps webhook endpoint errors to HTTP responses
+func writeWebhookError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	case errors.Is(err, customErrors.ErrWebhookEndpointInactive),
+		errors.Is(err, customErrors.ErrDeliveryAlreadyScheduled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, customErrors.ErrInvalidWebhookURL),
+		errors.Is(err, customErrors.ErrInvalidWebhookEvents),
+		errors.Is(err, customErrors.ErrTooManyWebhookEndpoints),
+		errors.Is(err, customErrors.ErrDescriptionTooLong):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -3,6 +3,7 @@ package initializers
 import (
 	"log"
 	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/utils"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -17,9 +18,64 @@ func ConnectToDB(connString string) {
 		log.Fatal("Failed to connect to database")
 	}
 
-	if err := DB.AutoMigrate(&models.APIKey{}, &models.Transaction{}, &models.User{}, &models.Wallet{}); err != nil {
+	if err := DB.AutoMigrate(&models.APIKey{}, &models.Transaction{}, &models.User{}, &models.Wallet{}, &models.RequestNonce{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.SigningKey{}, &models.UserIdentity{}, &models.LoginCode{}, &models.EmailToken{}, &models.RecoveryCode{}, &models.WebhookEvent{}, &models.FreezeEvent{}, &models.AuditEvent{}, &models.FeeRule{}, &models.ScheduledTransfer{}, &models.ScheduledTransferRun{}, &models.PaymentRequest{}, &models.Hold{}, &models.Escrow{}, &models.TransferBatch{}, &models.TransferBatchItem{}, &models.OutboxEvent{}, &models.WebhookEndpoint{}, &models.WebhookDelivery{}, &models.DomainEvent{}); err != nil {
 		log.Fatal("Failed to migrate database")
 	}
+
+	// Google sign-ins used to be stored on users.google_id
+	if err := DB.Exec(`INSERT INTO user_identities (user_id, provider, subject, email)
+		SELECT id, 'google', google_id, email FROM users WHERE google_id IS NOT NULL AND google_id <> ''
+		ON CONFLICT DO NOTHING`).Error; err != nil {
+		log.Fatal("Failed to backfill user identities")
+	}
+
+	// Disabled users used to be marked with users.is_active
+	if DB.Migrator().HasColumn(&models.User{}, "is_active") {
+		if err := DB.Exec(`UPDATE users SET status = ?, status_reason = ? WHERE is_active = false`, models.AccountFrozen, models.FreezeReasonOther).Error; err != nil {
+			log.Fatal("Failed to migrate user statuses")
+		}
+		if err := DB.Migrator().DropColumn(&models.User{}, "is_active"); err != nil {
+			log.Fatal("Failed to drop users.is_active")
+		}
+	}
+	// Wallets created before account numbers existed get one now
+	var walletIDs []string
+	if err := DB.Model(&models.Wallet{}).Where("account_number IS NULL OR account_number = ''").Pluck("id", &walletIDs).Error; err != nil {
+		log.Fatal("Failed to find wallets without account numbers")
+	}
+	for _, id := range walletIDs {
+		// A clash with an existing number fails on the unique index, so try again
+		for attempt := 0; ; attempt++ {
+			err := DB.Model(&models.Wallet{}).Where("id = ?", id).Update("account_number", utils.GenAccountNumber()).Error
+			if err == nil {
+				break
+			}
+			if attempt == 4 {
+				log.Fatal("Failed to assign wallet account numbers")
+			}
+		}
+	}
+
+	// The audit log is append-only, even for the application's own user
+	if err := DB.Exec(`CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_events is append-only';
+		END;
+		$$ LANGUAGE plpgsql`).Error; err != nil {
+		log.Fatal("Failed to create audit log guard")
+	}
+	for _, trigger := range []string{
+		`DROP TRIGGER IF EXISTS audit_events_no_change ON audit_events`,
+		`CREATE TRIGGER audit_events_no_change BEFORE UPDATE OR DELETE ON audit_events
+			FOR EACH ROW EXECUTE FUNCTION audit_events_append_only()`,
+		`DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events`,
+		`CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+			FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only()`,
+	} {
+		if err := DB.Exec(trigger).Error; err != nil {
+			log.Fatal("Failed to protect audit_events")
+		}
+	}
 	log.Println("Connected successfully to PostgreSQL database")
 }
 
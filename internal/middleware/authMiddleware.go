@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/models"
 	"whotterre/argent/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -11,11 +14,18 @@ import (
 
 func RequireAuth(authService services.AuthService, apiKeyService services.APIKeyService, requiredPermission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Already authenticated by RequireSignature
+		if _, ok := c.Get("user_id"); ok {
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		apiKeyHeader := c.GetHeader("x-api-key")
 
 		var userID uuid.UUID
-		var err error
+		// Browser sessions always operate on live data; API keys carry their own mode
+		mode := models.ModeLive
 
 		if authHeader != "" {
 			// JWT auth
@@ -27,12 +37,22 @@ func RequireAuth(authService services.AuthService, apiKeyService services.APIKey
 
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-			userID, err = authService.GetUserIDFromJWT(tokenString)
+			accessToken, err := authService.ValidateAccessToken(tokenString)
+			if errors.Is(err, customErrors.ErrAccountDisabled) {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
 			if err != nil {
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 				c.Abort()
 				return
 			}
+
+			userID = accessToken.UserID
+			// Needed to revoke the token on logout
+			c.Set("access_token", accessToken)
+			c.Set("auth_method", models.AuthMethodJWT)
 		} else if apiKeyHeader != "" {
 			// API key auth
 			if requiredPermission == "" {
@@ -41,7 +61,17 @@ func RequireAuth(authService services.AuthService, apiKeyService services.APIKey
 				return
 			}
 
-			apiKey, err := apiKeyService.ValidateAPIKey(apiKeyHeader, uuid.Nil, requiredPermission)
+			apiKey, err := apiKeyService.ValidateAPIKey(apiKeyHeader, uuid.Nil, requiredPermission, c.ClientIP())
+			if errors.Is(err, customErrors.ErrIPNotAllowed) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "API key not allowed from this IP address"})
+				c.Abort()
+				return
+			}
+			if errors.Is(err, customErrors.ErrSignatureRequired) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "This API key requires signed requests"})
+				c.Abort()
+				return
+			}
 			if err != nil {
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key or insufficient permissions"})
 				c.Abort()
@@ -49,6 +79,9 @@ func RequireAuth(authService services.AuthService, apiKeyService services.APIKey
 			}
 
 			userID = apiKey.UserID
+			mode = apiKey.Mode
+			c.Set("api_key_id", apiKey.ID)
+			c.Set("auth_method", models.AuthMethodAPIKey)
 		} else {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header or x-api-key required"})
 			c.Abort()
@@ -57,6 +90,7 @@ func RequireAuth(authService services.AuthService, apiKeyService services.APIKey
 
 		// Set user ID in context
 		c.Set("user_id", userID)
+		c.Set("mode", mode)
 		c.Next()
 	}
 }
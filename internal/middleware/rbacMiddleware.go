@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"whotterre/argent/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequirePermission lets through signed-in users whose role grants the
+// permission. It must run after RequireAuth. API keys never carry staff
+// permissions, so only JWT sessions qualify.
+func RequirePermission(adminService services.AdminService, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("access_token"); !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin endpoints need a signed-in user"})
+			c.Abort()
+			return
+		}
+
+		userID := c.MustGet("user_id").(uuid.UUID)
+		allowed, err := adminService.HasPermission(userID, permission)
+		if err != nil {
+			log.Printf("Failed to check permission %s for user %s: %v", permission, userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			c.Abort()
+			return
+		}
+		if !allowed {
+			log.Printf("SECURITY: user %s denied %s on %s %s", userID, permission, c.Request.Method, c.FullPath())
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to do this"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
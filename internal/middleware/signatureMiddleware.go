@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/services"
+	"whotterre/argent/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers carried by signed requests
+const (
+	HeaderKeyID     = "X-Argent-Key-Id"
+	HeaderTimestamp = "X-Argent-Timestamp"
+	HeaderNonce     = "X-Argent-Nonce"
+	HeaderSignature = "X-Argent-Signature"
+)
+
+// RequireSignature authenticates requests signed with an API key secret.
+// Requests without a signature header are passed on untouched so that
+// RequireAuth can handle JWT and plain x-api-key auth after it.
+func RequireSignature(apiKeyService services.APIKeyService, requiredPermission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		signature := c.GetHeader(HeaderSignature)
+		if signature == "" {
+			c.Next()
+			return
+		}
+
+		if requiredPermission == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "API key not allowed for this endpoint"})
+			c.Abort()
+			return
+		}
+
+		keyID := c.GetHeader(HeaderKeyID)
+		timestamp := c.GetHeader(HeaderTimestamp)
+		nonce := c.GetHeader(HeaderNonce)
+		if keyID == "" || timestamp == "" || nonce == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Signed requests require key id, timestamp, nonce and signature headers"})
+			c.Abort()
+			return
+		}
+
+		// Read the body for hashing and put it back for the handler
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		apiKey, err := apiKeyService.ValidateSignedRequest(dto.SignedRequest{
+			KeyID:     keyID,
+			Timestamp: timestamp,
+			Nonce:     nonce,
+			Signature: signature,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.RequestURI(),
+			BodyHash:  utils.HashBody(body),
+		}, requiredPermission, c.ClientIP())
+		if err != nil {
+			log.Printf("Signed request rejected: %v", err)
+			switch {
+			case errors.Is(err, customErrors.ErrIPNotAllowed):
+				c.JSON(http.StatusForbidden, gin.H{"error": "API key not allowed from this IP address"})
+			case errors.Is(err, customErrors.ErrAccountDisabled):
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			case errors.Is(err, customErrors.ErrStaleTimestamp),
+				errors.Is(err, customErrors.ErrReplayedNonce),
+				errors.Is(err, customErrors.ErrSigningUnavailable):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature or insufficient permissions"})
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", apiKey.UserID)
+		c.Set("mode", apiKey.Mode)
+		c.Set("api_key_id", apiKey.ID)
+		c.Set("auth_method", models.AuthMethodSignedRequest)
+		c.Next()
+	}
+}
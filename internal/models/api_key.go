@@ -8,15 +8,22 @@ import (
 )
 
 type APIKey struct {
-	ID          uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	UserID      uuid.UUID      `gorm:"type:uuid;not null" json:"user_id"`
-	User        User           `gorm:"foreignKey:UserID;references:ID" json:"user"`
-	Name        string         `gorm:"not null" json:"name"`
-	HashedKey   string         `gorm:"not null" json:"-"`
-	Permissions pq.StringArray `gorm:"type:text[];not null" json:"permissions"`
-	ExpiresAt   time.Time      `gorm:"not null" json:"expires_at"`
-	IsRevoked   bool           `gorm:"default:false" json:"is_revoked"`
-	CreatedAt   time.Time      `gorm:"default:now()" json:"created_at"`
+	ID                  uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID              uuid.UUID      `gorm:"type:uuid;not null" json:"user_id"`
+	User                User           `gorm:"foreignKey:UserID;references:ID" json:"user"`
+	Name                string         `gorm:"not null" json:"name"`
+	HashedKey           string         `gorm:"not null" json:"-"`
+	LookupHash          *string        `gorm:"uniqueIndex" json:"-"` // SHA-256 of the key; keys issued before it was stored have none and are rejected
+	Permissions         pq.StringArray `gorm:"type:text[];not null" json:"permissions"`
+	AllowedIPs          pq.StringArray `gorm:"type:text[]" json:"allowed_ips"`    // CIDRs; empty means any IP
+	Mode                string         `gorm:"not null;default:live" json:"mode"` // "live|test"
+	ExpiresAt           *time.Time     `json:"expires_at"`                        // nil means the key never expires
+	IsRevoked           bool           `gorm:"default:false" json:"is_revoked"`
+	EncryptedSecret     string         `json:"-"`                                       // for verifying signed requests
+	SignatureRequired   bool           `gorm:"default:false" json:"signature_required"` // reject plain x-api-key use
+	ReplacedByID        *uuid.UUID     `gorm:"type:uuid" json:"replaced_by_id"`         // set on rollover; the key keeps working until ExpiresAt
+	ExpiryWarningSentAt *time.Time     `json:"-"`
+	CreatedAt           time.Time      `gorm:"default:now()" json:"created_at"`
 }
 
 func (APIKey) TableName() string {
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audited actions
+const (
+	AuditLogin            = "auth.login"
+	AuditLoginFailed      = "auth.login_failed"
+	AuditLogout           = "auth.logout"
+	AuditPINLocked        = "auth.pin_locked"
+	AuditPINReset         = "auth.pin_reset"
+	Audit2FAEnabled       = "auth.2fa_enabled"
+	Audit2FADisabled      = "auth.2fa_disabled"
+	AuditAPIKeyCreated    = "api_key.create"
+	AuditAPIKeyRolledOver = "api_key.rollover"
+	AuditAPIKeyRevoked    = "api_key.revoke"
+	AuditAPIKeyUpdated    = "api_key.update"
+	AuditTransfer         = "wallet.transfer"
+	AuditDepositStarted   = "wallet.deposit_initiated"
+	AuditDepositCredited  = "wallet.deposit_credited"
+	AuditDepositHeld      = "wallet.deposit_held"
+	AuditWebhookReceived  = "webhook.received"
+	AuditRoleChanged      = "admin.role_change"
+	AuditUserStatus       = "admin.user_status"
+	AuditWalletStatus     = "admin.wallet_status"
+	AuditHandleChanged    = "user.handle_change"
+	AuditFeeRuleChanged   = "admin.fee_rule"
+	AuditTierChanged      = "admin.user_tier"
+	AuditScheduleChanged  = "wallet.schedule_change"
+	AuditScheduledRun     = "wallet.scheduled_transfer"
+	AuditPaymentRequest   = "wallet.payment_request"
+	AuditEscrow           = "wallet.escrow"
+	AuditEscrowResolved   = "admin.escrow_resolve"
+	AuditHold             = "wallet.hold"
+	AuditTransferBatch    = "wallet.transfer_batch"
+	AuditWebhookEndpoint  = "user.webhook_endpoint"
+	AuditWebhookRedeliver = "user.webhook_redeliver"
+)
+
+// How the actor authenticated
+const (
+	AuthMethodJWT           = "jwt"
+	AuthMethodAPIKey        = "api_key"
+	AuthMethodSignedRequest = "signed_request"
+	AuthMethodPassword      = "password"
+	AuthMethodMagicLink     = "magic_link"
+	AuthMethodOAuth         = "oauth"
+	AuthMethodWebhook       = "webhook"
+	AuthMethodSystem        = "system"
+)
+
+// AuditEvent is one entry in the append-only audit log. Each entry's hash
+// covers its own fields and the previous entry's hash, so editing, removing
+// or reordering entries breaks the chain from that point on.
+type AuditEvent struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Seq        int64      `gorm:"not null;uniqueIndex" json:"seq"`
+	ActorID    *uuid.UUID `gorm:"type:uuid;index" json:"actor_id,omitempty"`
+	AuthMethod string     `gorm:"not null" json:"auth_method"`
+	APIKeyID   *uuid.UUID `gorm:"type:uuid" json:"api_key_id,omitempty"`
+	IP         string     `json:"ip,omitempty"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	Action     string     `gorm:"not null;index" json:"action"`
+	TargetType string     `gorm:"index:idx_audit_events_target" json:"target_type,omitempty"`
+	TargetID   string     `gorm:"index:idx_audit_events_target" json:"target_id,omitempty"`
+	Before     string     `gorm:"type:text" json:"before,omitempty"` // JSON, kept as text so the hashed bytes survive
+	After      string     `gorm:"type:text" json:"after,omitempty"`
+	RequestID  string     `gorm:"index" json:"request_id,omitempty"`
+	CreatedAt  time.Time  `gorm:"not null;index" json:"created_at"`
+	PrevHash   string     `gorm:"not null" json:"prev_hash"`
+	Hash       string     `gorm:"not null" json:"hash"`
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// ComputeHash returns the SHA-256 of the event's fields and PrevHash.
+// CreatedAt must already be in UTC at microsecond precision, as Postgres
+// stores it, for the hash to match when the event is read back.
+func (e *AuditEvent) ComputeHash() string {
+	fields, _ := json.Marshal(struct {
+		Seq        int64      `json:"seq"`
+		ActorID    *uuid.UUID `json:"actor_id"`
+		AuthMethod string     `json:"auth_method"`
+		APIKeyID   *uuid.UUID `json:"api_key_id"`
+		IP         string     `json:"ip"`
+		UserAgent  string     `json:"user_agent"`
+		Action     string     `json:"action"`
+		TargetType string     `json:"target_type"`
+		TargetID   string     `json:"target_id"`
+		Before     string     `json:"before"`
+		After      string     `json:"after"`
+		RequestID  string     `json:"request_id"`
+		CreatedAt  string     `json:"created_at"`
+		PrevHash   string     `json:"prev_hash"`
+	}{
+		e.Seq, e.ActorID, e.AuthMethod, e.APIKeyID, e.IP, e.UserAgent, e.Action,
+		e.TargetType, e.TargetID, e.Before, e.After, e.RequestID,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano), e.PrevHash,
+	})
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:])
+}
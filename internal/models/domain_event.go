@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Domain events, named for what happened to the aggregate they describe
+const (
+	DomainDepositInitiated  = "DepositInitiated"
+	DomainDepositCredited   = "DepositCredited"
+	DomainDepositHeld       = "DepositHeld"
+	DomainDepositFailed     = "DepositFailed"
+	DomainTransferCompleted = "TransferCompleted"
+	DomainHoldPlaced        = "HoldPlaced"
+	DomainHoldReleased      = "HoldReleased"
+	DomainKeyCreated        = "KeyCreated"
+	DomainKeyRolledOver     = "KeyRolledOver"
+	DomainKeyRevoked        = "KeyRevoked"
+)
+
+// DomainEvent records a state change for other parts of the system, such
+// as analytics or another service, rather than for a particular user. It is
+// written in the same database transaction as the change, and a worker
+// publishes it to the configured event sinks once it has committed.
+type DomainEvent struct {
+	ID            uuid.UUID              `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Type          string                 `gorm:"not null;index" json:"type"`
+	AggregateType string                 `gorm:"not null" json:"aggregate_type"` // transaction, hold or api_key
+	AggregateID   string                 `gorm:"not null;index" json:"aggregate_id"`
+	Mode          string                 `gorm:"not null;default:live" json:"mode"`
+	Data          map[string]interface{} `gorm:"serializer:json;type:jsonb" json:"data"`
+	PublishedAt   *time.Time             `gorm:"index" json:"-"` // set once every sink has accepted it
+	LeaseUntil    *time.Time             `json:"-"`              // while set and in the future, a replica is publishing it
+	CreatedAt     time.Time              `gorm:"index" json:"created_at"`
+}
+
+func (DomainEvent) TableName() string {
+	return "domain_events"
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Escrow statuses
+const (
+	EscrowHeld     = "held"     // funds set aside in the payer's wallet
+	EscrowReleased = "released" // paid to the payee
+	EscrowRefunded = "refunded" // returned to the payer
+	EscrowDisputed = "disputed" // waiting for staff; the deadline no longer applies
+)
+
+// What happens to a held escrow when its deadline passes
+const (
+	EscrowOnExpiryRelease = "release"
+	EscrowOnExpiryRefund  = "refund"
+)
+
+// Escrow locks a payment in the payer's wallet with a hold until the payer
+// confirms delivery and releases it to the payee, or it is refunded. The
+// fee is held with the amount and only charged on release.
+type Escrow struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	PayerID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"payer_id"`
+	PayeeID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"payee_id"`
+	Mode          string     `gorm:"not null;default:live" json:"mode"`
+	Amount        float64    `gorm:"not null" json:"amount"`
+	Fee           float64    `gorm:"not null;default:0" json:"fee"`
+	Description   string     `json:"description,omitempty"`
+	Reference     string     `gorm:"not null;uniqueIndex" json:"reference"` // used for the release transaction
+	Status        string     `gorm:"not null;default:held;index" json:"status"`
+	ExpiresAt     time.Time  `gorm:"not null;index" json:"expires_at"`
+	OnExpiry      string     `gorm:"not null;default:release" json:"on_expiry"`
+	DisputedByID  *uuid.UUID `gorm:"type:uuid" json:"disputed_by_id,omitempty"`
+	DisputeReason string     `json:"dispute_reason,omitempty"`
+	Resolution    string     `json:"resolution,omitempty"`     // staff note when a dispute is settled
+	HoldID        uuid.UUID  `gorm:"type:uuid" json:"hold_id"` // sets the funds aside in the payer's wallet
+	TransactionID *uuid.UUID `gorm:"type:uuid" json:"transaction_id,omitempty"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (Escrow) TableName() string {
+	return "escrows"
+}
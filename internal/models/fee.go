@@ -0,0 +1,77 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Every wallet holds naira for now
+const CurrencyNGN = "NGN"
+
+// User tiers fee rules can target
+const (
+	TierStandard = "standard"
+	TierPremium  = "premium"
+	TierBusiness = "business"
+)
+
+func IsValidTier(tier string) bool {
+	return tier == TierStandard || tier == TierPremium || tier == TierBusiness
+}
+
+// Transaction types that can carry a fee
+func IsFeeableType(transactionType string) bool {
+	return transactionType == "transfer" || transactionType == "deposit"
+}
+
+// FeeRule prices one transaction type for one currency and, optionally, one
+// user tier. The fee is Flat plus Percent of the amount, taken from the
+// first band the amount falls in when bands are set, then held between
+// MinFee and MaxFee. A rule for the user's tier beats one for every tier.
+type FeeRule struct {
+	ID              uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	TransactionType string    `gorm:"not null;index" json:"transaction_type"`
+	Currency        string    `gorm:"not null;default:NGN" json:"currency"`
+	Tier            string    `gorm:"not null;default:''" json:"tier"` // empty for every tier
+	Flat            float64   `gorm:"not null;default:0" json:"flat"`
+	Percent         float64   `gorm:"not null;default:0" json:"percent"`
+	Bands           []FeeBand `gorm:"serializer:json;type:jsonb" json:"bands,omitempty"`
+	MinFee          float64   `gorm:"not null;default:0" json:"min_fee"`
+	MaxFee          float64   `gorm:"not null;default:0" json:"max_fee"` // 0 for no cap
+	Active          bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// FeeBand prices amounts up to UpTo, or any amount when UpTo is nil
+type FeeBand struct {
+	UpTo    *float64 `json:"up_to"`
+	Flat    float64  `json:"flat"`
+	Percent float64  `json:"percent"`
+}
+
+func (FeeRule) TableName() string {
+	return "fee_rules"
+}
+
+// Calculate returns the fee for an amount, rounded to kobo
+func (r *FeeRule) Calculate(amount float64) float64 {
+	flat, percent := r.Flat, r.Percent
+	for _, band := range r.Bands {
+		if band.UpTo == nil || amount <= *band.UpTo {
+			flat, percent = band.Flat, band.Percent
+			break
+		}
+	}
+
+	fee := flat + amount*percent/100
+	if fee < r.MinFee {
+		fee = r.MinFee
+	}
+	if r.MaxFee > 0 && fee > r.MaxFee {
+		fee = r.MaxFee
+	}
+	return math.Round(fee*100) / 100
+}
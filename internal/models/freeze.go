@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Users and wallets each have a status. Money movement is allowed only when
+// both the user and the wallet allow it.
+const (
+	AccountActive      = "active"
+	AccountDebitFrozen = "debit_frozen" // can receive money but not send it
+	AccountFrozen      = "frozen"       // no money in or out; a frozen user can't sign in
+	AccountClosed      = "closed"       // like frozen, and can't be reopened
+)
+
+// Why an account's status was changed
+const (
+	FreezeReasonFraudSuspected   = "fraud_suspected"
+	FreezeReasonComplianceReview = "compliance_review"
+	FreezeReasonLegalOrder       = "legal_order"
+	FreezeReasonCustomerRequest  = "customer_request"
+	FreezeReasonChargeback       = "chargeback"
+	FreezeReasonKYCIncomplete    = "kyc_incomplete"
+	FreezeReasonResolved         = "resolved"
+	FreezeReasonOther            = "other"
+)
+
+var freezeReasons = []string{
+	FreezeReasonFraudSuspected,
+	FreezeReasonComplianceReview,
+	FreezeReasonLegalOrder,
+	FreezeReasonCustomerRequest,
+	FreezeReasonChargeback,
+	FreezeReasonKYCIncomplete,
+	FreezeReasonResolved,
+	FreezeReasonOther,
+}
+
+func IsValidAccountStatus(status string) bool {
+	switch status {
+	case AccountActive, AccountDebitFrozen, AccountFrozen, AccountClosed:
+		return true
+	}
+	return false
+}
+
+func IsValidFreezeReason(reason string) bool {
+	for _, r := range freezeReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// CanDebit reports whether money may leave an account with this status
+func CanDebit(status string) bool {
+	return status == AccountActive
+}
+
+// CanCredit reports whether money may arrive in an account with this status
+func CanCredit(status string) bool {
+	return status == AccountActive || status == AccountDebitFrozen
+}
+
+// CanSignIn reports whether a user with this status may authenticate
+func CanSignIn(status string) bool {
+	return status == AccountActive || status == AccountDebitFrozen
+}
+
+const (
+	FreezeSubjectUser   = "user"
+	FreezeSubjectWallet = "wallet"
+)
+
+// FreezeEvent is an append-only record of a user or wallet status change
+type FreezeEvent struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	SubjectType string     `gorm:"not null;index:idx_freeze_events_subject" json:"subject_type"` // "user|wallet"
+	SubjectID   uuid.UUID  `gorm:"type:uuid;not null;index:idx_freeze_events_subject" json:"subject_id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"` // owner, for a wallet
+	FromStatus  string     `gorm:"not null" json:"from_status"`
+	ToStatus    string     `gorm:"not null" json:"to_status"`
+	ReasonCode  string     `gorm:"not null" json:"reason_code"`
+	Note        string     `json:"note"`
+	ActorID     *uuid.UUID `gorm:"type:uuid" json:"actor_id"` // staff member, nil for the system
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (FreezeEvent) TableName() string {
+	return "freeze_events"
+}
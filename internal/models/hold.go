@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Hold statuses
+const (
+	HoldActive   = "active"
+	HoldCaptured = "captured"
+	HoldVoided   = "voided"
+	HoldExpired  = "expired"
+)
+
+// What a hold was placed for. Authorizations are captured or voided by
+// their payee through the holds API; other holds belong to the feature
+// that placed them.
+const (
+	HoldKindAuthorization = "authorization"
+	HoldKindEscrow        = "escrow"
+)
+
+// Hold sets funds aside in a wallet: they leave its available balance but
+// stay in its ledger balance until the hold is captured, paying the payee,
+// or voided or expired, returning them. The fee for the full amount is held
+// too, and only charged on what is captured.
+type Hold struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	WalletID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"wallet_id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"` // the wallet's owner
+	PayeeID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"payee_id"`
+	Mode           string     `gorm:"not null;default:live" json:"mode"`
+	Kind           string     `gorm:"not null;default:authorization" json:"kind"`
+	Amount         float64    `gorm:"not null" json:"amount"`
+	Fee            float64    `gorm:"not null;default:0" json:"fee"`
+	CapturedAmount float64    `gorm:"not null;default:0" json:"captured_amount"`
+	CapturedFee    float64    `gorm:"not null;default:0" json:"captured_fee"`
+	Description    string     `json:"description,omitempty"`
+	Reference      string     `gorm:"not null;uniqueIndex" json:"reference"` // used for the capture transaction
+	Status         string     `gorm:"not null;default:active;index" json:"status"`
+	ExpiresAt      *time.Time `gorm:"index" json:"expires_at,omitempty"` // nil for holds that never expire by themselves
+	TransactionID  *uuid.UUID `gorm:"type:uuid" json:"transaction_id,omitempty"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Total is everything the hold sets aside, fee included
+func (h Hold) Total() float64 {
+	return h.Amount + h.Fee
+}
+
+func (Hold) TableName() string {
+	return "holds"
+}
@@ -0,0 +1,12 @@
+package models
+
+// Every key, wallet and transaction belongs to exactly one mode. Test-mode
+// data lives alongside live data but is never mixed with it in queries.
+const (
+	ModeLive = "live"
+	ModeTest = "test"
+)
+
+func IsValidMode(mode string) bool {
+	return mode == ModeLive || mode == ModeTest
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEvent is something that happened to a user's account, written in
+// the same database transaction as the change itself so it is never lost
+// and never recorded for a change that was rolled back. A worker fans it
+// out to the user's webhook endpoints.
+type OutboxEvent struct {
+	ID           uuid.UUID              `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID       uuid.UUID              `gorm:"type:uuid;not null;index" json:"user_id"`
+	Mode         string                 `gorm:"not null;default:live" json:"mode"`
+	Type         string                 `gorm:"not null" json:"type"`
+	Data         map[string]interface{} `gorm:"serializer:json;type:jsonb" json:"data"`
+	DispatchedAt *time.Time             `gorm:"index" json:"dispatched_at,omitempty"` // set once deliveries are queued
+	CreatedAt    time.Time              `gorm:"index" json:"created_at"`
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
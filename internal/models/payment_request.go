@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Payment request statuses
+const (
+	PaymentRequestOpen      = "open"
+	PaymentRequestPaid      = "paid"
+	PaymentRequestDeclined  = "declined"
+	PaymentRequestExpired   = "expired"
+	PaymentRequestCancelled = "cancelled" // withdrawn by the requester
+)
+
+// PaymentRequest asks for money. A request addressed to a payer can only be
+// paid or declined by them; one without a payer is a public link anyone can
+// pay through Code, from their wallet or by card.
+type PaymentRequest struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	RequesterID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"requester_id"`
+	PayerID       *uuid.UUID `gorm:"type:uuid;index" json:"payer_id,omitempty"`
+	Mode          string     `gorm:"not null;default:live" json:"mode"`
+	Amount        float64    `gorm:"not null" json:"amount"`
+	Memo          string     `json:"memo,omitempty"`
+	Code          string     `gorm:"not null;uniqueIndex" json:"code"` // used in the shareable link
+	Status        string     `gorm:"not null;default:open;index" json:"status"`
+	ExpiresAt     time.Time  `gorm:"not null;index" json:"expires_at"`
+	PaidByID      *uuid.UUID `gorm:"type:uuid" json:"paid_by_id,omitempty"` // nil for card payments
+	TransactionID *uuid.UUID `gorm:"type:uuid" json:"transaction_id,omitempty"`
+	PaidAt        *time.Time `json:"paid_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (PaymentRequest) TableName() string {
+	return "payment_requests"
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RequestNonce records a nonce already used by a signed request so that
+// replays are rejected until the nonce falls outside the timestamp window.
+type RequestNonce struct {
+	KeyID     uuid.UUID `gorm:"type:uuid;primaryKey" json:"key_id"`
+	Nonce     string    `gorm:"primaryKey" json:"nonce"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+func (RequestNonce) TableName() string {
+	return "request_nonces"
+}
@@ -0,0 +1,75 @@
+package models
+
+// Every user has one role. Staff roles unlock parts of the admin API; what
+// each role may do is listed in rolePermissions.
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleFinance = "finance"
+	RoleAdmin   = "admin"
+)
+
+// Admin API permissions
+const (
+	PermissionUsersRead        = "users:read"
+	PermissionUsersFreeze      = "users:freeze"
+	PermissionAccountsClose    = "accounts:close"
+	PermissionRolesManage      = "roles:manage"
+	PermissionWalletsRead      = "wallets:read"
+	PermissionTransactionsRead = "transactions:read"
+	PermissionWebhooksRead     = "webhooks:read"
+	PermissionAuditRead        = "audit:read"
+	PermissionFeesManage       = "fees:manage"
+	PermissionEscrowsResolve   = "escrows:resolve"
+)
+
+var rolePermissions = map[string][]string{
+	RoleUser: {},
+	RoleSupport: {
+		PermissionUsersRead,
+		PermissionUsersFreeze,
+		PermissionWalletsRead,
+		PermissionTransactionsRead,
+		PermissionWebhooksRead,
+		PermissionEscrowsResolve,
+	},
+	RoleFinance: {
+		PermissionUsersRead,
+		PermissionWalletsRead,
+		PermissionTransactionsRead,
+		PermissionWebhooksRead,
+		PermissionAuditRead,
+		PermissionFeesManage,
+	},
+	RoleAdmin: {
+		PermissionUsersRead,
+		PermissionUsersFreeze,
+		PermissionAccountsClose,
+		PermissionRolesManage,
+		PermissionWalletsRead,
+		PermissionTransactionsRead,
+		PermissionWebhooksRead,
+		PermissionAuditRead,
+		PermissionFeesManage,
+		PermissionEscrowsResolve,
+	},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func RoleHasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RolePermissions returns what a role may do
+func RolePermissions(role string) []string {
+	return append([]string{}, rolePermissions[role]...)
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scheduled transfer statuses
+const (
+	ScheduleActive    = "active"
+	SchedulePaused    = "paused"
+	ScheduleCancelled = "cancelled"
+	ScheduleCompleted = "completed" // no occurrences left
+)
+
+// What to do when an occurrence finds too little money in the wallet
+const (
+	ScheduleOnFailureRetry = "retry" // try again later, then skip the occurrence
+	ScheduleOnFailureSkip  = "skip"  // skip the occurrence straight away
+)
+
+// Outcomes of one occurrence
+const (
+	ScheduleRunPending = "pending" // waiting for a retry
+	ScheduleRunSuccess = "success"
+	ScheduleRunSkipped = "skipped"
+	ScheduleRunFailed  = "failed"
+)
+
+// ScheduledTransfer pays the same recipient on a one-off date or on a
+// recurrence (see utils/recurrence.go). NextRunAt is when the worker next
+// acts on it, OccurrenceAt the occurrence it will run then; they differ
+// while an occurrence is waiting for a retry.
+type ScheduledTransfer struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Mode          string     `gorm:"not null;default:live" json:"mode"`
+	Recipient     string     `gorm:"not null" json:"recipient"` // account number, fixed when the schedule is created
+	RecipientName string     `json:"recipient_name"`
+	Amount        float64    `gorm:"not null" json:"amount"`
+	Recurrence    string     `gorm:"not null;default:once" json:"recurrence"`
+	StartAt       time.Time  `gorm:"not null" json:"start_at"`
+	EndAt         *time.Time `json:"end_at,omitempty"`
+	MaxRuns       int        `gorm:"not null;default:0" json:"max_runs"` // 0 for no limit
+	OnFailure     string     `gorm:"not null;default:retry" json:"on_insufficient_funds"`
+	Status        string     `gorm:"not null;default:active;index" json:"status"`
+	NextRunAt     *time.Time `gorm:"index" json:"next_run_at,omitempty"`
+	OccurrenceAt  *time.Time `json:"occurrence_at,omitempty"`
+	RunCount      int        `gorm:"not null;default:0" json:"run_count"` // occurrences that paid
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (ScheduledTransfer) TableName() string {
+	return "scheduled_transfers"
+}
+
+// ScheduledTransferRun is one occurrence of a schedule. Each occurrence
+// has one row, however many times it is attempted.
+type ScheduledTransferRun struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	ScheduleID    uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_schedule_occurrence" json:"schedule_id"`
+	OccurrenceAt  time.Time  `gorm:"not null;uniqueIndex:idx_schedule_occurrence" json:"occurrence_at"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	Status        string     `gorm:"not null" json:"status"`
+	TransactionID *uuid.UUID `gorm:"type:uuid" json:"transaction_id,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (ScheduledTransferRun) TableName() string {
+	return "scheduled_transfer_runs"
+}
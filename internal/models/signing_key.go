@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SigningKey is an asymmetric key used to sign access JWTs. The newest
+// unretired key signs; retired keys stay published in the JWKS until tokens
+// signed with them have expired.
+type SigningKey struct {
+	KID        string     `gorm:"primaryKey" json:"kid"`
+	Algorithm  string     `gorm:"not null" json:"algorithm"`  // "EdDSA|RS256"
+	PublicKey  string     `gorm:"not null" json:"public_key"` // PKIX PEM
+	PrivateKey string     `gorm:"not null" json:"-"`          // encrypted PKCS#8 PEM
+	CreatedAt  time.Time  `json:"created_at"`
+	RetiredAt  *time.Time `json:"retired_at"` // no longer used for signing
+	ExpiresAt  *time.Time `json:"expires_at"` // no longer accepted for verification
+}
+
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is a server-side record of an opaque refresh token. Tokens are
+// single use: refreshing replaces the token with a new one in the same family,
+// and presenting a replaced token again revokes the whole family.
+type RefreshToken struct {
+	ID           uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	FamilyID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"family_id"`
+	TokenHash    string     `gorm:"not null;uniqueIndex" json:"-"` // SHA-256 of the token
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	ReplacedByID *uuid.UUID `gorm:"type:uuid" json:"replaced_by_id"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// RevokedToken lists access token IDs (jti) that must be rejected before
+// they expire naturally.
+type RevokedToken struct {
+	TokenID   string    `gorm:"primaryKey" json:"token_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// LoginCode is a single-use code handed to a front end after an OAuth login
+// and exchanged for tokens at /auth/token, so tokens never appear in a URL.
+type LoginCode struct {
+	CodeHash  string    `gorm:"primaryKey" json:"-"` // SHA-256 of the code
+	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+func (LoginCode) TableName() string {
+	return "login_codes"
+}
+
+const (
+	EmailTokenVerifyEmail   = "verify_email"
+	EmailTokenPasswordReset = "password_reset"
+	EmailTokenMagicLink     = "magic_link"
+	EmailTokenPINReset      = "pin_reset"
+)
+
+// EmailToken is a single-use token mailed to a user to verify their email,
+// reset their password or transaction PIN, or sign in. Magic links for addresses without an
+// account carry only the email; the account is created when the link is used.
+type EmailToken struct {
+	TokenHash string     `gorm:"primaryKey" json:"-"` // SHA-256 of the token
+	Purpose   string     `gorm:"not null" json:"purpose"`
+	UserID    *uuid.UUID `gorm:"type:uuid" json:"user_id"`
+	Email     string     `gorm:"not null;index" json:"email"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+}
+
+func (EmailToken) TableName() string {
+	return "email_tokens"
+}
@@ -7,19 +7,29 @@ import (
 )
 
 type Transaction struct {
-	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	SenderID   *uuid.UUID `gorm:"type:uuid" json:"sender_id"` // Pointer to allow null for deposits (system -> user)
-	Sender     *User      `gorm:"foreignKey:SenderID;references:ID" json:"sender"`
-	ReceiverID uuid.UUID  `gorm:"type:uuid;not null" json:"receiver_id"`
-	Receiver   User       `gorm:"foreignKey:ReceiverID;references:ID" json:"receiver"`
-	Amount     float64    `gorm:"not null" json:"amount"`
-	Type       string     `gorm:"not null" json:"type"`    // 'deposit', 'transfer'
-	Status     string     `gorm:"not null" json:"status"`  // "success|failed|pending"
-	Reference  string     `gorm:"unique" json:"reference"` // Paystack reference
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
+	ID              uuid.UUID         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	SenderID        *uuid.UUID        `gorm:"type:uuid;uniqueIndex:idx_sender_client_reference,priority:1" json:"sender_id"` // Pointer to allow null for deposits (system -> user)
+	Sender          *User             `gorm:"foreignKey:SenderID;references:ID" json:"sender"`
+	ReceiverID      uuid.UUID         `gorm:"type:uuid;not null" json:"receiver_id"`
+	Receiver        User              `gorm:"foreignKey:ReceiverID;references:ID" json:"receiver"`
+	Amount          float64           `gorm:"not null" json:"amount"`
+	Fee             float64           `gorm:"not null;default:0" json:"fee"`
+	Type            string            `gorm:"not null" json:"type"`                    // 'deposit', 'transfer', 'fee'
+	Status          string            `gorm:"not null" json:"status"`                  // "success|failed|pending|held"
+	Reference       string            `gorm:"unique" json:"reference"`                 // Paystack reference
+	Mode            string            `gorm:"not null;default:live;index" json:"mode"` // "live|test"
+	ParentID        *uuid.UUID        `gorm:"type:uuid;index" json:"parent_id,omitempty"`
+	Narration       string            `json:"narration,omitempty"`                                                                  // shown to both parties
+	Metadata        map[string]string `gorm:"serializer:json;type:jsonb" json:"metadata,omitempty"`                                 // the sender's key-value data
+	ClientReference *string           `gorm:"uniqueIndex:idx_sender_client_reference,priority:2" json:"client_reference,omitempty"` // the sender's own ID, unique per sender
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
 }
 
+// TransactionHeld marks a deposit that was paid while the receiving account
+// couldn't be credited. It is credited once the account is unfrozen.
+const TransactionHeld = "held"
+
 func (Transaction) TableName() string {
 	return "transactions"
 }
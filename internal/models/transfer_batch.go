@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How a batch handles an item that can't be paid
+const (
+	BatchAllOrNothing = "all_or_nothing" // pay every item or none of them
+	BatchBestEffort   = "best_effort"    // pay what can be paid
+)
+
+// Batch statuses
+const (
+	BatchQueued     = "queued"
+	BatchProcessing = "processing"
+	BatchCompleted  = "completed" // every item paid
+	BatchPartial    = "partial"   // best effort: some items failed
+	BatchFailed     = "failed"    // nothing paid
+)
+
+// Batch item statuses
+const (
+	BatchItemPending = "pending"
+	BatchItemSuccess = "success"
+	BatchItemFailed  = "failed"
+)
+
+// TransferBatch pays many recipients from one wallet. Items are checked
+// when the batch is submitted and paid in the background.
+type TransferBatch struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Mode           string     `gorm:"not null;default:live" json:"mode"`
+	Policy         string     `gorm:"not null" json:"policy"`
+	Status         string     `gorm:"not null;default:queued;index" json:"status"`
+	ItemCount      int        `gorm:"not null" json:"item_count"`
+	TotalAmount    float64    `gorm:"not null" json:"total_amount"`
+	SucceededCount int        `gorm:"not null;default:0" json:"succeeded_count"`
+	FailedCount    int        `gorm:"not null;default:0" json:"failed_count"`
+	Error          string     `json:"error,omitempty"`
+	LeaseUntil     *time.Time `json:"-"` // while a replica is paying the batch
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (TransferBatch) TableName() string {
+	return "transfer_batches"
+}
+
+// TransferBatchItem is one transfer in a batch. The recipient is pinned to
+// an account number when the batch is submitted.
+type TransferBatchItem struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	BatchID       uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_batch_item_line" json:"batch_id"`
+	Line          int        `gorm:"not null;uniqueIndex:idx_batch_item_line" json:"line"` // 1-based position in the submission
+	Recipient     string     `gorm:"not null" json:"recipient"`                            // as submitted
+	AccountNumber string     `gorm:"not null" json:"account_number"`
+	RecipientName string     `json:"recipient_name"`
+	Amount        float64    `gorm:"not null" json:"amount"`
+	Fee           float64    `gorm:"not null;default:0" json:"fee"`
+	Narration     string     `json:"narration,omitempty"`
+	Reference     string     `gorm:"not null;uniqueIndex" json:"reference"`
+	Status        string     `gorm:"not null;default:pending" json:"status"`
+	Error         string     `json:"error,omitempty"`
+	TransactionID *uuid.UUID `gorm:"type:uuid" json:"transaction_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (TransferBatchItem) TableName() string {
+	return "transfer_batch_items"
+}
@@ -7,23 +7,30 @@ import (
 )
 
 type User struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	GoogleID  string    `gorm:"unique;not null" json:"google_id"`
-	Email     string    `gorm:"unique;not null" json:"email"`
-	FirstName string    `gorm:"not null" json:"first_name"`
-	LastName  string    `gorm:"not null" json:"last_name"`
-	IsActive  bool      `gorm:"default:true" json:"is_active"`
-	Wallet    Wallet    `json:"wallet,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-}
-
-type GoogleUserInfo struct {
-	ID         string `json:"id"`
-	Email      string `json:"email"`
-	Name       string `json:"name"`
-	GivenName  string `json:"given_name"`
-	FamilyName string `json:"family_name"`
+	ID                 uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	GoogleID           *string    `gorm:"unique" json:"-"` // legacy, sign-in identities live in user_identities
+	Email              string     `gorm:"unique;not null" json:"email"`
+	FirstName          string     `gorm:"not null" json:"first_name"`
+	LastName           string     `gorm:"not null" json:"last_name"`
+	Handle             *string    `gorm:"uniqueIndex" json:"handle,omitempty"`
+	PasswordHash       *string    `json:"-"` // Argon2id, nil for users who only sign in with a provider or magic link
+	EmailVerifiedAt    *time.Time `json:"email_verified_at"`
+	Status             string     `gorm:"not null;default:active;index" json:"status"` // see freeze.go
+	StatusReason       string     `json:"status_reason,omitempty"`
+	Role               string     `gorm:"not null;default:user;index" json:"role"`
+	Tier               string     `gorm:"not null;default:standard" json:"tier"`
+	TOTPSecret         *string    `json:"-"` // encrypted, only in force once TOTPEnabledAt is set
+	TOTPEnabledAt      *time.Time `json:"totp_enabled_at"`
+	TOTPLastStep       int64      `gorm:"not null;default:0" json:"-"` // last accepted time step, against replays
+	TOTPFailedAttempts int        `gorm:"not null;default:0" json:"-"`
+	TOTPLockedUntil    *time.Time `json:"-"`
+	PINHash            *string    `json:"-"` // Argon2id hash of the transaction PIN
+	PINFailedAttempts  int        `gorm:"not null;default:0" json:"-"`
+	PINLockedUntil     *time.Time `json:"-"`
+	TokensRevokedAt    *time.Time `json:"-"` // access tokens issued earlier are rejected
+	Wallet             Wallet     `json:"wallet,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
 }
 
 func (User) TableName() string {
@@ -7,11 +7,22 @@ import (
 )
 
 type Wallet struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
-	Balance   float64   `gorm:"default:0" json:"balance"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_wallets_user_mode" json:"user_id"`
+	Mode          string    `gorm:"not null;default:live;uniqueIndex:idx_wallets_user_mode" json:"mode"` // "live|test"
+	AccountNumber string    `gorm:"size:10;uniqueIndex" json:"account_number"`                           // 10-digit NUBAN-style, see utils.GenAccountNumber
+	Balance       float64   `gorm:"default:0" json:"balance"`                                            // available to spend
+	HeldBalance   float64   `gorm:"not null;default:0" json:"held_balance"`                              // set aside by holds, not spendable
+	Status        string    `gorm:"not null;default:active" json:"status"`                               // see freeze.go
+	StatusReason  string    `json:"status_reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// LedgerBalance is everything in the wallet, including funds set aside by
+// holds. Only Balance, the available part, can be spent.
+func (w Wallet) LedgerBalance() float64 {
+	return w.Balance + w.HeldBalance
 }
 
 func (Wallet) TableName() string {
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Events users can subscribe webhook endpoints to. Endpoints may also
+// subscribe to a family, such as "escrow.*", or to "*" for everything.
+const (
+	EventTransferSent             = "transfer.sent"
+	EventTransferReceived         = "transfer.received"
+	EventDepositSucceeded         = "deposit.succeeded"
+	EventDepositHeld              = "deposit.held"
+	EventDepositFailed            = "deposit.failed"
+	EventKeyExpiring              = "key.expiring"
+	EventPaymentRequestReceived   = "payment_request.received"
+	EventPaymentRequestPaid       = "payment_request.paid"
+	EventPaymentRequestDeclined   = "payment_request.declined"
+	EventPaymentRequestCancelled  = "payment_request.cancelled"
+	EventEscrowCreated            = "escrow.created"
+	EventEscrowReleased           = "escrow.released"
+	EventEscrowRefunded           = "escrow.refunded"
+	EventEscrowDisputed           = "escrow.disputed"
+	EventHoldPlaced               = "hold.placed"
+	EventHoldCaptured             = "hold.captured"
+	EventHoldVoided               = "hold.voided"
+	EventHoldExpired              = "hold.expired"
+	EventScheduledTransferSuccess = "scheduled_transfer.success"
+	EventScheduledTransferPending = "scheduled_transfer.pending" // will be retried
+	EventScheduledTransferSkipped = "scheduled_transfer.skipped"
+	EventScheduledTransferFailed  = "scheduled_transfer.failed"
+	EventTransferBatchCompleted   = "transfer_batch.completed"
+	EventTransferBatchPartial     = "transfer_batch.partial"
+	EventTransferBatchFailed      = "transfer_batch.failed"
+	EventPing                     = "ping" // sent on request to test an endpoint
+)
+
+// WebhookEventTypes lists every event an endpoint can subscribe to
+var WebhookEventTypes = []string{
+	EventTransferSent, EventTransferReceived,
+	EventDepositSucceeded, EventDepositHeld, EventDepositFailed,
+	EventKeyExpiring,
+	EventPaymentRequestReceived, EventPaymentRequestPaid, EventPaymentRequestDeclined, EventPaymentRequestCancelled,
+	EventEscrowCreated, EventEscrowReleased, EventEscrowRefunded, EventEscrowDisputed,
+	EventHoldPlaced, EventHoldCaptured, EventHoldVoided, EventHoldExpired,
+	EventScheduledTransferSuccess, EventScheduledTransferPending, EventScheduledTransferSkipped, EventScheduledTransferFailed,
+	EventTransferBatchCompleted, EventTransferBatchPartial, EventTransferBatchFailed,
+}
+
+// WebhookEndpoint is a URL of the user's that is sent the events it
+// subscribes to in its mode, signed with its secret
+type WebhookEndpoint struct {
+	ID              uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID          uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	Mode            string         `gorm:"not null;default:live" json:"mode"`
+	URL             string         `gorm:"not null" json:"url"`
+	Description     string         `json:"description,omitempty"`
+	Events          pq.StringArray `gorm:"type:text[];not null" json:"events"`
+	EncryptedSecret string         `gorm:"not null" json:"-"`
+	Active          bool           `gorm:"not null;default:true" json:"active"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+func (WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// Subscribes reports whether the endpoint wants events of this type. Pings
+// go to every endpoint.
+func (e *WebhookEndpoint) Subscribes(eventType string) bool {
+	if eventType == EventPing {
+		return true
+	}
+	for _, event := range e.Events {
+		if event == "*" || event == eventType {
+			return true
+		}
+		if family, ok := strings.CutSuffix(event, ".*"); ok && strings.HasPrefix(eventType, family+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending" // waiting for its next attempt
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed" // out of attempts
+)
+
+// WebhookDelivery sends one event to one endpoint, retrying with backoff
+// until the endpoint answers 2xx or attempts run out
+type WebhookDelivery struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	EndpointID     uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_delivery_endpoint_event" json:"endpoint_id"`
+	EventID        uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_delivery_endpoint_event" json:"event_id"`
+	EventType      string     `gorm:"not null" json:"event_type"`
+	Status         string     `gorm:"not null;default:pending;index:idx_delivery_due,priority:1" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"not null;index:idx_delivery_due,priority:2" json:"next_attempt_at"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastResponse   string     `json:"last_response,omitempty"` // start of the endpoint's reply
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
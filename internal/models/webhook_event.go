@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WebhookEventProcessed = "processed"
+	WebhookEventIgnored   = "ignored"
+	WebhookEventFailed    = "failed"
+)
+
+// WebhookEvent records a payment provider webhook that passed signature
+// checks, so support can see what the provider told us and what we did.
+type WebhookEvent struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Provider  string    `gorm:"not null" json:"provider"`
+	Event     string    `json:"event"`
+	Reference string    `gorm:"index" json:"reference"`
+	Mode      string    `gorm:"not null" json:"mode"`
+	Status    string    `gorm:"not null;index" json:"status"` // "processed|ignored|failed"
+	Error     string    `json:"error,omitempty"`
+	Payload   string    `gorm:"type:text" json:"payload,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func (WebhookEvent) TableName() string {
+	return "webhook_events"
+}
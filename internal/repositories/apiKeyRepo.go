@@ -6,18 +6,28 @@ import (
 	"whotterre/argent/internal/models"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"gorm.io/gorm"
 )
 
 type APIKeyRepository interface {
 	CreateAPIKey(apiKey *models.APIKey) error
 	GetAllNonRevokedAPIKeys() ([]models.APIKey, error)
+	GetActiveAPIKeyByLookupHash(lookupHash string) (*models.APIKey, error)
+	GetAPIKeysByUserID(userID uuid.UUID) ([]models.APIKey, error)
 	GetActiveAPIKeysByUserID(userID uuid.UUID) ([]models.APIKey, error)
 	GetAPIKeyByID(id uuid.UUID) (*models.APIKey, error)
 	RevokeAPIKey(id uuid.UUID) error
-	GetExpiredKeyByID(id uuid.UUID) (*models.APIKey, error)
+	UpdateAllowedIPs(id uuid.UUID, allowedIPs []string) error
+	UpdateSignatureRequired(id uuid.UUID, required bool) error
+	MarkReplaced(id uuid.UUID, replacedByID uuid.UUID, expiresAt time.Time) (bool, error)
+	GetKeysExpiringBefore(cutoff time.Time) ([]models.APIKey, error)
+	MarkExpiryWarningSent(id uuid.UUID) (bool, error)
 }
 
+// Keys of users in other states don't authenticate
+var signInStatuses = []string{models.AccountActive, models.AccountDebitFrozen}
+
 type apiKeyRepository struct {
 	db *gorm.DB
 }
@@ -46,9 +56,29 @@ func (r *apiKeyRepository) GetAllNonRevokedAPIKeys() ([]models.APIKey, error) {
 	return apiKeys, nil
 }
 
+func (r *apiKeyRepository) GetActiveAPIKeyByLookupHash(lookupHash string) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	if err := r.db.Where("lookup_hash = ? AND is_revoked = false AND (expires_at IS NULL OR expires_at > ?) AND user_id IN (SELECT id FROM users WHERE status IN ?)", lookupHash, time.Now(), signInStatuses).
+		First(&apiKey).Error; err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+func (r *apiKeyRepository) GetAPIKeysByUserID(userID uuid.UUID) ([]models.APIKey, error) {
+	var apiKeys []models.APIKey
+	if err := r.db.Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&apiKeys).Error; err != nil {
+		log.Println("Failed to get API keys by user ID:", err)
+		return nil, err
+	}
+	return apiKeys, nil
+}
+
 func (r *apiKeyRepository) GetActiveAPIKeysByUserID(userID uuid.UUID) ([]models.APIKey, error) {
 	var apiKeys []models.APIKey
-	if err := r.db.Where("user_id = ? AND is_revoked = false AND expires_at > ?", userID, time.Now()).
+	if err := r.db.Where("user_id = ? AND is_revoked = false AND (expires_at IS NULL OR expires_at > ?) AND user_id IN (SELECT id FROM users WHERE status IN ?)", userID, time.Now(), signInStatuses).
 		Find(&apiKeys).Error; err != nil {
 		log.Println("Failed to get active API keys by user ID:", err)
 		return nil, err
@@ -58,7 +88,7 @@ func (r *apiKeyRepository) GetActiveAPIKeysByUserID(userID uuid.UUID) ([]models.
 
 func (r *apiKeyRepository) GetAPIKeyByID(id uuid.UUID) (*models.APIKey, error) {
 	var apiKey *models.APIKey
-	if err := r.db.Where("id = ?", id).First(&apiKey).Error; err != nil {
+	if err := r.db.Preload("User").Where("id = ?", id).First(&apiKey).Error; err != nil {
 		log.Println("Failed to get API key by ID:", err)
 		return nil, err
 	}
@@ -73,11 +103,60 @@ func (r *apiKeyRepository) RevokeAPIKey(id uuid.UUID) error {
 	return nil
 }
 
-func (r *apiKeyRepository) GetExpiredKeyByID(id uuid.UUID) (*models.APIKey, error) {
-	var apiKey *models.APIKey
-	if err := r.db.Where("id = ? AND expires_at <= ?", id, time.Now()).First(&apiKey).Error; err != nil {
-		log.Println("Failed to get expired key:", err)
+func (r *apiKeyRepository) UpdateAllowedIPs(id uuid.UUID, allowedIPs []string) error {
+	if err := r.db.Model(&models.APIKey{}).Where("id = ?", id).Update("allowed_ips", pq.StringArray(allowedIPs)).Error; err != nil {
+		log.Println("Failed to update API key allowed IPs:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) UpdateSignatureRequired(id uuid.UUID, required bool) error {
+	if err := r.db.Model(&models.APIKey{}).Where("id = ?", id).Update("signature_required", required).Error; err != nil {
+		log.Println("Failed to update API key signature requirement:", err)
+		return err
+	}
+	return nil
+}
+
+// MarkReplaced links a rolled-over key to its successor and shortens its
+// lifetime to the end of the grace period. It returns false if the key was
+// already replaced or revoked, so only one rollover of a key wins.
+func (r *apiKeyRepository) MarkReplaced(id uuid.UUID, replacedByID uuid.UUID, expiresAt time.Time) (bool, error) {
+	result := r.db.Model(&models.APIKey{}).
+		Where("id = ? AND replaced_by_id IS NULL AND is_revoked = false", id).
+		Updates(map[string]interface{}{
+			"replaced_by_id": replacedByID,
+			"expires_at":     expiresAt,
+		})
+	if result.Error != nil {
+		log.Println("Failed to mark API key as replaced:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// GetKeysExpiringBefore returns active keys that expire before cutoff and
+// whose owner hasn't been warned yet.
+func (r *apiKeyRepository) GetKeysExpiringBefore(cutoff time.Time) ([]models.APIKey, error) {
+	var apiKeys []models.APIKey
+	if err := r.db.Where("is_revoked = false AND replaced_by_id IS NULL AND expiry_warning_sent_at IS NULL AND expires_at > ? AND expires_at <= ?", time.Now(), cutoff).
+		Find(&apiKeys).Error; err != nil {
+		log.Println("Failed to get expiring API keys:", err)
 		return nil, err
 	}
-	return apiKey, nil
+	return apiKeys, nil
+}
+
+// MarkExpiryWarningSent claims the expiry warning for a key. It returns false
+// if another replica already claimed it.
+func (r *apiKeyRepository) MarkExpiryWarningSent(id uuid.UUID) (bool, error) {
+	result := r.db.Model(&models.APIKey{}).
+		Where("id = ? AND expiry_warning_sent_at IS NULL", id).
+		Update("expiry_warning_sent_at", time.Now())
+	if result.Error != nil {
+		log.Println("Failed to mark API key expiry warning as sent:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
 }
@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"log"
+	"time"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// auditChainLock is the advisory lock key ("audit" in ASCII) that serialises
+// appends, so every event links to the one before it
+const auditChainLock = 0x6175646974
+
+// AuditGenesisHash is the PrevHash of the first audit event
+const AuditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+type AuditRepository interface {
+	AppendAuditEvent(event *models.AuditEvent) error
+	ListAuditEvents(filter dto.AdminAuditFilter) ([]models.AuditEvent, int64, error)
+	GetAuditEventsAfter(seq int64, limit int) ([]models.AuditEvent, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+// AppendAuditEvent links the event to the end of the chain, hashes it and
+// stores it
+func (r *auditRepository) AppendAuditEvent(event *models.AuditEvent) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLock).Error; err != nil {
+			return err
+		}
+
+		var last models.AuditEvent
+		result := tx.Select("seq, hash").Order("seq DESC").Limit(1).Find(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+		event.Seq, event.PrevHash = 1, AuditGenesisHash
+		if result.RowsAffected > 0 {
+			event.Seq, event.PrevHash = last.Seq+1, last.Hash
+		}
+		event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		event.Hash = event.ComputeHash()
+
+		return tx.Create(event).Error
+	})
+	if err != nil {
+		log.Println("Failed to append audit event:", err)
+		return err
+	}
+	return nil
+}
+
+// ListAuditEvents returns audit events newest first
+func (r *auditRepository) ListAuditEvents(filter dto.AdminAuditFilter) ([]models.AuditEvent, int64, error) {
+	query := r.db.Model(&models.AuditEvent{})
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Println("Failed to count audit events:", err)
+		return nil, 0, err
+	}
+	var events []models.AuditEvent
+	if err := query.Order("seq DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&events).Error; err != nil {
+		log.Println("Failed to list audit events:", err)
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+// GetAuditEventsAfter returns up to limit events following seq, oldest first
+func (r *auditRepository) GetAuditEventsAfter(seq int64, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	if err := r.db.Where("seq > ?", seq).Order("seq").Limit(limit).Find(&events).Error; err != nil {
+		log.Println("Failed to read audit events:", err)
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"log"
+	"time"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type EscrowRepository interface {
+	CreateEscrow(escrow *models.Escrow) error
+	GetEscrowByID(id uuid.UUID) (*models.Escrow, error)
+	ListUserEscrows(userID uuid.UUID, mode string, filter dto.EscrowFilter) ([]models.Escrow, int64, error)
+	ListEscrows(status string, limit, offset int) ([]models.Escrow, int64, error)
+	GetExpiredEscrows(now time.Time, limit int) ([]models.Escrow, error)
+	TransitionEscrow(id uuid.UUID, from []string, updates map[string]interface{}) (bool, error)
+}
+
+type escrowRepository struct {
+	db *gorm.DB
+}
+
+func NewEscrowRepository(db *gorm.DB) EscrowRepository {
+	return &escrowRepository{
+		db: db,
+	}
+}
+
+func (r *escrowRepository) CreateEscrow(escrow *models.Escrow) error {
+	if err := r.db.Create(escrow).Error; err != nil {
+		log.Println("Failed to create escrow:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *escrowRepository) GetEscrowByID(id uuid.UUID) (*models.Escrow, error) {
+	var escrow *models.Escrow
+	if err := r.db.Where("id = ?", id).First(&escrow).Error; err != nil {
+		log.Println("Failed to get escrow:", err)
+		return nil, err
+	}
+	return escrow, nil
+}
+
+// ListUserEscrows returns escrows the user pays into or, with role "payee",
+// is paid from, newest first
+func (r *escrowRepository) ListUserEscrows(userID uuid.UUID, mode string, filter dto.EscrowFilter) ([]models.Escrow, int64, error) {
+	query := r.db.Model(&models.Escrow{}).Where("mode = ?", mode)
+	if filter.Role == "payee" {
+		query = query.Where("payee_id = ?", userID)
+	} else {
+		query = query.Where("payer_id = ?", userID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	return r.page(query, filter.Limit, filter.Offset)
+}
+
+// ListEscrows returns escrows across all users, newest first
+func (r *escrowRepository) ListEscrows(status string, limit, offset int) ([]models.Escrow, int64, error) {
+	query := r.db.Model(&models.Escrow{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	return r.page(query, limit, offset)
+}
+
+func (r *escrowRepository) page(query *gorm.DB, limit, offset int) ([]models.Escrow, int64, error) {
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Println("Failed to count escrows:", err)
+		return nil, 0, err
+	}
+	var escrows []models.Escrow
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&escrows).Error; err != nil {
+		log.Println("Failed to list escrows:", err)
+		return nil, 0, err
+	}
+	return escrows, total, nil
+}
+
+// GetExpiredEscrows returns held escrows past their deadline, oldest first.
+// Disputed escrows wait for staff instead.
+func (r *escrowRepository) GetExpiredEscrows(now time.Time, limit int) ([]models.Escrow, error) {
+	var escrows []models.Escrow
+	if err := r.db.Where("status = ? AND expires_at <= ?", models.EscrowHeld, now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&escrows).Error; err != nil {
+		log.Println("Failed to get expired escrows:", err)
+		return nil, err
+	}
+	return escrows, nil
+}
+
+// TransitionEscrow applies updates only while the escrow is in one of the
+// from statuses. It reports false when it wasn't.
+func (r *escrowRepository) TransitionEscrow(id uuid.UUID, from []string, updates map[string]interface{}) (bool, error) {
+	result := r.db.Model(&models.Escrow{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if result.Error != nil {
+		log.Println("Failed to update escrow:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"log"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type FeeRepository interface {
+	ListFeeRules() ([]models.FeeRule, error)
+	GetFeeRuleByID(id uuid.UUID) (*models.FeeRule, error)
+	GetActiveFeeRules(transactionType, currency string) ([]models.FeeRule, error)
+	ActiveFeeRuleExists(transactionType, currency, tier string, excludeID uuid.UUID) (bool, error)
+	CreateFeeRule(rule *models.FeeRule) error
+	SaveFeeRule(rule *models.FeeRule) error
+	DeleteFeeRule(id uuid.UUID) error
+}
+
+type feeRepository struct {
+	db *gorm.DB
+}
+
+func NewFeeRepository(db *gorm.DB) FeeRepository {
+	return &feeRepository{
+		db: db,
+	}
+}
+
+func (r *feeRepository) ListFeeRules() ([]models.FeeRule, error) {
+	var rules []models.FeeRule
+	if err := r.db.Order("transaction_type, currency, tier, created_at").Find(&rules).Error; err != nil {
+		log.Println("Failed to list fee rules:", err)
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *feeRepository) GetFeeRuleByID(id uuid.UUID) (*models.FeeRule, error) {
+	var rule *models.FeeRule
+	if err := r.db.Where("id = ?", id).First(&rule).Error; err != nil {
+		log.Println("Failed to get fee rule:", err)
+		return nil, err
+	}
+	return rule, nil
+}
+
+// GetActiveFeeRules returns the active rules for every tier and for specific
+// tiers; the caller picks the most specific
+func (r *feeRepository) GetActiveFeeRules(transactionType, currency string) ([]models.FeeRule, error) {
+	var rules []models.FeeRule
+	if err := r.db.Where("transaction_type = ? AND currency = ? AND active = true", transactionType, currency).Find(&rules).Error; err != nil {
+		log.Println("Failed to get active fee rules:", err)
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ActiveFeeRuleExists reports whether another active rule already prices
+// the same type, currency and tier
+func (r *feeRepository) ActiveFeeRuleExists(transactionType, currency, tier string, excludeID uuid.UUID) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.FeeRule{}).
+		Where("transaction_type = ? AND currency = ? AND tier = ? AND active = true AND id <> ?", transactionType, currency, tier, excludeID).
+		Count(&count).Error; err != nil {
+		log.Println("Failed to check fee rules:", err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *feeRepository) CreateFeeRule(rule *models.FeeRule) error {
+	if err := r.db.Create(rule).Error; err != nil {
+		log.Println("Failed to create fee rule:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *feeRepository) SaveFeeRule(rule *models.FeeRule) error {
+	if err := r.db.Save(rule).Error; err != nil {
+		log.Println("Failed to save fee rule:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *feeRepository) DeleteFeeRule(id uuid.UUID) error {
+	result := r.db.Where("id = ?", id).Delete(&models.FeeRule{})
+	if result.Error != nil {
+		log.Println("Failed to delete fee rule:", result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
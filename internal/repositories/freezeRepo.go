@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"log"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type FreezeRepository interface {
+	SetUserStatus(userID uuid.UUID, status, reason string, event *models.FreezeEvent) error
+	SetWalletStatus(walletID uuid.UUID, status, reason string, event *models.FreezeEvent) error
+	GetFreezeEvents(userID uuid.UUID) ([]models.FreezeEvent, error)
+}
+
+type freezeRepository struct {
+	db *gorm.DB
+}
+
+func NewFreezeRepository(db *gorm.DB) FreezeRepository {
+	return &freezeRepository{
+		db: db,
+	}
+}
+
+// SetUserStatus changes the user's status and records why, together
+func (r *freezeRepository) SetUserStatus(userID uuid.UUID, status, reason string, event *models.FreezeEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"status":        status,
+			"status_reason": reason,
+		}).Error; err != nil {
+			log.Println("Failed to update user status:", err)
+			return err
+		}
+		if err := tx.Create(event).Error; err != nil {
+			log.Println("Failed to record freeze event:", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// SetWalletStatus changes the wallet's status and records why, together
+func (r *freezeRepository) SetWalletStatus(walletID uuid.UUID, status, reason string, event *models.FreezeEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Wallet{}).Where("id = ?", walletID).Updates(map[string]interface{}{
+			"status":        status,
+			"status_reason": reason,
+		}).Error; err != nil {
+			log.Println("Failed to update wallet status:", err)
+			return err
+		}
+		if err := tx.Create(event).Error; err != nil {
+			log.Println("Failed to record freeze event:", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// GetFreezeEvents returns the status history of a user and their wallets,
+// newest first
+func (r *freezeRepository) GetFreezeEvents(userID uuid.UUID) ([]models.FreezeEvent, error) {
+	var events []models.FreezeEvent
+	if err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&events).Error; err != nil {
+		log.Println("Failed to get freeze events:", err)
+		return nil, err
+	}
+	return events, nil
+}
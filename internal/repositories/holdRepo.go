@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"log"
+	"time"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type HoldRepository interface {
+	CreateHold(hold *models.Hold) error
+	GetHoldByID(id uuid.UUID) (*models.Hold, error)
+	ListUserHolds(userID uuid.UUID, mode string, filter dto.HoldFilter) ([]models.Hold, int64, error)
+	GetExpiredHolds(now time.Time, limit int) ([]models.Hold, error)
+	TransitionHold(id uuid.UUID, from []string, updates map[string]interface{}) (bool, error)
+}
+
+type holdRepository struct {
+	db *gorm.DB
+}
+
+func NewHoldRepository(db *gorm.DB) HoldRepository {
+	return &holdRepository{
+		db: db,
+	}
+}
+
+func (r *holdRepository) CreateHold(hold *models.Hold) error {
+	if err := r.db.Create(hold).Error; err != nil {
+		log.Println("Failed to create hold:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *holdRepository) GetHoldByID(id uuid.UUID) (*models.Hold, error) {
+	var hold *models.Hold
+	if err := r.db.Where("id = ?", id).First(&hold).Error; err != nil {
+		log.Println("Failed to get hold:", err)
+		return nil, err
+	}
+	return hold, nil
+}
+
+// ListUserHolds returns holds on the user's wallet or, with role "payee",
+// placed for them, newest first
+func (r *holdRepository) ListUserHolds(userID uuid.UUID, mode string, filter dto.HoldFilter) ([]models.Hold, int64, error) {
+	query := r.db.Model(&models.Hold{}).Where("mode = ?", mode)
+	if filter.Role == "payee" {
+		query = query.Where("payee_id = ?", userID)
+	} else {
+		query = query.Where("user_id = ?", userID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Println("Failed to count holds:", err)
+		return nil, 0, err
+	}
+	var holds []models.Hold
+	if err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&holds).Error; err != nil {
+		log.Println("Failed to list holds:", err)
+		return nil, 0, err
+	}
+	return holds, total, nil
+}
+
+// GetExpiredHolds returns active holds past their expiry, oldest first
+func (r *holdRepository) GetExpiredHolds(now time.Time, limit int) ([]models.Hold, error) {
+	var holds []models.Hold
+	if err := r.db.Where("status = ? AND expires_at <= ?", models.HoldActive, now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&holds).Error; err != nil {
+		log.Println("Failed to get expired holds:", err)
+		return nil, err
+	}
+	return holds, nil
+}
+
+// TransitionHold applies updates only while the hold is in one of the from
+// statuses. It reports false when it wasn't.
+func (r *holdRepository) TransitionHold(id uuid.UUID, from []string, updates map[string]interface{}) (bool, error) {
+	result := r.db.Model(&models.Hold{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if result.Error != nil {
+		log.Println("Failed to update hold:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"log"
+	"whotterre/argent/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type IdentityRepository interface {
+	GetUserByIdentity(provider, subject string) (*models.User, error)
+	CreateIdentity(identity *models.UserIdentity) error
+}
+
+type identityRepository struct {
+	db *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) IdentityRepository {
+	return &identityRepository{
+		db: db,
+	}
+}
+
+// GetUserByIdentity returns the user linked to the provider account, with
+// their live wallet, or gorm.ErrRecordNotFound.
+func (r *identityRepository) GetUserByIdentity(provider, subject string) (*models.User, error) {
+	var user models.User
+	err := r.db.Preload("Wallet", "mode = ?", models.ModeLive).
+		Joins("JOIN user_identities ON user_identities.user_id = users.id").
+		Where("user_identities.provider = ? AND user_identities.subject = ?", provider, subject).
+		First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *identityRepository) CreateIdentity(identity *models.UserIdentity) error {
+	if err := r.db.Create(identity).Error; err != nil {
+		log.Println("Failed to link user identity:", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"log"
+	"time"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NonceRepository interface {
+	UseNonce(keyID uuid.UUID, nonce string, expiresAt time.Time) (bool, error)
+	DeleteExpiredNonces() error
+}
+
+type nonceRepository struct {
+	db *gorm.DB
+}
+
+func NewNonceRepository(db *gorm.DB) NonceRepository {
+	return &nonceRepository{
+		db: db,
+	}
+}
+
+// UseNonce records the nonce for the key. It returns false if the nonce has
+// already been used.
+func (r *nonceRepository) UseNonce(keyID uuid.UUID, nonce string, expiresAt time.Time) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RequestNonce{
+		KeyID:     keyID,
+		Nonce:     nonce,
+		ExpiresAt: expiresAt,
+	})
+	if result.Error != nil {
+		log.Println("Failed to record request nonce:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *nonceRepository) DeleteExpiredNonces() error {
+	if err := r.db.Where("expires_at < ?", time.Now()).Delete(&models.RequestNonce{}).Error; err != nil {
+		log.Println("Failed to delete expired nonces:", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"log"
+	"time"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository interface {
+	CreateEvent(event *models.OutboxEvent) error
+	GetEventByID(id uuid.UUID) (*models.OutboxEvent, error)
+	LockUndispatched(limit int) ([]models.OutboxEvent, error)
+	MarkDispatched(ids []uuid.UUID, at time.Time) error
+	CreateDomainEvent(event *models.DomainEvent) error
+	LockUnpublished(limit int) ([]models.DomainEvent, error)
+	LeaseDomainEvents(ids []uuid.UUID, until time.Time) error
+	MarkPublished(ids []uuid.UUID, at time.Time) error
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{
+		db: db,
+	}
+}
+
+func (r *outboxRepository) CreateEvent(event *models.OutboxEvent) error {
+	if err := r.db.Create(event).Error; err != nil {
+		log.Println("Failed to create outbox event:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *outboxRepository) GetEventByID(id uuid.UUID) (*models.OutboxEvent, error) {
+	var event *models.OutboxEvent
+	if err := r.db.Where("id = ?", id).First(&event).Error; err != nil {
+		log.Println("Failed to get outbox event:", err)
+		return nil, err
+	}
+	return event, nil
+}
+
+// LockUndispatched returns events not yet fanned out, oldest first, locking
+// them until the caller's transaction ends. Rows locked by another replica
+// are skipped.
+func (r *outboxRepository) LockUndispatched(limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("dispatched_at IS NULL").
+		Order("created_at").
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		log.Println("Failed to get undispatched outbox events:", err)
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *outboxRepository) MarkDispatched(ids []uuid.UUID, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := r.db.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Update("dispatched_at", at).Error; err != nil {
+		log.Println("Failed to mark outbox events dispatched:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *outboxRepository) CreateDomainEvent(event *models.DomainEvent) error {
+	if err := r.db.Create(event).Error; err != nil {
+		log.Println("Failed to create domain event:", err)
+		return err
+	}
+	return nil
+}
+
+// LockUnpublished returns the oldest domain events not yet published, by
+// created_at, locking them until the caller's transaction ends. It waits for
+// rows another replica is claiming rather than skipping them. created_at is
+// set before commit, so an event committed late may be claimed after newer
+// ones.
+func (r *outboxRepository) LockUnpublished(limit int) ([]models.DomainEvent, error) {
+	var events []models.DomainEvent
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("published_at IS NULL").
+		Order("created_at").
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		log.Println("Failed to get unpublished domain events:", err)
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *outboxRepository) MarkPublished(ids []uuid.UUID, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := r.db.Model(&models.DomainEvent{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+		"published_at": at,
+		"lease_until":  nil,
+	}).Error; err != nil {
+		log.Println("Failed to mark domain events published:", err)
+		return err
+	}
+	return nil
+}
+
+// LeaseDomainEvents marks domain events as being published by the caller
+// until the lease runs out
+func (r *outboxRepository) LeaseDomainEvents(ids []uuid.UUID, until time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := r.db.Model(&models.DomainEvent{}).Where("id IN ?", ids).Update("lease_until", until).Error; err != nil {
+		log.Println("Failed to lease domain events:", err)
+		return err
+	}
+	return nil
+}
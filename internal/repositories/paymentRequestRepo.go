@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"log"
+	"time"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PaymentRequestRepository interface {
+	CreatePaymentRequest(request *models.PaymentRequest) error
+	GetPaymentRequestByID(id uuid.UUID) (*models.PaymentRequest, error)
+	GetPaymentRequestByCode(code string) (*models.PaymentRequest, error)
+	ListPaymentRequests(userID uuid.UUID, mode string, filter dto.PaymentRequestFilter) ([]models.PaymentRequest, int64, error)
+	TransitionPaymentRequest(id uuid.UUID, from []string, updates map[string]interface{}) (bool, error)
+	ExpirePaymentRequests(now time.Time) (int64, error)
+}
+
+type paymentRequestRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRequestRepository(db *gorm.DB) PaymentRequestRepository {
+	return &paymentRequestRepository{
+		db: db,
+	}
+}
+
+func (r *paymentRequestRepository) CreatePaymentRequest(request *models.PaymentRequest) error {
+	if err := r.db.Create(request).Error; err != nil {
+		log.Println("Failed to create payment request:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *paymentRequestRepository) GetPaymentRequestByID(id uuid.UUID) (*models.PaymentRequest, error) {
+	var request *models.PaymentRequest
+	if err := r.db.Where("id = ?", id).First(&request).Error; err != nil {
+		log.Println("Failed to get payment request:", err)
+		return nil, err
+	}
+	return request, nil
+}
+
+func (r *paymentRequestRepository) GetPaymentRequestByCode(code string) (*models.PaymentRequest, error) {
+	var request *models.PaymentRequest
+	if err := r.db.Where("code = ?", code).First(&request).Error; err != nil {
+		log.Println("Failed to get payment request by code:", err)
+		return nil, err
+	}
+	return request, nil
+}
+
+// ListPaymentRequests returns requests the user sent or, with direction
+// "received", was asked to pay, newest first
+func (r *paymentRequestRepository) ListPaymentRequests(userID uuid.UUID, mode string, filter dto.PaymentRequestFilter) ([]models.PaymentRequest, int64, error) {
+	query := r.db.Model(&models.PaymentRequest{}).Where("mode = ?", mode)
+	if filter.Direction == "received" {
+		query = query.Where("payer_id = ?", userID)
+	} else {
+		query = query.Where("requester_id = ?", userID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Println("Failed to count payment requests:", err)
+		return nil, 0, err
+	}
+	var requests []models.PaymentRequest
+	if err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&requests).Error; err != nil {
+		log.Println("Failed to list payment requests:", err)
+		return nil, 0, err
+	}
+	return requests, total, nil
+}
+
+// TransitionPaymentRequest applies updates only while the request is in one
+// of the from statuses. It reports false when it wasn't.
+func (r *paymentRequestRepository) TransitionPaymentRequest(id uuid.UUID, from []string, updates map[string]interface{}) (bool, error) {
+	result := r.db.Model(&models.PaymentRequest{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if result.Error != nil {
+		log.Println("Failed to update payment request:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ExpirePaymentRequests marks open requests past their expiry as expired
+func (r *paymentRequestRepository) ExpirePaymentRequests(now time.Time) (int64, error) {
+	result := r.db.Model(&models.PaymentRequest{}).
+		Where("status = ? AND expires_at <= ?", models.PaymentRequestOpen, now).
+		Update("status", models.PaymentRequestExpired)
+	if result.Error != nil {
+		log.Println("Failed to expire payment requests:", result.Error)
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
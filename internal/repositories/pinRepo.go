@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"log"
+	"time"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PINRepository interface {
+	SetInitialPIN(userID uuid.UUID, pinHash string) (bool, error)
+	UpdatePIN(userID uuid.UUID, pinHash string) error
+	ClearPINFailures(userID uuid.UUID) error
+	ReservePINAttempt(userID uuid.UUID, maxAttempts int, lockUntil time.Time) (bool, bool, error)
+}
+
+type pinRepository struct {
+	db *gorm.DB
+}
+
+func NewPINRepository(db *gorm.DB) PINRepository {
+	return &pinRepository{
+		db: db,
+	}
+}
+
+// SetInitialPIN stores the user's first PIN. It returns false if a PIN is
+// already set, so a racing request can't overwrite it.
+func (r *pinRepository) SetInitialPIN(userID uuid.UUID, pinHash string) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND pin_hash IS NULL", userID).
+		Update("pin_hash", pinHash)
+	if result.Error != nil {
+		log.Println("Failed to set transaction PIN:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UpdatePIN replaces the PIN and lifts any lockout
+func (r *pinRepository) UpdatePIN(userID uuid.UUID, pinHash string) error {
+	if err := r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"pin_hash":            pinHash,
+		"pin_failed_attempts": 0,
+		"pin_locked_until":    nil,
+	}).Error; err != nil {
+		log.Println("Failed to update transaction PIN:", err)
+		return err
+	}
+	return nil
+}
+
+// ClearPINFailures forgets the attempts counted against the PIN, and the
+// lock if one of them set it, once the right PIN has been given
+func (r *pinRepository) ClearPINFailures(userID uuid.UUID) error {
+	if err := r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"pin_failed_attempts": 0,
+		"pin_locked_until":    nil,
+	}).Error; err != nil {
+		log.Println("Failed to clear PIN failures:", err)
+		return err
+	}
+	return nil
+}
+
+// ReservePINAttempt counts an attempt against the PIN before it is checked,
+// so parallel guesses can't get past the lock. Each attempt counts as wrong
+// until ClearPINFailures is called. The attempt that reaches maxAttempts
+// locks the PIN until lockUntil straight away and restarts the count for
+// after the lock. It reports whether the attempt may go ahead, which it may
+// not while the PIN is locked, and whether it set the lock.
+func (r *pinRepository) ReservePINAttempt(userID uuid.UUID, maxAttempts int, lockUntil time.Time) (bool, bool, error) {
+	var users []models.User
+	result := r.db.Model(&users).Clauses(clause.Returning{Columns: []clause.Column{{Name: "pin_locked_until"}}}).
+		Where("id = ? AND (pin_locked_until IS NULL OR pin_locked_until <= ?)", userID, time.Now()).
+		Updates(map[string]interface{}{
+			"pin_locked_until":    gorm.Expr("CASE WHEN pin_failed_attempts + 1 >= ? THEN ? ELSE pin_locked_until END", maxAttempts, lockUntil),
+			"pin_failed_attempts": gorm.Expr("CASE WHEN pin_failed_attempts + 1 >= ? THEN 0 ELSE pin_failed_attempts + 1 END", maxAttempts),
+		})
+	if result.Error != nil {
+		log.Println("Failed to reserve PIN attempt:", result.Error)
+		return false, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, false, nil
+	}
+	// Only an unlocked PIN can be reserved, so a lock in the future is new
+	locked := len(users) > 0 && users[0].PINLockedUntil != nil && users[0].PINLockedUntil.After(time.Now())
+	return true, locked, nil
+}
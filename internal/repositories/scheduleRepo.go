@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"log"
+	"time"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ScheduleRepository interface {
+	CreateSchedule(schedule *models.ScheduledTransfer) error
+	GetUserSchedule(id, userID uuid.UUID) (*models.ScheduledTransfer, error)
+	GetUserSchedules(userID uuid.UUID, mode string) ([]models.ScheduledTransfer, error)
+	CountActiveSchedules(userID uuid.UUID) (int64, error)
+	GetDueSchedules(now time.Time, limit int) ([]models.ScheduledTransfer, error)
+	ClaimSchedule(id uuid.UUID, nextRunAt, leaseUntil time.Time) (bool, error)
+	UpdateSchedule(id uuid.UUID, updates map[string]interface{}) error
+	TransitionSchedule(id uuid.UUID, from []string, updates map[string]interface{}) (bool, error)
+	GetOrCreateRun(scheduleID uuid.UUID, occurrenceAt time.Time) (*models.ScheduledTransferRun, error)
+	SaveRun(run *models.ScheduledTransferRun) error
+	GetRuns(scheduleID uuid.UUID, limit int) ([]models.ScheduledTransferRun, error)
+	CountSuccessfulRuns(scheduleID uuid.UUID) (int64, error)
+}
+
+type scheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduleRepository(db *gorm.DB) ScheduleRepository {
+	return &scheduleRepository{
+		db: db,
+	}
+}
+
+func (r *scheduleRepository) CreateSchedule(schedule *models.ScheduledTransfer) error {
+	if err := r.db.Create(schedule).Error; err != nil {
+		log.Println("Failed to create scheduled transfer:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *scheduleRepository) GetUserSchedule(id, userID uuid.UUID) (*models.ScheduledTransfer, error) {
+	var schedule *models.ScheduledTransfer
+	if err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&schedule).Error; err != nil {
+		log.Println("Failed to get scheduled transfer:", err)
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (r *scheduleRepository) GetUserSchedules(userID uuid.UUID, mode string) ([]models.ScheduledTransfer, error) {
+	var schedules []models.ScheduledTransfer
+	if err := r.db.Where("user_id = ? AND mode = ?", userID, mode).Order("created_at DESC").Find(&schedules).Error; err != nil {
+		log.Println("Failed to get scheduled transfers:", err)
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// CountActiveSchedules counts the user's schedules that haven't ended,
+// including paused ones
+func (r *scheduleRepository) CountActiveSchedules(userID uuid.UUID) (int64, error) {
+	var count int64
+	if err := r.db.Model(&models.ScheduledTransfer{}).
+		Where("user_id = ? AND status IN ?", userID, []string{models.ScheduleActive, models.SchedulePaused}).
+		Count(&count).Error; err != nil {
+		log.Println("Failed to count scheduled transfers:", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetDueSchedules returns active schedules whose next run is due, oldest
+// first
+func (r *scheduleRepository) GetDueSchedules(now time.Time, limit int) ([]models.ScheduledTransfer, error) {
+	var schedules []models.ScheduledTransfer
+	if err := r.db.Where("status = ? AND next_run_at <= ?", models.ScheduleActive, now).
+		Order("next_run_at").
+		Limit(limit).
+		Find(&schedules).Error; err != nil {
+		log.Println("Failed to get due scheduled transfers:", err)
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// ClaimSchedule pushes a due schedule's next run to leaseUntil, so other
+// replicas leave it alone while this one runs it. It reports false when
+// another replica claimed it first. If the claimer dies, the schedule is
+// picked up again once the lease runs out.
+func (r *scheduleRepository) ClaimSchedule(id uuid.UUID, nextRunAt, leaseUntil time.Time) (bool, error) {
+	result := r.db.Model(&models.ScheduledTransfer{}).
+		Where("id = ? AND status = ? AND next_run_at = ?", id, models.ScheduleActive, nextRunAt).
+		Update("next_run_at", leaseUntil)
+	if result.Error != nil {
+		log.Println("Failed to claim scheduled transfer:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *scheduleRepository) UpdateSchedule(id uuid.UUID, updates map[string]interface{}) error {
+	if err := r.db.Model(&models.ScheduledTransfer{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		log.Println("Failed to update scheduled transfer:", err)
+		return err
+	}
+	return nil
+}
+
+// TransitionSchedule applies updates only while the schedule is in one of
+// the from statuses. It reports false when it wasn't.
+func (r *scheduleRepository) TransitionSchedule(id uuid.UUID, from []string, updates map[string]interface{}) (bool, error) {
+	result := r.db.Model(&models.ScheduledTransfer{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if result.Error != nil {
+		log.Println("Failed to update scheduled transfer:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetOrCreateRun returns the occurrence's run, creating it as pending on
+// the first attempt
+func (r *scheduleRepository) GetOrCreateRun(scheduleID uuid.UUID, occurrenceAt time.Time) (*models.ScheduledTransferRun, error) {
+	run := models.ScheduledTransferRun{
+		ScheduleID:   scheduleID,
+		OccurrenceAt: occurrenceAt,
+		Status:       models.ScheduleRunPending,
+	}
+	if err := r.db.Where("schedule_id = ? AND occurrence_at = ?", scheduleID, occurrenceAt).FirstOrCreate(&run).Error; err != nil {
+		log.Println("Failed to get scheduled transfer run:", err)
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (r *scheduleRepository) SaveRun(run *models.ScheduledTransferRun) error {
+	if err := r.db.Save(run).Error; err != nil {
+		log.Println("Failed to save scheduled transfer run:", err)
+		return err
+	}
+	return nil
+}
+
+// GetRuns returns the schedule's most recent runs, newest first
+func (r *scheduleRepository) GetRuns(scheduleID uuid.UUID, limit int) ([]models.ScheduledTransferRun, error) {
+	var runs []models.ScheduledTransferRun
+	if err := r.db.Where("schedule_id = ?", scheduleID).Order("occurrence_at DESC").Limit(limit).Find(&runs).Error; err != nil {
+		log.Println("Failed to get scheduled transfer runs:", err)
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (r *scheduleRepository) CountSuccessfulRuns(scheduleID uuid.UUID) (int64, error) {
+	var count int64
+	if err := r.db.Model(&models.ScheduledTransferRun{}).
+		Where("schedule_id = ? AND status = ?", scheduleID, models.ScheduleRunSuccess).
+		Count(&count).Error; err != nil {
+		log.Println("Failed to count scheduled transfer runs:", err)
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"log"
+	"time"
+	"whotterre/argent/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type SigningKeyRepository interface {
+	CreateSigningKey(key *models.SigningKey) error
+	GetVerificationKeys() ([]models.SigningKey, error)
+	RetireKeysExcept(kid string, expiresAt time.Time) error
+}
+
+type signingKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewSigningKeyRepository(db *gorm.DB) SigningKeyRepository {
+	return &signingKeyRepository{
+		db: db,
+	}
+}
+
+func (r *signingKeyRepository) CreateSigningKey(key *models.SigningKey) error {
+	if err := r.db.Create(key).Error; err != nil {
+		log.Println("Failed to create signing key:", err)
+		return err
+	}
+	return nil
+}
+
+// GetVerificationKeys returns every key that may still verify tokens, newest first
+func (r *signingKeyRepository) GetVerificationKeys() ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	if err := r.db.Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("created_at DESC").
+		Find(&keys).Error; err != nil {
+		log.Println("Failed to get signing keys:", err)
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RetireKeysExcept stops every other active key from signing and schedules
+// it to leave the key set at expiresAt.
+func (r *signingKeyRepository) RetireKeysExcept(kid string, expiresAt time.Time) error {
+	if err := r.db.Model(&models.SigningKey{}).
+		Where("kid <> ? AND retired_at IS NULL", kid).
+		Updates(map[string]interface{}{
+			"retired_at": time.Now(),
+			"expires_at": expiresAt,
+		}).Error; err != nil {
+		log.Println("Failed to retire signing keys:", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package repositories
+
+import (
+	"log"
+	"time"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TokenRepository interface {
+	CreateRefreshToken(token *models.RefreshToken) error
+	GetRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error)
+	RotateRefreshToken(oldID uuid.UUID, newToken *models.RefreshToken) error
+	RevokeRefreshTokenFamily(familyID uuid.UUID) error
+	RevokeUserRefreshTokens(userID uuid.UUID) error
+	RevokeAccessToken(tokenID string, expiresAt time.Time) error
+	IsAccessTokenRevoked(tokenID string) (bool, error)
+	CreateLoginCode(code *models.LoginCode) error
+	ConsumeLoginCode(codeHash string) (*models.LoginCode, error)
+	CreateEmailToken(token *models.EmailToken) error
+	ConsumeEmailToken(tokenHash, purpose string) (*models.EmailToken, error)
+	DeleteExpiredTokens() error
+}
+
+type tokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) TokenRepository {
+	return &tokenRepository{
+		db: db,
+	}
+}
+
+func (r *tokenRepository) CreateRefreshToken(token *models.RefreshToken) error {
+	if err := r.db.Create(token).Error; err != nil {
+		log.Println("Failed to create refresh token:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *tokenRepository) GetRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error) {
+	var token *models.RefreshToken
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		log.Println("Failed to get refresh token:", err)
+		return nil, err
+	}
+	return token, nil
+}
+
+// RotateRefreshToken stores newToken and marks the old token as replaced in
+// one transaction. If the old token was used concurrently the rotation fails
+// with ErrRefreshTokenReused.
+func (r *tokenRepository) RotateRefreshToken(oldID uuid.UUID, newToken *models.RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(newToken).Error; err != nil {
+			log.Println("Failed to create rotated refresh token:", err)
+			return err
+		}
+
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND replaced_by_id IS NULL AND revoked_at IS NULL", oldID).
+			Update("replaced_by_id", newToken.ID)
+		if result.Error != nil {
+			log.Println("Failed to mark refresh token as replaced:", result.Error)
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return customErrors.ErrRefreshTokenReused
+		}
+		return nil
+	})
+}
+
+func (r *tokenRepository) RevokeRefreshTokenFamily(familyID uuid.UUID) error {
+	if err := r.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		log.Println("Failed to revoke refresh token family:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *tokenRepository) RevokeUserRefreshTokens(userID uuid.UUID) error {
+	if err := r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		log.Println("Failed to revoke user's refresh tokens:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *tokenRepository) RevokeAccessToken(tokenID string, expiresAt time.Time) error {
+	revoked := models.RevokedToken{
+		TokenID:   tokenID,
+		ExpiresAt: expiresAt,
+	}
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error; err != nil {
+		log.Println("Failed to revoke access token:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *tokenRepository) IsAccessTokenRevoked(tokenID string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.RevokedToken{}).Where("token_id = ?", tokenID).Count(&count).Error; err != nil {
+		log.Println("Failed to check access token revocation:", err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *tokenRepository) CreateLoginCode(code *models.LoginCode) error {
+	if err := r.db.Create(code).Error; err != nil {
+		log.Println("Failed to create login code:", err)
+		return err
+	}
+	return nil
+}
+
+// ConsumeLoginCode deletes and returns an unexpired login code, so that only
+// one exchange can ever succeed.
+func (r *tokenRepository) ConsumeLoginCode(codeHash string) (*models.LoginCode, error) {
+	var codes []models.LoginCode
+	result := r.db.Clauses(clause.Returning{}).
+		Where("code_hash = ? AND expires_at > ?", codeHash, time.Now()).
+		Delete(&codes)
+	if result.Error != nil {
+		log.Println("Failed to consume login code:", result.Error)
+		return nil, result.Error
+	}
+	if len(codes) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &codes[0], nil
+}
+
+// CreateEmailToken stores a new email token, replacing any outstanding token
+// for the same purpose and address so only the latest email works.
+func (r *tokenRepository) CreateEmailToken(token *models.EmailToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("purpose = ? AND LOWER(email) = LOWER(?)", token.Purpose, token.Email).Delete(&models.EmailToken{}).Error; err != nil {
+			log.Println("Failed to replace email tokens:", err)
+			return err
+		}
+		if err := tx.Create(token).Error; err != nil {
+			log.Println("Failed to create email token:", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// ConsumeEmailToken deletes and returns an unexpired email token of the given
+// purpose, so that each token works once.
+func (r *tokenRepository) ConsumeEmailToken(tokenHash, purpose string) (*models.EmailToken, error) {
+	var tokens []models.EmailToken
+	result := r.db.Clauses(clause.Returning{}).
+		Where("token_hash = ? AND purpose = ? AND expires_at > ?", tokenHash, purpose, time.Now()).
+		Delete(&tokens)
+	if result.Error != nil {
+		log.Println("Failed to consume email token:", result.Error)
+		return nil, result.Error
+	}
+	if len(tokens) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &tokens[0], nil
+}
+
+// DeleteExpiredTokens prunes refresh tokens and revocation entries that can
+// no longer be presented anyway.
+func (r *tokenRepository) DeleteExpiredTokens() error {
+	now := time.Now()
+	if err := r.db.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		log.Println("Failed to delete expired revoked tokens:", err)
+		return err
+	}
+	if err := r.db.Where("expires_at < ?", now).Delete(&models.RefreshToken{}).Error; err != nil {
+		log.Println("Failed to delete expired refresh tokens:", err)
+		return err
+	}
+	if err := r.db.Where("expires_at < ?", now).Delete(&models.LoginCode{}).Error; err != nil {
+		log.Println("Failed to delete expired login codes:", err)
+		return err
+	}
+	if err := r.db.Where("expires_at < ?", now).Delete(&models.EmailToken{}).Error; err != nil {
+		log.Println("Failed to delete expired email tokens:", err)
+		return err
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"log"
+	"whotterre/argent/internal/dto"
 	"whotterre/argent/internal/models"
 
 	"github.com/google/uuid"
@@ -10,10 +11,14 @@ import (
 
 type TransactionRepository interface {
 	CreateTransaction(transaction *models.Transaction) error
-	GetUserTransactions(userID uuid.UUID) ([]models.Transaction, error)
+	GetUserTransactions(userID uuid.UUID, mode string) ([]models.Transaction, error)
 	GetTransactionByID(id uuid.UUID) (*models.Transaction, error)
 	GetTransactionByReference(reference string) (*models.Transaction, error)
+	GetTransactionByClientReference(senderID uuid.UUID, clientReference string) (*models.Transaction, error)
 	UpdateTransactionStatus(id uuid.UUID, status string) error
+	TransitionStatus(id uuid.UUID, from []string, to string) (bool, error)
+	ListTransactions(filter dto.AdminTransactionFilter) ([]models.Transaction, int64, error)
+	GetHeldDeposits(userID uuid.UUID) ([]models.Transaction, error)
 }
 
 type transactionRepository struct {
@@ -34,9 +39,9 @@ func (r *transactionRepository) CreateTransaction(transaction *models.Transactio
 	return nil
 }
 
-func (r *transactionRepository) GetUserTransactions(userID uuid.UUID) ([]models.Transaction, error) {
+func (r *transactionRepository) GetUserTransactions(userID uuid.UUID, mode string) ([]models.Transaction, error) {
 	var transactions []models.Transaction
-	if err := r.db.Where("receiver_id = ? OR sender_id = ?", userID, userID).
+	if err := r.db.Where("(receiver_id = ? OR sender_id = ?) AND mode = ?", userID, userID, mode).
 		Order("created_at DESC").
 		Find(&transactions).Error; err != nil {
 		log.Println("Failed to get user transactions:", err)
@@ -63,6 +68,17 @@ func (r *transactionRepository) GetTransactionByReference(reference string) (*mo
 	return transaction, nil
 }
 
+// GetTransactionByClientReference finds a transaction the sender tagged with
+// their own reference
+func (r *transactionRepository) GetTransactionByClientReference(senderID uuid.UUID, clientReference string) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	if err := r.db.Where("sender_id = ? AND client_reference = ?", senderID, clientReference).First(&transaction).Error; err != nil {
+		log.Println("Failed to get transaction by client reference:", err)
+		return nil, err
+	}
+	return transaction, nil
+}
+
 func (r *transactionRepository) UpdateTransactionStatus(id uuid.UUID, status string) error {
 	if err := r.db.Model(&models.Transaction{}).Where("id = ?", id).Update("status", status).Error; err != nil {
 		log.Println("Failed to update transaction status:", err)
@@ -70,3 +86,62 @@ func (r *transactionRepository) UpdateTransactionStatus(id uuid.UUID, status str
 	}
 	return nil
 }
+
+// TransitionStatus moves the transaction to a new status only if it is still
+// in one of the from statuses, so two callers can't both act on it
+func (r *transactionRepository) TransitionStatus(id uuid.UUID, from []string, to string) (bool, error) {
+	result := r.db.Model(&models.Transaction{}).Where("id = ? AND status IN ?", id, from).Update("status", to)
+	if result.Error != nil {
+		log.Println("Failed to update transaction status:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ListTransactions searches every user's transactions for the admin API,
+// newest first
+func (r *transactionRepository) ListTransactions(filter dto.AdminTransactionFilter) ([]models.Transaction, int64, error) {
+	query := r.db.Model(&models.Transaction{})
+	if filter.UserID != nil {
+		query = query.Where("receiver_id = ? OR sender_id = ?", *filter.UserID, *filter.UserID)
+	}
+	if filter.Reference != "" {
+		query = query.Where("reference = ?", filter.Reference)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Mode != "" {
+		query = query.Where("mode = ?", filter.Mode)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Println("Failed to count transactions:", err)
+		return nil, 0, err
+	}
+	var transactions []models.Transaction
+	if err := query.Preload("Sender").Preload("Receiver").
+		Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).
+		Find(&transactions).Error; err != nil {
+		log.Println("Failed to list transactions:", err)
+		return nil, 0, err
+	}
+	return transactions, total, nil
+}
+
+// GetHeldDeposits returns the user's deposits that arrived while their
+// account couldn't be credited
+func (r *transactionRepository) GetHeldDeposits(userID uuid.UUID) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	if err := r.db.Where("receiver_id = ? AND type = ? AND status = ?", userID, "deposit", models.TransactionHeld).
+		Order("created_at").
+		Find(&transactions).Error; err != nil {
+		log.Println("Failed to get held deposits:", err)
+		return nil, err
+	}
+	return transactions, nil
+}
@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"log"
+	"time"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TransferBatchRepository interface {
+	CreateBatch(batch *models.TransferBatch, items []models.TransferBatchItem) error
+	GetUserBatch(id, userID uuid.UUID, mode string) (*models.TransferBatch, error)
+	ListUserBatches(userID uuid.UUID, mode string, limit, offset int) ([]models.TransferBatch, int64, error)
+	GetBatchItems(batchID uuid.UUID) ([]models.TransferBatchItem, error)
+	GetRunnableBatches(now time.Time, limit int) ([]models.TransferBatch, error)
+	ClaimBatch(id uuid.UUID, now, leaseUntil time.Time) (bool, error)
+	UpdateBatch(id uuid.UUID, updates map[string]interface{}) error
+	UpdateItem(id uuid.UUID, updates map[string]interface{}) error
+}
+
+type transferBatchRepository struct {
+	db *gorm.DB
+}
+
+func NewTransferBatchRepository(db *gorm.DB) TransferBatchRepository {
+	return &transferBatchRepository{
+		db: db,
+	}
+}
+
+// CreateBatch saves a batch and its items together
+func (r *transferBatchRepository) CreateBatch(batch *models.TransferBatch, items []models.TransferBatchItem) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(batch).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].BatchID = batch.ID
+		}
+		return tx.CreateInBatches(items, 100).Error
+	})
+	if err != nil {
+		log.Println("Failed to create transfer batch:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *transferBatchRepository) GetUserBatch(id, userID uuid.UUID, mode string) (*models.TransferBatch, error) {
+	var batch *models.TransferBatch
+	if err := r.db.Where("id = ? AND user_id = ? AND mode = ?", id, userID, mode).First(&batch).Error; err != nil {
+		log.Println("Failed to get transfer batch:", err)
+		return nil, err
+	}
+	return batch, nil
+}
+
+// ListUserBatches returns the user's batches in a mode, newest first
+func (r *transferBatchRepository) ListUserBatches(userID uuid.UUID, mode string, limit, offset int) ([]models.TransferBatch, int64, error) {
+	query := r.db.Model(&models.TransferBatch{}).Where("user_id = ? AND mode = ?", userID, mode)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Println("Failed to count transfer batches:", err)
+		return nil, 0, err
+	}
+	var batches []models.TransferBatch
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&batches).Error; err != nil {
+		log.Println("Failed to list transfer batches:", err)
+		return nil, 0, err
+	}
+	return batches, total, nil
+}
+
+// GetBatchItems returns a batch's items in submission order
+func (r *transferBatchRepository) GetBatchItems(batchID uuid.UUID) ([]models.TransferBatchItem, error) {
+	var items []models.TransferBatchItem
+	if err := r.db.Where("batch_id = ?", batchID).Order("line").Find(&items).Error; err != nil {
+		log.Println("Failed to get transfer batch items:", err)
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetRunnableBatches returns queued batches, and batches whose lease ran out
+// because the replica paying them stopped, oldest first
+func (r *transferBatchRepository) GetRunnableBatches(now time.Time, limit int) ([]models.TransferBatch, error) {
+	var batches []models.TransferBatch
+	if err := r.db.Where("status = ? OR (status = ? AND lease_until <= ?)", models.BatchQueued, models.BatchProcessing, now).
+		Order("created_at").
+		Limit(limit).
+		Find(&batches).Error; err != nil {
+		log.Println("Failed to get runnable transfer batches:", err)
+		return nil, err
+	}
+	return batches, nil
+}
+
+// ClaimBatch leases a runnable batch to the caller until leaseUntil. It
+// reports false when another replica claimed it first.
+func (r *transferBatchRepository) ClaimBatch(id uuid.UUID, now, leaseUntil time.Time) (bool, error) {
+	result := r.db.Model(&models.TransferBatch{}).
+		Where("id = ? AND (status = ? OR (status = ? AND lease_until <= ?))", id, models.BatchQueued, models.BatchProcessing, now).
+		Updates(map[string]interface{}{
+			"status":      models.BatchProcessing,
+			"lease_until": leaseUntil,
+			"started_at":  gorm.Expr("COALESCE(started_at, ?)", now),
+		})
+	if result.Error != nil {
+		log.Println("Failed to claim transfer batch:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *transferBatchRepository) UpdateBatch(id uuid.UUID, updates map[string]interface{}) error {
+	if err := r.db.Model(&models.TransferBatch{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		log.Println("Failed to update transfer batch:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *transferBatchRepository) UpdateItem(id uuid.UUID, updates map[string]interface{}) error {
+	if err := r.db.Model(&models.TransferBatchItem{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		log.Println("Failed to update transfer batch item:", err)
+		return err
+	}
+	return nil
+}
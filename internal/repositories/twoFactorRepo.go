@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"log"
+	"time"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TwoFactorRepository interface {
+	SetPendingTOTPSecret(userID uuid.UUID, encryptedSecret string) error
+	EnableTOTP(userID uuid.UUID, step int64, recoveryCodeHashes []string) error
+	DisableTOTP(userID uuid.UUID) error
+	UseTOTPStep(userID uuid.UUID, step int64) (bool, error)
+	RecordTOTPFailure(userID uuid.UUID, maxAttempts int, lockUntil time.Time) error
+	ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error
+	UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error)
+	CountUnusedRecoveryCodes(userID uuid.UUID) (int64, error)
+}
+
+type twoFactorRepository struct {
+	db *gorm.DB
+}
+
+func NewTwoFactorRepository(db *gorm.DB) TwoFactorRepository {
+	return &twoFactorRepository{
+		db: db,
+	}
+}
+
+// SetPendingTOTPSecret stores a secret awaiting confirmation. It never
+// replaces the secret of an enabled enrollment.
+func (r *twoFactorRepository) SetPendingTOTPSecret(userID uuid.UUID, encryptedSecret string) error {
+	if err := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_enabled_at IS NULL", userID).
+		Update("totp_secret", encryptedSecret).Error; err != nil {
+		log.Println("Failed to store TOTP secret:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *twoFactorRepository) EnableTOTP(userID uuid.UUID, step int64, recoveryCodeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_enabled_at":      time.Now(),
+			"totp_last_step":       step,
+			"totp_failed_attempts": 0,
+		}).Error; err != nil {
+			log.Println("Failed to enable TOTP:", err)
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, recoveryCodeHashes)
+	})
+}
+
+func (r *twoFactorRepository) DisableTOTP(userID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_secret":          nil,
+			"totp_enabled_at":      nil,
+			"totp_last_step":       0,
+			"totp_failed_attempts": 0,
+			"totp_locked_until":    nil,
+		}).Error; err != nil {
+			log.Println("Failed to disable TOTP:", err)
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			log.Println("Failed to delete recovery codes:", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// UseTOTPStep records a successfully verified time step. It returns false if
+// that step (or a later one) was already used, i.e. the code is a replay.
+func (r *twoFactorRepository) UseTOTPStep(userID uuid.UUID, step int64) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Updates(map[string]interface{}{
+			"totp_last_step":       step,
+			"totp_failed_attempts": 0,
+		})
+	if result.Error != nil {
+		log.Println("Failed to record TOTP step:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RecordTOTPFailure counts a failed code and locks two-factor verification
+// once maxAttempts is reached, restarting the count for after the lock.
+func (r *twoFactorRepository) RecordTOTPFailure(userID uuid.UUID, maxAttempts int, lockUntil time.Time) error {
+	if err := r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"totp_locked_until":    gorm.Expr("CASE WHEN totp_failed_attempts + 1 >= ? THEN ? ELSE totp_locked_until END", maxAttempts, lockUntil),
+		"totp_failed_attempts": gorm.Expr("CASE WHEN totp_failed_attempts + 1 >= ? THEN 0 ELSE totp_failed_attempts + 1 END", maxAttempts),
+	}).Error; err != nil {
+		log.Println("Failed to record TOTP failure:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *twoFactorRepository) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// UseRecoveryCode marks an unused recovery code as used, returning false if
+// there is no such code
+func (r *twoFactorRepository) UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error) {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Println("Failed to use recovery code:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *twoFactorRepository) CountUnusedRecoveryCodes(userID uuid.UUID) (int64, error) {
+	var count int64
+	if err := r.db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error; err != nil {
+		log.Println("Failed to count recovery codes:", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		log.Println("Failed to delete recovery codes:", err)
+		return err
+	}
+	codes := make([]models.RecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: hash})
+	}
+	if err := tx.Create(&codes).Error; err != nil {
+		log.Println("Failed to create recovery codes:", err)
+		return err
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"log"
+	"strings"
+	"time"
 	"github.com/google/uuid"
 	"whotterre/argent/internal/dto"
 	"whotterre/argent/internal/models"
@@ -13,7 +15,16 @@ type UserRepository interface {
 	FindOrCreateUser(newUser *dto.CreateNewUserRequest) (*models.User, error)
 	GetUserById(id uuid.UUID) (*models.User, error)
 	GetUserByEmail(email string) (*models.User, error)
-	GetUserByGoogleID(googleID string) (*models.User, error)
+	CreateUser(user *models.User) error
+	UpdatePassword(id uuid.UUID, passwordHash *string) error
+	MarkEmailVerified(id uuid.UUID) error
+	RevokeAllTokens(id uuid.UUID) error
+	SearchUsers(filter dto.AdminUserFilter) ([]models.User, int64, error)
+	UpdateRole(id uuid.UUID, role string) error
+	UpdateTier(id uuid.UUID, tier string) error
+	PromoteToAdmin(emails []string) (int64, error)
+	GetUserByHandle(handle string) (*models.User, error)
+	SetHandle(id uuid.UUID, handle *string) (bool, error)
 }
 type userRepository struct {
 	db *gorm.DB
@@ -28,44 +39,61 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 func (r *userRepository) FindOrCreateUser(newUser *dto.CreateNewUserRequest) (*models.User, error) {
 	var user models.User
 	// Check if user exists
-	err := r.db.Preload("Wallet").Where("email = ?", newUser.Email).First(&user).Error
+	err := r.db.Preload("Wallet", "mode = ?", models.ModeLive).Where("LOWER(email) = LOWER(?)", newUser.Email).First(&user).Error
 	if err == nil {
 		return &user, nil
 	}
 
 	// If not found, create
-	tx := r.db.Begin()
 	user = models.User{
-		GoogleID:  newUser.GoogleID,
 		Email:     newUser.Email,
 		FirstName: newUser.FirstName,
 		LastName:  newUser.LastName,
 	}
+	if newUser.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := r.CreateUser(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
 
-	if err := tx.Create(&user).Error; err != nil {
+// CreateUser creates the user together with their live wallet
+func (r *userRepository) CreateUser(user *models.User) error {
+	tx := r.db.Begin()
+	if err := tx.Create(user).Error; err != nil {
 		tx.Rollback()
 		log.Println("Failed to create user:", err)
-		return nil, err
+		return err
 	}
 
 	// Create Wallet
+	accountNumber, err := uniqueAccountNumber(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 	wallet := models.Wallet{
-		UserID:  user.ID,
-		Balance: 0,
+		UserID:        user.ID,
+		Mode:          models.ModeLive,
+		AccountNumber: accountNumber,
+		Balance:       0,
 	}
 	if err := tx.Create(&wallet).Error; err != nil {
 		tx.Rollback()
 		log.Println("Failed to create wallet:", err)
-		return nil, err
+		return err
 	}
 
 	if err := tx.Commit().Error; err != nil {
 		log.Println("Failed to commit transaction:", err)
-		return nil, err
+		return err
 	}
 
 	user.Wallet = wallet
-	return &user, nil
+	return nil
 }
 
 func (r *userRepository) GetUserById(id uuid.UUID) (*models.User, error){
@@ -78,18 +106,114 @@ func (r *userRepository) GetUserById(id uuid.UUID) (*models.User, error){
 }
 func (r *userRepository) GetUserByEmail(email string) (*models.User, error) {
 	var user *models.User
-	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := r.db.Where("LOWER(email) = LOWER(?)", email).First(&user).Error; err != nil {
 		log.Println("Failed to get user by email")
 		return nil, err
 	}
 	return user, nil
 }
 
-func (r *userRepository) GetUserByGoogleID(googleID string) (*models.User, error) {
+func (r *userRepository) RevokeAllTokens(id uuid.UUID) error {
+	if err := r.db.Model(&models.User{}).Where("id = ?", id).Update("tokens_revoked_at", time.Now()).Error; err != nil {
+		log.Println("Failed to revoke user's tokens:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *userRepository) UpdatePassword(id uuid.UUID, passwordHash *string) error {
+	if err := r.db.Model(&models.User{}).Where("id = ?", id).Update("password_hash", passwordHash).Error; err != nil {
+		log.Println("Failed to update user's password:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *userRepository) MarkEmailVerified(id uuid.UUID) error {
+	if err := r.db.Model(&models.User{}).Where("id = ? AND email_verified_at IS NULL", id).Update("email_verified_at", time.Now()).Error; err != nil {
+		log.Println("Failed to mark user's email verified:", err)
+		return err
+	}
+	return nil
+}
+
+// SearchUsers finds users by email or name for the admin API, newest first
+func (r *userRepository) SearchUsers(filter dto.AdminUserFilter) ([]models.User, int64, error) {
+	query := r.db.Model(&models.User{})
+	if filter.Query != "" {
+		pattern := "%" + strings.ToLower(filter.Query) + "%"
+		query = query.Where("LOWER(email) LIKE ? OR LOWER(first_name || ' ' || last_name) LIKE ?", pattern, pattern)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Println("Failed to count users:", err)
+		return nil, 0, err
+	}
+	var users []models.User
+	if err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&users).Error; err != nil {
+		log.Println("Failed to search users:", err)
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *userRepository) UpdateRole(id uuid.UUID, role string) error {
+	if err := r.db.Model(&models.User{}).Where("id = ?", id).Update("role", role).Error; err != nil {
+		log.Println("Failed to update user's role:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *userRepository) UpdateTier(id uuid.UUID, tier string) error {
+	if err := r.db.Model(&models.User{}).Where("id = ?", id).Update("tier", tier).Error; err != nil {
+		log.Println("Failed to update user's tier:", err)
+		return err
+	}
+	return nil
+}
+
+// PromoteToAdmin makes the verified users with the given emails admins
+func (r *userRepository) PromoteToAdmin(emails []string) (int64, error) {
+	lowered := make([]string, 0, len(emails))
+	for _, email := range emails {
+		lowered = append(lowered, strings.ToLower(email))
+	}
+	result := r.db.Model(&models.User{}).
+		Where("LOWER(email) IN ? AND email_verified_at IS NOT NULL AND role <> ?", lowered, models.RoleAdmin).
+		Update("role", models.RoleAdmin)
+	if result.Error != nil {
+		log.Println("Failed to promote admins:", result.Error)
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// GetUserByHandle looks a user up by handle, which is stored lowercase
+func (r *userRepository) GetUserByHandle(handle string) (*models.User, error) {
 	var user *models.User
-	if err := r.db.Where("google_id = ?", googleID).First(&user).Error; err != nil {
-		log.Println("Failed to get user by Google ID")
+	if err := r.db.Where("handle = ?", handle).First(&user).Error; err != nil {
+		log.Println("Failed to get user by handle:", err)
 		return nil, err
 	}
 	return user, nil
 }
+
+// SetHandle sets or, with nil, clears the user's handle. It reports false
+// when another user already has the handle.
+func (r *userRepository) SetHandle(id uuid.UUID, handle *string) (bool, error) {
+	query := r.db.Model(&models.User{}).Where("id = ?", id)
+	if handle != nil {
+		query = query.Where("NOT EXISTS (SELECT 1 FROM users WHERE handle = ? AND id <> ?)", *handle, id)
+	}
+	result := query.Update("handle", handle)
+	if result.Error != nil {
+		log.Println("Failed to update user's handle:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -1,18 +1,29 @@
 package repositories
 
 import (
+	"errors"
 	"log"
 	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/utils"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type WalletRepository interface {
-	GetWalletByUserID(userID uuid.UUID) (*models.Wallet, error)
+	GetWalletByUserID(userID uuid.UUID, mode string) (*models.Wallet, error)
+	GetOrCreateWallet(userID uuid.UUID, mode string) (*models.Wallet, error)
 	CreateWallet(wallet *models.Wallet) error
 	UpdateBalance(walletID uuid.UUID, newBalance float64) error
-	GetBalance(userID uuid.UUID) (float64, error)
+	GetBalance(userID uuid.UUID, mode string) (float64, error)
+	GetWalletByID(id uuid.UUID) (*models.Wallet, error)
+	GetUserWallets(userID uuid.UUID) ([]models.Wallet, error)
+	GetWalletByAccountNumber(accountNumber string) (*models.Wallet, error)
+	Debit(walletID uuid.UUID, amount float64) (bool, error)
+	Credit(walletID uuid.UUID, amount float64) error
+	Hold(walletID uuid.UUID, amount float64) (bool, error)
+	ReleaseHeld(walletID uuid.UUID, amount float64) (bool, error)
+	DebitHeld(walletID uuid.UUID, amount float64) (bool, error)
 }
 
 type walletRepository struct {
@@ -25,16 +36,44 @@ func NewWalletRepository(db *gorm.DB) WalletRepository {
 	}
 }
 
-func (r *walletRepository) GetWalletByUserID(userID uuid.UUID) (*models.Wallet, error) {
+func (r *walletRepository) GetWalletByUserID(userID uuid.UUID, mode string) (*models.Wallet, error) {
 	var wallet *models.Wallet
-	if err := r.db.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+	if err := r.db.Where("user_id = ? AND mode = ?", userID, mode).First(&wallet).Error; err != nil {
 		log.Println("Failed to get wallet by user ID:", err)
 		return nil, err
 	}
 	return wallet, nil
 }
 
+func (r *walletRepository) GetOrCreateWallet(userID uuid.UUID, mode string) (*models.Wallet, error) {
+	wallet := models.Wallet{UserID: userID, Mode: mode}
+	err := r.db.Where("user_id = ? AND mode = ?", userID, mode).First(&wallet).Error
+	if err == nil {
+		return &wallet, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Println("Failed to get or create wallet:", err)
+		return nil, err
+	}
+
+	if wallet.AccountNumber, err = uniqueAccountNumber(r.db); err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("user_id = ? AND mode = ?", userID, mode).FirstOrCreate(&wallet).Error; err != nil {
+		log.Println("Failed to get or create wallet:", err)
+		return nil, err
+	}
+	return &wallet, nil
+}
+
 func (r *walletRepository) CreateWallet(wallet *models.Wallet) error {
+	if wallet.AccountNumber == "" {
+		accountNumber, err := uniqueAccountNumber(r.db)
+		if err != nil {
+			return err
+		}
+		wallet.AccountNumber = accountNumber
+	}
 	if err := r.db.Create(wallet).Error; err != nil {
 		log.Println("Failed to create wallet:", err)
 		return err
@@ -50,11 +89,122 @@ func (r *walletRepository) UpdateBalance(walletID uuid.UUID, newBalance float64)
 	return nil
 }
 
-func (r *walletRepository) GetBalance(userID uuid.UUID) (float64, error) {
+func (r *walletRepository) GetBalance(userID uuid.UUID, mode string) (float64, error) {
 	var balance float64
-	if err := r.db.Model(&models.Wallet{}).Where("user_id = ?", userID).Select("balance").Scan(&balance).Error; err != nil {
+	if err := r.db.Model(&models.Wallet{}).Where("user_id = ? AND mode = ?", userID, mode).Select("balance").Scan(&balance).Error; err != nil {
 		log.Println("Failed to get balance:", err)
 		return 0, err
 	}
 	return balance, nil
 }
+
+func (r *walletRepository) GetWalletByID(id uuid.UUID) (*models.Wallet, error) {
+	var wallet *models.Wallet
+	if err := r.db.Where("id = ?", id).First(&wallet).Error; err != nil {
+		log.Println("Failed to get wallet by ID:", err)
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// GetUserWallets returns the user's wallets in every mode
+func (r *walletRepository) GetUserWallets(userID uuid.UUID) ([]models.Wallet, error) {
+	var wallets []models.Wallet
+	if err := r.db.Where("user_id = ?", userID).Order("mode").Find(&wallets).Error; err != nil {
+		log.Println("Failed to get user wallets:", err)
+		return nil, err
+	}
+	return wallets, nil
+}
+
+func (r *walletRepository) GetWalletByAccountNumber(accountNumber string) (*models.Wallet, error) {
+	var wallet *models.Wallet
+	if err := r.db.Where("account_number = ?", accountNumber).First(&wallet).Error; err != nil {
+		log.Println("Failed to get wallet by account number:", err)
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// Debit takes amount from the wallet in one statement. It reports false,
+// leaving the balance alone, when the wallet holds less than amount.
+func (r *walletRepository) Debit(walletID uuid.UUID, amount float64) (bool, error) {
+	result := r.db.Model(&models.Wallet{}).
+		Where("id = ? AND balance >= ?", walletID, amount).
+		Update("balance", gorm.Expr("balance - ?", amount))
+	if result.Error != nil {
+		log.Println("Failed to debit wallet:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Credit adds amount to the wallet in one statement
+func (r *walletRepository) Credit(walletID uuid.UUID, amount float64) error {
+	if err := r.db.Model(&models.Wallet{}).Where("id = ?", walletID).Update("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
+		log.Println("Failed to credit wallet:", err)
+		return err
+	}
+	return nil
+}
+
+// uniqueAccountNumber picks an account number no wallet has yet. The unique
+// index still guards against two wallets picking the same one at once.
+func uniqueAccountNumber(db *gorm.DB) (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		accountNumber := utils.GenAccountNumber()
+		var count int64
+		if err := db.Model(&models.Wallet{}).Where("account_number = ?", accountNumber).Count(&count).Error; err != nil {
+			log.Println("Failed to check account number:", err)
+			return "", err
+		}
+		if count == 0 {
+			return accountNumber, nil
+		}
+	}
+	return "", errors.New("could not find a free account number")
+}
+
+// Hold moves amount from the wallet's balance to its held balance, unless the
+// balance can't cover it
+func (r *walletRepository) Hold(walletID uuid.UUID, amount float64) (bool, error) {
+	result := r.db.Model(&models.Wallet{}).
+		Where("id = ? AND balance >= ?", walletID, amount).
+		Updates(map[string]interface{}{
+			"balance":      gorm.Expr("balance - ?", amount),
+			"held_balance": gorm.Expr("held_balance + ?", amount),
+		})
+	if result.Error != nil {
+		log.Println("Failed to hold funds:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ReleaseHeld moves amount from the wallet's held balance back to its
+// balance
+func (r *walletRepository) ReleaseHeld(walletID uuid.UUID, amount float64) (bool, error) {
+	result := r.db.Model(&models.Wallet{}).
+		Where("id = ? AND held_balance >= ?", walletID, amount).
+		Updates(map[string]interface{}{
+			"balance":      gorm.Expr("balance + ?", amount),
+			"held_balance": gorm.Expr("held_balance - ?", amount),
+		})
+	if result.Error != nil {
+		log.Println("Failed to release held funds:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DebitHeld takes amount out of the wallet's held balance
+func (r *walletRepository) DebitHeld(walletID uuid.UUID, amount float64) (bool, error) {
+	result := r.db.Model(&models.Wallet{}).
+		Where("id = ? AND held_balance >= ?", walletID, amount).
+		Update("held_balance", gorm.Expr("held_balance - ?", amount))
+	if result.Error != nil {
+		log.Println("Failed to debit held funds:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,194 @@
+package repositories
+
+import (
+	"log"
+	"time"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookEndpointRepository interface {
+	CreateEndpoint(endpoint *models.WebhookEndpoint) error
+	GetEndpointByID(id uuid.UUID) (*models.WebhookEndpoint, error)
+	GetUserEndpoint(id, userID uuid.UUID, mode string) (*models.WebhookEndpoint, error)
+	ListUserEndpoints(userID uuid.UUID, mode string) ([]models.WebhookEndpoint, error)
+	CountUserEndpoints(userID uuid.UUID) (int64, error)
+	GetActiveEndpoints(userID uuid.UUID, mode string) ([]models.WebhookEndpoint, error)
+	UpdateEndpoint(id uuid.UUID, updates map[string]interface{}) error
+	DeleteEndpoint(id uuid.UUID) error
+
+	CreateDeliveries(deliveries []models.WebhookDelivery) error
+	GetDelivery(id, endpointID uuid.UUID) (*models.WebhookDelivery, error)
+	ListDeliveries(endpointID uuid.UUID, status string, limit, offset int) ([]models.WebhookDelivery, int64, error)
+	GetDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error)
+	ClaimDelivery(id uuid.UUID, now, leaseUntil time.Time) (bool, error)
+	UpdateDelivery(id uuid.UUID, updates map[string]interface{}) error
+}
+
+type webhookEndpointRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookEndpointRepository(db *gorm.DB) WebhookEndpointRepository {
+	return &webhookEndpointRepository{
+		db: db,
+	}
+}
+
+func (r *webhookEndpointRepository) CreateEndpoint(endpoint *models.WebhookEndpoint) error {
+	if err := r.db.Create(endpoint).Error; err != nil {
+		log.Println("Failed to create webhook endpoint:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *webhookEndpointRepository) GetEndpointByID(id uuid.UUID) (*models.WebhookEndpoint, error) {
+	var endpoint *models.WebhookEndpoint
+	if err := r.db.Where("id = ?", id).First(&endpoint).Error; err != nil {
+		log.Println("Failed to get webhook endpoint:", err)
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (r *webhookEndpointRepository) GetUserEndpoint(id, userID uuid.UUID, mode string) (*models.WebhookEndpoint, error) {
+	var endpoint *models.WebhookEndpoint
+	if err := r.db.Where("id = ? AND user_id = ? AND mode = ?", id, userID, mode).First(&endpoint).Error; err != nil {
+		log.Println("Failed to get webhook endpoint:", err)
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (r *webhookEndpointRepository) ListUserEndpoints(userID uuid.UUID, mode string) ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	if err := r.db.Where("user_id = ? AND mode = ?", userID, mode).Order("created_at").Find(&endpoints).Error; err != nil {
+		log.Println("Failed to list webhook endpoints:", err)
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// CountUserEndpoints counts the user's endpoints in every mode
+func (r *webhookEndpointRepository) CountUserEndpoints(userID uuid.UUID) (int64, error) {
+	var count int64
+	if err := r.db.Model(&models.WebhookEndpoint{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		log.Println("Failed to count webhook endpoints:", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *webhookEndpointRepository) GetActiveEndpoints(userID uuid.UUID, mode string) ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	if err := r.db.Where("user_id = ? AND mode = ? AND active", userID, mode).Find(&endpoints).Error; err != nil {
+		log.Println("Failed to get active webhook endpoints:", err)
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (r *webhookEndpointRepository) UpdateEndpoint(id uuid.UUID, updates map[string]interface{}) error {
+	if err := r.db.Model(&models.WebhookEndpoint{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		log.Println("Failed to update webhook endpoint:", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteEndpoint removes an endpoint along with its delivery log
+func (r *webhookEndpointRepository) DeleteEndpoint(id uuid.UUID) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("endpoint_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&models.WebhookEndpoint{}).Error
+	})
+	if err != nil {
+		log.Println("Failed to delete webhook endpoint:", err)
+		return err
+	}
+	return nil
+}
+
+// CreateDeliveries queues deliveries, skipping any event already queued for
+// the same endpoint
+func (r *webhookEndpointRepository) CreateDeliveries(deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error; err != nil {
+		log.Println("Failed to create webhook deliveries:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *webhookEndpointRepository) GetDelivery(id, endpointID uuid.UUID) (*models.WebhookDelivery, error) {
+	var delivery *models.WebhookDelivery
+	if err := r.db.Where("id = ? AND endpoint_id = ?", id, endpointID).First(&delivery).Error; err != nil {
+		log.Println("Failed to get webhook delivery:", err)
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// ListDeliveries returns an endpoint's delivery log, newest first
+func (r *webhookEndpointRepository) ListDeliveries(endpointID uuid.UUID, status string, limit, offset int) ([]models.WebhookDelivery, int64, error) {
+	query := r.db.Model(&models.WebhookDelivery{}).Where("endpoint_id = ?", endpointID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Println("Failed to count webhook deliveries:", err)
+		return nil, 0, err
+	}
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&deliveries).Error; err != nil {
+		log.Println("Failed to list webhook deliveries:", err)
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+// GetDueDeliveries returns pending deliveries whose next attempt is due,
+// oldest first
+func (r *webhookEndpointRepository) GetDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if err := r.db.Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&deliveries).Error; err != nil {
+		log.Println("Failed to get due webhook deliveries:", err)
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ClaimDelivery pushes a due delivery's next attempt out to leaseUntil so
+// no other replica sends it meanwhile. It reports false when another
+// replica claimed it first.
+func (r *webhookEndpointRepository) ClaimDelivery(id uuid.UUID, now, leaseUntil time.Time) (bool, error) {
+	result := r.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", id, models.DeliveryPending, now).
+		Update("next_attempt_at", leaseUntil)
+	if result.Error != nil {
+		log.Println("Failed to claim webhook delivery:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *webhookEndpointRepository) UpdateDelivery(id uuid.UUID, updates map[string]interface{}) error {
+	if err := r.db.Model(&models.WebhookDelivery{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		log.Println("Failed to update webhook delivery:", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"log"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebhookEventRepository interface {
+	CreateWebhookEvent(event *models.WebhookEvent) error
+	ListWebhookEvents(filter dto.AdminWebhookFilter) ([]models.WebhookEvent, int64, error)
+	GetWebhookEventByID(id uuid.UUID) (*models.WebhookEvent, error)
+}
+
+type webhookEventRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookEventRepository(db *gorm.DB) WebhookEventRepository {
+	return &webhookEventRepository{
+		db: db,
+	}
+}
+
+func (r *webhookEventRepository) CreateWebhookEvent(event *models.WebhookEvent) error {
+	if err := r.db.Create(event).Error; err != nil {
+		log.Println("Failed to record webhook event:", err)
+		return err
+	}
+	return nil
+}
+
+// ListWebhookEvents returns webhook events newest first, without payloads
+func (r *webhookEventRepository) ListWebhookEvents(filter dto.AdminWebhookFilter) ([]models.WebhookEvent, int64, error) {
+	query := r.db.Model(&models.WebhookEvent{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Event != "" {
+		query = query.Where("event = ?", filter.Event)
+	}
+	if filter.Reference != "" {
+		query = query.Where("reference = ?", filter.Reference)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Println("Failed to count webhook events:", err)
+		return nil, 0, err
+	}
+	var events []models.WebhookEvent
+	if err := query.Select("id, provider, event, reference, mode, status, error, created_at").Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&events).Error; err != nil {
+		log.Println("Failed to list webhook events:", err)
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+func (r *webhookEventRepository) GetWebhookEventByID(id uuid.UUID) (*models.WebhookEvent, error) {
+	var event *models.WebhookEvent
+	if err := r.db.Where("id = ?", id).First(&event).Error; err != nil {
+		log.Println("Failed to get webhook event:", err)
+		return nil, err
+	}
+	return event, nil
+}
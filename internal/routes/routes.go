@@ -1,11 +1,15 @@
 package routes
 
 import (
+	"log"
+	"time"
 	"whotterre/argent/internal/config"
 	"whotterre/argent/internal/handlers"
 	"whotterre/argent/internal/middleware"
+	"whotterre/argent/internal/models"
 	"whotterre/argent/internal/repositories"
 	"whotterre/argent/internal/services"
+	"whotterre/argent/internal/workers"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -14,43 +18,262 @@ import (
 )
 
 func SetupRoutes(app *gin.Engine, cfg config.Config, db *gorm.DB) {
+	// Audit log
+	auditRepo := repositories.NewAuditRepository(db)
+	auditService := services.NewAuditService(auditRepo)
+
 	// Auth modules
 	userRepo := repositories.NewUserRepository(db)
-	authService := services.NewAuthService(userRepo, cfg)
-	authHandler := handlers.NewAuthHandler(authService, cfg)
+	identityRepo := repositories.NewIdentityRepository(db)
+	tokenRepo := repositories.NewTokenRepository(db)
+	signingKeyRepo := repositories.NewSigningKeyRepository(db)
+	jwtKeyService := services.NewJWTKeyService(signingKeyRepo, cfg)
+	identityProviders := services.NewIdentityProviders(cfg)
+	authService := services.NewAuthService(userRepo, identityRepo, tokenRepo, identityProviders, jwtKeyService, cfg)
+	authHandler := handlers.NewAuthHandler(authService, auditService, cfg)
 
 	// API Key modules
 	auth := app.Group("/auth")
-	// Google Auth routes
-	auth.GET("/google", authHandler.HandleGoogleLogin)
-	auth.GET("/google/callback", authHandler.HandleGoogleCallback)
+	// OAuth/OIDC sign-in routes
+	auth.GET("/providers", authHandler.ListProviders)
+	auth.GET("/:provider", authHandler.HandleLogin)
+	auth.GET("/:provider/callback", authHandler.HandleCallback)
+	auth.POST("/:provider/callback", authHandler.HandleCallback)
+	auth.POST("/token", authHandler.ExchangeLoginCode)
+	auth.POST("/refresh", authHandler.RefreshToken)
+	app.GET("/.well-known/jwks.json", authHandler.JWKS)
+
+	// Email/password and magic-link routes
+	mailer := services.NewMailer(cfg)
+	passwordAuthService := services.NewPasswordAuthService(userRepo, tokenRepo, authService, mailer, cfg)
+	passwordAuthHandler := handlers.NewPasswordAuthHandler(passwordAuthService, auditService)
+	auth.POST("/register", passwordAuthHandler.Register)
+	auth.POST("/login", passwordAuthHandler.Login)
+	auth.GET("/email/verify", passwordAuthHandler.VerifyEmail)
+	auth.POST("/email/verify", passwordAuthHandler.VerifyEmail)
+	auth.POST("/email/resend", passwordAuthHandler.ResendVerification)
+	auth.POST("/password/forgot", passwordAuthHandler.ForgotPassword)
+	auth.POST("/password/reset", passwordAuthHandler.ResetPassword)
+	auth.POST("/magic-link", passwordAuthHandler.RequestMagicLink)
+	auth.POST("/magic-link/verify", passwordAuthHandler.VerifyMagicLink)
 	// API Key routes
 	apiKeyRepo := repositories.NewAPIKeyRepository(db)
-	apiKeyService := services.NewAPIKeyService(apiKeyRepo)
-	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	notifier := services.NewLogNotifier()
+	nonceRepo := repositories.NewNonceRepository(db)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, nonceRepo, notifier, db, cfg)
+
+	// Two-factor routes
+	twoFactorRepo := repositories.NewTwoFactorRepository(db)
+	twoFactorService := services.NewTwoFactorService(userRepo, twoFactorRepo, jwtKeyService, auditService, cfg)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService, twoFactorService, auditService)
+
+	auth.POST("/logout", middleware.RequireAuth(authService, apiKeyService, ""), authHandler.Logout)
+
+	twoFactor := auth.Group("")
+	twoFactor.Use(middleware.RequireAuth(authService, apiKeyService, ""))
+	twoFactor.GET("/2fa", twoFactorHandler.Status)
+	twoFactor.POST("/2fa/enroll", twoFactorHandler.Enroll)
+	twoFactor.POST("/2fa/confirm", twoFactorHandler.Confirm)
+	twoFactor.POST("/2fa/disable", twoFactorHandler.Disable)
+	twoFactor.POST("/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+	twoFactor.POST("/step-up", twoFactorHandler.StepUp)
+
+	// Transaction PIN routes
+	pinRepo := repositories.NewPINRepository(db)
+	pinService := services.NewPINService(userRepo, pinRepo, tokenRepo, mailer, auditService, cfg)
+	pinHandler := handlers.NewPINHandler(pinService, twoFactorService)
+	pin := auth.Group("/pin")
+	pin.Use(middleware.RequireAuth(authService, apiKeyService, ""))
+	pin.GET("", pinHandler.Status)
+	pin.POST("", pinHandler.SetPIN)
+	pin.PUT("", pinHandler.ChangePIN)
+	pin.POST("/reset/request", pinHandler.RequestPINReset)
+	pin.POST("/reset", pinHandler.ResetPIN)
 
 	apiKey := app.Group("/keys")
 	apiKey.Use(middleware.RequireAuth(authService, apiKeyService, ""))
 	apiKey.POST("/create", apiKeyHandler.CreateAPIKey)
 	apiKey.POST("/rollover", apiKeyHandler.RolloverAPIKey)
+	apiKey.GET("", apiKeyHandler.ListAPIKeys)
+	apiKey.PUT("/:id/allowed-ips", apiKeyHandler.UpdateAllowedIPs)
+	apiKey.PUT("/:id/signature", apiKeyHandler.UpdateSignatureRequired)
 
 	// Wallet modules
 	walletRepo := repositories.NewWalletRepository(db)
 	transactionRepo := repositories.NewTransactionRepository(db)
-	walletService := services.NewWalletService(walletRepo, transactionRepo, userRepo, cfg.PaystackSecret, db, cfg)
-	walletHandler := handlers.NewWalletHandler(walletService)
+	webhookEventRepo := repositories.NewWebhookEventRepository(db)
+	feeRepo := repositories.NewFeeRepository(db)
+	feeService := services.NewFeeService(feeRepo, userRepo, walletRepo, auditService)
+	if err := feeService.EnsureRevenueAccount(); err != nil {
+		log.Fatal("Failed to set up the revenue account: ", err)
+	}
+	walletService := services.NewWalletService(walletRepo, transactionRepo, userRepo, webhookEventRepo, auditService, feeService, cfg.PaystackSecret, db, cfg)
+	walletHandler := handlers.NewWalletHandler(walletService, feeService, twoFactorService, pinService, auditService, cfg)
 
 	wallet := app.Group("/wallet")
-	wallet.Use(middleware.RequireAuth(authService, apiKeyService, "read"))
-	wallet.POST("/deposit", walletHandler.Deposit)
+	wallet.Use(middleware.RequireSignature(apiKeyService, "read"), middleware.RequireAuth(authService, apiKeyService, "read"))
 	wallet.GET("/balance", walletHandler.GetBalance)
-	wallet.POST("/transfer", walletHandler.Transfer)
 	wallet.GET("/transactions", walletHandler.GetTransactions)
 	wallet.GET("/deposit/:reference/status", walletHandler.GetDepositStatus)
+	wallet.GET("/resolve", walletHandler.ResolveRecipient)
+	wallet.GET("/quote", walletHandler.Quote)
+
+	// Deposits and transfers need their own permission rather than the
+	// group's read, so they sit outside it
+	app.POST("/wallet/deposit", middleware.RequireSignature(apiKeyService, "deposit"), middleware.RequireAuth(authService, apiKeyService, "deposit"), walletHandler.Deposit)
+	app.POST("/wallet/transfer", middleware.RequireSignature(apiKeyService, "transfer"), middleware.RequireAuth(authService, apiKeyService, "transfer"), walletHandler.Transfer)
+
+	// Handles are part of the user's profile, so API keys can't change them
+	handle := app.Group("/wallet/handle")
+	handle.Use(middleware.RequireAuth(authService, apiKeyService, ""))
+	handle.PUT("", walletHandler.SetHandle)
+	handle.DELETE("", walletHandler.ClearHandle)
+
+	// Schedules, batches, payment requests, holds and escrows move money or
+	// set it aside, so API keys need the transfer permission for all of them
+	scheduleRepo := repositories.NewScheduleRepository(db)
+	scheduleService := services.NewScheduleService(scheduleRepo, walletService, notifier, auditService, db, cfg)
+	scheduleHandler := handlers.NewScheduleHandler(scheduleService, twoFactorService, pinService)
+	schedules := app.Group("/wallet/schedules")
+	schedules.Use(middleware.RequireSignature(apiKeyService, "transfer"), middleware.RequireAuth(authService, apiKeyService, "transfer"))
+	schedules.POST("", scheduleHandler.CreateSchedule)
+	schedules.GET("", scheduleHandler.ListSchedules)
+	schedules.GET("/:id", scheduleHandler.GetSchedule)
+	schedules.POST("/:id/pause", scheduleHandler.PauseSchedule)
+	schedules.POST("/:id/resume", scheduleHandler.ResumeSchedule)
+	schedules.POST("/:id/cancel", scheduleHandler.CancelSchedule)
+
+	transferBatchRepo := repositories.NewTransferBatchRepository(db)
+	transferBatchService := services.NewTransferBatchService(transferBatchRepo, transactionRepo, walletRepo, userRepo, walletService, feeService, notifier, auditService, db, cfg)
+	transferBatchHandler := handlers.NewTransferBatchHandler(transferBatchService, twoFactorService, pinService)
+	batches := app.Group("/wallet/transfers/batch")
+	batches.Use(middleware.RequireSignature(apiKeyService, "transfer"), middleware.RequireAuth(authService, apiKeyService, "transfer"))
+	batches.POST("", transferBatchHandler.SubmitBatch)
+	batches.GET("", transferBatchHandler.ListBatches)
+	batches.GET("/:id", transferBatchHandler.GetBatch)
+	batches.GET("/:id/result", transferBatchHandler.GetBatchResult)
+
+	paymentRequestRepo := repositories.NewPaymentRequestRepository(db)
+	paymentRequestService := services.NewPaymentRequestService(paymentRequestRepo, walletService, walletRepo, userRepo, notifier, auditService, db, cfg)
+	paymentRequestHandler := handlers.NewPaymentRequestHandler(paymentRequestService, twoFactorService, pinService, cfg)
+	requests := app.Group("/wallet/requests")
+	requests.Use(middleware.RequireSignature(apiKeyService, "transfer"), middleware.RequireAuth(authService, apiKeyService, "transfer"))
+	requests.POST("", paymentRequestHandler.CreatePaymentRequest)
+	requests.GET("", paymentRequestHandler.ListPaymentRequests)
+	requests.GET("/:id", paymentRequestHandler.GetPaymentRequest)
+	requests.POST("/:id/pay", paymentRequestHandler.PayPaymentRequest)
+	requests.POST("/:id/decline", paymentRequestHandler.DeclinePaymentRequest)
+	requests.POST("/:id/cancel", paymentRequestHandler.CancelPaymentRequest)
+	// Public payment links can be viewed and paid by card without an account
+	app.GET("/pay/:code", paymentRequestHandler.GetPaymentLink)
+	app.POST("/pay/:code/card", paymentRequestHandler.PayLinkByCard)
+	app.POST("/pay/:code/wallet", middleware.RequireSignature(apiKeyService, "transfer"), middleware.RequireAuth(authService, apiKeyService, "transfer"), paymentRequestHandler.PayLinkFromWallet)
+
+	holdRepo := repositories.NewHoldRepository(db)
+	holdService := services.NewHoldService(holdRepo, walletService, walletRepo, userRepo, feeService, notifier, auditService, db)
+	holdHandler := handlers.NewHoldHandler(holdService, twoFactorService, pinService)
+	holds := app.Group("/wallet/holds")
+	holds.Use(middleware.RequireSignature(apiKeyService, "transfer"), middleware.RequireAuth(authService, apiKeyService, "transfer"))
+	holds.POST("", holdHandler.PlaceHold)
+	holds.GET("", holdHandler.ListHolds)
+	holds.GET("/:id", holdHandler.GetHold)
+	holds.POST("/:id/capture", holdHandler.CaptureHold)
+	holds.POST("/:id/void", holdHandler.VoidHold)
+
+	escrowRepo := repositories.NewEscrowRepository(db)
+	escrowService := services.NewEscrowService(escrowRepo, holdRepo, walletService, walletRepo, userRepo, feeService, notifier, auditService, db)
+	escrowHandler := handlers.NewEscrowHandler(escrowService, twoFactorService, pinService)
+	escrows := app.Group("/wallet/escrows")
+	escrows.Use(middleware.RequireSignature(apiKeyService, "transfer"), middleware.RequireAuth(authService, apiKeyService, "transfer"))
+	escrows.POST("", escrowHandler.CreateEscrow)
+	escrows.GET("", escrowHandler.ListEscrows)
+	escrows.GET("/:id", escrowHandler.GetEscrow)
+	escrows.POST("/:id/release", escrowHandler.ReleaseEscrow)
+	escrows.POST("/:id/cancel", escrowHandler.CancelEscrow)
+	escrows.POST("/:id/dispute", escrowHandler.DisputeEscrow)
+
+	// Webhook endpoints are sent events from the outbox. API keys need the
+	// read permission, as the events carry account activity.
+	webhookEndpointRepo := repositories.NewWebhookEndpointRepository(db)
+	outboxRepo := repositories.NewOutboxRepository(db)
+	webhookService := services.NewWebhookService(webhookEndpointRepo, outboxRepo, auditService, db, cfg)
+	webhookEndpointHandler := handlers.NewWebhookEndpointHandler(webhookService)
+	webhooks := app.Group("/webhooks/endpoints")
+	webhooks.Use(middleware.RequireSignature(apiKeyService, "read"), middleware.RequireAuth(authService, apiKeyService, "read"))
+	webhooks.POST("", webhookEndpointHandler.CreateWebhookEndpoint)
+	webhooks.GET("", webhookEndpointHandler.ListWebhookEndpoints)
+	webhooks.GET("/:id", webhookEndpointHandler.GetWebhookEndpoint)
+	webhooks.PUT("/:id", webhookEndpointHandler.UpdateWebhookEndpoint)
+	webhooks.DELETE("/:id", webhookEndpointHandler.DeleteWebhookEndpoint)
+	webhooks.POST("/:id/rotate-secret", webhookEndpointHandler.RotateWebhookSecret)
+	webhooks.POST("/:id/ping", webhookEndpointHandler.PingWebhookEndpoint)
+	webhooks.GET("/:id/deliveries", webhookEndpointHandler.ListWebhookDeliveries)
+	webhooks.POST("/:id/deliveries/:delivery_id/redeliver", webhookEndpointHandler.RedeliverWebhook)
+
+	// Domain events recorded alongside state changes go to the external
+	// sinks in EVENT_SINKS, then to in-process subscribers on eventBus
+	eventBus := services.NewEventBus()
+	eventDispatcher := services.NewEventDispatcher(db, append(services.NewEventSinks(cfg, db), eventBus)...)
+
+	// Real-time wallet updates are worked out from domain events and sent
+	// to open event streams on every replica over LISTEN/NOTIFY
+	realtimeService := services.NewRealtimeService(walletService, db, cfg)
+	eventBus.Subscribe("*", realtimeService.HandleEvent)
+	realtimeHandler := handlers.NewRealtimeHandler(realtimeService)
+	wallet.GET("/events", realtimeHandler.StreamWalletEvents)
+
+	// Admin modules
+	freezeRepo := repositories.NewFreezeRepository(db)
+	freezeService := services.NewFreezeService(userRepo, walletRepo, freezeRepo, tokenRepo, walletService, auditService)
+	adminService := services.NewAdminService(userRepo, walletRepo, transactionRepo, webhookEventRepo, auditService)
+	adminService.BootstrapAdmins(cfg.AdminEmails)
+	adminHandler := handlers.NewAdminHandler(adminService, freezeService, feeService, twoFactorService, auditService)
+
+	admin := app.Group("/admin")
+	admin.Use(middleware.RequireAuth(authService, apiKeyService, ""))
+	admin.GET("/users", middleware.RequirePermission(adminService, models.PermissionUsersRead), adminHandler.SearchUsers)
+	admin.GET("/users/:id", middleware.RequirePermission(adminService, models.PermissionUsersRead), adminHandler.GetUser)
+	admin.PUT("/users/:id/role", middleware.RequirePermission(adminService, models.PermissionRolesManage), adminHandler.SetRole)
+	admin.PUT("/users/:id/status", middleware.RequirePermission(adminService, models.PermissionUsersFreeze), adminHandler.SetUserStatus)
+	admin.GET("/users/:id/freeze-events", middleware.RequirePermission(adminService, models.PermissionUsersRead), adminHandler.GetFreezeEvents)
+	admin.GET("/wallets/:id", middleware.RequirePermission(adminService, models.PermissionWalletsRead), adminHandler.GetWallet)
+	admin.PUT("/wallets/:id/status", middleware.RequirePermission(adminService, models.PermissionUsersFreeze), adminHandler.SetWalletStatus)
+	admin.GET("/transactions", middleware.RequirePermission(adminService, models.PermissionTransactionsRead), adminHandler.ListTransactions)
+	admin.GET("/webhooks", middleware.RequirePermission(adminService, models.PermissionWebhooksRead), adminHandler.ListWebhookEvents)
+	admin.GET("/webhooks/:id", middleware.RequirePermission(adminService, models.PermissionWebhooksRead), adminHandler.GetWebhookEvent)
+	admin.GET("/audit-events", middleware.RequirePermission(adminService, models.PermissionAuditRead), adminHandler.ListAuditEvents)
+	admin.GET("/audit-events/verify", middleware.RequirePermission(adminService, models.PermissionAuditRead), adminHandler.VerifyAuditLog)
+	admin.PUT("/users/:id/tier", middleware.RequirePermission(adminService, models.PermissionFeesManage), adminHandler.SetTier)
+	admin.GET("/fees", middleware.RequirePermission(adminService, models.PermissionFeesManage), adminHandler.ListFeeRules)
+	admin.POST("/fees", middleware.RequirePermission(adminService, models.PermissionFeesManage), adminHandler.CreateFeeRule)
+	admin.GET("/fees/revenue", middleware.RequirePermission(adminService, models.PermissionTransactionsRead), adminHandler.GetRevenue)
+	admin.PUT("/fees/:id", middleware.RequirePermission(adminService, models.PermissionFeesManage), adminHandler.UpdateFeeRule)
+	admin.DELETE("/fees/:id", middleware.RequirePermission(adminService, models.PermissionFeesManage), adminHandler.DeleteFeeRule)
+	admin.GET("/escrows", middleware.RequirePermission(adminService, models.PermissionEscrowsResolve), escrowHandler.AdminListEscrows)
+	admin.POST("/escrows/:id/resolve", middleware.RequirePermission(adminService, models.PermissionEscrowsResolve), escrowHandler.ResolveEscrow)
 
 	// Public wallet endpoints (no auth required)
 	app.POST("/wallet/paystack/webhook", walletHandler.Webhook)
 	app.GET("/wallet/deposit/callback", walletHandler.DepositCallback)
+	app.GET("/wallet/deposit/complete", realtimeHandler.DepositCompletePage)
+	app.GET("/wallet/deposit/:reference/events", realtimeHandler.StreamDepositEvents)
+
+	// Background workers
+	workers.Every(time.Hour, "api key expiry warnings", apiKeyService.SendExpiryWarnings)
+	workers.Every(10*time.Minute, "request nonce cleanup", apiKeyService.CleanupNonces)
+	workers.Every(time.Hour, "expired token cleanup", authService.CleanupTokens)
+	workers.Every(time.Hour, "jwt signing key rotation", jwtKeyService.RotateIfDue)
+	workers.Every(time.Minute, "scheduled transfers", scheduleService.RunDue)
+	workers.Every(10*time.Minute, "payment request expiry", paymentRequestService.ExpireRequests)
+	workers.Every(time.Minute, "escrow deadlines", escrowService.ResolveExpired)
+	workers.Every(time.Minute, "hold expiry", holdService.ExpireHolds)
+	workers.Every(10*time.Second, "batch transfers", transferBatchService.RunQueued)
+	workers.Every(5*time.Second, "webhook dispatch", webhookService.DispatchEvents)
+	workers.Every(5*time.Second, "webhook delivery", webhookService.DeliverDue)
+	workers.Every(2*time.Second, "domain events", eventDispatcher.PublishPending)
+	go realtimeService.Listen()
+
 	// Swagger docs
 	app.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 }
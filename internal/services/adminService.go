@@ -0,0 +1,123 @@
+package services
+
+import (
+	"log"
+
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// AdminService backs the staff-only admin API: role checks and user, wallet,
+// transaction and webhook lookups. Freezing lives in FreezeService.
+type AdminService interface {
+	HasPermission(userID uuid.UUID, permission string) (bool, error)
+	SearchUsers(filter dto.AdminUserFilter) ([]models.User, int64, error)
+	GetUser(userID uuid.UUID) (*models.User, []models.Wallet, error)
+	SetRole(actor AuditActor, userID uuid.UUID, role string) error
+	GetWallet(walletID uuid.UUID) (*models.Wallet, error)
+	ListTransactions(filter dto.AdminTransactionFilter) ([]models.Transaction, int64, error)
+	ListWebhookEvents(filter dto.AdminWebhookFilter) ([]models.WebhookEvent, int64, error)
+	GetWebhookEvent(id uuid.UUID) (*models.WebhookEvent, error)
+	BootstrapAdmins(emails []string)
+}
+
+type adminService struct {
+	userRepo         repositories.UserRepository
+	walletRepo       repositories.WalletRepository
+	transactionRepo  repositories.TransactionRepository
+	webhookEventRepo repositories.WebhookEventRepository
+	auditService     AuditService
+}
+
+func NewAdminService(userRepo repositories.UserRepository, walletRepo repositories.WalletRepository, transactionRepo repositories.TransactionRepository, webhookEventRepo repositories.WebhookEventRepository, auditService AuditService) AdminService {
+	return &adminService{
+		userRepo:         userRepo,
+		walletRepo:       walletRepo,
+		transactionRepo:  transactionRepo,
+		webhookEventRepo: webhookEventRepo,
+		auditService:     auditService,
+	}
+}
+
+// HasPermission looks the role up on every request, so demoting a staff
+// member takes effect immediately
+func (s *adminService) HasPermission(userID uuid.UUID, permission string) (bool, error) {
+	user, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return false, err
+	}
+	return models.CanSignIn(user.Status) && models.RoleHasPermission(user.Role, permission), nil
+}
+
+func (s *adminService) SearchUsers(filter dto.AdminUserFilter) ([]models.User, int64, error) {
+	return s.userRepo.SearchUsers(filter)
+}
+
+func (s *adminService) GetUser(userID uuid.UUID) (*models.User, []models.Wallet, error) {
+	user, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	wallets, err := s.walletRepo.GetUserWallets(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, wallets, nil
+}
+
+func (s *adminService) SetRole(actor AuditActor, userID uuid.UUID, role string) error {
+	if !models.IsValidRole(role) {
+		return customErrors.ErrInvalidRole
+	}
+	// Stops the last admin from locking everyone out by accident
+	if actor.UserID == userID {
+		return customErrors.ErrCannotChangeOwnRole
+	}
+	user, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdateRole(userID, role); err != nil {
+		return err
+	}
+	log.Printf("SECURITY: admin %s set role of user %s to %s", actor.UserID, userID, role)
+	s.auditService.Record(actor, models.AuditRoleChanged, "user", userID.String(),
+		map[string]string{"role": user.Role}, map[string]string{"role": role})
+	return nil
+}
+
+func (s *adminService) GetWallet(walletID uuid.UUID) (*models.Wallet, error) {
+	return s.walletRepo.GetWalletByID(walletID)
+}
+
+func (s *adminService) ListTransactions(filter dto.AdminTransactionFilter) ([]models.Transaction, int64, error) {
+	return s.transactionRepo.ListTransactions(filter)
+}
+
+func (s *adminService) ListWebhookEvents(filter dto.AdminWebhookFilter) ([]models.WebhookEvent, int64, error) {
+	return s.webhookEventRepo.ListWebhookEvents(filter)
+}
+
+func (s *adminService) GetWebhookEvent(id uuid.UUID) (*models.WebhookEvent, error) {
+	return s.webhookEventRepo.GetWebhookEventByID(id)
+}
+
+// BootstrapAdmins promotes the first staff accounts from ADMIN_EMAILS. Only
+// verified addresses count, so nobody can claim admin by registering one.
+func (s *adminService) BootstrapAdmins(emails []string) {
+	if len(emails) == 0 {
+		return
+	}
+	promoted, err := s.userRepo.PromoteToAdmin(emails)
+	if err != nil {
+		return
+	}
+	if promoted > 0 {
+		log.Printf("SECURITY: promoted %d user(s) from ADMIN_EMAILS to admin", promoted)
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+const auditVerifyBatch = 1000
+
+// AuditActor is who performed an audited action and where from. Handlers
+// build it from the request; background work uses SystemActor.
+type AuditActor struct {
+	UserID     uuid.UUID // uuid.Nil when nobody is signed in
+	AuthMethod string
+	APIKeyID   *uuid.UUID
+	IP         string
+	UserAgent  string
+	RequestID  string
+}
+
+// SystemActor is the actor for work the server does on its own
+var SystemActor = AuditActor{AuthMethod: models.AuthMethodSystem}
+
+// AuditService writes the append-only audit log that auditors rely on and
+// checks its hash chain
+type AuditService interface {
+	Record(actor AuditActor, action, targetType, targetID string, before, after interface{})
+	ListEvents(filter dto.AdminAuditFilter) ([]models.AuditEvent, int64, error)
+	Verify() (*dto.AuditVerifyResponse, error)
+}
+
+type auditService struct {
+	auditRepo repositories.AuditRepository
+}
+
+func NewAuditService(auditRepo repositories.AuditRepository) AuditService {
+	return &auditService{
+		auditRepo: auditRepo,
+	}
+}
+
+// Record appends an event. before and after are stored as JSON and may be
+// nil. The action has already happened, so a failure to record it is logged
+// rather than returned.
+func (s *auditService) Record(actor AuditActor, action, targetType, targetID string, before, after interface{}) {
+	event := &models.AuditEvent{
+		AuthMethod: actor.AuthMethod,
+		APIKeyID:   actor.APIKeyID,
+		IP:         actor.IP,
+		UserAgent:  actor.UserAgent,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     auditJSON(before),
+		After:      auditJSON(after),
+		RequestID:  actor.RequestID,
+	}
+	if actor.UserID != uuid.Nil {
+		actorID := actor.UserID
+		event.ActorID = &actorID
+	}
+
+	if err := s.auditRepo.AppendAuditEvent(event); err != nil {
+		log.Printf("SECURITY: failed to write audit event %s for %s %s: %v", action, targetType, targetID, err)
+	}
+}
+
+func (s *auditService) ListEvents(filter dto.AdminAuditFilter) ([]models.AuditEvent, int64, error) {
+	return s.auditRepo.ListAuditEvents(filter)
+}
+
+// Verify walks the whole chain, recomputing every hash. It stops at the
+// first entry that was changed, removed or inserted out of order.
+func (s *auditService) Verify() (*dto.AuditVerifyResponse, error) {
+	result := &dto.AuditVerifyResponse{Valid: true, HeadHash: repositories.AuditGenesisHash}
+
+	for {
+		events, err := s.auditRepo.GetAuditEventsAfter(result.HeadSeq, auditVerifyBatch)
+		if err != nil {
+			return nil, err
+		}
+		for i := range events {
+			event := &events[i]
+			problem := ""
+			switch {
+			case event.Seq != result.HeadSeq+1:
+				problem = "entry missing before this one"
+			case event.PrevHash != result.HeadHash:
+				problem = "previous hash doesn't match"
+			case event.ComputeHash() != event.Hash:
+				problem = "entry was modified"
+			}
+			if problem != "" {
+				seq := event.Seq
+				result.Valid, result.BrokenAt, result.Problem = false, &seq, problem
+				log.Printf("SECURITY: audit log verification failed at seq %d: %s", seq, problem)
+				return result, nil
+			}
+			result.Checked++
+			result.HeadSeq, result.HeadHash = event.Seq, event.Hash
+		}
+		if len(events) < auditVerifyBatch {
+			return result, nil
+		}
+	}
+}
+
+func auditJSON(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Failed to encode audit value: %v", err)
+		return ""
+	}
+	return string(encoded)
+}
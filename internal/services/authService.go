@@ -3,101 +3,298 @@ package services
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
 
 	"whotterre/argent/internal/config"
+	"whotterre/argent/internal/customErrors"
 	"whotterre/argent/internal/dto"
 	"whotterre/argent/internal/models"
 	"whotterre/argent/internal/repositories"
+	"whotterre/argent/internal/utils"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
+	"gorm.io/gorm"
 )
 
 type AuthService interface {
-	GenerateStateToken() string
-	GetAuthCodeURL(state string) string
-	ExchangeCode(code string) (*oauth2.Token, error)
-	GenerateJWT(user *models.User, jwtSecret string) (string, error)
-	GetGoogleUserInfo(accessToken string) (*models.GoogleUserInfo, error)
+	Providers() []string
+	StartLogin(provider, redirectURI string) (authURL string, sealedSession string, err error)
+	OpenLoginSession(sealedSession, provider, state string) (*LoginSession, error)
+	LoginWithProvider(ctx context.Context, session *LoginSession, code string) (*models.User, error)
+	CreateLoginCode(user *models.User) (string, error)
+	ExchangeLoginCode(code string) (*dto.TokenResponse, error)
+	GenerateJWT(user *models.User) (string, error)
 	FindOrCreateUser(newUser *dto.CreateNewUserRequest) (*models.User, error)
 	ParseJWT(tokenString string) (*jwt.MapClaims, error)
 	GetUserIDFromJWT(tokenString string) (uuid.UUID, error)
+	ValidateAccessToken(tokenString string) (*AccessToken, error)
+	IssueTokens(user *models.User) (*dto.TokenResponse, error)
+	RefreshTokens(refreshToken string) (*dto.TokenResponse, error)
+	Logout(userID uuid.UUID, accessToken *AccessToken, input dto.LogoutRequest) error
+	CleanupTokens() error
+	JWKS() (*dto.JWKSResponse, error)
+}
+
+const (
+	// How long a user may take to sign in at the provider
+	LoginSessionTTL = 10 * time.Minute
+	// How long a front end has to exchange its login code for tokens
+	loginCodeTTL = time.Minute
+)
+
+// LoginSession is an in-flight provider login. It travels sealed in a cookie
+// between /auth/:provider and the callback, so the PKCE verifier and nonce
+// never reach the browser in the clear.
+type LoginSession struct {
+	Provider    string `json:"provider"`
+	State       string `json:"state"`
+	Nonce       string `json:"nonce"`
+	Verifier    string `json:"verifier"`
+	RedirectURI string `json:"redirect_uri,omitempty"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// AccessToken is a validated access JWT
+type AccessToken struct {
+	UserID    uuid.UUID
+	TokenID   string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
 }
 
 type authService struct {
-	authRepo    repositories.UserRepository
-	oauthConfig *oauth2.Config
-	jwtSecret   string
+	authRepo     repositories.UserRepository
+	identityRepo repositories.IdentityRepository
+	tokenRepo    repositories.TokenRepository
+	providers    map[string]IdentityProvider
+	keyService   JWTKeyService
+	config       config.Config
+	sessionKey   string
 }
 
-func NewAuthService(authRepo repositories.UserRepository, cfg config.Config) AuthService {
-	redirectURL := cfg.BaseURL + "/auth/google/callback"
-	log.Printf("OAuth Redirect URL: %s", redirectURL)
+func NewAuthService(authRepo repositories.UserRepository, identityRepo repositories.IdentityRepository, tokenRepo repositories.TokenRepository, providers map[string]IdentityProvider, keyService JWTKeyService, cfg config.Config) AuthService {
+	// Derive a separate key so sealed sessions can't be swapped for other secrets
+	sessionKey := "oauth-session:" + cfg.SecretsEncryptionKey
+	if cfg.SecretsEncryptionKey == "" {
+		log.Println("WARNING: SECRETS_ENCRYPTION_KEY not set, logins started before a restart or on another replica will fail")
+		sessionKey = randomToken()
+	}
 	return &authService{
-		authRepo: authRepo,
-		oauthConfig: &oauth2.Config{
-			ClientID:     cfg.GoogleClientID,
-			ClientSecret: cfg.GoogleClientSecret,
-			RedirectURL:  redirectURL,
-			Scopes: []string{
-				"https://www.googleapis.com/auth/userinfo.email",
-				"https://www.googleapis.com/auth/userinfo.profile",
-			},
-			Endpoint: google.Endpoint,
-		},
-		jwtSecret: cfg.JWTSecret,
-	}
-}
-
-// Generates a 32 character CSRF token string
-func (s *authService) GenerateStateToken() string {
-	b := make([]byte, 32)
-	rand.Read(b)
-	return base64.URLEncoding.EncodeToString(b)
+		authRepo:     authRepo,
+		identityRepo: identityRepo,
+		tokenRepo:    tokenRepo,
+		providers:    providers,
+		keyService:   keyService,
+		config:       cfg,
+		sessionKey:   sessionKey,
+	}
 }
 
-func (s *authService) GetAuthCodeURL(state string) string {
-	return s.oauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
+// Providers lists the names of the enabled sign-in providers
+func (s *authService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
-func (s *authService) ExchangeCode(code string) (*oauth2.Token, error) {
-	return s.oauthConfig.Exchange(context.Background(), code)
+// StartLogin begins a provider login with fresh state, nonce and PKCE
+// verifier. It returns the provider's authorization URL and the sealed
+// session to hand back on the callback. A non-empty redirectURI must be on
+// the allowlist; the callback then redirects there with a login code.
+func (s *authService) StartLogin(provider, redirectURI string) (string, string, error) {
+	identityProvider, ok := s.providers[provider]
+	if !ok {
+		return "", "", customErrors.ErrUnknownProvider
+	}
+	if redirectURI != "" && !s.isAllowedRedirect(redirectURI) {
+		return "", "", customErrors.ErrRedirectNotAllowed
+	}
+
+	session := LoginSession{
+		Provider:    provider,
+		State:       randomToken(),
+		Nonce:       randomToken(),
+		Verifier:    oauth2.GenerateVerifier(),
+		RedirectURI: redirectURI,
+		ExpiresAt:   time.Now().Add(LoginSessionTTL).Unix(),
+	}
+
+	authURL, err := identityProvider.AuthCodeURL(session.State, session.Nonce, session.Verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	payload, err := json.Marshal(session)
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := utils.EncryptSecret(s.sessionKey, string(payload))
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, sealed, nil
+}
+
+// OpenLoginSession unseals a login session and checks it belongs to this
+// provider callback and its state parameter.
+func (s *authService) OpenLoginSession(sealedSession, provider, state string) (*LoginSession, error) {
+	payload, err := utils.DecryptSecret(s.sessionKey, sealedSession)
+	if err != nil {
+		return nil, customErrors.ErrInvalidOAuthState
+	}
+
+	var session LoginSession
+	if err := json.Unmarshal([]byte(payload), &session); err != nil {
+		return nil, customErrors.ErrInvalidOAuthState
+	}
+	if session.Provider != provider || time.Now().Unix() > session.ExpiresAt {
+		return nil, customErrors.ErrInvalidOAuthState
+	}
+	if subtle.ConstantTimeCompare([]byte(session.State), []byte(state)) != 1 {
+		return nil, customErrors.ErrInvalidOAuthState
+	}
+	return &session, nil
 }
 
-func (s *authService) GetGoogleUserInfo(accessToken string) (*models.GoogleUserInfo, error) {
-	resp, err := http.Get("https://www.googleapis.com/oauth2/v2/userinfo?access_token=" + accessToken)
+// LoginWithProvider completes a provider sign-in and returns the linked
+// user. A first-time identity is linked to the account with the same email
+// when the provider has verified that email, otherwise a new user is created.
+func (s *authService) LoginWithProvider(ctx context.Context, session *LoginSession, code string) (*models.User, error) {
+	identityProvider, ok := s.providers[session.Provider]
+	if !ok {
+		return nil, customErrors.ErrUnknownProvider
+	}
+
+	identity, err := identityProvider.Authenticate(ctx, code, session.Nonce, session.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	if identity.Subject == "" || identity.Email == "" {
+		return nil, customErrors.ErrIncompleteIdentity
+	}
+
+	user, err := s.identityRepo.GetUserByIdentity(identity.Provider, identity.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if !identity.EmailVerified {
+		// Linking on an unverified email would let anyone claim the account
+		if _, err := s.authRepo.GetUserByEmail(identity.Email); err == nil {
+			log.Printf("SECURITY: refused to link unverified %s identity to existing account %s", identity.Provider, identity.Email)
+			return nil, customErrors.ErrUnverifiedEmail
+		}
+	}
+
+	user, err = s.FindOrCreateUser(&dto.CreateNewUserRequest{
+		Email:         identity.Email,
+		FirstName:     identity.FirstName,
+		LastName:      identity.LastName,
+		EmailVerified: identity.EmailVerified,
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	if identity.EmailVerified {
+		if err := claimUnverifiedAccount(s.authRepo, s.tokenRepo, user); err != nil {
+			return nil, err
+		}
+	}
 
-	var userInfo models.GoogleUserInfo
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
+	if err := s.identityRepo.CreateIdentity(&models.UserIdentity{
+		UserID:   user.ID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}); err != nil {
 		return nil, err
 	}
+	log.Printf("Linked %s identity to user %s", identity.Provider, user.ID)
+	return user, nil
+}
 
-	return &userInfo, nil
+// CreateLoginCode issues a short-lived, single-use code a front end exchanges
+// for tokens, so tokens aren't exposed in redirect URLs or browser history.
+func (s *authService) CreateLoginCode(user *models.User) (string, error) {
+	code := "lc_" + utils.GenString(40)
+	err := s.tokenRepo.CreateLoginCode(&models.LoginCode{
+		CodeHash:  hashToken(code),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(loginCodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
 }
 
-func (s *authService) GenerateJWT(user *models.User, jwtSecret string) (string, error) {
+func (s *authService) ExchangeLoginCode(code string) (*dto.TokenResponse, error) {
+	loginCode, err := s.tokenRepo.ConsumeLoginCode(hashToken(code))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, customErrors.ErrInvalidLoginCode
+		}
+		return nil, err
+	}
+
+	user, err := s.authRepo.GetUserById(loginCode.UserID)
+	if err != nil {
+		return nil, customErrors.ErrInvalidLoginCode
+	}
+	return s.IssueTokens(user)
+}
+
+func (s *authService) isAllowedRedirect(redirectURI string) bool {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil || parsed.Host == "" || parsed.Fragment != "" || parsed.User != nil {
+		return false
+	}
+	if parsed.Scheme != "https" && parsed.Scheme != "http" {
+		return false
+	}
+
+	// Query strings are the front end's own business; match on the rest
+	target := strings.TrimSuffix(parsed.Scheme+"://"+parsed.Host+parsed.Path, "/")
+	for _, allowed := range s.config.OAuthRedirectAllowlist {
+		if target == strings.TrimSuffix(allowed, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *authService) GenerateJWT(user *models.User) (string, error) {
 	// jwt payload
+	now := time.Now()
 	claims := jwt.MapClaims{
+		"iss":     s.config.BaseURL,
 		"user_id": user.ID,
 		"email":   user.Email,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(),
-		"iat":     time.Now().Unix(),
+		"jti":     uuid.NewString(),
+		"exp":     now.Add(s.config.AccessTokenTTL).Unix(),
+		"iat":     now.Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtSecret))
+	return s.keyService.Sign(claims)
 }
 
 func (s *authService) FindOrCreateUser(newUser *dto.CreateNewUserRequest) (*models.User, error) {
@@ -110,13 +307,10 @@ func (s *authService) FindOrCreateUser(newUser *dto.CreateNewUserRequest) (*mode
 }
 
 func (s *authService) ParseJWT(tokenString string) (*jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.jwtSecret), nil
-	})
-
+	token, err := jwt.Parse(tokenString, s.keyService.Keyfunc,
+		jwt.WithValidMethods(s.keyService.ValidMethods()),
+		jwt.WithIssuer(s.config.BaseURL),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -129,20 +323,198 @@ func (s *authService) ParseJWT(tokenString string) (*jwt.MapClaims, error) {
 }
 
 func (s *authService) GetUserIDFromJWT(tokenString string) (uuid.UUID, error) {
-	claims, err := s.ParseJWT(tokenString)
+	accessToken, err := s.ValidateAccessToken(tokenString)
 	if err != nil {
 		return uuid.Nil, err
 	}
+	return accessToken.UserID, nil
+}
+
+// ValidateAccessToken parses an access JWT and rejects tokens that were
+// revoked individually (logout) or by a user-wide revocation.
+func (s *authService) ValidateAccessToken(tokenString string) (*AccessToken, error) {
+	claims, err := s.ParseJWT(tokenString)
+	if err != nil {
+		return nil, err
+	}
 
 	userIDStr, ok := (*claims)["user_id"].(string)
 	if !ok {
-		return uuid.Nil, fmt.Errorf("user_id claim not found or not a string")
+		return nil, fmt.Errorf("user_id claim not found or not a string")
 	}
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user_id format: %v", err)
+		return nil, fmt.Errorf("invalid user_id format: %v", err)
+	}
+
+	tokenID, _ := (*claims)["jti"].(string)
+	if tokenID == "" {
+		// Tokens issued before revocation support can't be revoked, so stop accepting them
+		return nil, fmt.Errorf("jti claim not found")
+	}
+
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return nil, fmt.Errorf("iat claim not found")
+	}
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return nil, fmt.Errorf("exp claim not found")
+	}
+
+	revoked, err := s.tokenRepo.IsAccessTokenRevoked(tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, customErrors.ErrTokenRevoked
+	}
+
+	user, err := s.authRepo.GetUserById(userID)
+	if err != nil {
+		return nil, err
+	}
+	// iat only has second precision, so tokens issued in the second of the
+	// revocation itself (e.g. by the login that triggered it) stay valid
+	if user.TokensRevokedAt != nil && issuedAt.Time.Before(user.TokensRevokedAt.Truncate(time.Second)) {
+		return nil, customErrors.ErrTokenRevoked
+	}
+	if !models.CanSignIn(user.Status) {
+		return nil, customErrors.ErrAccountDisabled
+	}
+
+	return &AccessToken{
+		UserID:    userID,
+		TokenID:   tokenID,
+		IssuedAt:  issuedAt.Time,
+		ExpiresAt: expiresAt.Time,
+	}, nil
+}
+
+// IssueTokens starts a new session: a short-lived access JWT plus a refresh
+// token in a fresh token family.
+func (s *authService) IssueTokens(user *models.User) (*dto.TokenResponse, error) {
+	return s.issueTokens(user, uuid.New(), nil)
+}
+
+// RefreshTokens exchanges a refresh token for a new token pair. Each refresh
+// token works once; presenting a used one again is treated as theft and
+// revokes the whole family.
+func (s *authService) RefreshTokens(refreshToken string) (*dto.TokenResponse, error) {
+	stored, err := s.tokenRepo.GetRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil {
+		return nil, customErrors.ErrInvalidRefreshToken
+	}
+
+	if stored.ReplacedByID != nil {
+		log.Printf("SECURITY: refresh token reuse detected for user %s, revoking family %s", stored.UserID, stored.FamilyID)
+		if err := s.tokenRepo.RevokeRefreshTokenFamily(stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, customErrors.ErrRefreshTokenReused
+	}
+	if stored.RevokedAt != nil || !stored.ExpiresAt.After(time.Now()) {
+		return nil, customErrors.ErrInvalidRefreshToken
+	}
+
+	user, err := s.authRepo.GetUserById(stored.UserID)
+	if err != nil {
+		return nil, customErrors.ErrInvalidRefreshToken
 	}
 
-	return userID, nil
+	tokens, err := s.issueTokens(user, stored.FamilyID, &stored.ID)
+	if errors.Is(err, customErrors.ErrRefreshTokenReused) {
+		// Lost a race with another refresh using the same token
+		log.Printf("SECURITY: concurrent refresh token reuse for user %s, revoking family %s", stored.UserID, stored.FamilyID)
+		if err := s.tokenRepo.RevokeRefreshTokenFamily(stored.FamilyID); err != nil {
+			return nil, err
+		}
+	}
+	return tokens, err
+}
+
+// Logout revokes the current access token and either the given refresh
+// token's session or, with AllSessions, every session the user has.
+func (s *authService) Logout(userID uuid.UUID, accessToken *AccessToken, input dto.LogoutRequest) error {
+	if accessToken != nil {
+		if err := s.tokenRepo.RevokeAccessToken(accessToken.TokenID, accessToken.ExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	if input.AllSessions {
+		if err := s.tokenRepo.RevokeUserRefreshTokens(userID); err != nil {
+			return err
+		}
+		return s.authRepo.RevokeAllTokens(userID)
+	}
+
+	if input.RefreshToken != "" {
+		stored, err := s.tokenRepo.GetRefreshTokenByHash(hashToken(input.RefreshToken))
+		if err != nil || stored.UserID != userID {
+			return customErrors.ErrInvalidRefreshToken
+		}
+		return s.tokenRepo.RevokeRefreshTokenFamily(stored.FamilyID)
+	}
+	return nil
+}
+
+func (s *authService) JWKS() (*dto.JWKSResponse, error) {
+	return s.keyService.JWKS()
+}
+
+func (s *authService) CleanupTokens() error {
+	return s.tokenRepo.DeleteExpiredTokens()
+}
+
+func (s *authService) issueTokens(user *models.User, familyID uuid.UUID, replacesID *uuid.UUID) (*dto.TokenResponse, error) {
+	if !models.CanSignIn(user.Status) {
+		log.Printf("SECURITY: refused to sign in disabled user %s", user.ID)
+		return nil, customErrors.ErrAccountDisabled
+	}
+
+	accessToken, err := s.GenerateJWT(user)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken := "rt_" + utils.GenString(48)
+	stored := &models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.config.RefreshTokenTTL),
+	}
+
+	if replacesID != nil {
+		err = s.tokenRepo.RotateRefreshToken(*replacesID, stored)
+	} else {
+		err = s.tokenRepo.CreateRefreshToken(stored)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.config.AccessTokenTTL.Seconds()),
+		UserID:       user.ID,
+	}, nil
+}
+
+// randomToken returns 32 random bytes, base64url encoded
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hashToken returns the hex SHA-256 of an opaque token for storage and lookup
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
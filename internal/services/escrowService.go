@@ -0,0 +1,424 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+	"whotterre/argent/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	escrowDefaultExpiry = 14 * 24 * time.Hour
+	escrowMaxExpiry     = 90 * 24 * time.Hour
+	escrowDescMax       = 280
+	escrowBatchSize     = 100
+)
+
+// EscrowService locks payments between wallets until they are released to
+// the payee or refunded to the payer
+type EscrowService interface {
+	Create(actor AuditActor, mode string, input dto.CreateEscrowRequest) (*models.Escrow, error)
+	List(userID uuid.UUID, mode string, filter dto.EscrowFilter) ([]models.Escrow, int64, error)
+	Get(userID uuid.UUID, mode string, id uuid.UUID) (*models.Escrow, error)
+	Release(actor AuditActor, mode string, id uuid.UUID) (*models.Escrow, error)
+	Cancel(actor AuditActor, mode string, id uuid.UUID) (*models.Escrow, error)
+	Dispute(actor AuditActor, mode string, id uuid.UUID, reason string) (*models.Escrow, error)
+	AdminList(status string, limit, offset int) ([]models.Escrow, int64, error)
+	Resolve(actor AuditActor, id uuid.UUID, input dto.ResolveEscrowRequest) (*models.Escrow, error)
+	ResolveExpired() error
+}
+
+type escrowService struct {
+	escrowRepo    repositories.EscrowRepository
+	holdRepo      repositories.HoldRepository
+	walletService WalletService
+	walletRepo    repositories.WalletRepository
+	userRepo      repositories.UserRepository
+	feeService    FeeService
+	notifier      Notifier
+	auditService  AuditService
+	db            *gorm.DB
+}
+
+func NewEscrowService(escrowRepo repositories.EscrowRepository, holdRepo repositories.HoldRepository, walletService WalletService, walletRepo repositories.WalletRepository, userRepo repositories.UserRepository, feeService FeeService, notifier Notifier, auditService AuditService, db *gorm.DB) EscrowService {
+	return &escrowService{
+		escrowRepo:    escrowRepo,
+		holdRepo:      holdRepo,
+		walletService: walletService,
+		walletRepo:    walletRepo,
+		userRepo:      userRepo,
+		feeService:    feeService,
+		notifier:      notifier,
+		auditService:  auditService,
+		db:            db,
+	}
+}
+
+// Create holds the amount and its transfer fee in the payer's wallet.
+// Nothing reaches the payee until the escrow is released.
+func (s *escrowService) Create(actor AuditActor, mode string, input dto.CreateEscrowRequest) (*models.Escrow, error) {
+	if input.Amount <= 0 {
+		return nil, customErrors.ErrInvalidAmount
+	}
+	description := strings.TrimSpace(input.Description)
+	if len([]rune(description)) > escrowDescMax {
+		return nil, customErrors.ErrDescriptionTooLong
+	}
+	onExpiry := input.OnExpiry
+	if onExpiry == "" {
+		onExpiry = models.EscrowOnExpiryRelease
+	}
+	if onExpiry != models.EscrowOnExpiryRelease && onExpiry != models.EscrowOnExpiryRefund {
+		return nil, customErrors.ErrInvalidOnExpiry
+	}
+	now := time.Now().UTC()
+	expiresAt := now.Add(escrowDefaultExpiry)
+	if input.ExpiresAt != nil {
+		expiresAt = input.ExpiresAt.UTC()
+		if !expiresAt.After(now) || expiresAt.After(now.Add(escrowMaxExpiry)) {
+			return nil, customErrors.ErrInvalidEscrowExpiry
+		}
+	}
+
+	payer, payerWallet, payee, err := resolveHoldParties(s.walletService, s.walletRepo, s.userRepo, actor.UserID, input.Payee, mode)
+	if err != nil {
+		return nil, err
+	}
+	fee, err := s.feeService.CalculateFee(payer, "transfer", input.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if payerWallet.Balance < input.Amount+fee {
+		return nil, customErrors.ErrInsufficientBalance
+	}
+
+	// The escrow's own deadline applies, so the hold doesn't expire
+	reference := utils.GenRefString()
+	hold := &models.Hold{
+		WalletID:    payerWallet.ID,
+		UserID:      payer.ID,
+		PayeeID:     payee.ID,
+		Mode:        mode,
+		Kind:        models.HoldKindEscrow,
+		Amount:      input.Amount,
+		Fee:         fee,
+		Description: description,
+		Reference:   reference,
+		Status:      models.HoldActive,
+	}
+	escrow := &models.Escrow{
+		PayerID:     payer.ID,
+		PayeeID:     payee.ID,
+		Mode:        mode,
+		Amount:      input.Amount,
+		Fee:         fee,
+		Description: description,
+		Reference:   reference,
+		Status:      models.EscrowHeld,
+		ExpiresAt:   expiresAt,
+		OnExpiry:    onExpiry,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := placeHold(repositories.NewWalletRepository(tx), repositories.NewHoldRepository(tx), repositories.NewOutboxRepository(tx), hold); err != nil {
+			return err
+		}
+		escrow.HoldID = hold.ID
+		if err := repositories.NewEscrowRepository(tx).CreateEscrow(escrow); err != nil {
+			return err
+		}
+		return recordEscrowEvent(repositories.NewOutboxRepository(tx), escrow, "escrow.created")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditEscrow, "escrow", escrow.ID.String(), nil, escrow)
+	s.notify(escrow, "escrow.created")
+	return escrow, nil
+}
+
+func (s *escrowService) List(userID uuid.UUID, mode string, filter dto.EscrowFilter) ([]models.Escrow, int64, error) {
+	return s.escrowRepo.ListUserEscrows(userID, mode, filter)
+}
+
+// Get returns an escrow to its payer or payee. Anyone else gets
+// gorm.ErrRecordNotFound, as if it didn't exist.
+func (s *escrowService) Get(userID uuid.UUID, mode string, id uuid.UUID) (*models.Escrow, error) {
+	escrow, err := s.escrowRepo.GetEscrowByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if escrow.Mode != mode || (escrow.PayerID != userID && escrow.PayeeID != userID) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return escrow, nil
+}
+
+// Release pays a held escrow to the payee, once the payer has what they
+// paid for
+func (s *escrowService) Release(actor AuditActor, mode string, id uuid.UUID) (*models.Escrow, error) {
+	escrow, err := s.Get(actor.UserID, mode, id)
+	if err != nil {
+		return nil, err
+	}
+	if escrow.PayerID != actor.UserID {
+		return nil, customErrors.ErrNotEscrowPayer
+	}
+	if err := s.settle(actor, escrow, models.EscrowHeld, models.EscrowReleased, nil); err != nil {
+		return nil, err
+	}
+	return escrow, nil
+}
+
+// Cancel refunds a held escrow to the payer. Only the payee may cancel, so
+// the payer can't take the money back once the payee has delivered.
+func (s *escrowService) Cancel(actor AuditActor, mode string, id uuid.UUID) (*models.Escrow, error) {
+	escrow, err := s.Get(actor.UserID, mode, id)
+	if err != nil {
+		return nil, err
+	}
+	if escrow.PayeeID != actor.UserID {
+		return nil, customErrors.ErrNotEscrowPayee
+	}
+	if err := s.settle(actor, escrow, models.EscrowHeld, models.EscrowRefunded, nil); err != nil {
+		return nil, err
+	}
+	return escrow, nil
+}
+
+// Dispute stops a held escrow from being released, refunded or resolved by
+// its deadline until staff settle it
+func (s *escrowService) Dispute(actor AuditActor, mode string, id uuid.UUID, reason string) (*models.Escrow, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, customErrors.ErrDisputeReasonMissing
+	}
+	if len([]rune(reason)) > escrowDescMax {
+		return nil, customErrors.ErrDescriptionTooLong
+	}
+	escrow, err := s.Get(actor.UserID, mode, id)
+	if err != nil {
+		return nil, err
+	}
+
+	disputed := *escrow
+	disputed.Status = models.EscrowDisputed
+	disputed.DisputedByID = &actor.UserID
+	disputed.DisputeReason = reason
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		changed, err := repositories.NewEscrowRepository(tx).TransitionEscrow(escrow.ID, []string{models.EscrowHeld}, map[string]interface{}{
+			"status":         models.EscrowDisputed,
+			"disputed_by_id": actor.UserID,
+			"dispute_reason": reason,
+		})
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return customErrors.ErrEscrowNotHeld
+		}
+		return recordEscrowEvent(repositories.NewOutboxRepository(tx), &disputed, "escrow.disputed")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditEscrow, "escrow", escrow.ID.String(),
+		map[string]string{"status": escrow.Status},
+		map[string]string{"status": models.EscrowDisputed, "reason": reason})
+	*escrow = disputed
+	s.notify(escrow, "escrow.disputed")
+	return escrow, nil
+}
+
+func (s *escrowService) AdminList(status string, limit, offset int) ([]models.Escrow, int64, error) {
+	return s.escrowRepo.ListEscrows(status, limit, offset)
+}
+
+// Resolve settles a disputed escrow either way. Staff can't settle escrows
+// they are a party to.
+func (s *escrowService) Resolve(actor AuditActor, id uuid.UUID, input dto.ResolveEscrowRequest) (*models.Escrow, error) {
+	var status string
+	switch input.Outcome {
+	case models.EscrowOnExpiryRelease:
+		status = models.EscrowReleased
+	case models.EscrowOnExpiryRefund:
+		status = models.EscrowRefunded
+	default:
+		return nil, customErrors.ErrInvalidEscrowOutcome
+	}
+	escrow, err := s.escrowRepo.GetEscrowByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if actor.UserID == escrow.PayerID || actor.UserID == escrow.PayeeID {
+		log.Printf("SECURITY: user %s tried to settle escrow %s they are a party to", actor.UserID, escrow.ID)
+		return nil, customErrors.ErrCannotResolveOwn
+	}
+	note := strings.TrimSpace(input.Note)
+	if err := s.settle(actor, escrow, models.EscrowDisputed, status, map[string]interface{}{"resolution": note}); err != nil {
+		return nil, err
+	}
+	escrow.Resolution = note
+	return escrow, nil
+}
+
+// ResolveExpired releases or refunds held escrows past their deadline, as
+// each asked for when it was created
+func (s *escrowService) ResolveExpired() error {
+	escrows, err := s.escrowRepo.GetExpiredEscrows(time.Now().UTC(), escrowBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range escrows {
+		status := models.EscrowReleased
+		if escrows[i].OnExpiry == models.EscrowOnExpiryRefund {
+			status = models.EscrowRefunded
+		}
+		// A frozen payer or a payee who can't be credited yet is tried
+		// again on the next run
+		if err := s.settle(SystemActor, &escrows[i], models.EscrowHeld, status, nil); err != nil && !errors.Is(err, customErrors.ErrEscrowNotHeld) {
+			log.Printf("Failed to settle expired escrow %s: %v", escrows[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// settle releases or refunds an escrow in the from status by capturing or
+// voiding its hold. The status change and the money moving happen
+// together, so an escrow is only ever settled once. Releasing needs a payer
+// who may still be debited; refunds are always allowed.
+func (s *escrowService) settle(actor AuditActor, escrow *models.Escrow, from, status string, updates map[string]interface{}) error {
+	hold, err := s.holdRepo.GetHoldByID(escrow.HoldID)
+	if err != nil {
+		return err
+	}
+	var payeeWallet *models.Wallet
+	if status == models.EscrowReleased {
+		payer, err := s.userRepo.GetUserById(escrow.PayerID)
+		if err != nil {
+			return err
+		}
+		payerWallet, err := s.walletRepo.GetWalletByUserID(escrow.PayerID, escrow.Mode)
+		if err != nil {
+			return err
+		}
+		if !canDebit(payer, payerWallet) {
+			return customErrors.ErrDebitsBlocked
+		}
+		payee, err := s.userRepo.GetUserById(escrow.PayeeID)
+		if err != nil {
+			return err
+		}
+		if payeeWallet, err = s.walletRepo.GetWalletByUserID(escrow.PayeeID, escrow.Mode); err != nil {
+			return err
+		}
+		if !canCredit(payee, payeeWallet) {
+			return customErrors.ErrRecipientCannotReceive
+		}
+	}
+
+	now := time.Now().UTC()
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["status"] = status
+	updates["resolved_at"] = now
+
+	var transaction *models.Transaction
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		escrowRepo := repositories.NewEscrowRepository(tx)
+		walletRepo := repositories.NewWalletRepository(tx)
+		holdRepo := repositories.NewHoldRepository(tx)
+		outboxRepo := repositories.NewOutboxRepository(tx)
+
+		changed, err := escrowRepo.TransitionEscrow(escrow.ID, []string{from}, updates)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			if from == models.EscrowDisputed {
+				return customErrors.ErrEscrowNotDisputed
+			}
+			return customErrors.ErrEscrowNotHeld
+		}
+
+		if status == models.EscrowRefunded {
+			if err := releaseHold(walletRepo, holdRepo, outboxRepo, hold, models.HoldVoided); err != nil {
+				return err
+			}
+		} else {
+			transaction, err = captureHold(s.feeService, walletRepo, holdRepo, repositories.NewTransactionRepository(tx), outboxRepo, hold, payeeWallet.ID, escrow.Amount, escrow.Fee)
+			if err != nil {
+				return err
+			}
+			if _, err := escrowRepo.TransitionEscrow(escrow.ID, []string{status}, map[string]interface{}{"transaction_id": transaction.ID}); err != nil {
+				return err
+			}
+		}
+
+		settled := *escrow
+		settled.Status = status
+		return recordEscrowEvent(outboxRepo, &settled, "escrow."+status)
+	})
+	if err != nil {
+		return err
+	}
+
+	action := models.AuditEscrow
+	if from == models.EscrowDisputed {
+		action = models.AuditEscrowResolved
+	}
+	after := map[string]interface{}{"status": status, "amount": escrow.Amount, "fee": escrow.Fee, "mode": escrow.Mode}
+	if transaction != nil {
+		after["reference"] = transaction.Reference
+	}
+	s.auditService.Record(actor, action, "escrow", escrow.ID.String(), map[string]string{"status": escrow.Status}, after)
+
+	escrow.Status = status
+	escrow.ResolvedAt = &now
+	if transaction != nil {
+		escrow.TransactionID = &transaction.ID
+	}
+	s.notify(escrow, "escrow."+status)
+	return nil
+}
+
+// recordEscrowEvent tells both parties' webhook endpoints about an escrow
+// event, in the caller's transaction
+func recordEscrowEvent(outboxRepo repositories.OutboxRepository, escrow *models.Escrow, event string) error {
+	data := escrowEventData(escrow)
+	if err := recordEvent(outboxRepo, escrow.PayerID, escrow.Mode, event, data); err != nil {
+		return err
+	}
+	return recordEvent(outboxRepo, escrow.PayeeID, escrow.Mode, event, data)
+}
+
+// notify tells both parties about an escrow event once it has committed
+func (s *escrowService) notify(escrow *models.Escrow, event string) {
+	data := escrowEventData(escrow)
+	s.notifier.Notify(escrow.PayerID, event, data)
+	s.notifier.Notify(escrow.PayeeID, event, data)
+}
+
+func escrowEventData(escrow *models.Escrow) map[string]interface{} {
+	return map[string]interface{}{
+		"mode":        escrow.Mode,
+		"escrow_id":   escrow.ID,
+		"payer_id":    escrow.PayerID,
+		"payee_id":    escrow.PayeeID,
+		"amount":      escrow.Amount,
+		"description": escrow.Description,
+		"status":      escrow.Status,
+		"expires_at":  escrow.ExpiresAt,
+	}
+}
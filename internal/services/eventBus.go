@@ -0,0 +1,193 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// eventPublishBatch is how many domain events are claimed at a time
+	eventPublishBatch = 100
+	// eventPublishTimeout bounds each sink's Publish call, so a stuck sink
+	// can't hold the dispatcher up for long
+	eventPublishTimeout = 20 * time.Second
+	// eventLease is how long a replica may take to publish a claimed batch
+	// to every sink before another replica claims it again
+	eventLease = 2 * time.Minute
+)
+
+// EventSink receives domain events once the changes they describe have
+// committed. Events are delivered at least once, so sinks and their
+// consumers should ignore event IDs they have already seen. Ordering is best
+// effort: events go out by the time they were recorded, which is set before
+// commit, so an event from a long transaction can arrive after newer ones.
+type EventSink interface {
+	Name() string
+	Publish(events []models.DomainEvent) error
+}
+
+// EventHandler handles a domain event published to in-process subscribers
+type EventHandler func(event models.DomainEvent) error
+
+// EventBus is the in-process event sink: it hands each event to the
+// handlers subscribed to its type, or to "*" for every event
+type EventBus interface {
+	EventSink
+	Subscribe(eventType string, handler EventHandler)
+}
+
+type memoryEventBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]EventHandler
+}
+
+// NewEventBus returns an in-memory EventBus. Handlers only run on the
+// replica that publishes the event; a handler that fails is logged and
+// doesn't hold back the others.
+func NewEventBus() EventBus {
+	return &memoryEventBus{
+		handlers: map[string][]EventHandler{},
+	}
+}
+
+func (b *memoryEventBus) Name() string {
+	return "memory"
+}
+
+func (b *memoryEventBus) Subscribe(eventType string, handler EventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+func (b *memoryEventBus) Publish(events []models.DomainEvent) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, event := range events {
+		for _, handler := range b.handlers[event.Type] {
+			b.handle(handler, event)
+		}
+		for _, handler := range b.handlers["*"] {
+			b.handle(handler, event)
+		}
+	}
+	return nil
+}
+
+func (b *memoryEventBus) handle(handler EventHandler, event models.DomainEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Event handler panicked on %s %s: %v", event.Type, event.ID, r)
+		}
+	}()
+	if err := handler(event); err != nil {
+		log.Printf("Event handler failed on %s %s: %v", event.Type, event.ID, err)
+	}
+}
+
+// EventDispatcher publishes recorded domain events to the event sinks
+type EventDispatcher interface {
+	PublishPending() error
+}
+
+type eventDispatcher struct {
+	db    *gorm.DB
+	sinks []EventSink
+}
+
+// NewEventDispatcher publishes to sinks in the order given, so put sinks
+// that can fail before the in-process bus
+func NewEventDispatcher(db *gorm.DB, sinks ...EventSink) EventDispatcher {
+	return &eventDispatcher{
+		db:    db,
+		sinks: sinks,
+	}
+}
+
+// PublishPending publishes unpublished domain events, oldest first. Each
+// batch is claimed with a lease in a short transaction, published to every
+// sink outside it and then marked published, so no rows stay locked while
+// sinks are slow. Only one replica publishes at a time, so batches don't
+// overtake each other. If a sink fails, the batch is published again to
+// every sink once its lease runs out.
+func (d *eventDispatcher) PublishPending() error {
+	for {
+		events, err := d.claim()
+		if err != nil || len(events) == 0 {
+			return err
+		}
+		for _, sink := range d.sinks {
+			if err := publishWithTimeout(sink, events); err != nil {
+				return fmt.Errorf("%s sink: %w", sink.Name(), err)
+			}
+		}
+		ids := make([]uuid.UUID, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+		if err := repositories.NewOutboxRepository(d.db).MarkPublished(ids, time.Now().UTC()); err != nil {
+			return err
+		}
+		if len(events) < eventPublishBatch {
+			return nil
+		}
+	}
+}
+
+// claim leases the oldest unpublished events. It claims nothing while
+// another replica's lease on the oldest of them is running, so two replicas
+// don't publish batches at the same time.
+func (d *eventDispatcher) claim() ([]models.DomainEvent, error) {
+	var events []models.DomainEvent
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		outboxRepo := repositories.NewOutboxRepository(tx)
+
+		locked, err := outboxRepo.LockUnpublished(eventPublishBatch)
+		if err != nil || len(locked) == 0 {
+			return err
+		}
+		now := time.Now().UTC()
+		if lease := locked[0].LeaseUntil; lease != nil && lease.After(now) {
+			return nil
+		}
+		ids := make([]uuid.UUID, len(locked))
+		for i, event := range locked {
+			ids[i] = event.ID
+		}
+		if err := outboxRepo.LeaseDomainEvents(ids, now.Add(eventLease)); err != nil {
+			return err
+		}
+		events = locked
+		return nil
+	})
+	return events, err
+}
+
+// publishWithTimeout gives up on a sink that takes longer than
+// eventPublishTimeout. The call is left to finish in the background, and
+// the batch is published again later.
+func publishWithTimeout(sink EventSink, events []models.DomainEvent) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panicked: %v", r)
+			}
+		}()
+		done <- sink.Publish(events)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(eventPublishTimeout):
+		return errors.New("timed out")
+	}
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"whotterre/argent/internal/config"
+	"whotterre/argent/internal/models"
+
+	"github.com/nats-io/nats.go"
+	"gorm.io/gorm"
+)
+
+// eventSinkTimeout bounds each call an external sink makes
+const eventSinkTimeout = 10 * time.Second
+
+// pgNotifyLimit is the largest payload Postgres accepts in a notification
+const pgNotifyLimit = 8000
+
+// NewEventSinks returns the external sinks listed in EVENT_SINKS. Sinks
+// that are misconfigured are logged and left out.
+func NewEventSinks(cfg config.Config, db *gorm.DB) []EventSink {
+	var sinks []EventSink
+	for _, name := range cfg.EventSinks {
+		switch strings.ToLower(name) {
+		case "postgres":
+			sinks = append(sinks, NewPostgresEventSink(db, cfg.EventNotifyChannel))
+		case "nats":
+			sink, err := NewNATSEventSink(cfg.NATSURL, cfg.EventNATSSubject)
+			if err != nil {
+				log.Printf("Skipping nats event sink: %v", err)
+				continue
+			}
+			sinks = append(sinks, sink)
+		case "kafka":
+			if cfg.KafkaRESTURL == "" {
+				log.Printf("Skipping kafka event sink: KAFKA_REST_URL is not set")
+				continue
+			}
+			sinks = append(sinks, NewKafkaRESTEventSink(cfg.KafkaRESTURL, cfg.EventKafkaTopic))
+		default:
+			log.Printf("Skipping unknown event sink %q", name)
+		}
+	}
+	return sinks
+}
+
+type postgresEventSink struct {
+	db      *gorm.DB
+	channel string
+}
+
+// NewPostgresEventSink sends each event as a JSON notification on channel,
+// for other services to LISTEN to. Events too big for a notification are
+// sent without their data, marked "truncated".
+func NewPostgresEventSink(db *gorm.DB, channel string) EventSink {
+	return &postgresEventSink{
+		db:      db,
+		channel: channel,
+	}
+}
+
+func (s *postgresEventSink) Name() string {
+	return "postgres"
+}
+
+func (s *postgresEventSink) Publish(events []models.DomainEvent) error {
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if len(payload) >= pgNotifyLimit {
+			event.Data = map[string]interface{}{"truncated": true}
+			if payload, err = json.Marshal(event); err != nil {
+				return err
+			}
+		}
+		if err := s.db.Exec("SELECT pg_notify(?, ?)", s.channel, string(payload)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type natsEventSink struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// NewNATSEventSink publishes each event to "<subject>.<event type>" on the
+// NATS server at serverURL (nats://[user:pass@]host:port, or tls:// for a
+// TLS connection) and waits for the server to acknowledge each batch. A
+// server that is down at startup is connected to once it comes up.
+func NewNATSEventSink(serverURL, subject string) (EventSink, error) {
+	parsed, err := url.Parse(serverURL)
+	if err != nil || parsed.Hostname() == "" || (parsed.Scheme != "nats" && parsed.Scheme != "tls") {
+		return nil, fmt.Errorf("invalid NATS_URL %q", serverURL)
+	}
+	conn, err := nats.Connect(serverURL,
+		nats.Name("argent"),
+		nats.Timeout(eventSinkTimeout),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("Disconnected from NATS: %v", err)
+			}
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &natsEventSink{
+		conn:    conn,
+		subject: subject,
+	}, nil
+}
+
+func (s *natsEventSink) Name() string {
+	return "nats"
+}
+
+func (s *natsEventSink) Publish(events []models.DomainEvent) error {
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if err := s.conn.Publish(s.subject+"."+event.Type, payload); err != nil {
+			return err
+		}
+	}
+	// The server answers the flush once it has handled everything published
+	// before it
+	return s.conn.FlushTimeout(eventSinkTimeout)
+}
+
+type kafkaRESTEventSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewKafkaRESTEventSink produces events to topic through a Kafka REST proxy
+// (the Confluent v2 API) at proxyURL, keyed by aggregate ID so each
+// aggregate's events stay in order on one partition
+func NewKafkaRESTEventSink(proxyURL, topic string) EventSink {
+	return &kafkaRESTEventSink{
+		endpoint: strings.TrimSuffix(proxyURL, "/") + "/topics/" + url.PathEscape(topic),
+		client:   &http.Client{Timeout: eventSinkTimeout},
+	}
+}
+
+func (s *kafkaRESTEventSink) Name() string {
+	return "kafka"
+}
+
+type kafkaRecord struct {
+	Key   string             `json:"key"`
+	Value models.DomainEvent `json:"value"`
+}
+
+func (s *kafkaRESTEventSink) Publish(events []models.DomainEvent) error {
+	records := make([]kafkaRecord, len(events))
+	for i, event := range events {
+		records[i] = kafkaRecord{Key: event.AggregateID, Value: event}
+	}
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+		Message string `json:"message"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("kafka REST proxy returned %d: %s", resp.StatusCode, result.Message)
+	}
+	for _, offset := range result.Offsets {
+		if offset.ErrorCode != nil {
+			return fmt.Errorf("kafka REST proxy rejected a record: %s", offset.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"math"
+	"strings"
+
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// The platform's revenue account. The .invalid domain can't receive mail,
+// so nobody can sign in to it with a magic link or password reset.
+const revenueAccountEmail = "revenue@argent.invalid"
+
+// FeeService prices transactions from the fee rules and owns the platform
+// revenue account the fees are paid into
+type FeeService interface {
+	CalculateFee(user *models.User, transactionType string, amount float64) (float64, error)
+	Quote(userID uuid.UUID, transactionType string, amount float64) (*dto.FeeQuote, error)
+	ListRules() ([]models.FeeRule, error)
+	CreateRule(actor AuditActor, input dto.FeeRuleRequest) (*models.FeeRule, error)
+	UpdateRule(actor AuditActor, id uuid.UUID, input dto.FeeRuleRequest) (*models.FeeRule, error)
+	DeleteRule(actor AuditActor, id uuid.UUID) error
+	SetTier(actor AuditActor, userID uuid.UUID, tier string) error
+	EnsureRevenueAccount() error
+	RevenueUserID() uuid.UUID
+	GetRevenueWallets() ([]models.Wallet, error)
+}
+
+type feeService struct {
+	feeRepo       repositories.FeeRepository
+	userRepo      repositories.UserRepository
+	walletRepo    repositories.WalletRepository
+	auditService  AuditService
+	revenueUserID uuid.UUID
+}
+
+func NewFeeService(feeRepo repositories.FeeRepository, userRepo repositories.UserRepository, walletRepo repositories.WalletRepository, auditService AuditService) FeeService {
+	return &feeService{
+		feeRepo:      feeRepo,
+		userRepo:     userRepo,
+		walletRepo:   walletRepo,
+		auditService: auditService,
+	}
+}
+
+// CalculateFee prices a transaction for the user. A rule for the user's
+// tier beats one for every tier; with no rule the transaction is free.
+func (s *feeService) CalculateFee(user *models.User, transactionType string, amount float64) (float64, error) {
+	// Money moving within the platform's own account is never charged
+	if user.ID == s.revenueUserID {
+		return 0, nil
+	}
+
+	rules, err := s.feeRepo.GetActiveFeeRules(transactionType, models.CurrencyNGN)
+	if err != nil {
+		return 0, err
+	}
+	var match *models.FeeRule
+	for i := range rules {
+		if rules[i].Tier == user.Tier {
+			match = &rules[i]
+			break
+		}
+		if rules[i].Tier == "" {
+			match = &rules[i]
+		}
+	}
+	if match == nil {
+		return 0, nil
+	}
+	return match.Calculate(amount), nil
+}
+
+func (s *feeService) Quote(userID uuid.UUID, transactionType string, amount float64) (*dto.FeeQuote, error) {
+	if !models.IsFeeableType(transactionType) {
+		return nil, customErrors.ErrInvalidFeeType
+	}
+	if amount <= 0 {
+		return nil, customErrors.ErrInvalidAmount
+	}
+	user, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return nil, err
+	}
+	fee, err := s.CalculateFee(user, transactionType, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &dto.FeeQuote{
+		Type:      transactionType,
+		Currency:  models.CurrencyNGN,
+		Amount:    amount,
+		Fee:       fee,
+		Total:     roundKobo(amount + fee),
+		NetAmount: amount,
+	}
+	if transactionType == "deposit" {
+		if fee >= amount {
+			return nil, customErrors.ErrFeeExceedsAmount
+		}
+		quote.Total = amount
+		quote.NetAmount = roundKobo(amount - fee)
+	}
+	return quote, nil
+}
+
+func (s *feeService) ListRules() ([]models.FeeRule, error) {
+	return s.feeRepo.ListFeeRules()
+}
+
+func (s *feeService) CreateRule(actor AuditActor, input dto.FeeRuleRequest) (*models.FeeRule, error) {
+	rule := &models.FeeRule{}
+	if err := s.applyRule(rule, input); err != nil {
+		return nil, err
+	}
+	if err := s.feeRepo.CreateFeeRule(rule); err != nil {
+		return nil, err
+	}
+	s.auditService.Record(actor, models.AuditFeeRuleChanged, "fee_rule", rule.ID.String(), nil, rule)
+	return rule, nil
+}
+
+func (s *feeService) UpdateRule(actor AuditActor, id uuid.UUID, input dto.FeeRuleRequest) (*models.FeeRule, error) {
+	rule, err := s.feeRepo.GetFeeRuleByID(id)
+	if err != nil {
+		return nil, err
+	}
+	before := *rule
+	if err := s.applyRule(rule, input); err != nil {
+		return nil, err
+	}
+	if err := s.feeRepo.SaveFeeRule(rule); err != nil {
+		return nil, err
+	}
+	s.auditService.Record(actor, models.AuditFeeRuleChanged, "fee_rule", rule.ID.String(), before, rule)
+	return rule, nil
+}
+
+func (s *feeService) DeleteRule(actor AuditActor, id uuid.UUID) error {
+	rule, err := s.feeRepo.GetFeeRuleByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.feeRepo.DeleteFeeRule(id); err != nil {
+		return err
+	}
+	s.auditService.Record(actor, models.AuditFeeRuleChanged, "fee_rule", id.String(), rule, nil)
+	return nil
+}
+
+// applyRule validates the request and copies it onto the rule
+func (s *feeService) applyRule(rule *models.FeeRule, input dto.FeeRuleRequest) error {
+	input.TransactionType = strings.ToLower(strings.TrimSpace(input.TransactionType))
+	input.Currency = strings.ToUpper(strings.TrimSpace(input.Currency))
+	input.Tier = strings.ToLower(strings.TrimSpace(input.Tier))
+	if input.Currency == "" {
+		input.Currency = models.CurrencyNGN
+	}
+
+	if !models.IsFeeableType(input.TransactionType) {
+		return customErrors.ErrInvalidFeeType
+	}
+	if input.Currency != models.CurrencyNGN {
+		return customErrors.ErrUnsupportedCurrency
+	}
+	if input.Tier != "" && !models.IsValidTier(input.Tier) {
+		return customErrors.ErrInvalidTier
+	}
+	if !validFeeValues(input.Flat, input.Percent) || input.MinFee < 0 || input.MaxFee < 0 {
+		return customErrors.ErrInvalidFeeValues
+	}
+	if input.MaxFee > 0 && input.MaxFee < input.MinFee {
+		return customErrors.ErrInvalidFeeCap
+	}
+
+	bands := make([]models.FeeBand, 0, len(input.Bands))
+	for i, band := range input.Bands {
+		if !validFeeValues(band.Flat, band.Percent) {
+			return customErrors.ErrInvalidFeeValues
+		}
+		last := i == len(input.Bands)-1
+		if band.UpTo == nil && !last {
+			return customErrors.ErrInvalidFeeBands
+		}
+		if band.UpTo != nil && (*band.UpTo <= 0 || i > 0 && *band.UpTo <= *input.Bands[i-1].UpTo) {
+			return customErrors.ErrInvalidFeeBands
+		}
+		bands = append(bands, models.FeeBand{UpTo: band.UpTo, Flat: band.Flat, Percent: band.Percent})
+	}
+
+	active := input.Active == nil || *input.Active
+	if active {
+		taken, err := s.feeRepo.ActiveFeeRuleExists(input.TransactionType, input.Currency, input.Tier, rule.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return customErrors.ErrFeeRuleConflict
+		}
+	}
+
+	rule.TransactionType = input.TransactionType
+	rule.Currency = input.Currency
+	rule.Tier = input.Tier
+	rule.Flat = input.Flat
+	rule.Percent = input.Percent
+	rule.Bands = bands
+	rule.MinFee = input.MinFee
+	rule.MaxFee = input.MaxFee
+	rule.Active = active
+	return nil
+}
+
+func validFeeValues(flat, percent float64) bool {
+	return flat >= 0 && percent >= 0 && percent <= 100
+}
+
+func (s *feeService) SetTier(actor AuditActor, userID uuid.UUID, tier string) error {
+	tier = strings.ToLower(strings.TrimSpace(tier))
+	if !models.IsValidTier(tier) {
+		return customErrors.ErrInvalidTier
+	}
+	user, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return err
+	}
+	if user.Tier == tier {
+		return nil
+	}
+
+	if err := s.userRepo.UpdateTier(userID, tier); err != nil {
+		return err
+	}
+	s.auditService.Record(actor, models.AuditTierChanged, "user", userID.String(),
+		map[string]string{"tier": user.Tier}, map[string]string{"tier": tier})
+	return nil
+}
+
+// EnsureRevenueAccount finds or creates the account fees are paid into.
+// It runs once at startup, before any transaction can be charged.
+func (s *feeService) EnsureRevenueAccount() error {
+	user, err := s.userRepo.GetUserByEmail(revenueAccountEmail)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		user = &models.User{
+			Email:     revenueAccountEmail,
+			FirstName: "Argent",
+			LastName:  "Revenue",
+		}
+		if err = s.userRepo.CreateUser(user); err == nil {
+			log.Printf("Created platform revenue account %s", user.ID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	s.revenueUserID = user.ID
+	return nil
+}
+
+func (s *feeService) RevenueUserID() uuid.UUID {
+	return s.revenueUserID
+}
+
+func (s *feeService) GetRevenueWallets() ([]models.Wallet, error) {
+	return s.walletRepo.GetUserWallets(s.revenueUserID)
+}
+
+// roundKobo rounds naira amounts to the nearest kobo
+func roundKobo(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"log"
+	"strings"
+
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// FreezeService lets staff freeze, unfreeze and close users and wallets.
+// Every change is recorded in freeze_events with who made it and why.
+type FreezeService interface {
+	SetUserStatus(actor AuditActor, userID uuid.UUID, status, reasonCode, note string) error
+	SetWalletStatus(actor AuditActor, walletID uuid.UUID, status, reasonCode, note string) error
+	GetFreezeEvents(userID uuid.UUID) ([]models.FreezeEvent, error)
+}
+
+type freezeService struct {
+	userRepo      repositories.UserRepository
+	walletRepo    repositories.WalletRepository
+	freezeRepo    repositories.FreezeRepository
+	tokenRepo     repositories.TokenRepository
+	walletService WalletService
+	auditService  AuditService
+}
+
+func NewFreezeService(userRepo repositories.UserRepository, walletRepo repositories.WalletRepository, freezeRepo repositories.FreezeRepository, tokenRepo repositories.TokenRepository, walletService WalletService, auditService AuditService) FreezeService {
+	return &freezeService{
+		userRepo:      userRepo,
+		walletRepo:    walletRepo,
+		freezeRepo:    freezeRepo,
+		tokenRepo:     tokenRepo,
+		walletService: walletService,
+		auditService:  auditService,
+	}
+}
+
+// SetUserStatus applies to all of the user's wallets. Freezing or closing a
+// user also ends their sessions; their API keys stop working with it.
+func (s *freezeService) SetUserStatus(actor AuditActor, userID uuid.UUID, status, reasonCode, note string) error {
+	actorID := actor.UserID
+	if actorID == userID {
+		return customErrors.ErrCannotFreezeSelf
+	}
+	user, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return err
+	}
+	if err := checkStatusChange(user.Status, status, reasonCode); err != nil {
+		return err
+	}
+
+	event := &models.FreezeEvent{
+		SubjectType: models.FreezeSubjectUser,
+		SubjectID:   userID,
+		UserID:      userID,
+		FromStatus:  user.Status,
+		ToStatus:    status,
+		ReasonCode:  reasonCode,
+		Note:        strings.TrimSpace(note),
+		ActorID:     &actorID,
+	}
+	if err := s.freezeRepo.SetUserStatus(userID, status, reasonCode, event); err != nil {
+		return err
+	}
+	log.Printf("SECURITY: admin %s changed status of user %s from %s to %s (%s)", actorID, userID, user.Status, status, reasonCode)
+	s.auditService.Record(actor, models.AuditUserStatus, "user", userID.String(),
+		map[string]string{"status": user.Status, "reason_code": user.StatusReason},
+		map[string]string{"status": status, "reason_code": reasonCode, "note": event.Note})
+
+	if !models.CanSignIn(status) {
+		if err := s.tokenRepo.RevokeUserRefreshTokens(userID); err != nil {
+			return err
+		}
+		if err := s.userRepo.RevokeAllTokens(userID); err != nil {
+			return err
+		}
+	}
+	if models.CanCredit(status) {
+		s.walletService.ReleaseHeldDeposits(userID)
+	}
+	return nil
+}
+
+func (s *freezeService) SetWalletStatus(actor AuditActor, walletID uuid.UUID, status, reasonCode, note string) error {
+	actorID := actor.UserID
+	wallet, err := s.walletRepo.GetWalletByID(walletID)
+	if err != nil {
+		return err
+	}
+	if actorID == wallet.UserID {
+		return customErrors.ErrCannotFreezeSelf
+	}
+	if err := checkStatusChange(wallet.Status, status, reasonCode); err != nil {
+		return err
+	}
+
+	event := &models.FreezeEvent{
+		SubjectType: models.FreezeSubjectWallet,
+		SubjectID:   walletID,
+		UserID:      wallet.UserID,
+		FromStatus:  wallet.Status,
+		ToStatus:    status,
+		ReasonCode:  reasonCode,
+		Note:        strings.TrimSpace(note),
+		ActorID:     &actorID,
+	}
+	if err := s.freezeRepo.SetWalletStatus(walletID, status, reasonCode, event); err != nil {
+		return err
+	}
+	log.Printf("SECURITY: admin %s changed status of wallet %s (user %s) from %s to %s (%s)", actorID, walletID, wallet.UserID, wallet.Status, status, reasonCode)
+	s.auditService.Record(actor, models.AuditWalletStatus, "wallet", walletID.String(),
+		map[string]string{"status": wallet.Status, "reason_code": wallet.StatusReason},
+		map[string]string{"status": status, "reason_code": reasonCode, "note": event.Note})
+
+	if models.CanCredit(status) {
+		s.walletService.ReleaseHeldDeposits(wallet.UserID)
+	}
+	return nil
+}
+
+func (s *freezeService) GetFreezeEvents(userID uuid.UUID) ([]models.FreezeEvent, error) {
+	return s.freezeRepo.GetFreezeEvents(userID)
+}
+
+func checkStatusChange(from, to, reasonCode string) error {
+	if !models.IsValidAccountStatus(to) {
+		return customErrors.ErrInvalidAccountStatus
+	}
+	if !models.IsValidFreezeReason(reasonCode) {
+		return customErrors.ErrInvalidFreezeReason
+	}
+	if from == models.AccountClosed {
+		return customErrors.ErrAccountClosed
+	}
+	if from == to {
+		return customErrors.ErrStatusUnchanged
+	}
+	return nil
+}
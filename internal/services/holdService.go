@@ -0,0 +1,470 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+	"whotterre/argent/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	holdDefaultExpiry = 7 * 24 * time.Hour
+	holdMaxExpiry     = 30 * 24 * time.Hour
+	holdDescMax       = 280
+	holdBatchSize     = 100
+)
+
+var errHeldBalanceMismatch = errors.New("held balance doesn't cover the hold")
+
+// HoldService manages authorization holds: a payer sets funds aside for a
+// payee, who later captures some or all of them or voids the hold
+type HoldService interface {
+	Place(actor AuditActor, mode string, input dto.PlaceHoldRequest) (*models.Hold, error)
+	List(userID uuid.UUID, mode string, filter dto.HoldFilter) ([]models.Hold, int64, error)
+	Get(userID uuid.UUID, mode string, id uuid.UUID) (*models.Hold, error)
+	Capture(actor AuditActor, mode string, id uuid.UUID, amount *float64) (*models.Hold, error)
+	Void(actor AuditActor, mode string, id uuid.UUID) (*models.Hold, error)
+	ExpireHolds() error
+}
+
+type holdService struct {
+	holdRepo      repositories.HoldRepository
+	walletService WalletService
+	walletRepo    repositories.WalletRepository
+	userRepo      repositories.UserRepository
+	feeService    FeeService
+	notifier      Notifier
+	auditService  AuditService
+	db            *gorm.DB
+}
+
+func NewHoldService(holdRepo repositories.HoldRepository, walletService WalletService, walletRepo repositories.WalletRepository, userRepo repositories.UserRepository, feeService FeeService, notifier Notifier, auditService AuditService, db *gorm.DB) HoldService {
+	return &holdService{
+		holdRepo:      holdRepo,
+		walletService: walletService,
+		walletRepo:    walletRepo,
+		userRepo:      userRepo,
+		feeService:    feeService,
+		notifier:      notifier,
+		auditService:  auditService,
+		db:            db,
+	}
+}
+
+// Place authorizes the payee to take up to the amount from the actor's
+// wallet. The amount and its transfer fee leave the available balance
+// straight away.
+func (s *holdService) Place(actor AuditActor, mode string, input dto.PlaceHoldRequest) (*models.Hold, error) {
+	if input.Amount <= 0 {
+		return nil, customErrors.ErrInvalidAmount
+	}
+	description := strings.TrimSpace(input.Description)
+	if len([]rune(description)) > holdDescMax {
+		return nil, customErrors.ErrDescriptionTooLong
+	}
+	now := time.Now().UTC()
+	expiresAt := now.Add(holdDefaultExpiry)
+	if input.ExpiresAt != nil {
+		expiresAt = input.ExpiresAt.UTC()
+		if !expiresAt.After(now) || expiresAt.After(now.Add(holdMaxExpiry)) {
+			return nil, customErrors.ErrInvalidHoldExpiry
+		}
+	}
+
+	payer, payerWallet, payee, err := resolveHoldParties(s.walletService, s.walletRepo, s.userRepo, actor.UserID, input.Payee, mode)
+	if err != nil {
+		return nil, err
+	}
+	fee, err := s.feeService.CalculateFee(payer, "transfer", input.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if payerWallet.Balance < input.Amount+fee {
+		return nil, customErrors.ErrInsufficientBalance
+	}
+
+	hold := &models.Hold{
+		WalletID:    payerWallet.ID,
+		UserID:      payer.ID,
+		PayeeID:     payee.ID,
+		Mode:        mode,
+		Kind:        models.HoldKindAuthorization,
+		Amount:      input.Amount,
+		Fee:         fee,
+		Description: description,
+		Reference:   utils.GenRefString(),
+		Status:      models.HoldActive,
+		ExpiresAt:   &expiresAt,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		outboxRepo := repositories.NewOutboxRepository(tx)
+		if err := placeHold(repositories.NewWalletRepository(tx), repositories.NewHoldRepository(tx), outboxRepo, hold); err != nil {
+			return err
+		}
+		return recordHoldEvent(outboxRepo, hold, "hold.placed")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditHold, "hold", hold.ID.String(), nil, hold)
+	s.notify(hold, "hold.placed")
+	return hold, nil
+}
+
+func (s *holdService) List(userID uuid.UUID, mode string, filter dto.HoldFilter) ([]models.Hold, int64, error) {
+	return s.holdRepo.ListUserHolds(userID, mode, filter)
+}
+
+// Get returns a hold to its payer or payee. Anyone else gets
+// gorm.ErrRecordNotFound, as if it didn't exist.
+func (s *holdService) Get(userID uuid.UUID, mode string, id uuid.UUID) (*models.Hold, error) {
+	hold, err := s.holdRepo.GetHoldByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Mode != mode || (hold.UserID != userID && hold.PayeeID != userID) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return hold, nil
+}
+
+// Capture pays the payee amount, or the whole hold when amount is nil, and
+// returns the rest to the payer. A hold is captured once. The fee is worked
+// out on the captured amount but never more than was held for it. A payer
+// frozen since the hold was placed can't be captured from.
+func (s *holdService) Capture(actor AuditActor, mode string, id uuid.UUID, amount *float64) (*models.Hold, error) {
+	hold, err := s.payeeHold(actor.UserID, mode, id)
+	if err != nil {
+		return nil, err
+	}
+
+	captured := hold.Amount
+	if amount != nil {
+		captured = *amount
+	}
+	if captured <= 0 {
+		return nil, customErrors.ErrInvalidAmount
+	}
+	if captured > hold.Amount {
+		return nil, customErrors.ErrCaptureExceedsHold
+	}
+	payer, err := s.userRepo.GetUserById(hold.UserID)
+	if err != nil {
+		return nil, err
+	}
+	payerWallet, err := s.walletRepo.GetWalletByUserID(hold.UserID, hold.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if !canDebit(payer, payerWallet) {
+		return nil, customErrors.ErrDebitsBlocked
+	}
+	fee := hold.Fee
+	if captured < hold.Amount {
+		if fee, err = s.feeService.CalculateFee(payer, "transfer", captured); err != nil {
+			return nil, err
+		}
+		fee = math.Min(fee, hold.Fee)
+	}
+
+	payee, err := s.userRepo.GetUserById(hold.PayeeID)
+	if err != nil {
+		return nil, err
+	}
+	payeeWallet, err := s.walletRepo.GetWalletByUserID(hold.PayeeID, hold.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if !canCredit(payee, payeeWallet) {
+		return nil, customErrors.ErrRecipientCannotReceive
+	}
+
+	var transaction *models.Transaction
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		outboxRepo := repositories.NewOutboxRepository(tx)
+		transaction, err = captureHold(s.feeService, repositories.NewWalletRepository(tx), repositories.NewHoldRepository(tx), repositories.NewTransactionRepository(tx), outboxRepo, hold, payeeWallet.ID, captured, fee)
+		if err != nil {
+			return err
+		}
+		return recordHoldEvent(outboxRepo, hold, "hold.captured")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditHold, "hold", hold.ID.String(),
+		map[string]string{"status": models.HoldActive},
+		map[string]interface{}{"status": models.HoldCaptured, "captured_amount": captured, "fee": fee, "reference": transaction.Reference})
+	s.notify(hold, "hold.captured")
+	return hold, nil
+}
+
+// Void cancels a hold, returning everything to the payer
+func (s *holdService) Void(actor AuditActor, mode string, id uuid.UUID) (*models.Hold, error) {
+	hold, err := s.payeeHold(actor.UserID, mode, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.release(actor, hold, models.HoldVoided); err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// ExpireHolds returns the funds of active holds past their expiry to their
+// payers
+func (s *holdService) ExpireHolds() error {
+	holds, err := s.holdRepo.GetExpiredHolds(time.Now().UTC(), holdBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range holds {
+		if err := s.release(SystemActor, &holds[i], models.HoldExpired); err != nil && !errors.Is(err, customErrors.ErrHoldNotActive) {
+			log.Printf("Failed to expire hold %s: %v", holds[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// payeeHold returns an active authorization the user may capture or void.
+// One found past its expiry is expired on the spot.
+func (s *holdService) payeeHold(userID uuid.UUID, mode string, id uuid.UUID) (*models.Hold, error) {
+	hold, err := s.Get(userID, mode, id)
+	if err != nil {
+		return nil, err
+	}
+	if hold.PayeeID != userID {
+		return nil, customErrors.ErrNotHoldPayee
+	}
+	if hold.Kind != models.HoldKindAuthorization {
+		return nil, customErrors.ErrHoldNotCapturable
+	}
+	if hold.Status != models.HoldActive {
+		return nil, customErrors.ErrHoldNotActive
+	}
+	if hold.ExpiresAt != nil && !time.Now().Before(*hold.ExpiresAt) {
+		s.release(SystemActor, hold, models.HoldExpired)
+		return nil, customErrors.ErrHoldNotActive
+	}
+	return hold, nil
+}
+
+func (s *holdService) release(actor AuditActor, hold *models.Hold, status string) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		outboxRepo := repositories.NewOutboxRepository(tx)
+		if err := releaseHold(repositories.NewWalletRepository(tx), repositories.NewHoldRepository(tx), outboxRepo, hold, status); err != nil {
+			return err
+		}
+		return recordHoldEvent(outboxRepo, hold, "hold."+status)
+	})
+	if err != nil {
+		return err
+	}
+	s.auditService.Record(actor, models.AuditHold, "hold", hold.ID.String(),
+		map[string]string{"status": models.HoldActive}, map[string]string{"status": status})
+	s.notify(hold, "hold."+status)
+	return nil
+}
+
+// recordHoldEvent tells both parties' webhook endpoints about a hold
+// event, in the caller's transaction
+func recordHoldEvent(outboxRepo repositories.OutboxRepository, hold *models.Hold, event string) error {
+	data := holdNotificationData(hold)
+	if err := recordEvent(outboxRepo, hold.UserID, hold.Mode, event, data); err != nil {
+		return err
+	}
+	return recordEvent(outboxRepo, hold.PayeeID, hold.Mode, event, data)
+}
+
+// notify tells both parties about a hold event once it has committed
+func (s *holdService) notify(hold *models.Hold, event string) {
+	data := holdNotificationData(hold)
+	s.notifier.Notify(hold.UserID, event, data)
+	s.notifier.Notify(hold.PayeeID, event, data)
+}
+
+func holdNotificationData(hold *models.Hold) map[string]interface{} {
+	return map[string]interface{}{
+		"mode":            hold.Mode,
+		"hold_id":         hold.ID,
+		"payer_id":        hold.UserID,
+		"payee_id":        hold.PayeeID,
+		"amount":          hold.Amount,
+		"captured_amount": hold.CapturedAmount,
+		"description":     hold.Description,
+		"status":          hold.Status,
+	}
+}
+
+// resolveHoldParties looks up a payer who may pay and a payee who may be
+// paid in mode
+func resolveHoldParties(walletService WalletService, walletRepo repositories.WalletRepository, userRepo repositories.UserRepository, payerID uuid.UUID, recipient, mode string) (*models.User, *models.Wallet, *models.User, error) {
+	payer, err := userRepo.GetUserById(payerID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	payerWallet, err := walletRepo.GetWalletByUserID(payer.ID, mode)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !canDebit(payer, payerWallet) {
+		return nil, nil, nil, customErrors.ErrDebitsBlocked
+	}
+
+	resolved, err := walletService.ResolveRecipient(recipient, mode)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	payeeWallet, err := walletRepo.GetWalletByAccountNumber(resolved.AccountNumber)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if payeeWallet.UserID == payer.ID {
+		return nil, nil, nil, customErrors.ErrCannotPaySelf
+	}
+	payee, err := userRepo.GetUserById(payeeWallet.UserID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !canCredit(payee, payeeWallet) {
+		return nil, nil, nil, customErrors.ErrRecipientCannotReceive
+	}
+	return payer, payerWallet, payee, nil
+}
+
+// placeHold sets the hold's total aside in its wallet and records it. It
+// runs inside the caller's database transaction.
+func placeHold(walletRepo repositories.WalletRepository, holdRepo repositories.HoldRepository, outboxRepo repositories.OutboxRepository, hold *models.Hold) error {
+	held, err := walletRepo.Hold(hold.WalletID, hold.Total())
+	if err != nil {
+		return err
+	}
+	if !held {
+		return customErrors.ErrInsufficientBalance
+	}
+	if err := holdRepo.CreateHold(hold); err != nil {
+		return err
+	}
+	return recordDomainEvent(outboxRepo, models.DomainHoldPlaced, "hold", hold.ID.String(), hold.Mode, holdEventData(hold))
+}
+
+// captureHold pays amount of an active hold to the payee's wallet as a
+// transfer, charges fee and returns what is left to the payer's available
+// balance. It runs inside the caller's database transaction.
+func captureHold(feeService FeeService, walletRepo repositories.WalletRepository, holdRepo repositories.HoldRepository, transactionRepo repositories.TransactionRepository, outboxRepo repositories.OutboxRepository, hold *models.Hold, payeeWalletID uuid.UUID, amount, fee float64) (*models.Transaction, error) {
+	now := time.Now().UTC()
+	changed, err := holdRepo.TransitionHold(hold.ID, []string{models.HoldActive}, map[string]interface{}{
+		"status":          models.HoldCaptured,
+		"captured_amount": amount,
+		"captured_fee":    fee,
+		"closed_at":       now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return nil, customErrors.ErrHoldNotActive
+	}
+
+	if err := debitHeld(walletRepo.DebitHeld, hold.WalletID, hold.Total()); err != nil {
+		return nil, err
+	}
+	if rest := roundKobo(hold.Total() - amount - fee); rest > 0 {
+		if err := walletRepo.Credit(hold.WalletID, rest); err != nil {
+			return nil, err
+		}
+	}
+	if err := walletRepo.Credit(payeeWalletID, amount); err != nil {
+		return nil, err
+	}
+	transaction := &models.Transaction{
+		SenderID:   &hold.UserID,
+		ReceiverID: hold.PayeeID,
+		Amount:     amount,
+		Fee:        fee,
+		Type:       "transfer",
+		Status:     "success",
+		Reference:  hold.Reference,
+		Mode:       hold.Mode,
+	}
+	if err := transactionRepo.CreateTransaction(transaction); err != nil {
+		return nil, err
+	}
+	if err := recordTransferEvents(outboxRepo, transaction); err != nil {
+		return nil, err
+	}
+	if err := chargeFee(feeService, walletRepo, transactionRepo, transaction, hold.UserID); err != nil {
+		return nil, err
+	}
+	if _, err := holdRepo.TransitionHold(hold.ID, []string{models.HoldCaptured}, map[string]interface{}{"transaction_id": transaction.ID}); err != nil {
+		return nil, err
+	}
+
+	hold.Status = models.HoldCaptured
+	hold.CapturedAmount = amount
+	hold.CapturedFee = fee
+	hold.ClosedAt = &now
+	hold.TransactionID = &transaction.ID
+	return transaction, nil
+}
+
+// releaseHold closes an active hold with status, voided or expired, and
+// returns its total to the wallet's available balance. It runs inside the
+// caller's database transaction.
+func releaseHold(walletRepo repositories.WalletRepository, holdRepo repositories.HoldRepository, outboxRepo repositories.OutboxRepository, hold *models.Hold, status string) error {
+	now := time.Now().UTC()
+	changed, err := holdRepo.TransitionHold(hold.ID, []string{models.HoldActive}, map[string]interface{}{
+		"status":    status,
+		"closed_at": now,
+	})
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return customErrors.ErrHoldNotActive
+	}
+	if err := debitHeld(walletRepo.ReleaseHeld, hold.WalletID, hold.Total()); err != nil {
+		return err
+	}
+	hold.Status = status
+	hold.ClosedAt = &now
+	return recordDomainEvent(outboxRepo, models.DomainHoldReleased, "hold", hold.ID.String(), hold.Mode, holdEventData(hold))
+}
+
+func holdEventData(hold *models.Hold) map[string]interface{} {
+	return map[string]interface{}{
+		"hold_id":   hold.ID,
+		"kind":      hold.Kind,
+		"payer_id":  hold.UserID,
+		"payee_id":  hold.PayeeID,
+		"amount":    hold.Amount,
+		"fee":       hold.Fee,
+		"status":    hold.Status,
+		"reference": hold.Reference,
+	}
+}
+
+// debitHeld takes amount out of a held balance with move, which fails if
+// the held balance no longer covers it
+func debitHeld(move func(walletID uuid.UUID, amount float64) (bool, error), walletID uuid.UUID, amount float64) error {
+	ok, err := move(walletID, amount)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Printf("SECURITY: wallet %s held balance doesn't cover %.2f", walletID, amount)
+		return errHeldBalanceMismatch
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"whotterre/argent/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+// ExternalIdentity is who signed in, as asserted by an identity provider
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+// IdentityProvider runs the OAuth authorization code flow with PKCE against
+// an external identity provider and reports who signed in. The nonce is
+// checked against the ID token where the provider issues one.
+type IdentityProvider interface {
+	Name() string
+	AuthCodeURL(state, nonce, verifier string) (string, error)
+	Authenticate(ctx context.Context, code, nonce, verifier string) (*ExternalIdentity, error)
+}
+
+var identityHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewIdentityProviders builds the configured sign-in providers keyed by name
+func NewIdentityProviders(cfg config.Config) map[string]IdentityProvider {
+	providers := make(map[string]IdentityProvider, len(cfg.OAuthProviders))
+	for _, providerCfg := range cfg.OAuthProviders {
+		redirectURL := cfg.BaseURL + "/auth/" + providerCfg.Name + "/callback"
+		switch providerCfg.Type {
+		case "github":
+			providers[providerCfg.Name] = newGitHubProvider(providerCfg, redirectURL)
+		default:
+			providers[providerCfg.Name] = newOIDCProvider(providerCfg, redirectURL)
+		}
+		log.Printf("Sign-in provider %s enabled, redirect URL: %s", providerCfg.Name, redirectURL)
+	}
+	return providers
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+type oidcClaims struct {
+	jwt.RegisteredClaims
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // Apple sends "true" as a string
+	GivenName     string      `json:"given_name"`
+	FamilyName    string      `json:"family_name"`
+	Name          string      `json:"name"`
+	TenantID      string      `json:"tid"`
+	Nonce         string      `json:"nonce"`
+}
+
+// oidcProvider signs users in with any OpenID Connect provider, configured
+// from the issuer's discovery document.
+type oidcProvider struct {
+	config      config.OAuthProvider
+	redirectURL string
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+}
+
+func newOIDCProvider(providerCfg config.OAuthProvider, redirectURL string) IdentityProvider {
+	if len(providerCfg.Scopes) == 0 {
+		providerCfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &oidcProvider{
+		config:      providerCfg,
+		redirectURL: redirectURL,
+	}
+}
+
+func (p *oidcProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *oidcProvider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	opts := []oauth2.AuthCodeOption{
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	}
+	if p.config.ResponseMode != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("response_mode", p.config.ResponseMode))
+	}
+	return p.oauthConfig(discovery).AuthCodeURL(state, opts...), nil
+}
+
+func (p *oidcProvider) Authenticate(ctx context.Context, code, nonce, verifier string) (*ExternalIdentity, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, identityHTTPClient)
+	token, err := p.oauthConfig(discovery).Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+	claims, err := p.parseIDToken(discovery, rawIDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// Some providers leave profile claims out of the ID token
+	if claims.Email == "" && discovery.UserinfoEndpoint != "" {
+		if err := p.fetchUserInfo(ctx, discovery, token, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	identity := &ExternalIdentity{
+		Provider:      p.config.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: isTrue(claims.EmailVerified),
+		FirstName:     claims.GivenName,
+		LastName:      claims.FamilyName,
+	}
+	if identity.FirstName == "" && identity.LastName == "" {
+		identity.FirstName, identity.LastName = splitName(claims.Name)
+	}
+	return identity, nil
+}
+
+// parseIDToken checks the ID token's issuer, audience, expiry and nonce. It
+// arrives directly from the token endpoint over TLS, which OIDC Core 3.1.3.7
+// accepts in place of verifying its signature.
+func (p *oidcProvider) parseIDToken(discovery *oidcDiscovery, rawIDToken, nonce string) (*oidcClaims, error) {
+	claims := &oidcClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(rawIDToken, claims); err != nil {
+		return nil, err
+	}
+
+	// Multi-tenant issuers (e.g. Microsoft "common") template the tenant ID
+	issuer := strings.ReplaceAll(discovery.Issuer, "{tenantid}", claims.TenantID)
+	// Google may omit the scheme from iss
+	if claims.Issuer != issuer && "https://"+claims.Issuer != issuer {
+		return nil, fmt.Errorf("invalid id_token: unexpected issuer %q", claims.Issuer)
+	}
+	validator := jwt.NewValidator(jwt.WithAudience(p.config.ClientID), jwt.WithExpirationRequired())
+	if err := validator.Validate(claims); err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("id_token has no subject")
+	}
+	return claims, nil
+}
+
+func (p *oidcProvider) fetchUserInfo(ctx context.Context, discovery *oidcDiscovery, token *oauth2.Token, claims *oidcClaims) error {
+	client := p.oauthConfig(discovery).Client(ctx, token)
+	var userInfo oidcClaims
+	if err := getJSON(client, discovery.UserinfoEndpoint, &userInfo); err != nil {
+		return err
+	}
+	if userInfo.Subject != claims.Subject {
+		return errors.New("userinfo subject does not match id_token")
+	}
+
+	claims.Email = userInfo.Email
+	claims.EmailVerified = userInfo.EmailVerified
+	if claims.GivenName == "" && claims.FamilyName == "" {
+		claims.GivenName, claims.FamilyName, claims.Name = userInfo.GivenName, userInfo.FamilyName, userInfo.Name
+	}
+	return nil
+}
+
+// discover fetches and caches the issuer's discovery document
+func (p *oidcProvider) discover() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := getJSON(identityHTTPClient, p.config.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("OIDC discovery for %s failed: %w", p.config.Name, err)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" {
+		return nil, fmt.Errorf("OIDC discovery for %s returned no endpoints", p.config.Name)
+	}
+	if discovery.Issuer == "" {
+		discovery.Issuer = p.config.Issuer
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+func (p *oidcProvider) oauthConfig(discovery *oidcDiscovery) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.redirectURL,
+		Scopes:       p.config.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		},
+	}
+}
+
+// githubProvider signs users in with GitHub, which speaks plain OAuth 2.0
+// rather than OIDC.
+type githubProvider struct {
+	oauthConfig *oauth2.Config
+}
+
+func newGitHubProvider(providerCfg config.OAuthProvider, redirectURL string) IdentityProvider {
+	scopes := providerCfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"read:user", "user:email"}
+	}
+	return &githubProvider{
+		oauthConfig: &oauth2.Config{
+			ClientID:     providerCfg.ClientID,
+			ClientSecret: providerCfg.ClientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       scopes,
+			Endpoint:     github.Endpoint,
+		},
+	}
+}
+
+func (p *githubProvider) Name() string {
+	return "github"
+}
+
+// GitHub issues no ID token, so the nonce is unused
+func (p *githubProvider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	return p.oauthConfig.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (p *githubProvider) Authenticate(ctx context.Context, code, nonce, verifier string) (*ExternalIdentity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, identityHTTPClient)
+	token, err := p.oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+	client := p.oauthConfig.Client(ctx, token)
+
+	var profile struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(client, "https://api.github.com/user", &profile); err != nil {
+		return nil, err
+	}
+
+	// The profile email is optional and unverified, so use the primary address
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(client, "https://api.github.com/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &ExternalIdentity{
+		Provider: "github",
+		Subject:  strconv.FormatInt(profile.ID, 10),
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+		}
+	}
+	identity.FirstName, identity.LastName = splitName(profile.Name)
+	if identity.FirstName == "" {
+		identity.FirstName = profile.Login
+	}
+	return identity, nil
+}
+
+func getJSON(client *http.Client, url string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func isTrue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// splitName splits a display name into first name and the rest
+func splitName(name string) (string, string) {
+	first, last, _ := strings.Cut(strings.TrimSpace(name), " ")
+	return first, strings.TrimSpace(last)
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"whotterre/argent/internal/config"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+	"whotterre/argent/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// How often replicas reload the key set to pick up rotations made elsewhere
+const signingKeyReloadInterval = time.Minute
+
+// JWTKeyService manages the rotating key set used to sign and verify access
+// tokens, and publishes its public half as a JWKS.
+type JWTKeyService interface {
+	Sign(claims jwt.Claims) (string, error)
+	Keyfunc(token *jwt.Token) (interface{}, error)
+	ValidMethods() []string
+	JWKS() (*dto.JWKSResponse, error)
+	RotateIfDue() error
+}
+
+type loadedKey struct {
+	kid       string
+	algorithm string
+	createdAt time.Time
+	retired   bool
+	expiresAt *time.Time
+	private   crypto.Signer
+	public    crypto.PublicKey
+}
+
+type jwtKeyService struct {
+	signingKeyRepo repositories.SigningKeyRepository
+	config         config.Config
+
+	mu       sync.RWMutex
+	keys     map[string]*loadedKey
+	current  *loadedKey
+	loadedAt time.Time
+}
+
+func NewJWTKeyService(signingKeyRepo repositories.SigningKeyRepository, cfg config.Config) JWTKeyService {
+	if cfg.SecretsEncryptionKey == "" {
+		log.Println("WARNING: SECRETS_ENCRYPTION_KEY not set, JWT signing keys are kept in memory only and tokens won't survive restarts or verify across replicas")
+	}
+	return &jwtKeyService{
+		signingKeyRepo: signingKeyRepo,
+		config:         cfg,
+		keys:           map[string]*loadedKey{},
+	}
+}
+
+func (s *jwtKeyService) Sign(claims jwt.Claims) (string, error) {
+	key, err := s.currentKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.algorithm), claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// Keyfunc resolves the verification key for a token from its kid header.
+// Unknown kids trigger a reload in case another replica just rotated.
+func (s *jwtKeyService) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid")
+	}
+
+	key := s.lookup(kid)
+	if key == nil {
+		if err := s.reload(); err != nil {
+			return nil, err
+		}
+		key = s.lookup(kid)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+func (s *jwtKeyService) ValidMethods() []string {
+	return []string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}
+}
+
+func (s *jwtKeyService) JWKS() (*dto.JWKSResponse, error) {
+	if err := s.reloadIfStale(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	response := &dto.JWKSResponse{Keys: []dto.JWK{}}
+	for _, key := range s.keys {
+		jwk := dto.JWK{
+			KeyID:     key.kid,
+			Algorithm: key.algorithm,
+			Use:       "sig",
+		}
+		switch pub := key.public.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		}
+		response.Keys = append(response.Keys, jwk)
+	}
+	return response, nil
+}
+
+// RotateIfDue generates a new signing key when there is none or the current
+// one is older than the rotation interval. The previous key keeps verifying
+// until every token it signed has expired.
+func (s *jwtKeyService) RotateIfDue() error {
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	current := s.current
+	s.mu.RUnlock()
+
+	if current != nil && time.Since(current.createdAt) < s.config.JWTKeyRotation {
+		return nil
+	}
+	return s.rotate()
+}
+
+func (s *jwtKeyService) rotate() error {
+	key, err := generateSigningKey(s.config.JWTSigningAlg)
+	if err != nil {
+		return err
+	}
+	// Tokens signed by the outgoing key live at most one access token TTL
+	retiredKeysExpireAt := key.createdAt.Add(s.config.AccessTokenTTL + signingKeyReloadInterval)
+
+	if s.config.SecretsEncryptionKey == "" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for kid, k := range s.keys {
+			if k.expiresAt != nil && k.expiresAt.Before(key.createdAt) {
+				delete(s.keys, kid)
+				continue
+			}
+			if !k.retired {
+				k.retired = true
+				k.expiresAt = &retiredKeysExpireAt
+			}
+		}
+		s.keys[key.kid] = key
+		s.current = key
+		log.Printf("Generated in-memory JWT signing key %s (%s)", key.kid, key.algorithm)
+		return nil
+	}
+
+	record, err := key.toModel(s.config.SecretsEncryptionKey)
+	if err != nil {
+		return err
+	}
+	if err := s.signingKeyRepo.CreateSigningKey(record); err != nil {
+		return err
+	}
+	if err := s.signingKeyRepo.RetireKeysExcept(key.kid, retiredKeysExpireAt); err != nil {
+		return err
+	}
+	log.Printf("Rotated JWT signing key, new key %s (%s)", key.kid, key.algorithm)
+	return s.reload()
+}
+
+func (s *jwtKeyService) currentKey() (*loadedKey, error) {
+	if err := s.reloadIfStale(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	current := s.current
+	s.mu.RUnlock()
+	if current != nil {
+		return current, nil
+	}
+
+	// First start: nothing to sign with yet
+	if err := s.RotateIfDue(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.current == nil {
+		return nil, errors.New("no JWT signing key available")
+	}
+	return s.current, nil
+}
+
+func (s *jwtKeyService) lookup(kid string) *loadedKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys[kid]
+}
+
+func (s *jwtKeyService) reloadIfStale() error {
+	s.mu.RLock()
+	stale := time.Since(s.loadedAt) > signingKeyReloadInterval
+	s.mu.RUnlock()
+	if !stale {
+		return nil
+	}
+	return s.reload()
+}
+
+func (s *jwtKeyService) reload() error {
+	if s.config.SecretsEncryptionKey == "" {
+		return nil
+	}
+
+	records, err := s.signingKeyRepo.GetVerificationKeys()
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*loadedKey, len(records))
+	var current *loadedKey
+	for _, record := range records {
+		key, err := loadSigningKey(record, s.config.SecretsEncryptionKey)
+		if err != nil {
+			log.Printf("Skipping unreadable signing key %s: %v", record.KID, err)
+			continue
+		}
+		keys[key.kid] = key
+		// Records are newest first
+		if current == nil && !key.retired {
+			current = key
+		}
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.current = current
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+func generateSigningKey(algorithm string) (*loadedKey, error) {
+	key := &loadedKey{
+		kid:       uuid.NewString(),
+		algorithm: algorithm,
+		createdAt: time.Now(),
+	}
+
+	switch algorithm {
+	case jwt.SigningMethodEdDSA.Alg():
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key.private, key.public = priv, pub
+	case jwt.SigningMethodRS256.Alg():
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		key.private, key.public = priv, &priv.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported JWT signing algorithm %q", algorithm)
+	}
+	return key, nil
+}
+
+func (k *loadedKey) toModel(encryptionKey string) (*models.SigningKey, error) {
+	privDER, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return nil, err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(k.public)
+	if err != nil {
+		return nil, err
+	}
+
+	privPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})
+	encrypted, err := utils.EncryptSecret(encryptionKey, string(privPEM))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SigningKey{
+		KID:        k.kid,
+		Algorithm:  k.algorithm,
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})),
+		PrivateKey: encrypted,
+		CreatedAt:  k.createdAt,
+	}, nil
+}
+
+func loadSigningKey(record models.SigningKey, encryptionKey string) (*loadedKey, error) {
+	privPEM, err := utils.DecryptSecret(encryptionKey, record.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(privPEM))
+	if block == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot sign")
+	}
+
+	return &loadedKey{
+		kid:       record.KID,
+		algorithm: record.Algorithm,
+		createdAt: record.CreatedAt,
+		retired:   record.RetiredAt != nil,
+		private:   signer,
+		public:    signer.Public(),
+	}, nil
+}
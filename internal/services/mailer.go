@@ -0,0 +1,125 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"whotterre/argent/internal/config"
+
+	"github.com/google/uuid"
+)
+
+// Mailer delivers transactional email such as verification and sign-in links
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// NewMailer returns the mailer selected by MAIL_DRIVER
+func NewMailer(cfg config.Config) Mailer {
+	switch cfg.MailDriver {
+	case "smtp":
+		return NewSMTPMailer(cfg)
+	case "file":
+		return NewFileMailer(cfg.MailFileDir, cfg.MailFrom)
+	default:
+		return NewLogMailer()
+	}
+}
+
+type smtpMailer struct {
+	addr         string
+	auth         smtp.Auth
+	from         string
+	envelopeFrom string
+}
+
+// NewSMTPMailer sends mail through an SMTP server, upgrading to TLS with
+// STARTTLS when the server offers it
+func NewSMTPMailer(cfg config.Config) Mailer {
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	// MAIL_FROM may include a display name, the envelope needs the bare address
+	envelopeFrom := cfg.MailFrom
+	if address, err := mail.ParseAddress(cfg.MailFrom); err == nil {
+		envelopeFrom = address.Address
+	}
+	return &smtpMailer{
+		addr:         net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+		auth:         auth,
+		from:         cfg.MailFrom,
+		envelopeFrom: envelopeFrom,
+	}
+}
+
+func (m *smtpMailer) Send(to, subject, body string) error {
+	message := buildMessage(m.from, to, subject, body)
+	if err := smtp.SendMail(m.addr, m.auth, m.envelopeFrom, []string{to}, message); err != nil {
+		log.Printf("Failed to send email to %s: %v", to, err)
+		return err
+	}
+	return nil
+}
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer writes each email to an .eml file in dir, for local testing
+func NewFileMailer(dir, from string) Mailer {
+	return &fileMailer{
+		dir:  dir,
+		from: from,
+	}
+}
+
+func (m *fileMailer) Send(to, subject, body string) error {
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), uuid.NewString())
+	path := filepath.Join(m.dir, name)
+	if err := os.WriteFile(path, buildMessage(m.from, to, subject, body), 0o600); err != nil {
+		log.Printf("Failed to write email to %s: %v", path, err)
+		return err
+	}
+	log.Printf("Email to %s written to %s", to, path)
+	return nil
+}
+
+type logMailer struct{}
+
+// NewLogMailer returns a Mailer that only writes emails to the log
+func NewLogMailer() Mailer {
+	return &logMailer{}
+}
+
+func (m *logMailer) Send(to, subject, body string) error {
+	log.Printf("Email to %s: %s\n%s", to, subject, body)
+	return nil
+}
+
+// buildMessage formats a plain text RFC 5322 message
+func buildMessage(from, to, subject, body string) []byte {
+	// Header values must not smuggle in extra headers
+	clean := strings.NewReplacer("\r", "", "\n", "")
+	headers := []string{
+		"From: " + clean.Replace(from),
+		"To: " + clean.Replace(to),
+		"Subject: " + clean.Replace(subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	body = strings.ReplaceAll(body, "\n", "\r\n")
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + body + "\r\n")
+}
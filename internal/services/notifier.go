@@ -0,0 +1,24 @@
+package services
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// Notifier delivers user-facing notifications such as expiry warnings.
+type Notifier interface {
+	Notify(userID uuid.UUID, event string, data map[string]interface{}) error
+}
+
+type logNotifier struct{}
+
+// NewLogNotifier returns a Notifier that only writes notifications to the log
+func NewLogNotifier() Notifier {
+	return &logNotifier{}
+}
+
+func (n *logNotifier) Notify(userID uuid.UUID, event string, data map[string]interface{}) error {
+	log.Printf("Notification for user %s: %s %v", userID, event, data)
+	return nil
+}
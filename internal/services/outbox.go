@@ -0,0 +1,67 @@
+package services
+
+import (
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// recordEvent writes an event for the user to the outbox. Called with a
+// repository on the caller's database transaction, the event is kept only
+// if the change it describes is.
+func recordEvent(outboxRepo repositories.OutboxRepository, userID uuid.UUID, mode, eventType string, data map[string]interface{}) error {
+	return outboxRepo.CreateEvent(&models.OutboxEvent{
+		UserID: userID,
+		Mode:   mode,
+		Type:   eventType,
+		Data:   data,
+	})
+}
+
+// recordDomainEvent writes a domain event to the outbox, to be published to
+// the event sinks once the caller's transaction commits
+func recordDomainEvent(outboxRepo repositories.OutboxRepository, eventType, aggregateType, aggregateID, mode string, data map[string]interface{}) error {
+	return outboxRepo.CreateDomainEvent(&models.DomainEvent{
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Mode:          mode,
+		Data:          data,
+	})
+}
+
+// recordTransferEvents tells both parties about a transfer, and records a
+// TransferCompleted domain event. Metadata and the client reference are
+// the sender's own, so the receiver doesn't see them.
+func recordTransferEvents(outboxRepo repositories.OutboxRepository, transaction *models.Transaction) error {
+	if err := recordDomainEvent(outboxRepo, models.DomainTransferCompleted, "transaction", transaction.ID.String(), transaction.Mode, transactionEventData(transaction, true)); err != nil {
+		return err
+	}
+	if transaction.SenderID != nil {
+		if err := recordEvent(outboxRepo, *transaction.SenderID, transaction.Mode, models.EventTransferSent, transactionEventData(transaction, true)); err != nil {
+			return err
+		}
+	}
+	return recordEvent(outboxRepo, transaction.ReceiverID, transaction.Mode, models.EventTransferReceived, transactionEventData(transaction, false))
+}
+
+func transactionEventData(transaction *models.Transaction, forSender bool) map[string]interface{} {
+	data := map[string]interface{}{
+		"transaction_id": transaction.ID,
+		"type":           transaction.Type,
+		"reference":      transaction.Reference,
+		"amount":         transaction.Amount,
+		"fee":            transaction.Fee,
+		"status":         transaction.Status,
+		"sender_id":      transaction.SenderID,
+		"receiver_id":    transaction.ReceiverID,
+		"narration":      transaction.Narration,
+		"created_at":     transaction.CreatedAt,
+	}
+	if forSender {
+		data["metadata"] = transaction.Metadata
+		data["client_reference"] = transaction.ClientReference
+	}
+	return data
+}
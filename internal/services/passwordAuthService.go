@@ -0,0 +1,354 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"whotterre/argent/internal/config"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+	"whotterre/argent/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	verifyEmailTTL   = 24 * time.Hour
+	passwordResetTTL = time.Hour
+	magicLinkTTL     = 15 * time.Minute
+
+	minPasswordLength = 8
+	maxPasswordLength = 128
+)
+
+// PasswordAuthService handles email+password accounts and passwordless
+// magic-link sign-in. Successful logins get the same tokens as OAuth logins.
+type PasswordAuthService interface {
+	Register(input dto.RegisterRequest) (*models.User, error)
+	Login(input dto.PasswordLoginRequest) (*dto.TokenResponse, error)
+	VerifyEmail(token string) error
+	ResendVerification(email string) error
+	RequestPasswordReset(email string) error
+	ResetPassword(input dto.ResetPasswordRequest) error
+	RequestMagicLink(email string) error
+	LoginWithMagicLink(token string) (*dto.TokenResponse, error)
+}
+
+type passwordAuthService struct {
+	userRepo    repositories.UserRepository
+	tokenRepo   repositories.TokenRepository
+	authService AuthService
+	mailer      Mailer
+	config      config.Config
+	dummyHash   string
+}
+
+func NewPasswordAuthService(userRepo repositories.UserRepository, tokenRepo repositories.TokenRepository, authService AuthService, mailer Mailer, cfg config.Config) PasswordAuthService {
+	// Compared against when the email is unknown, so logins take as long
+	// whether or not the account exists
+	dummyHash, _ := utils.HashPassword(randomToken())
+	return &passwordAuthService{
+		userRepo:    userRepo,
+		tokenRepo:   tokenRepo,
+		authService: authService,
+		mailer:      mailer,
+		config:      cfg,
+		dummyHash:   dummyHash,
+	}
+}
+
+func (s *passwordAuthService) Register(input dto.RegisterRequest) (*models.User, error) {
+	email, err := normalizeEmail(input.Email)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePassword(input.Password); err != nil {
+		return nil, err
+	}
+	firstName, lastName := strings.TrimSpace(input.FirstName), strings.TrimSpace(input.LastName)
+	if firstName == "" || lastName == "" {
+		return nil, customErrors.ErrNameRequired
+	}
+
+	if _, err := s.userRepo.GetUserByEmail(email); err == nil {
+		return nil, customErrors.ErrEmailAlreadyRegistered
+	}
+
+	passwordHash, err := utils.HashPassword(input.Password)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{
+		Email:        email,
+		FirstName:    firstName,
+		LastName:     lastName,
+		PasswordHash: &passwordHash,
+	}
+	if err := s.userRepo.CreateUser(user); err != nil {
+		return nil, err
+	}
+
+	s.sendVerification(user)
+	return user, nil
+}
+
+func (s *passwordAuthService) Login(input dto.PasswordLoginRequest) (*dto.TokenResponse, error) {
+	user, err := s.userRepo.GetUserByEmail(strings.TrimSpace(input.Email))
+	if err != nil || user.PasswordHash == nil {
+		utils.VerifyPassword(input.Password, s.dummyHash)
+		return nil, customErrors.ErrInvalidCredentials
+	}
+
+	ok, err := utils.VerifyPassword(input.Password, *user.PasswordHash)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		log.Printf("SECURITY: failed password login for user %s", user.ID)
+		return nil, customErrors.ErrInvalidCredentials
+	}
+	// Only revealed once the password is right, so it can't be used to probe
+	if user.EmailVerifiedAt == nil {
+		return nil, customErrors.ErrEmailNotVerified
+	}
+
+	return s.authService.IssueTokens(user)
+}
+
+func (s *passwordAuthService) VerifyEmail(token string) error {
+	emailToken, err := s.consumeToken(token, models.EmailTokenVerifyEmail)
+	if err != nil {
+		return err
+	}
+	if emailToken.UserID == nil {
+		return customErrors.ErrInvalidEmailToken
+	}
+	return s.userRepo.MarkEmailVerified(*emailToken.UserID)
+}
+
+// ResendVerification mails a new verification link. It succeeds silently for
+// unknown or already verified addresses so it can't be used to probe accounts.
+func (s *passwordAuthService) ResendVerification(email string) error {
+	user, err := s.userRepo.GetUserByEmail(strings.TrimSpace(email))
+	if err != nil || user.EmailVerifiedAt != nil {
+		return nil
+	}
+	s.sendVerification(user)
+	return nil
+}
+
+// RequestPasswordReset mails a reset link to a registered address. Users who
+// have only signed in with a provider can use it to set a first password.
+func (s *passwordAuthService) RequestPasswordReset(email string) error {
+	user, err := s.userRepo.GetUserByEmail(strings.TrimSpace(email))
+	if err != nil {
+		return nil
+	}
+
+	token, err := s.createToken(user, user.Email, models.EmailTokenPasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	link := s.link("/auth/password/reset", token)
+	s.send(user.Email, "Reset your Argent password",
+		"Use the link below to choose a new password. It expires in 1 hour.\n\n"+link+
+			"\n\nOr POST the token to /auth/password/reset:\n"+token+
+			"\n\nIf you didn't ask to reset your password, you can ignore this email.")
+	return nil
+}
+
+// ResetPassword sets a new password and signs the user out everywhere
+func (s *passwordAuthService) ResetPassword(input dto.ResetPasswordRequest) error {
+	if err := validatePassword(input.Password); err != nil {
+		return err
+	}
+	emailToken, err := s.consumeToken(input.Token, models.EmailTokenPasswordReset)
+	if err != nil {
+		return err
+	}
+	if emailToken.UserID == nil {
+		return customErrors.ErrInvalidEmailToken
+	}
+	userID := *emailToken.UserID
+
+	passwordHash, err := utils.HashPassword(input.Password)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(userID, &passwordHash); err != nil {
+		return err
+	}
+	// Receiving the email proves the address is theirs
+	if err := s.userRepo.MarkEmailVerified(userID); err != nil {
+		return err
+	}
+	if err := s.tokenRepo.RevokeUserRefreshTokens(userID); err != nil {
+		return err
+	}
+	log.Printf("SECURITY: password reset for user %s, all sessions revoked", userID)
+	return s.userRepo.RevokeAllTokens(userID)
+}
+
+// RequestMagicLink mails a one-time sign-in link. Addresses without an
+// account get one too; the account is created when the link is used.
+func (s *passwordAuthService) RequestMagicLink(email string) error {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		user = nil
+	}
+
+	token, err := s.createToken(user, email, models.EmailTokenMagicLink, magicLinkTTL)
+	if err != nil {
+		return err
+	}
+	link := s.link("/auth/magic-link/verify", token)
+	s.send(email, "Your Argent sign-in link",
+		"Use the link below to sign in. It expires in 15 minutes and works once.\n\n"+link+
+			"\n\nOr POST the token to /auth/magic-link/verify:\n"+token+
+			"\n\nIf you didn't try to sign in, you can ignore this email.")
+	return nil
+}
+
+func (s *passwordAuthService) LoginWithMagicLink(token string) (*dto.TokenResponse, error) {
+	emailToken, err := s.consumeToken(token, models.EmailTokenMagicLink)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *models.User
+	if emailToken.UserID != nil {
+		user, err = s.userRepo.GetUserById(*emailToken.UserID)
+	} else {
+		user, err = s.userRepo.FindOrCreateUser(&dto.CreateNewUserRequest{
+			Email:         emailToken.Email,
+			EmailVerified: true,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := claimUnverifiedAccount(s.userRepo, s.tokenRepo, user); err != nil {
+		return nil, err
+	}
+	return s.authService.IssueTokens(user)
+}
+
+func (s *passwordAuthService) sendVerification(user *models.User) {
+	token, err := s.createToken(user, user.Email, models.EmailTokenVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return
+	}
+	link := s.link("/auth/email/verify", token)
+	s.send(user.Email, "Verify your Argent email address",
+		"Welcome to Argent! Confirm your email address with the link below. It expires in 24 hours.\n\n"+link)
+}
+
+func (s *passwordAuthService) createToken(user *models.User, email, purpose string, ttl time.Duration) (string, error) {
+	return createEmailToken(s.tokenRepo, user, email, purpose, ttl)
+}
+
+func (s *passwordAuthService) consumeToken(token, purpose string) (*models.EmailToken, error) {
+	emailToken, err := s.tokenRepo.ConsumeEmailToken(hashToken(token), purpose)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, customErrors.ErrInvalidEmailToken
+		}
+		return nil, err
+	}
+	return emailToken, nil
+}
+
+func (s *passwordAuthService) link(path, token string) string {
+	return s.config.AuthLinkBaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func (s *passwordAuthService) send(to, subject, body string) {
+	sendMail(s.mailer, to, subject, body)
+}
+
+// claimUnverifiedAccount marks the user's email verified once its owner has
+// proven control of it. A password set before then may belong to someone
+// who pre-registered the address, so it is discarded along with its sessions.
+func claimUnverifiedAccount(userRepo repositories.UserRepository, tokenRepo repositories.TokenRepository, user *models.User) error {
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	if user.PasswordHash != nil {
+		log.Printf("SECURITY: discarding unverified password of user %s after email ownership was proven", user.ID)
+		if err := userRepo.UpdatePassword(user.ID, nil); err != nil {
+			return err
+		}
+		if err := tokenRepo.RevokeUserRefreshTokens(user.ID); err != nil {
+			return err
+		}
+		if err := userRepo.RevokeAllTokens(user.ID); err != nil {
+			return err
+		}
+		user.PasswordHash = nil
+	}
+
+	if err := userRepo.MarkEmailVerified(user.ID); err != nil {
+		return err
+	}
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	return nil
+}
+
+// createEmailToken stores a single-use email token and returns its plaintext
+func createEmailToken(tokenRepo repositories.TokenRepository, user *models.User, email, purpose string, ttl time.Duration) (string, error) {
+	token := "et_" + utils.GenString(48)
+	emailToken := &models.EmailToken{
+		TokenHash: hashToken(token),
+		Purpose:   purpose,
+		Email:     email,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if user != nil {
+		emailToken.UserID = &user.ID
+	}
+	if err := tokenRepo.CreateEmailToken(emailToken); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// sendMail delivers mail in the background, so response times don't reveal
+// whether an address has an account
+func sendMail(mailer Mailer, to, subject, body string) {
+	go func() {
+		if err := mailer.Send(to, subject, body); err != nil {
+			log.Printf("Failed to send %q email: %v", subject, err)
+		}
+	}()
+}
+
+// normalizeEmail trims an email address and checks it is a bare address
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", customErrors.ErrInvalidEmail
+	}
+	return email, nil
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return customErrors.ErrWeakPassword
+	}
+	return nil
+}
@@ -0,0 +1,474 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"whotterre/argent/internal/config"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+	"whotterre/argent/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	paymentRequestDefaultExpiry = 7 * 24 * time.Hour
+	paymentRequestMaxExpiry     = 90 * 24 * time.Hour
+	paymentRequestMemoMax       = 140
+	// Payments for a request use references starting with this and the
+	// request's ID, so a deposit can be traced back to its request
+	paymentRequestRefPrefix = "preq_"
+)
+
+// PaymentRequestService manages requests for money, addressed to a payer or
+// shared as a public link, and their payment
+type PaymentRequestService interface {
+	Create(actor AuditActor, mode string, input dto.CreatePaymentRequest) (*models.PaymentRequest, error)
+	List(userID uuid.UUID, mode string, filter dto.PaymentRequestFilter) ([]models.PaymentRequest, int64, error)
+	Get(userID, id uuid.UUID) (*models.PaymentRequest, error)
+	GetPublic(code string) (*dto.PublicPaymentRequest, error)
+	Pay(actor AuditActor, mode string, id uuid.UUID) (*models.PaymentRequest, error)
+	PayLink(actor AuditActor, mode, code string) (*models.PaymentRequest, error)
+	PayByCard(code, email string) (*dto.DepositWalletResponse, error)
+	Decline(actor AuditActor, id uuid.UUID) error
+	Cancel(actor AuditActor, id uuid.UUID) error
+	ExpireRequests() error
+}
+
+type paymentRequestService struct {
+	paymentRequestRepo repositories.PaymentRequestRepository
+	walletService      WalletService
+	walletRepo         repositories.WalletRepository
+	userRepo           repositories.UserRepository
+	notifier           Notifier
+	auditService       AuditService
+	db                 *gorm.DB
+	config             config.Config
+}
+
+func NewPaymentRequestService(paymentRequestRepo repositories.PaymentRequestRepository, walletService WalletService, walletRepo repositories.WalletRepository, userRepo repositories.UserRepository, notifier Notifier, auditService AuditService, db *gorm.DB, cfg config.Config) PaymentRequestService {
+	s := &paymentRequestService{
+		paymentRequestRepo: paymentRequestRepo,
+		walletService:      walletService,
+		walletRepo:         walletRepo,
+		userRepo:           userRepo,
+		notifier:           notifier,
+		auditService:       auditService,
+		db:                 db,
+		config:             cfg,
+	}
+	walletService.OnDepositPaid(s.cardPaymentReceived)
+	return s
+}
+
+func (s *paymentRequestService) Create(actor AuditActor, mode string, input dto.CreatePaymentRequest) (*models.PaymentRequest, error) {
+	if input.Amount <= 0 {
+		return nil, customErrors.ErrInvalidAmount
+	}
+	memo := strings.TrimSpace(input.Memo)
+	if len([]rune(memo)) > paymentRequestMemoMax {
+		return nil, customErrors.ErrMemoTooLong
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(paymentRequestDefaultExpiry)
+	if input.ExpiresAt != nil {
+		expiresAt = input.ExpiresAt.UTC()
+		if !expiresAt.After(now) || expiresAt.After(now.Add(paymentRequestMaxExpiry)) {
+			return nil, customErrors.ErrInvalidRequestExpiry
+		}
+	}
+
+	request := &models.PaymentRequest{
+		RequesterID: actor.UserID,
+		Mode:        mode,
+		Amount:      input.Amount,
+		Memo:        memo,
+		Code:        utils.GenString(12),
+		Status:      models.PaymentRequestOpen,
+		ExpiresAt:   expiresAt,
+	}
+
+	if payer := strings.TrimSpace(input.Payer); payer != "" {
+		recipient, err := s.walletService.ResolveRecipient(payer, mode)
+		if err != nil {
+			return nil, err
+		}
+		wallet, err := s.walletRepo.GetWalletByAccountNumber(recipient.AccountNumber)
+		if err != nil {
+			return nil, err
+		}
+		if wallet.UserID == actor.UserID {
+			return nil, customErrors.ErrCannotRequestSelf
+		}
+		request.PayerID = &wallet.UserID
+	}
+
+	var data map[string]interface{}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewPaymentRequestRepository(tx).CreatePaymentRequest(request); err != nil {
+			return err
+		}
+		if request.PayerID == nil {
+			return nil
+		}
+		data = map[string]interface{}{
+			"mode":         request.Mode,
+			"request_id":   request.ID,
+			"requester_id": request.RequesterID,
+			"amount":       request.Amount,
+			"memo":         request.Memo,
+			"expires_at":   request.ExpiresAt,
+		}
+		return recordEvent(repositories.NewOutboxRepository(tx), *request.PayerID, request.Mode, models.EventPaymentRequestReceived, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.auditService.Record(actor, models.AuditPaymentRequest, "payment_request", request.ID.String(), nil, request)
+
+	if request.PayerID != nil {
+		s.notifier.Notify(*request.PayerID, models.EventPaymentRequestReceived, data)
+	}
+	return request, nil
+}
+
+func (s *paymentRequestService) List(userID uuid.UUID, mode string, filter dto.PaymentRequestFilter) ([]models.PaymentRequest, int64, error) {
+	return s.paymentRequestRepo.ListPaymentRequests(userID, mode, filter)
+}
+
+// Get returns a request to its requester or payer. Anyone else gets
+// gorm.ErrRecordNotFound, as if it didn't exist.
+func (s *paymentRequestService) Get(userID, id uuid.UUID) (*models.PaymentRequest, error) {
+	request, err := s.paymentRequestRepo.GetPaymentRequestByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if request.RequesterID != userID && (request.PayerID == nil || *request.PayerID != userID) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return request, nil
+}
+
+// GetPublic shows a public link to whoever holds it. Requests addressed to a
+// payer aren't reachable by code.
+func (s *paymentRequestService) GetPublic(code string) (*dto.PublicPaymentRequest, error) {
+	request, err := s.getLink(code)
+	if err != nil {
+		return nil, err
+	}
+	requester, err := s.userRepo.GetUserById(request.RequesterID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := request.Status
+	if status == models.PaymentRequestOpen && !time.Now().Before(request.ExpiresAt) {
+		status = models.PaymentRequestExpired
+	}
+	return &dto.PublicPaymentRequest{
+		Code:          request.Code,
+		RequesterName: maskName(requester.FirstName, requester.LastName),
+		Amount:        request.Amount,
+		Memo:          request.Memo,
+		Status:        status,
+		ExpiresAt:     request.ExpiresAt,
+	}, nil
+}
+
+// Pay pays a request addressed to the actor from their wallet
+func (s *paymentRequestService) Pay(actor AuditActor, mode string, id uuid.UUID) (*models.PaymentRequest, error) {
+	request, err := s.Get(actor.UserID, id)
+	if err != nil {
+		return nil, err
+	}
+	if request.PayerID == nil || *request.PayerID != actor.UserID {
+		return nil, customErrors.ErrNotRequestPayer
+	}
+	return s.pay(actor, mode, request)
+}
+
+// PayLink pays a public link from the actor's wallet
+func (s *paymentRequestService) PayLink(actor AuditActor, mode, code string) (*models.PaymentRequest, error) {
+	request, err := s.getLink(code)
+	if err != nil {
+		return nil, err
+	}
+	if request.RequesterID == actor.UserID {
+		return nil, customErrors.ErrCannotPaySelf
+	}
+	return s.pay(actor, mode, request)
+}
+
+// errRequestSettled rolls back a wallet payment of a request that was
+// settled some other way while it was being made
+var errRequestSettled = errors.New("payment request settled during payment")
+
+// pay transfers the requested amount to the requester and marks the request
+// paid in the same database transaction, so a request paid by card or
+// cancelled meanwhile leaves the payer's money where it was. Every wallet
+// payment of a request uses the same reference, so a request can't be paid
+// twice from wallets even by concurrent payers.
+func (s *paymentRequestService) pay(actor AuditActor, mode string, request *models.PaymentRequest) (*models.PaymentRequest, error) {
+	if err := s.checkPayable(request); err != nil {
+		return nil, err
+	}
+	if request.Mode != mode {
+		return nil, customErrors.ErrRequestModeMismatch
+	}
+
+	reference := paymentRequestRefPrefix + request.ID.String()
+	paidAt := time.Now().UTC()
+	data := map[string]interface{}{
+		"mode":       request.Mode,
+		"request_id": request.ID,
+		"amount":     request.Amount,
+		"paid_by_id": actor.UserID,
+		"reference":  reference,
+	}
+	requester, err := s.walletService.GetBalance(request.RequesterID, mode)
+	if err != nil {
+		return nil, err
+	}
+	transaction, err := s.walletService.TransferWith(actor.UserID, requester.AccountNumber, request.Amount, mode, reference, dto.TransferDetails{}, func(tx *gorm.DB, transaction *models.Transaction) error {
+		changed, err := repositories.NewPaymentRequestRepository(tx).TransitionPaymentRequest(request.ID, []string{models.PaymentRequestOpen, models.PaymentRequestExpired}, map[string]interface{}{
+			"status":         models.PaymentRequestPaid,
+			"paid_by_id":     actor.UserID,
+			"transaction_id": transaction.ID,
+			"paid_at":        paidAt,
+		})
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return errRequestSettled
+		}
+		return recordEvent(repositories.NewOutboxRepository(tx), request.RequesterID, request.Mode, models.EventPaymentRequestPaid, data)
+	})
+	if errors.Is(err, customErrors.ErrDuplicateReference) {
+		// A retry of the actor's own payment, which marked the request paid
+		// along with it
+		if transaction == nil || transaction.SenderID == nil || *transaction.SenderID != actor.UserID {
+			return nil, customErrors.ErrRequestAlreadyPaid
+		}
+		return s.paymentRequestRepo.GetPaymentRequestByID(request.ID)
+	}
+	if errors.Is(err, errRequestSettled) {
+		if current, err := s.paymentRequestRepo.GetPaymentRequestByID(request.ID); err == nil && current.Status != models.PaymentRequestPaid {
+			return nil, customErrors.ErrRequestNotOpen
+		}
+		return nil, customErrors.ErrRequestAlreadyPaid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditPaymentRequest, "payment_request", request.ID.String(),
+		map[string]string{"status": request.Status},
+		map[string]interface{}{"status": models.PaymentRequestPaid, "reference": transaction.Reference, "amount": transaction.Amount, "fee": transaction.Fee, "mode": transaction.Mode})
+	s.notifier.Notify(request.RequesterID, models.EventPaymentRequestPaid, data)
+
+	request.Status = models.PaymentRequestPaid
+	request.PaidByID = &actor.UserID
+	request.TransactionID = &transaction.ID
+	request.PaidAt = &paidAt
+	return request, nil
+}
+
+// PayByCard starts a Paystack checkout for a public link, for payers
+// without a wallet. The request is marked paid once the deposit is
+// confirmed.
+func (s *paymentRequestService) PayByCard(code, email string) (*dto.DepositWalletResponse, error) {
+	email = strings.TrimSpace(email)
+	if _, err := mail.ParseAddress(email); err != nil {
+		return nil, customErrors.ErrInvalidPayerEmail
+	}
+	request, err := s.getLink(code)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPayable(request); err != nil {
+		return nil, err
+	}
+
+	// Each checkout gets its own reference, since a payer may abandon one
+	// and start another
+	reference := paymentRequestRefPrefix + request.ID.String() + "_" + utils.GenString(6)
+	return s.walletService.CollectPayment(request.RequesterID, email, request.Amount, request.Mode, reference)
+}
+
+// cardPaymentReceived marks a request paid when a card payment for it is
+// confirmed. The money has arrived, so this happens even if the request
+// expired or was cancelled while the payer was at checkout.
+func (s *paymentRequestService) cardPaymentReceived(transaction *models.Transaction) {
+	reference := transaction.Reference
+	if !strings.HasPrefix(reference, paymentRequestRefPrefix) || len(reference) < len(paymentRequestRefPrefix)+36 {
+		return
+	}
+	id, err := uuid.Parse(reference[len(paymentRequestRefPrefix) : len(paymentRequestRefPrefix)+36])
+	if err != nil {
+		return
+	}
+	request, err := s.paymentRequestRepo.GetPaymentRequestByID(id)
+	if err != nil {
+		return
+	}
+	if request.Status == models.PaymentRequestPaid {
+		if request.TransactionID == nil || *request.TransactionID != transaction.ID {
+			log.Printf("Payment request %s was paid again by card with %s", request.ID, reference)
+		}
+		return
+	}
+
+	paidAt := time.Now().UTC()
+	data := map[string]interface{}{
+		"mode":       request.Mode,
+		"request_id": request.ID,
+		"amount":     request.Amount,
+		"reference":  reference,
+		"method":     "card",
+	}
+	changed := false
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		changed, err = repositories.NewPaymentRequestRepository(tx).TransitionPaymentRequest(request.ID, []string{models.PaymentRequestOpen, models.PaymentRequestExpired, models.PaymentRequestCancelled}, map[string]interface{}{
+			"status":         models.PaymentRequestPaid,
+			"transaction_id": transaction.ID,
+			"paid_at":        paidAt,
+		})
+		if err != nil || !changed {
+			return err
+		}
+		return recordEvent(repositories.NewOutboxRepository(tx), request.RequesterID, request.Mode, models.EventPaymentRequestPaid, data)
+	})
+	if err != nil {
+		log.Printf("Failed to mark payment request %s paid by card: %v", request.ID, err)
+		return
+	}
+	if !changed {
+		log.Printf("Card payment %s for payment request %s arrived after it was settled", reference, request.ID)
+		return
+	}
+
+	s.auditService.Record(SystemActor, models.AuditPaymentRequest, "payment_request", request.ID.String(),
+		map[string]string{"status": request.Status},
+		map[string]interface{}{"status": models.PaymentRequestPaid, "reference": reference, "amount": transaction.Amount, "fee": transaction.Fee, "mode": transaction.Mode, "method": "card"})
+	s.notifier.Notify(request.RequesterID, models.EventPaymentRequestPaid, data)
+}
+
+// Decline turns down a request addressed to the actor
+func (s *paymentRequestService) Decline(actor AuditActor, id uuid.UUID) error {
+	request, err := s.Get(actor.UserID, id)
+	if err != nil {
+		return err
+	}
+	if request.PayerID == nil || *request.PayerID != actor.UserID {
+		return customErrors.ErrNotRequestPayer
+	}
+	return s.transition(actor, request, models.PaymentRequestDeclined)
+}
+
+// Cancel withdraws one of the actor's own open requests
+func (s *paymentRequestService) Cancel(actor AuditActor, id uuid.UUID) error {
+	request, err := s.Get(actor.UserID, id)
+	if err != nil {
+		return err
+	}
+	if request.RequesterID != actor.UserID {
+		return customErrors.ErrNotRequester
+	}
+	return s.transition(actor, request, models.PaymentRequestCancelled)
+}
+
+// ExpireRequests marks open requests past their expiry as expired
+func (s *paymentRequestService) ExpireRequests() error {
+	expired, err := s.paymentRequestRepo.ExpirePaymentRequests(time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if expired > 0 {
+		log.Printf("Expired %d payment requests", expired)
+	}
+	return nil
+}
+
+// transition moves an open request to status, declined or cancelled, and
+// tells the other party: the requester of a decline, or the payer of a
+// cancelled request addressed to them
+func (s *paymentRequestService) transition(actor AuditActor, request *models.PaymentRequest, status string) error {
+	if err := s.checkPayable(request); err != nil {
+		return err
+	}
+	notifyID, event := &request.RequesterID, models.EventPaymentRequestDeclined
+	if status == models.PaymentRequestCancelled {
+		notifyID, event = request.PayerID, models.EventPaymentRequestCancelled
+	}
+	data := map[string]interface{}{
+		"mode":         request.Mode,
+		"request_id":   request.ID,
+		"amount":       request.Amount,
+		"requester_id": request.RequesterID,
+		"payer_id":     request.PayerID,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		changed, err := repositories.NewPaymentRequestRepository(tx).TransitionPaymentRequest(request.ID, []string{models.PaymentRequestOpen}, map[string]interface{}{"status": status})
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return customErrors.ErrRequestNotOpen
+		}
+		if notifyID == nil {
+			return nil
+		}
+		return recordEvent(repositories.NewOutboxRepository(tx), *notifyID, request.Mode, event, data)
+	})
+	if err != nil {
+		return err
+	}
+	s.auditService.Record(actor, models.AuditPaymentRequest, "payment_request", request.ID.String(),
+		map[string]string{"status": request.Status}, map[string]string{"status": status})
+	if notifyID != nil {
+		s.notifier.Notify(*notifyID, event, data)
+	}
+	return nil
+}
+
+// checkPayable fails unless the request is open and unexpired. Requests
+// found past their expiry are marked expired straight away rather than
+// waiting for the worker.
+func (s *paymentRequestService) checkPayable(request *models.PaymentRequest) error {
+	switch request.Status {
+	case models.PaymentRequestOpen:
+	case models.PaymentRequestPaid:
+		return customErrors.ErrRequestAlreadyPaid
+	case models.PaymentRequestExpired:
+		return customErrors.ErrRequestExpired
+	default:
+		return customErrors.ErrRequestNotOpen
+	}
+	if !time.Now().Before(request.ExpiresAt) {
+		s.paymentRequestRepo.TransitionPaymentRequest(request.ID, []string{models.PaymentRequestOpen}, map[string]interface{}{"status": models.PaymentRequestExpired})
+		return customErrors.ErrRequestExpired
+	}
+	return nil
+}
+
+// getLink finds a public link by its code
+func (s *paymentRequestService) getLink(code string) (*models.PaymentRequest, error) {
+	request, err := s.paymentRequestRepo.GetPaymentRequestByCode(code)
+	if err != nil {
+		return nil, err
+	}
+	if request.PayerID != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return request, nil
+}
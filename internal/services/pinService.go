@@ -0,0 +1,236 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"time"
+
+	"whotterre/argent/internal/config"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+	"whotterre/argent/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const pinResetTTL = 30 * time.Minute
+
+// PINService manages the transaction PIN users confirm transfers with
+type PINService interface {
+	Status(userID uuid.UUID) (*dto.PINStatusResponse, error)
+	SetPIN(userID uuid.UUID, pin string) error
+	ChangePIN(actor AuditActor, input dto.ChangePINRequest) error
+	RequestReset(userID uuid.UUID) error
+	ResetPIN(actor AuditActor, input dto.ResetPINRequest) error
+	VerifyPIN(actor AuditActor, pin string) error
+}
+
+type pinService struct {
+	userRepo     repositories.UserRepository
+	pinRepo      repositories.PINRepository
+	tokenRepo    repositories.TokenRepository
+	mailer       Mailer
+	auditService AuditService
+	config       config.Config
+}
+
+func NewPINService(userRepo repositories.UserRepository, pinRepo repositories.PINRepository, tokenRepo repositories.TokenRepository, mailer Mailer, auditService AuditService, cfg config.Config) PINService {
+	return &pinService{
+		userRepo:     userRepo,
+		pinRepo:      pinRepo,
+		tokenRepo:    tokenRepo,
+		mailer:       mailer,
+		auditService: auditService,
+		config:       cfg,
+	}
+}
+
+func (s *pinService) Status(userID uuid.UUID) (*dto.PINStatusResponse, error) {
+	user, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &dto.PINStatusResponse{Set: user.PINHash != nil}
+	if pinLocked(user) {
+		status.LockedUntil = user.PINLockedUntil
+	}
+	return status, nil
+}
+
+func (s *pinService) SetPIN(userID uuid.UUID, pin string) error {
+	if err := validatePIN(pin); err != nil {
+		return err
+	}
+	pinHash, err := utils.HashPassword(pin)
+	if err != nil {
+		return err
+	}
+
+	set, err := s.pinRepo.SetInitialPIN(userID, pinHash)
+	if err != nil {
+		return err
+	}
+	if !set {
+		return customErrors.ErrPINAlreadySet
+	}
+	log.Printf("SECURITY: transaction PIN set for user %s", userID)
+	return nil
+}
+
+func (s *pinService) ChangePIN(actor AuditActor, input dto.ChangePINRequest) error {
+	userID := actor.UserID
+	if err := validatePIN(input.NewPIN); err != nil {
+		return err
+	}
+	if err := s.VerifyPIN(actor, input.CurrentPIN); err != nil {
+		return err
+	}
+
+	pinHash, err := utils.HashPassword(input.NewPIN)
+	if err != nil {
+		return err
+	}
+	if err := s.pinRepo.UpdatePIN(userID, pinHash); err != nil {
+		return err
+	}
+	log.Printf("SECURITY: transaction PIN changed for user %s", userID)
+	return nil
+}
+
+// RequestReset emails the user a link to choose a new PIN, for when they've
+// forgotten it or it is locked
+func (s *pinService) RequestReset(userID uuid.UUID) error {
+	user, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return err
+	}
+	if user.PINHash == nil {
+		return customErrors.ErrPINNotSet
+	}
+
+	token, err := createEmailToken(s.tokenRepo, user, user.Email, models.EmailTokenPINReset, pinResetTTL)
+	if err != nil {
+		return err
+	}
+	link := s.config.AuthLinkBaseURL + "/auth/pin/reset?token=" + url.QueryEscape(token)
+	sendMail(s.mailer, user.Email, "Reset your Argent transaction PIN",
+		"Use the link below to choose a new transaction PIN. It expires in 30 minutes.\n\n"+link+
+			"\n\nOr POST the token to /auth/pin/reset while signed in:\n"+token+
+			"\n\nIf you didn't ask to reset your PIN, sign in and change your password.")
+	log.Printf("SECURITY: transaction PIN reset requested for user %s", userID)
+	return nil
+}
+
+// ResetPIN sets a new PIN with an emailed reset token and lifts any lockout
+func (s *pinService) ResetPIN(actor AuditActor, input dto.ResetPINRequest) error {
+	userID := actor.UserID
+	if err := validatePIN(input.PIN); err != nil {
+		return err
+	}
+
+	emailToken, err := s.tokenRepo.ConsumeEmailToken(hashToken(input.Token), models.EmailTokenPINReset)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return customErrors.ErrInvalidEmailToken
+		}
+		return err
+	}
+	// The token only works in the session of the user it was mailed to
+	if emailToken.UserID == nil || *emailToken.UserID != userID {
+		log.Printf("SECURITY: user %s presented a PIN reset token issued to someone else", userID)
+		return customErrors.ErrInvalidEmailToken
+	}
+
+	pinHash, err := utils.HashPassword(input.PIN)
+	if err != nil {
+		return err
+	}
+	if err := s.pinRepo.UpdatePIN(userID, pinHash); err != nil {
+		return err
+	}
+	log.Printf("SECURITY: transaction PIN reset for user %s", userID)
+	s.auditService.Record(actor, models.AuditPINReset, "user", userID.String(), nil, nil)
+	sendMail(s.mailer, emailToken.Email, "Your Argent transaction PIN was reset",
+		"Your transaction PIN was just reset. If this wasn't you, change your password and contact support right away.")
+	return nil
+}
+
+// VerifyPIN checks the PIN for a transfer. Too many wrong PINs lock it for
+// PIN_LOCKOUT, and the user is told by email. The attempt is counted before
+// the PIN is checked, so guesses made in parallel are limited like any other.
+func (s *pinService) VerifyPIN(actor AuditActor, pin string) error {
+	userID := actor.UserID
+	user, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return err
+	}
+	if user.PINHash == nil {
+		return customErrors.ErrPINNotSet
+	}
+	if pin == "" {
+		return customErrors.ErrPINRequired
+	}
+
+	lockUntil := time.Now().Add(s.config.PINLockout)
+	reserved, locked, err := s.pinRepo.ReservePINAttempt(userID, s.config.PINMaxAttempts, lockUntil)
+	if err != nil {
+		return err
+	}
+	if !reserved {
+		return customErrors.ErrPINLocked
+	}
+
+	ok, err := utils.VerifyPassword(pin, *user.PINHash)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return s.pinRepo.ClearPINFailures(userID)
+	}
+
+	log.Printf("SECURITY: wrong transaction PIN for user %s", userID)
+	if locked {
+		log.Printf("SECURITY: transaction PIN locked for user %s until %s", userID, lockUntil.Format(time.RFC3339))
+		s.auditService.Record(actor, models.AuditPINLocked, "user", userID.String(), nil,
+			map[string]interface{}{"failed_attempts": s.config.PINMaxAttempts, "locked_until": lockUntil.UTC()})
+		sendMail(s.mailer, user.Email, "Your Argent transaction PIN is locked",
+			"Your transaction PIN was entered wrongly too many times, so transfers are blocked until "+
+				lockUntil.UTC().Format(time.RFC1123)+".\n\nIf this wasn't you, change your password. You can also reset your PIN from the app.")
+		return customErrors.ErrPINLocked
+	}
+	return customErrors.ErrInvalidPIN
+}
+
+func pinLocked(user *models.User) bool {
+	return user.PINLockedUntil != nil && time.Now().Before(*user.PINLockedUntil)
+}
+
+// validatePIN accepts 4-6 digits, refusing repeated digits like 0000 and
+// runs like 1234 or 987654
+func validatePIN(pin string) error {
+	if len(pin) < 4 || len(pin) > 6 {
+		return customErrors.ErrWeakPIN
+	}
+	for i := 0; i < len(pin); i++ {
+		if pin[i] < '0' || pin[i] > '9' {
+			return customErrors.ErrWeakPIN
+		}
+	}
+
+	repeated, ascending, descending := true, true, true
+	for i := 1; i < len(pin); i++ {
+		diff := int(pin[i]) - int(pin[i-1])
+		repeated = repeated && diff == 0
+		ascending = ascending && diff == 1
+		descending = descending && diff == -1
+	}
+	if repeated || ascending || descending {
+		return customErrors.ErrWeakPIN
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+	"whotterre/argent/internal/config"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Wallet updates pushed to event streams
+const (
+	UpdateBalance  = "balance"
+	UpdateDeposit  = "deposit"
+	UpdateTransfer = "transfer"
+)
+
+// streamBuffer is how far a slow client may fall behind before updates to
+// it are dropped
+const streamBuffer = 16
+
+// RealtimeService pushes balance changes, deposit status changes and
+// incoming transfers to clients holding an event stream open. Updates are
+// worked out from domain events on whichever replica publishes them, then
+// sent to every replica over Postgres LISTEN/NOTIFY.
+type RealtimeService interface {
+	Subscribe(userID uuid.UUID, mode string) (<-chan dto.WalletUpdate, func(), error)
+	SubscribeDeposit(reference string) (<-chan dto.WalletUpdate, func(), error)
+	HandleEvent(event models.DomainEvent) error
+	Listen()
+}
+
+// streamKey identifies what a stream watches: a user's wallet in a mode,
+// or a single deposit by reference
+type streamKey struct {
+	userID    uuid.UUID
+	mode      string
+	reference string
+}
+
+type realtimeService struct {
+	walletService WalletService
+	db            *gorm.DB
+	config        config.Config
+
+	mu      sync.Mutex
+	streams map[streamKey]map[chan dto.WalletUpdate]struct{}
+}
+
+func NewRealtimeService(walletService WalletService, db *gorm.DB, cfg config.Config) RealtimeService {
+	return &realtimeService{
+		walletService: walletService,
+		db:            db,
+		config:        cfg,
+		streams:       map[streamKey]map[chan dto.WalletUpdate]struct{}{},
+	}
+}
+
+// walletNotification carries an update to every replica
+type walletNotification struct {
+	UserID    uuid.UUID        `json:"user_id"`
+	Mode      string           `json:"mode"`
+	Reference string           `json:"reference,omitempty"` // also sent to streams watching this deposit
+	Update    dto.WalletUpdate `json:"update"`
+}
+
+// Subscribe opens a stream of updates to the user's wallet in mode,
+// starting with its current balance. Call the returned function to close it.
+func (s *realtimeService) Subscribe(userID uuid.UUID, mode string) (<-chan dto.WalletUpdate, func(), error) {
+	return s.subscribe(streamKey{userID: userID, mode: mode})
+}
+
+// SubscribeDeposit opens a stream of status changes to one deposit,
+// starting with its current status
+func (s *realtimeService) SubscribeDeposit(reference string) (<-chan dto.WalletUpdate, func(), error) {
+	return s.subscribe(streamKey{reference: reference})
+}
+
+// subscribe registers a stream before reading the snapshot it starts with,
+// so no change made after the snapshot is missed
+func (s *realtimeService) subscribe(key streamKey) (<-chan dto.WalletUpdate, func(), error) {
+	s.mu.Lock()
+	subscribers := s.streams[key]
+	if len(subscribers) >= s.config.RealtimeMaxStreams {
+		s.mu.Unlock()
+		return nil, nil, customErrors.ErrTooManyStreams
+	}
+	if subscribers == nil {
+		subscribers = map[chan dto.WalletUpdate]struct{}{}
+		s.streams[key] = subscribers
+	}
+	updates := make(chan dto.WalletUpdate, streamBuffer)
+	subscribers[updates] = struct{}{}
+	s.mu.Unlock()
+
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.streams[key], updates)
+		if len(s.streams[key]) == 0 {
+			delete(s.streams, key)
+		}
+	}
+
+	snapshot, err := s.snapshot(key)
+	if err != nil {
+		unsubscribe()
+		return nil, nil, err
+	}
+	s.send(key, *snapshot)
+	return updates, unsubscribe, nil
+}
+
+// snapshot is the current state a stream watches
+func (s *realtimeService) snapshot(key streamKey) (*dto.WalletUpdate, error) {
+	if key.reference != "" {
+		status, err := s.walletService.GetDepositStatus(key.reference)
+		if err != nil {
+			return nil, err
+		}
+		return walletUpdate(UpdateDeposit, status)
+	}
+	balance, err := s.walletService.GetBalance(key.userID, key.mode)
+	if err != nil {
+		return nil, err
+	}
+	return walletUpdate(UpdateBalance, balance)
+}
+
+// HandleEvent works out which streams a domain event concerns and sends
+// them the update. It is subscribed to the event bus.
+func (s *realtimeService) HandleEvent(event models.DomainEvent) error {
+	var data struct {
+		Reference  string     `json:"reference"`
+		Amount     float64    `json:"amount"`
+		Status     string     `json:"status"`
+		Narration  string     `json:"narration"`
+		SenderID   *uuid.UUID `json:"sender_id"`
+		ReceiverID *uuid.UUID `json:"receiver_id"`
+		PayerID    *uuid.UUID `json:"payer_id"`
+		CreatedAt  string     `json:"created_at"`
+	}
+	raw, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return err
+	}
+
+	switch event.Type {
+	case models.DomainTransferCompleted:
+		if data.ReceiverID != nil {
+			transfer := dto.IncomingTransfer{
+				Reference: data.Reference,
+				Amount:    data.Amount,
+				Narration: data.Narration,
+				CreatedAt: data.CreatedAt,
+			}
+			if data.SenderID != nil {
+				transfer.SenderID = data.SenderID.String()
+			}
+			if err := s.publish(*data.ReceiverID, event.Mode, "", UpdateTransfer, transfer); err != nil {
+				return err
+			}
+			if err := s.publishBalance(*data.ReceiverID, event.Mode); err != nil {
+				return err
+			}
+		}
+		if data.SenderID != nil {
+			return s.publishBalance(*data.SenderID, event.Mode)
+		}
+	case models.DomainDepositInitiated, models.DomainDepositCredited, models.DomainDepositHeld, models.DomainDepositFailed:
+		if data.ReceiverID == nil {
+			return nil
+		}
+		status := dto.DepositStatusResponse{
+			Reference: data.Reference,
+			Status:    data.Status,
+			Amount:    data.Amount,
+		}
+		if err := s.publish(*data.ReceiverID, event.Mode, data.Reference, UpdateDeposit, status); err != nil {
+			return err
+		}
+		if event.Type == models.DomainDepositCredited {
+			return s.publishBalance(*data.ReceiverID, event.Mode)
+		}
+	case models.DomainHoldPlaced, models.DomainHoldReleased:
+		if data.PayerID != nil {
+			return s.publishBalance(*data.PayerID, event.Mode)
+		}
+	}
+	return nil
+}
+
+func (s *realtimeService) publishBalance(userID uuid.UUID, mode string) error {
+	balance, err := s.walletService.GetBalance(userID, mode)
+	if err != nil {
+		return err
+	}
+	return s.publish(userID, mode, "", UpdateBalance, balance)
+}
+
+// publish sends an update to every replica's streams for the user, and for
+// the deposit if reference is set
+func (s *realtimeService) publish(userID uuid.UUID, mode, reference, event string, data interface{}) error {
+	update, err := walletUpdate(event, data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(walletNotification{
+		UserID:    userID,
+		Mode:      mode,
+		Reference: reference,
+		Update:    *update,
+	})
+	if err != nil {
+		return err
+	}
+	return s.db.Exec("SELECT pg_notify(?, ?)", s.config.RealtimeChannel, string(payload)).Error
+}
+
+// Listen passes the updates published by every replica to this replica's
+// streams. It runs until the process exits.
+func (s *realtimeService) Listen() {
+	listener := pq.NewListener(s.config.DatabaseURL, time.Second, time.Minute, func(_ pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Wallet update listener: %v", err)
+		}
+	})
+	if err := listener.Listen(s.config.RealtimeChannel); err != nil {
+		log.Printf("Failed to listen for wallet updates: %v", err)
+		return
+	}
+
+	for {
+		select {
+		case notification := <-listener.Notify:
+			if notification == nil {
+				// The connection dropped and updates may have been missed
+				s.resync()
+				continue
+			}
+			var n walletNotification
+			if err := json.Unmarshal([]byte(notification.Extra), &n); err != nil {
+				log.Printf("Ignoring malformed wallet update: %v", err)
+				continue
+			}
+			s.send(streamKey{userID: n.UserID, mode: n.Mode}, n.Update)
+			if n.Reference != "" {
+				s.send(streamKey{reference: n.Reference}, n.Update)
+			}
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
+
+// resync sends every open stream its current state
+func (s *realtimeService) resync() {
+	s.mu.Lock()
+	keys := make([]streamKey, 0, len(s.streams))
+	for key := range s.streams {
+		keys = append(keys, key)
+	}
+	s.mu.Unlock()
+
+	for _, key := range keys {
+		snapshot, err := s.snapshot(key)
+		if err != nil {
+			continue
+		}
+		s.send(key, *snapshot)
+	}
+}
+
+// send queues an update on this replica's streams for key. Streams too far
+// behind miss it rather than hold up the others.
+func (s *realtimeService) send(key streamKey, update dto.WalletUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for updates := range s.streams[key] {
+		select {
+		case updates <- update:
+		default:
+			log.Printf("Dropped %s update for a slow event stream", update.Event)
+		}
+	}
+}
+
+func walletUpdate(event string, data interface{}) (*dto.WalletUpdate, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.WalletUpdate{Event: event, Data: raw}, nil
+}
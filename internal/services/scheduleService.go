@@ -0,0 +1,389 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"whotterre/argent/internal/config"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+	"whotterre/argent/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	scheduleBatchSize = 100
+	// How long a replica may take to run a claimed schedule before another
+	// replica picks it up
+	scheduleLease   = 5 * time.Minute
+	scheduleRunsMax = 50
+)
+
+// ScheduleService manages scheduled and recurring transfers and runs the
+// ones that are due
+type ScheduleService interface {
+	Create(actor AuditActor, mode string, input dto.CreateScheduleRequest) (*models.ScheduledTransfer, error)
+	List(userID uuid.UUID, mode string) ([]models.ScheduledTransfer, error)
+	Get(userID, id uuid.UUID) (*models.ScheduledTransfer, []models.ScheduledTransferRun, error)
+	Pause(actor AuditActor, id uuid.UUID) error
+	Resume(actor AuditActor, id uuid.UUID) error
+	Cancel(actor AuditActor, id uuid.UUID) error
+	RunDue() error
+}
+
+type scheduleService struct {
+	scheduleRepo  repositories.ScheduleRepository
+	walletService WalletService
+	notifier      Notifier
+	auditService  AuditService
+	db            *gorm.DB
+	config        config.Config
+}
+
+func NewScheduleService(scheduleRepo repositories.ScheduleRepository, walletService WalletService, notifier Notifier, auditService AuditService, db *gorm.DB, cfg config.Config) ScheduleService {
+	return &scheduleService{
+		scheduleRepo:  scheduleRepo,
+		walletService: walletService,
+		notifier:      notifier,
+		auditService:  auditService,
+		db:            db,
+		config:        cfg,
+	}
+}
+
+func (s *scheduleService) Create(actor AuditActor, mode string, input dto.CreateScheduleRequest) (*models.ScheduledTransfer, error) {
+	if input.Amount <= 0 {
+		return nil, customErrors.ErrInvalidAmount
+	}
+	recurrence := strings.TrimSpace(input.Recurrence)
+	if recurrence == "" {
+		recurrence = utils.RecurrenceOnce
+	}
+	if !utils.ValidRecurrence(recurrence) {
+		return nil, customErrors.ErrInvalidRecurrence
+	}
+	onFailure := input.OnInsufficientFunds
+	if onFailure == "" {
+		onFailure = models.ScheduleOnFailureRetry
+	}
+	if onFailure != models.ScheduleOnFailureRetry && onFailure != models.ScheduleOnFailureSkip {
+		return nil, customErrors.ErrInvalidOnFailure
+	}
+	if input.MaxRuns < 0 {
+		return nil, customErrors.ErrInvalidMaxRuns
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	start := now
+	if input.StartAt != nil {
+		start = input.StartAt.UTC().Truncate(time.Second)
+	} else if recurrence == utils.RecurrenceOnce {
+		return nil, customErrors.ErrInvalidScheduleTime
+	}
+
+	schedule := &models.ScheduledTransfer{
+		UserID:     actor.UserID,
+		Mode:       mode,
+		Amount:     input.Amount,
+		Recurrence: recurrence,
+		StartAt:    start,
+		EndAt:      input.EndAt,
+		MaxRuns:    input.MaxRuns,
+		OnFailure:  onFailure,
+		Status:     models.ScheduleActive,
+	}
+	if schedule.EndAt != nil && !schedule.EndAt.After(start) {
+		return nil, customErrors.ErrInvalidScheduleTime
+	}
+	first, ok := s.nextOccurrence(schedule, now.Add(-time.Second))
+	if !ok {
+		return nil, customErrors.ErrInvalidScheduleTime
+	}
+	schedule.NextRunAt = &first
+	schedule.OccurrenceAt = &first
+
+	count, err := s.scheduleRepo.CountActiveSchedules(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= int64(s.config.ScheduleMaxPerUser) {
+		return nil, customErrors.ErrTooManySchedules
+	}
+
+	// Pin the recipient to an account number now, so a handle changing
+	// hands later can't redirect the payments
+	recipient, err := s.walletService.ResolveRecipient(input.Recipient, mode)
+	if err != nil {
+		return nil, err
+	}
+	own, err := s.walletService.GetBalance(actor.UserID, mode)
+	if err != nil {
+		return nil, err
+	}
+	if own.AccountNumber == recipient.AccountNumber {
+		return nil, customErrors.ErrCannotPaySelf
+	}
+	schedule.Recipient = recipient.AccountNumber
+	schedule.RecipientName = recipient.Name
+
+	if err := s.scheduleRepo.CreateSchedule(schedule); err != nil {
+		return nil, err
+	}
+	s.auditService.Record(actor, models.AuditScheduleChanged, "scheduled_transfer", schedule.ID.String(), nil, schedule)
+	return schedule, nil
+}
+
+func (s *scheduleService) List(userID uuid.UUID, mode string) ([]models.ScheduledTransfer, error) {
+	return s.scheduleRepo.GetUserSchedules(userID, mode)
+}
+
+func (s *scheduleService) Get(userID, id uuid.UUID) (*models.ScheduledTransfer, []models.ScheduledTransferRun, error) {
+	schedule, err := s.scheduleRepo.GetUserSchedule(id, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	runs, err := s.scheduleRepo.GetRuns(id, scheduleRunsMax)
+	if err != nil {
+		return nil, nil, err
+	}
+	return schedule, runs, nil
+}
+
+func (s *scheduleService) Pause(actor AuditActor, id uuid.UUID) error {
+	schedule, err := s.scheduleRepo.GetUserSchedule(id, actor.UserID)
+	if err != nil {
+		return err
+	}
+	return s.transition(actor, schedule, []string{models.ScheduleActive}, models.SchedulePaused,
+		map[string]interface{}{"status": models.SchedulePaused}, customErrors.ErrScheduleNotActive)
+}
+
+// Resume restarts a paused schedule from its next occurrence; occurrences
+// missed while paused are not paid. A one-off transfer whose date passed
+// while paused runs straight away.
+func (s *scheduleService) Resume(actor AuditActor, id uuid.UUID) error {
+	schedule, err := s.scheduleRepo.GetUserSchedule(id, actor.UserID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	updates := map[string]interface{}{"status": models.ScheduleActive}
+	if schedule.Recurrence == utils.RecurrenceOnce {
+		runAt := schedule.StartAt
+		if runAt.Before(now) {
+			runAt = now
+		}
+		updates["next_run_at"] = runAt
+		updates["occurrence_at"] = schedule.StartAt
+	} else {
+		next, ok := s.nextOccurrence(schedule, now.Add(-time.Second))
+		if !ok {
+			updates["status"] = models.ScheduleCompleted
+			updates["next_run_at"] = nil
+			updates["occurrence_at"] = nil
+		} else {
+			updates["next_run_at"] = next
+			updates["occurrence_at"] = next
+		}
+	}
+	return s.transition(actor, schedule, []string{models.SchedulePaused}, updates["status"].(string), updates, customErrors.ErrScheduleNotPaused)
+}
+
+func (s *scheduleService) Cancel(actor AuditActor, id uuid.UUID) error {
+	schedule, err := s.scheduleRepo.GetUserSchedule(id, actor.UserID)
+	if err != nil {
+		return err
+	}
+	return s.transition(actor, schedule, []string{models.ScheduleActive, models.SchedulePaused}, models.ScheduleCancelled,
+		map[string]interface{}{"status": models.ScheduleCancelled, "next_run_at": nil}, customErrors.ErrScheduleEnded)
+}
+
+// transition applies a user's status change, failing with notAllowed, or
+// ErrScheduleEnded for schedules that are over, when the schedule isn't in
+// one of the from statuses
+func (s *scheduleService) transition(actor AuditActor, schedule *models.ScheduledTransfer, from []string, status string, updates map[string]interface{}, notAllowed error) error {
+	changed, err := s.scheduleRepo.TransitionSchedule(schedule.ID, from, updates)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		if schedule.Status == models.ScheduleCancelled || schedule.Status == models.ScheduleCompleted {
+			return customErrors.ErrScheduleEnded
+		}
+		return notAllowed
+	}
+	s.auditService.Record(actor, models.AuditScheduleChanged, "scheduled_transfer", schedule.ID.String(),
+		map[string]string{"status": schedule.Status}, map[string]string{"status": status})
+	return nil
+}
+
+// RunDue runs every schedule that is due. Each is claimed first, so several
+// replicas can run this at once without paying an occurrence twice.
+func (s *scheduleService) RunDue() error {
+	schedules, err := s.scheduleRepo.GetDueSchedules(time.Now().UTC(), scheduleBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range schedules {
+		s.runSchedule(&schedules[i])
+	}
+	return nil
+}
+
+func (s *scheduleService) runSchedule(schedule *models.ScheduledTransfer) {
+	if schedule.NextRunAt == nil || schedule.OccurrenceAt == nil {
+		return
+	}
+	now := time.Now().UTC()
+	claimed, err := s.scheduleRepo.ClaimSchedule(schedule.ID, *schedule.NextRunAt, now.Add(scheduleLease))
+	if err != nil || !claimed {
+		return
+	}
+
+	occurrence := *schedule.OccurrenceAt
+	run, err := s.scheduleRepo.GetOrCreateRun(schedule.ID, occurrence)
+	if err != nil {
+		return // tried again when the lease runs out
+	}
+	if run.Status != models.ScheduleRunPending {
+		// Finished by a replica that stopped before moving the schedule on
+		s.advance(schedule, occurrence, run.Error)
+		return
+	}
+
+	// The reference is fixed per occurrence, so if this occurrence was
+	// already paid by an attempt that died before recording it, Transfer
+	// returns that payment rather than paying again. A successful run is
+	// recorded, with its event, in the transfer's transaction.
+	reference := fmt.Sprintf("sched_%s_%d", schedule.ID, occurrence.Unix())
+	run.Attempts++
+	transaction, err := s.walletService.TransferWith(schedule.UserID, schedule.Recipient, schedule.Amount, schedule.Mode, reference, dto.TransferDetails{}, func(tx *gorm.DB, transaction *models.Transaction) error {
+		succeeded := *run
+		succeeded.Status = models.ScheduleRunSuccess
+		succeeded.TransactionID = &transaction.ID
+		succeeded.Error = ""
+		return saveRun(tx, schedule, &succeeded, reference)
+	})
+	saved := err == nil
+	if errors.Is(err, customErrors.ErrDuplicateReference) {
+		err = nil
+	}
+
+	retryAt := now.Add(s.config.ScheduleRetryInterval)
+	switch {
+	case err == nil:
+		run.Status = models.ScheduleRunSuccess
+		run.TransactionID = &transaction.ID
+		run.Error = ""
+	case errors.Is(err, customErrors.ErrInsufficientBalance):
+		run.Status = models.ScheduleRunSkipped
+		run.Error = err.Error()
+		// Retry unless out of retries or the retry would run into the next
+		// occurrence
+		next, ok := s.nextOccurrence(schedule, occurrence)
+		if schedule.OnFailure == models.ScheduleOnFailureRetry && run.Attempts <= s.config.ScheduleMaxRetries && (!ok || retryAt.Before(next)) {
+			run.Status = models.ScheduleRunPending
+		}
+	default:
+		run.Status = models.ScheduleRunFailed
+		run.Error = err.Error()
+	}
+	if !saved {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			return saveRun(tx, schedule, run, reference)
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	s.notifier.Notify(schedule.UserID, "scheduled_transfer."+run.Status, scheduleRunEventData(schedule, run, reference))
+	if run.Status == models.ScheduleRunSuccess {
+		s.auditService.Record(AuditActor{UserID: schedule.UserID, AuthMethod: models.AuthMethodSystem}, models.AuditScheduledRun, "transaction", reference, nil, map[string]interface{}{
+			"amount":      transaction.Amount,
+			"fee":         transaction.Fee,
+			"receiver_id": transaction.ReceiverID,
+			"mode":        transaction.Mode,
+			"schedule_id": schedule.ID,
+		})
+	}
+
+	if run.Status == models.ScheduleRunPending {
+		s.scheduleRepo.TransitionSchedule(schedule.ID, []string{models.ScheduleActive}, map[string]interface{}{
+			"next_run_at": retryAt,
+			"last_run_at": now,
+			"last_error":  run.Error,
+		})
+		return
+	}
+	s.advance(schedule, occurrence, run.Error)
+}
+
+// saveRun saves an attempt at an occurrence and records its event for the
+// schedule's owner, in the caller's transaction
+func saveRun(tx *gorm.DB, schedule *models.ScheduledTransfer, run *models.ScheduledTransferRun, reference string) error {
+	if err := repositories.NewScheduleRepository(tx).SaveRun(run); err != nil {
+		return err
+	}
+	return recordEvent(repositories.NewOutboxRepository(tx), schedule.UserID, schedule.Mode, "scheduled_transfer."+run.Status, scheduleRunEventData(schedule, run, reference))
+}
+
+func scheduleRunEventData(schedule *models.ScheduledTransfer, run *models.ScheduledTransferRun, reference string) map[string]interface{} {
+	return map[string]interface{}{
+		"mode":           schedule.Mode,
+		"schedule_id":    schedule.ID,
+		"occurrence_at":  run.OccurrenceAt,
+		"amount":         schedule.Amount,
+		"recipient":      schedule.Recipient,
+		"recipient_name": schedule.RecipientName,
+		"reference":      reference,
+		"attempt":        run.Attempts,
+		"error":          run.Error,
+	}
+}
+
+// advance records the finished occurrence and moves the schedule to its
+// next one, or completes it. Occurrences missed while the worker wasn't
+// running are skipped rather than paid in a burst.
+func (s *scheduleService) advance(schedule *models.ScheduledTransfer, occurrence time.Time, lastError string) {
+	now := time.Now().UTC()
+	paid, err := s.scheduleRepo.CountSuccessfulRuns(schedule.ID)
+	if err != nil {
+		return
+	}
+	if err := s.scheduleRepo.UpdateSchedule(schedule.ID, map[string]interface{}{
+		"run_count":   paid,
+		"last_run_at": now,
+		"last_error":  lastError,
+	}); err != nil {
+		return
+	}
+
+	after := occurrence
+	if now.After(after) {
+		after = now
+	}
+	updates := map[string]interface{}{"status": models.ScheduleCompleted, "next_run_at": nil, "occurrence_at": nil}
+	if next, ok := s.nextOccurrence(schedule, after); ok && (schedule.MaxRuns == 0 || paid < int64(schedule.MaxRuns)) {
+		updates = map[string]interface{}{"next_run_at": next, "occurrence_at": next}
+	}
+	// A schedule paused or cancelled while it ran keeps its new status
+	s.scheduleRepo.TransitionSchedule(schedule.ID, []string{models.ScheduleActive}, updates)
+}
+
+// nextOccurrence returns the schedule's first occurrence after after that
+// is before its end
+func (s *scheduleService) nextOccurrence(schedule *models.ScheduledTransfer, after time.Time) (time.Time, bool) {
+	next, ok := utils.NextOccurrence(schedule.Recurrence, schedule.StartAt, after)
+	if !ok || schedule.EndAt != nil && next.After(*schedule.EndAt) {
+		return time.Time{}, false
+	}
+	return next, true
+}
@@ -0,0 +1,419 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"whotterre/argent/internal/config"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	batchLease      = 10 * time.Minute
+	batchRunLimit   = 10
+	batchRolledBack = "not paid: the batch was rolled back"
+)
+
+// TransferBatchService pays many recipients from one wallet. Batches are
+// checked when submitted and paid in the background.
+type TransferBatchService interface {
+	Submit(actor AuditActor, mode string, input dto.SubmitBatchRequest) (*models.TransferBatch, []dto.BatchItemError, error)
+	List(userID uuid.UUID, mode string, limit, offset int) ([]models.TransferBatch, int64, error)
+	Get(userID uuid.UUID, mode string, id uuid.UUID) (*models.TransferBatch, []models.TransferBatchItem, error)
+	RunQueued() error
+}
+
+type transferBatchService struct {
+	batchRepo       repositories.TransferBatchRepository
+	transactionRepo repositories.TransactionRepository
+	walletRepo      repositories.WalletRepository
+	userRepo        repositories.UserRepository
+	walletService   WalletService
+	feeService      FeeService
+	notifier        Notifier
+	auditService    AuditService
+	db              *gorm.DB
+	config          config.Config
+}
+
+func NewTransferBatchService(batchRepo repositories.TransferBatchRepository, transactionRepo repositories.TransactionRepository, walletRepo repositories.WalletRepository, userRepo repositories.UserRepository, walletService WalletService, feeService FeeService, notifier Notifier, auditService AuditService, db *gorm.DB, cfg config.Config) TransferBatchService {
+	return &transferBatchService{
+		batchRepo:       batchRepo,
+		transactionRepo: transactionRepo,
+		walletRepo:      walletRepo,
+		userRepo:        userRepo,
+		walletService:   walletService,
+		feeService:      feeService,
+		notifier:        notifier,
+		auditService:    auditService,
+		db:              db,
+		config:          cfg,
+	}
+}
+
+// Submit checks every item and queues the batch. Items that can't be paid
+// are all returned, by line, with ErrInvalidBatchItems and nothing is
+// queued. An all-or-nothing batch must also be covered by the balance now.
+func (s *transferBatchService) Submit(actor AuditActor, mode string, input dto.SubmitBatchRequest) (*models.TransferBatch, []dto.BatchItemError, error) {
+	policy := input.Policy
+	if policy == "" {
+		policy = models.BatchBestEffort
+	}
+	if policy != models.BatchBestEffort && policy != models.BatchAllOrNothing {
+		return nil, nil, customErrors.ErrInvalidBatchPolicy
+	}
+	if len(input.Items) == 0 {
+		return nil, nil, customErrors.ErrEmptyBatch
+	}
+	if len(input.Items) > s.config.BatchMaxItems {
+		return nil, nil, fmt.Errorf("%w: at most %d", customErrors.ErrBatchTooLarge, s.config.BatchMaxItems)
+	}
+
+	sender, err := s.userRepo.GetUserById(actor.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	senderWallet, err := s.walletRepo.GetWalletByUserID(sender.ID, mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !canDebit(sender, senderWallet) {
+		return nil, nil, customErrors.ErrDebitsBlocked
+	}
+
+	batch := &models.TransferBatch{
+		ID:        uuid.New(),
+		UserID:    actor.UserID,
+		Mode:      mode,
+		Policy:    policy,
+		Status:    models.BatchQueued,
+		ItemCount: len(input.Items),
+	}
+	items := make([]models.TransferBatchItem, 0, len(input.Items))
+	var itemErrors []dto.BatchItemError
+	var fees float64
+	for i, item := range input.Items {
+		line := i + 1
+		if item.Amount <= 0 {
+			itemErrors = append(itemErrors, dto.BatchItemError{Line: line, Error: customErrors.ErrInvalidAmount.Error()})
+			continue
+		}
+		details, err := checkTransferDetails(dto.TransferDetails{Narration: item.Narration})
+		if err != nil {
+			itemErrors = append(itemErrors, dto.BatchItemError{Line: line, Error: err.Error()})
+			continue
+		}
+		recipient, err := s.walletService.ResolveRecipient(item.Recipient, mode)
+		if err != nil {
+			if !errors.Is(err, customErrors.ErrRecipientNotFound) {
+				return nil, nil, err
+			}
+			itemErrors = append(itemErrors, dto.BatchItemError{Line: line, Error: err.Error()})
+			continue
+		}
+		if err := s.checkPayee(sender, recipient.AccountNumber); err != nil {
+			if !isTransferRefusal(err) {
+				return nil, nil, err
+			}
+			itemErrors = append(itemErrors, dto.BatchItemError{Line: line, Error: err.Error()})
+			continue
+		}
+		fee, err := s.feeService.CalculateFee(sender, "transfer", item.Amount)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		batch.TotalAmount += item.Amount
+		fees += fee
+		items = append(items, models.TransferBatchItem{
+			Line:          line,
+			Recipient:     item.Recipient,
+			AccountNumber: recipient.AccountNumber,
+			RecipientName: recipient.Name,
+			Amount:        item.Amount,
+			Fee:           fee,
+			Narration:     details.Narration,
+			Reference:     fmt.Sprintf("batch_%s_%d", batch.ID, line),
+			Status:        models.BatchItemPending,
+		})
+	}
+
+	if len(itemErrors) > 0 {
+		return nil, itemErrors, customErrors.ErrInvalidBatchItems
+	}
+	if policy == models.BatchAllOrNothing && senderWallet.Balance < batch.TotalAmount+fees {
+		return nil, nil, customErrors.ErrInsufficientBalance
+	}
+
+	if err := s.batchRepo.CreateBatch(batch, items); err != nil {
+		return nil, nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditTransferBatch, "transfer_batch", batch.ID.String(), nil, batch)
+	return batch, nil, nil
+}
+
+func (s *transferBatchService) List(userID uuid.UUID, mode string, limit, offset int) ([]models.TransferBatch, int64, error) {
+	return s.batchRepo.ListUserBatches(userID, mode, limit, offset)
+}
+
+// Get returns one of the user's batches with its items
+func (s *transferBatchService) Get(userID uuid.UUID, mode string, id uuid.UUID) (*models.TransferBatch, []models.TransferBatchItem, error) {
+	batch, err := s.batchRepo.GetUserBatch(id, userID, mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, err := s.batchRepo.GetBatchItems(batch.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return batch, items, nil
+}
+
+// RunQueued pays queued batches, and picks up batches left half paid by a
+// replica that stopped. Each batch is leased so only one replica pays it.
+func (s *transferBatchService) RunQueued() error {
+	now := time.Now().UTC()
+	batches, err := s.batchRepo.GetRunnableBatches(now, batchRunLimit)
+	if err != nil {
+		return err
+	}
+	for i := range batches {
+		claimed, err := s.batchRepo.ClaimBatch(batches[i].ID, now, now.Add(batchLease))
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		if err := s.run(&batches[i]); err != nil {
+			// The lease runs out and the batch is retried; item references
+			// keep anything already paid from being paid twice
+			log.Printf("Failed to run transfer batch %s: %v", batches[i].ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *transferBatchService) run(batch *models.TransferBatch) error {
+	items, err := s.batchRepo.GetBatchItems(batch.ID)
+	if err != nil {
+		return err
+	}
+
+	var failure string
+	if batch.Policy == models.BatchAllOrNothing {
+		failure, err = s.payAll(batch, items)
+	} else {
+		err = s.payEach(batch, items)
+	}
+	if err != nil {
+		return err
+	}
+
+	var succeeded, failed int
+	for _, item := range items {
+		if item.Status == models.BatchItemSuccess {
+			succeeded++
+		} else {
+			failed++
+		}
+	}
+	status := models.BatchCompleted
+	switch {
+	case succeeded == 0:
+		status = models.BatchFailed
+	case failed > 0:
+		status = models.BatchPartial
+	}
+
+	before := *batch
+	now := time.Now().UTC()
+	batch.Status = status
+	batch.SucceededCount = succeeded
+	batch.FailedCount = failed
+	batch.Error = failure
+	batch.CompletedAt = &now
+	batch.LeaseUntil = nil
+	data := map[string]interface{}{
+		"mode":            batch.Mode,
+		"batch_id":        batch.ID,
+		"policy":          batch.Policy,
+		"item_count":      batch.ItemCount,
+		"total_amount":    batch.TotalAmount,
+		"succeeded_count": succeeded,
+		"failed_count":    failed,
+		"status":          status,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewTransferBatchRepository(tx).UpdateBatch(batch.ID, map[string]interface{}{
+			"status":          status,
+			"succeeded_count": succeeded,
+			"failed_count":    failed,
+			"error":           failure,
+			"completed_at":    now,
+			"lease_until":     nil,
+		}); err != nil {
+			return err
+		}
+		return recordEvent(repositories.NewOutboxRepository(tx), batch.UserID, batch.Mode, "transfer_batch."+status, data)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.auditService.Record(SystemActor, models.AuditTransferBatch, "transfer_batch", batch.ID.String(), before, batch)
+	s.notifier.Notify(batch.UserID, "transfer_batch."+status, data)
+	return nil
+}
+
+// payEach pays the items one by one, recording each outcome as it goes.
+// An item whose reference was already used was paid by an earlier run.
+func (s *transferBatchService) payEach(batch *models.TransferBatch, items []models.TransferBatchItem) error {
+	for i := range items {
+		item := &items[i]
+		if item.Status != models.BatchItemPending {
+			continue
+		}
+
+		transaction, err := s.walletService.Transfer(batch.UserID, item.AccountNumber, item.Amount, batch.Mode, item.Reference, dto.TransferDetails{Narration: item.Narration})
+		if err != nil && !errors.Is(err, customErrors.ErrDuplicateReference) {
+			if !isTransferRefusal(err) {
+				return err
+			}
+			item.Status = models.BatchItemFailed
+			item.Error = err.Error()
+			if err := s.batchRepo.UpdateItem(item.ID, map[string]interface{}{
+				"status": item.Status,
+				"error":  item.Error,
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.paid(item, transaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// payAll pays every item in one database transaction. When it fails nothing
+// was paid, and the failure is returned to be recorded on the batch.
+func (s *transferBatchService) payAll(batch *models.TransferBatch, items []models.TransferBatchItem) (string, error) {
+	// A run that committed and stopped before recording it has already
+	// paid every item
+	if _, err := s.transactionRepo.GetTransactionByReference(items[0].Reference); err == nil {
+		for i := range items {
+			transaction, err := s.transactionRepo.GetTransactionByReference(items[i].Reference)
+			if err != nil {
+				return "", err
+			}
+			if err := s.paid(&items[i], transaction); err != nil {
+				return "", err
+			}
+		}
+		return "", nil
+	}
+
+	transfers := make([]dto.BatchTransfer, len(items))
+	for i, item := range items {
+		transfers[i] = dto.BatchTransfer{
+			Recipient: item.AccountNumber,
+			Amount:    item.Amount,
+			Reference: item.Reference,
+			Narration: item.Narration,
+		}
+	}
+
+	transactions, err := s.walletService.TransferAll(batch.UserID, batch.Mode, transfers)
+	if err == nil {
+		for i := range items {
+			if err := s.paid(&items[i], &transactions[i]); err != nil {
+				return "", err
+			}
+		}
+		return "", nil
+	}
+
+	if !isTransferRefusal(err) {
+		return "", err
+	}
+
+	// Blame the item that failed, when it was one item
+	var transferErr *TransferError
+	failing := -1
+	failure := err.Error()
+	if errors.As(err, &transferErr) {
+		failing = transferErr.Index
+		failure = fmt.Sprintf("line %d: %s", items[failing].Line, transferErr.Err)
+	}
+	for i := range items {
+		item := &items[i]
+		item.Status = models.BatchItemFailed
+		item.Error = batchRolledBack
+		if i == failing {
+			item.Error = transferErr.Err.Error()
+		}
+		if err := s.batchRepo.UpdateItem(item.ID, map[string]interface{}{
+			"status": item.Status,
+			"error":  item.Error,
+		}); err != nil {
+			return "", err
+		}
+	}
+	return failure, nil
+}
+
+// checkPayee refuses a payee the sender can't pay: themselves, or an account
+// that may not receive
+func (s *transferBatchService) checkPayee(sender *models.User, accountNumber string) error {
+	payeeWallet, err := s.walletRepo.GetWalletByAccountNumber(accountNumber)
+	if err != nil {
+		return err
+	}
+	if payeeWallet.UserID == sender.ID {
+		return customErrors.ErrCannotPaySelf
+	}
+	payee, err := s.userRepo.GetUserById(payeeWallet.UserID)
+	if err != nil {
+		return err
+	}
+	if !canCredit(payee, payeeWallet) {
+		return customErrors.ErrRecipientCannotReceive
+	}
+	return nil
+}
+
+// paid records the transaction that paid an item
+func (s *transferBatchService) paid(item *models.TransferBatchItem, transaction *models.Transaction) error {
+	item.Status = models.BatchItemSuccess
+	item.Error = ""
+	item.Fee = transaction.Fee
+	item.TransactionID = &transaction.ID
+	return s.batchRepo.UpdateItem(item.ID, map[string]interface{}{
+		"status":         item.Status,
+		"error":          "",
+		"fee":            item.Fee,
+		"transaction_id": transaction.ID,
+	})
+}
+
+// isTransferRefusal reports whether a transfer was refused for a reason
+// retrying won't fix, as opposed to failing on the way
+func isTransferRefusal(err error) bool {
+	return errors.Is(err, customErrors.ErrInvalidAmount) ||
+		errors.Is(err, customErrors.ErrDebitsBlocked) ||
+		errors.Is(err, customErrors.ErrInsufficientBalance) ||
+		errors.Is(err, customErrors.ErrCannotPaySelf) ||
+		errors.Is(err, customErrors.ErrRecipientNotFound) ||
+		errors.Is(err, customErrors.ErrRecipientCannotReceive)
+}
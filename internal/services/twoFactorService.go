@@ -0,0 +1,337 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"log"
+	"strings"
+	"time"
+
+	"whotterre/argent/internal/config"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+	"whotterre/argent/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer             = "Argent"
+	recoveryCodeCount      = 10
+	maxTwoFactorAttempts   = 5
+	twoFactorLockoutPeriod = 15 * time.Minute
+
+	// typ claim that tells step-up tokens apart from access tokens
+	stepUpTokenType = "step_up"
+)
+
+// TwoFactorService manages TOTP enrollment and recovery codes, and issues the
+// short-lived step-up tokens sensitive actions require.
+type TwoFactorService interface {
+	Status(userID uuid.UUID) (*dto.TwoFactorStatusResponse, error)
+	Enroll(userID uuid.UUID) (*dto.TOTPEnrollResponse, error)
+	Confirm(actor AuditActor, code string) ([]string, error)
+	Disable(actor AuditActor, code string) error
+	RegenerateRecoveryCodes(userID uuid.UUID, code string) ([]string, error)
+	StepUp(userID uuid.UUID, code string) (*dto.StepUpResponse, error)
+	VerifyStepUp(userID uuid.UUID, stepUpToken string) error
+	TransferNeedsStepUp(amount float64) bool
+}
+
+type twoFactorService struct {
+	userRepo      repositories.UserRepository
+	twoFactorRepo repositories.TwoFactorRepository
+	keyService    JWTKeyService
+	auditService  AuditService
+	config        config.Config
+}
+
+func NewTwoFactorService(userRepo repositories.UserRepository, twoFactorRepo repositories.TwoFactorRepository, keyService JWTKeyService, auditService AuditService, cfg config.Config) TwoFactorService {
+	return &twoFactorService{
+		userRepo:      userRepo,
+		twoFactorRepo: twoFactorRepo,
+		keyService:    keyService,
+		auditService:  auditService,
+		config:        cfg,
+	}
+}
+
+func (s *twoFactorService) Status(userID uuid.UUID) (*dto.TwoFactorStatusResponse, error) {
+	user, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &dto.TwoFactorStatusResponse{Enabled: user.TOTPEnabledAt != nil}
+	if status.Enabled {
+		status.RecoveryCodesRemaining, err = s.twoFactorRepo.CountUnusedRecoveryCodes(userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// Enroll generates a new TOTP secret. It takes effect once Confirm sees a
+// valid code from it, so an abandoned enrollment changes nothing.
+func (s *twoFactorService) Enroll(userID uuid.UUID) (*dto.TOTPEnrollResponse, error) {
+	if s.config.SecretsEncryptionKey == "" {
+		return nil, customErrors.ErrTwoFactorUnavailable
+	}
+
+	user, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, customErrors.ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := utils.EncryptSecret(s.config.SecretsEncryptionKey, secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.SetPendingTOTPSecret(userID, encrypted); err != nil {
+		return nil, err
+	}
+
+	return &dto.TOTPEnrollResponse{
+		Secret:     secret,
+		OTPAuthURL: utils.TOTPURI(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// Confirm enables two-factor authentication with the first code from the
+// pending secret and returns the recovery codes. They are only shown once.
+func (s *twoFactorService) Confirm(actor AuditActor, code string) ([]string, error) {
+	userID := actor.UserID
+	user, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, customErrors.ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == nil {
+		return nil, customErrors.ErrTwoFactorNotPending
+	}
+	if err := s.checkLock(user); err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.DecryptSecret(s.config.SecretsEncryptionKey, *user.TOTPSecret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := utils.ValidateTOTP(secret, normalizeCode(code), time.Now())
+	if !ok {
+		return nil, s.recordFailure(user)
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.EnableTOTP(userID, step, hashes); err != nil {
+		return nil, err
+	}
+	log.Printf("SECURITY: two-factor authentication enabled for user %s", userID)
+	s.auditService.Record(actor, models.Audit2FAEnabled, "user", userID.String(),
+		map[string]bool{"two_factor": false}, map[string]bool{"two_factor": true})
+	return codes, nil
+}
+
+func (s *twoFactorService) Disable(actor AuditActor, code string) error {
+	userID := actor.UserID
+	user, err := s.verifyCode(userID, code)
+	if err != nil {
+		return err
+	}
+	if err := s.twoFactorRepo.DisableTOTP(user.ID); err != nil {
+		return err
+	}
+	log.Printf("SECURITY: two-factor authentication disabled for user %s", userID)
+	s.auditService.Record(actor, models.Audit2FADisabled, "user", userID.String(),
+		map[string]bool{"two_factor": true}, map[string]bool{"two_factor": false})
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code with a fresh set
+func (s *twoFactorService) RegenerateRecoveryCodes(userID uuid.UUID, code string) ([]string, error) {
+	if _, err := s.verifyCode(userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	log.Printf("SECURITY: recovery codes regenerated for user %s", userID)
+	return codes, nil
+}
+
+// StepUp exchanges a second factor for a short-lived step-up token, signed
+// like access tokens but only accepted by step-up checks
+func (s *twoFactorService) StepUp(userID uuid.UUID, code string) (*dto.StepUpResponse, error) {
+	if _, err := s.verifyCode(userID, code); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	token, err := s.keyService.Sign(jwt.MapClaims{
+		"iss": s.config.BaseURL,
+		"sub": userID.String(),
+		"typ": stepUpTokenType,
+		"amr": []string{"otp"},
+		"jti": uuid.NewString(),
+		"iat": now.Unix(),
+		"exp": now.Add(s.config.StepUpTTL).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.StepUpResponse{
+		StepUpToken: token,
+		ExpiresIn:   int64(s.config.StepUpTTL.Seconds()),
+	}, nil
+}
+
+// VerifyStepUp checks the step-up token for a sensitive action. Users without
+// two-factor authentication pass unless TWO_FACTOR_REQUIRED is set.
+func (s *twoFactorService) VerifyStepUp(userID uuid.UUID, stepUpToken string) error {
+	user, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return err
+	}
+
+	if stepUpToken == "" {
+		if user.TOTPEnabledAt != nil {
+			return customErrors.ErrStepUpRequired
+		}
+		if s.config.TwoFactorRequired {
+			return customErrors.ErrTwoFactorNotEnrolled
+		}
+		return nil
+	}
+
+	token, err := jwt.Parse(stepUpToken, s.keyService.Keyfunc,
+		jwt.WithValidMethods(s.keyService.ValidMethods()),
+		jwt.WithIssuer(s.config.BaseURL),
+		jwt.WithSubject(userID.String()),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || !token.Valid {
+		return customErrors.ErrInvalidStepUpToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != stepUpTokenType {
+		return customErrors.ErrInvalidStepUpToken
+	}
+
+	// Logging out everywhere also ends step-up sessions
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return customErrors.ErrInvalidStepUpToken
+	}
+	if user.TokensRevokedAt != nil && issuedAt.Time.Before(user.TokensRevokedAt.Truncate(time.Second)) {
+		return customErrors.ErrInvalidStepUpToken
+	}
+	return nil
+}
+
+func (s *twoFactorService) TransferNeedsStepUp(amount float64) bool {
+	return amount > s.config.StepUpTransferThreshold
+}
+
+// verifyCode accepts a current TOTP code or an unused recovery code from a
+// user with two-factor authentication enabled. Each code works once.
+func (s *twoFactorService) verifyCode(userID uuid.UUID, code string) (*models.User, error) {
+	user, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt == nil || user.TOTPSecret == nil {
+		return nil, customErrors.ErrTwoFactorNotEnabled
+	}
+	if err := s.checkLock(user); err != nil {
+		return nil, err
+	}
+
+	code = normalizeCode(code)
+	if len(code) == 6 {
+		secret, err := utils.DecryptSecret(s.config.SecretsEncryptionKey, *user.TOTPSecret)
+		if err != nil {
+			return nil, err
+		}
+		if step, ok := utils.ValidateTOTP(secret, code, time.Now()); ok {
+			fresh, err := s.twoFactorRepo.UseTOTPStep(userID, step)
+			if err != nil {
+				return nil, err
+			}
+			if fresh {
+				return user, nil
+			}
+		}
+		return nil, s.recordFailure(user)
+	}
+
+	used, err := s.twoFactorRepo.UseRecoveryCode(userID, hashToken(code))
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, s.recordFailure(user)
+	}
+	log.Printf("SECURITY: recovery code used by user %s", userID)
+	return user, nil
+}
+
+func (s *twoFactorService) checkLock(user *models.User) error {
+	if user.TOTPLockedUntil != nil && time.Now().Before(*user.TOTPLockedUntil) {
+		return customErrors.ErrTwoFactorLocked
+	}
+	return nil
+}
+
+func (s *twoFactorService) recordFailure(user *models.User) error {
+	log.Printf("SECURITY: invalid two-factor code for user %s", user.ID)
+	if err := s.twoFactorRepo.RecordTOTPFailure(user.ID, maxTwoFactorAttempts, time.Now().Add(twoFactorLockoutPeriod)); err != nil {
+		return err
+	}
+	return customErrors.ErrInvalidTwoFactorCode
+}
+
+// generateRecoveryCodes returns codes like "k3j9d-x2m4q" and their hashes
+func generateRecoveryCodes() ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(b))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeCode strips the separators users type into codes, and lowercases
+// recovery codes
+func normalizeCode(code string) string {
+	code = strings.NewReplacer(" ", "", "-", "").Replace(code)
+	return strings.ToLower(code)
+}
@@ -1,16 +1,14 @@
 package services
 
 import (
-	"crypto/hmac"
-	"crypto/sha512"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"io"
 	"log"
-	"net/http"
+	"math"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 	"whotterre/argent/internal/config"
 	"whotterre/argent/internal/customErrors"
 	"whotterre/argent/internal/dto"
@@ -23,35 +21,61 @@ import (
 )
 
 type WalletService interface {
-	DepositWallet(input dto.DepositWalletRequest, userID uuid.UUID) (*dto.DepositWalletResponse, error)
-	GetBalance(userID uuid.UUID) (float64, error)
-	Transfer(userID uuid.UUID, receiverWalletID string, amount float64) error
-	GetTransactions(userID uuid.UUID) ([]models.Transaction, error)
-	ProcessWebhook(payload []byte, signature string) error
+	DepositWallet(input dto.DepositWalletRequest, userID uuid.UUID, mode string) (*dto.DepositWalletResponse, error)
+	CollectPayment(receiverID uuid.UUID, payerEmail string, amount float64, mode, reference string) (*dto.DepositWalletResponse, error)
+	OnDepositPaid(listener func(transaction *models.Transaction))
+	GetBalance(userID uuid.UUID, mode string) (*dto.BalanceResponse, error)
+	Transfer(userID uuid.UUID, recipient string, amount float64, mode, reference string, details dto.TransferDetails) (*models.Transaction, error)
+	TransferWith(userID uuid.UUID, recipient string, amount float64, mode, reference string, details dto.TransferDetails, within TransferHook) (*models.Transaction, error)
+	TransferAll(userID uuid.UUID, mode string, transfers []dto.BatchTransfer) ([]models.Transaction, error)
+	ResolveRecipient(recipient, mode string) (*dto.ResolveRecipientResponse, error)
+	SetHandle(actor AuditActor, handle string) (string, error)
+	ClearHandle(actor AuditActor) error
+	GetTransactions(userID uuid.UUID, mode, clientReference string) ([]models.Transaction, error)
+	ProcessWebhook(payload []byte, signature string) (*models.WebhookEvent, error)
 	GetDepositStatus(reference string) (map[string]interface{}, error)
+	ReleaseHeldDeposits(userID uuid.UUID)
 }
 
 type walletService struct {
 	walletRepo      repositories.WalletRepository
 	transactionRepo repositories.TransactionRepository
 	userRepo        repositories.UserRepository
-	paystackSecret  string
+	webhookRepo     repositories.WebhookEventRepository
+	auditService    AuditService
+	feeService      FeeService
+	providers       map[string]PaymentProvider
 	db              *gorm.DB
 	config          config.Config
+
+	depositListeners []func(transaction *models.Transaction)
 }
 
-func NewWalletService(walletRepo repositories.WalletRepository, transactionRepo repositories.TransactionRepository, userRepo repositories.UserRepository, paystackSecret string, db *gorm.DB, cfg config.Config) WalletService {
+func NewWalletService(walletRepo repositories.WalletRepository, transactionRepo repositories.TransactionRepository, userRepo repositories.UserRepository, webhookRepo repositories.WebhookEventRepository, auditService AuditService, feeService FeeService, paystackSecret string, db *gorm.DB, cfg config.Config) WalletService {
+	// Sandbox deposits go to Paystack's test environment when a test secret
+	// is configured, otherwise to a fake provider that completes instantly
+	testProvider := NewFakeProvider()
+	if cfg.PaystackTestSecret != "" {
+		testProvider = NewPaystackProvider(cfg.PaystackTestSecret)
+	}
+
 	return &walletService{
 		walletRepo:      walletRepo,
 		transactionRepo: transactionRepo,
 		userRepo:        userRepo,
-		paystackSecret:  paystackSecret,
-		db:              db,
-		config:          cfg,
+		webhookRepo:     webhookRepo,
+		auditService:    auditService,
+		feeService:      feeService,
+		providers: map[string]PaymentProvider{
+			models.ModeLive: NewPaystackProvider(paystackSecret),
+			models.ModeTest: testProvider,
+		},
+		db:     db,
+		config: cfg,
 	}
 }
 
-func (s *walletService) DepositWallet(input dto.DepositWalletRequest, userID uuid.UUID) (*dto.DepositWalletResponse, error) {
+func (s *walletService) DepositWallet(input dto.DepositWalletRequest, userID uuid.UUID, mode string) (*dto.DepositWalletResponse, error) {
 	if input.Amount <= 0 {
 		return nil, customErrors.ErrInsufficientFunds
 	}
@@ -61,134 +85,465 @@ func (s *walletService) DepositWallet(input dto.DepositWalletRequest, userID uui
 		return nil, err
 	}
 
-	// Generate reference
-	ref := utils.GenRefString()
+	return s.startDeposit(user, user.Email, input.Amount, mode, utils.GenRefString())
+}
+
+// CollectPayment starts a card payment into the receiver's wallet by someone
+// without a wallet. It is a deposit in every other respect, so the same fee
+// applies and it completes through the Paystack webhook.
+func (s *walletService) CollectPayment(receiverID uuid.UUID, payerEmail string, amount float64, mode, reference string) (*dto.DepositWalletResponse, error) {
+	if amount <= 0 {
+		return nil, customErrors.ErrInvalidAmount
+	}
+
+	receiver, err := s.userRepo.GetUserById(receiverID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.startDeposit(receiver, payerEmail, amount, mode, reference)
+}
+
+// OnDepositPaid registers a listener called once a deposit's payment is
+// confirmed, whether it was credited or held. It may be called more than
+// once for the same deposit, so listeners must be idempotent.
+func (s *walletService) OnDepositPaid(listener func(transaction *models.Transaction)) {
+	s.depositListeners = append(s.depositListeners, listener)
+}
+
+// startDeposit records a pending deposit into user's wallet and sends the
+// payer to checkout
+func (s *walletService) startDeposit(user *models.User, payerEmail string, amount float64, mode, ref string) (*dto.DepositWalletResponse, error) {
+	// Make sure the wallet to be credited exists and may be credited before
+	// sending the payer to checkout
+	wallet, err := s.getWallet(user.ID, mode)
+	if err != nil {
+		return nil, err
+	}
+	if !canCredit(user, wallet) {
+		return nil, customErrors.ErrCreditsBlocked
+	}
+
+	// The fee is fixed now, as quoted, and taken out of the deposit when
+	// it is credited
+	fee, err := s.feeService.CalculateFee(user, "deposit", amount)
+	if err != nil {
+		return nil, err
+	}
+	if fee >= amount {
+		return nil, customErrors.ErrFeeExceedsAmount
+	}
 
 	// Create transaction
 	transaction := &models.Transaction{
-		ReceiverID: userID,
-		Amount:     input.Amount,
+		ReceiverID: user.ID,
+		Amount:     amount,
+		Fee:        fee,
 		Type:       "deposit",
 		Status:     "pending",
 		Reference:  ref,
+		Mode:       mode,
 	}
-	err = s.transactionRepo.CreateTransaction(transaction)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewTransactionRepository(tx).CreateTransaction(transaction); err != nil {
+			return err
+		}
+		return recordDepositEvents(repositories.NewOutboxRepository(tx), transaction, "", models.DomainDepositInitiated, "pending")
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Call Paystack
-	payload := map[string]interface{}{
-		"amount":       int(input.Amount * 100),
-		"email":        user.Email,
-		"reference":    ref,
-		"callback_url": s.config.BaseURL + "/wallet/deposit/callback",
-	}
-	resp, err := s.callPaystack("transaction/initialize", payload)
+	provider := s.providers[mode]
+	authorizationURL, err := provider.InitializeCharge(payerEmail, amount, ref, s.config.BaseURL+"/wallet/deposit/callback")
 	if err != nil {
 		return nil, err
 	}
 
-	data := resp["data"].(map[string]interface{})
+	if provider.AutoCompletes() {
+		if err := s.completeDeposit(transaction); err != nil {
+			return nil, err
+		}
+	}
 
 	return &dto.DepositWalletResponse{
 		Reference:        ref,
-		AuthorizationURL: data["authorization_url"].(string),
+		AuthorizationURL: authorizationURL,
 	}, nil
 }
 
-func (s *walletService) GetBalance(userID uuid.UUID) (float64, error) {
-	wallet, err := s.walletRepo.GetWalletByUserID(userID)
+func (s *walletService) GetBalance(userID uuid.UUID, mode string) (*dto.BalanceResponse, error) {
+	wallet, err := s.getWallet(userID, mode)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return wallet.Balance, nil
+	return &dto.BalanceResponse{
+		Balance:          wallet.Balance,
+		AvailableBalance: wallet.Balance,
+		LedgerBalance:    wallet.LedgerBalance(),
+		HeldBalance:      wallet.HeldBalance,
+		AccountNumber:    wallet.AccountNumber,
+	}, nil
 }
 
-func (s *walletService) Transfer(userID uuid.UUID, receiverWalletID string, amount float64) error {
+// Transfer pays amount plus any fee from the user's wallet to the recipient.
+// An empty reference gets a generated one. A reference that was already
+// used returns that transaction with ErrDuplicateReference, so callers that
+// retry with the same reference pay once. A client reference the sender
+// already used returns that transaction with ErrDuplicateClientReference.
+func (s *walletService) Transfer(userID uuid.UUID, recipient string, amount float64, mode, reference string, details dto.TransferDetails) (*models.Transaction, error) {
+	return s.TransferWith(userID, recipient, amount, mode, reference, details, nil)
+}
+
+// TransferHook runs in a transfer's database transaction once the money has
+// moved. Returning an error rolls the transfer back.
+type TransferHook func(tx *gorm.DB, transaction *models.Transaction) error
+
+// TransferWith is Transfer that also runs within, if set, in the transfer's
+// database transaction, so what it records commits or rolls back with the
+// payment
+func (s *walletService) TransferWith(userID uuid.UUID, recipient string, amount float64, mode, reference string, details dto.TransferDetails, within TransferHook) (*models.Transaction, error) {
+	if amount <= 0 {
+		return nil, customErrors.ErrInvalidAmount
+	}
+	details, err := checkTransferDetails(details)
+	if err != nil {
+		return nil, err
+	}
+	if reference == "" {
+		reference = utils.GenRefString()
+	} else if existing, err := s.transactionRepo.GetTransactionByReference(reference); err == nil {
+		return existing, customErrors.ErrDuplicateReference
+	}
+	var clientReference *string
+	if details.ClientReference != "" {
+		clientReference = &details.ClientReference
+		if existing, err := s.transactionRepo.GetTransactionByClientReference(userID, details.ClientReference); err == nil {
+			return existing, customErrors.ErrDuplicateClientReference
+		}
+	}
+
 	// Get sender wallet
-	senderWallet, err := s.walletRepo.GetWalletByUserID(userID)
+	senderWallet, err := s.getWallet(userID, mode)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	sender, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canDebit(sender, senderWallet) {
+		return nil, customErrors.ErrDebitsBlocked
+	}
+
+	fee, err := s.feeService.CalculateFee(sender, "transfer", amount)
+	if err != nil {
+		return nil, err
 	}
-	if senderWallet.Balance < amount {
-		return errors.New("insufficient balance")
+	if senderWallet.Balance < amount+fee {
+		return nil, customErrors.ErrInsufficientBalance
 	}
 
-	// Parse receiver ID
-	receiverID, err := uuid.Parse(receiverWalletID)
+	// Find the receiver's wallet in the same mode as the sender's
+	receiver, receiverWallet, err := s.resolveRecipient(recipient, mode)
 	if err != nil {
-		return errors.New("invalid receiver wallet ID")
+		return nil, err
 	}
+	receiverID := receiver.ID
 
 	// Prevent self-transfer
 	if userID == receiverID {
-		return errors.New("cannot transfer to yourself")
+		return nil, customErrors.ErrCannotPaySelf
 	}
 
-	// Get receiver wallet
-	receiverWallet, err := s.walletRepo.GetWalletByUserID(receiverID)
+	if !canCredit(receiver, receiverWallet) {
+		return nil, customErrors.ErrRecipientCannotReceive
+	}
+
+	transaction := &models.Transaction{
+		SenderID:        &userID,
+		ReceiverID:      receiverID,
+		Amount:          amount,
+		Fee:             fee,
+		Type:            "transfer",
+		Status:          "success",
+		Reference:       reference,
+		Mode:            mode,
+		Narration:       details.Narration,
+		Metadata:        details.Metadata,
+		ClientReference: clientReference,
+	}
+
+	// Atomic transfer: the debit, both credits and the transaction rows
+	// are written together or not at all
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		walletRepo := repositories.NewWalletRepository(tx)
+		transactionRepo := repositories.NewTransactionRepository(tx)
+
+		// Deduct the amount and fee from sender, unless another payment got
+		// there first
+		debited, err := walletRepo.Debit(senderWallet.ID, amount+fee)
+		if err != nil {
+			return err
+		}
+		if !debited {
+			return customErrors.ErrInsufficientBalance
+		}
+
+		// Add to receiver
+		if err := walletRepo.Credit(receiverWallet.ID, amount); err != nil {
+			return err
+		}
+		if err := transactionRepo.CreateTransaction(transaction); err != nil {
+			return err
+		}
+		if err := recordTransferEvents(repositories.NewOutboxRepository(tx), transaction); err != nil {
+			return err
+		}
+		if err := chargeFee(s.feeService, walletRepo, transactionRepo, transaction, userID); err != nil {
+			return err
+		}
+		if within != nil {
+			return within(tx, transaction)
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		// Lost a race with another transfer using the same reference
+		if existing, lookupErr := s.transactionRepo.GetTransactionByReference(reference); lookupErr == nil {
+			return existing, customErrors.ErrDuplicateReference
+		}
+		if clientReference != nil {
+			if existing, lookupErr := s.transactionRepo.GetTransactionByClientReference(userID, *clientReference); lookupErr == nil {
+				return existing, customErrors.ErrDuplicateClientReference
+			}
+		}
+		return nil, err
 	}
+	return transaction, nil
+}
+
+const (
+	narrationMax       = 140
+	metadataMaxKeys    = 20
+	metadataKeyMax     = 40
+	metadataValueMax   = 500
+	clientReferenceMax = 64
+)
 
-	// Atomic transfer
-	tx := s.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+// checkTransferDetails trims the narration and checks every detail stays
+// within its bounds
+func checkTransferDetails(details dto.TransferDetails) (dto.TransferDetails, error) {
+	details.Narration = strings.TrimSpace(details.Narration)
+	if utf8.RuneCountInString(details.Narration) > narrationMax {
+		return details, customErrors.ErrNarrationTooLong
+	}
+	if len(details.Metadata) > metadataMaxKeys {
+		return details, customErrors.ErrInvalidMetadata
+	}
+	for key, value := range details.Metadata {
+		if key == "" || utf8.RuneCountInString(key) > metadataKeyMax || utf8.RuneCountInString(value) > metadataValueMax {
+			return details, customErrors.ErrInvalidMetadata
 		}
-	}()
+	}
+	if len(details.Metadata) == 0 {
+		details.Metadata = nil
+	}
+	if details.ClientReference != "" && !clientReferencePattern.MatchString(details.ClientReference) {
+		return details, customErrors.ErrInvalidClientReference
+	}
+	return details, nil
+}
+
+var clientReferencePattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,` + strconv.Itoa(clientReferenceMax) + `}$`)
+
+// TransferError reports which of several transfers failed
+type TransferError struct {
+	Index int
+	Err   error
+}
+
+func (e *TransferError) Error() string {
+	return e.Err.Error()
+}
 
-	// Deduct from sender
-	err = s.walletRepo.UpdateBalance(senderWallet.ID, senderWallet.Balance-amount)
+func (e *TransferError) Unwrap() error {
+	return e.Err
+}
+
+// TransferAll makes every transfer from the user's wallet or none of them,
+// in one database transaction. A transfer that can't be made fails the lot
+// with a *TransferError naming it.
+func (s *walletService) TransferAll(userID uuid.UUID, mode string, transfers []dto.BatchTransfer) ([]models.Transaction, error) {
+	senderWallet, err := s.getWallet(userID, mode)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return nil, err
+	}
+	sender, err := s.userRepo.GetUserById(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canDebit(sender, senderWallet) {
+		return nil, customErrors.ErrDebitsBlocked
 	}
 
-	// Add to receiver
-	err = s.walletRepo.UpdateBalance(receiverWallet.ID, receiverWallet.Balance+amount)
+	// Check every transfer before moving any money
+	transactions := make([]models.Transaction, len(transfers))
+	receiverWallets := make([]uuid.UUID, len(transfers))
+	for i, transfer := range transfers {
+		if transfer.Amount <= 0 {
+			return nil, &TransferError{Index: i, Err: customErrors.ErrInvalidAmount}
+		}
+		details, err := checkTransferDetails(dto.TransferDetails{Narration: transfer.Narration})
+		if err != nil {
+			return nil, &TransferError{Index: i, Err: err}
+		}
+		fee, err := s.feeService.CalculateFee(sender, "transfer", transfer.Amount)
+		if err != nil {
+			return nil, err
+		}
+		receiver, receiverWallet, err := s.resolveRecipient(transfer.Recipient, mode)
+		if err != nil {
+			return nil, &TransferError{Index: i, Err: err}
+		}
+		if receiver.ID == userID {
+			return nil, &TransferError{Index: i, Err: customErrors.ErrCannotPaySelf}
+		}
+		if !canCredit(receiver, receiverWallet) {
+			return nil, &TransferError{Index: i, Err: customErrors.ErrRecipientCannotReceive}
+		}
+		reference := transfer.Reference
+		if reference == "" {
+			reference = utils.GenRefString()
+		}
+
+		transactions[i] = models.Transaction{
+			SenderID:   &userID,
+			ReceiverID: receiver.ID,
+			Amount:     transfer.Amount,
+			Fee:        fee,
+			Type:       "transfer",
+			Status:     "success",
+			Reference:  reference,
+			Mode:       mode,
+			Narration:  details.Narration,
+		}
+		receiverWallets[i] = receiverWallet.ID
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		walletRepo := repositories.NewWalletRepository(tx)
+		transactionRepo := repositories.NewTransactionRepository(tx)
+
+		for i := range transactions {
+			transaction := &transactions[i]
+			debited, err := walletRepo.Debit(senderWallet.ID, transaction.Amount+transaction.Fee)
+			if err != nil {
+				return err
+			}
+			if !debited {
+				return &TransferError{Index: i, Err: customErrors.ErrInsufficientBalance}
+			}
+			if err := walletRepo.Credit(receiverWallets[i], transaction.Amount); err != nil {
+				return err
+			}
+			if err := transactionRepo.CreateTransaction(transaction); err != nil {
+				return err
+			}
+			if err := recordTransferEvents(repositories.NewOutboxRepository(tx), transaction); err != nil {
+				return err
+			}
+			if err := chargeFee(s.feeService, walletRepo, transactionRepo, transaction, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		tx.Rollback()
-		return err
+		return nil, err
 	}
+	return transactions, nil
+}
 
-	// Create transaction
-	transaction := &models.Transaction{
-		SenderID:   &userID,
-		ReceiverID: receiverID,
-		Amount:     amount,
-		Type:       "transfer",
-		Status:     "success",
-		Reference:  utils.GenRefString(), // Generate unique reference for transfers
+// chargeFee pays the parent transaction's fee into the revenue wallet in the
+// same mode and records it as its own line in the payer's history. It runs
+// inside the caller's database transaction.
+func chargeFee(feeService FeeService, walletRepo repositories.WalletRepository, transactionRepo repositories.TransactionRepository, parent *models.Transaction, payerID uuid.UUID) error {
+	if parent.Fee <= 0 {
+		return nil
 	}
-	err = s.transactionRepo.CreateTransaction(transaction)
+	revenueID := feeService.RevenueUserID()
+	revenueWallet, err := walletRepo.GetOrCreateWallet(revenueID, parent.Mode)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
-
-	tx.Commit()
-	return nil
+	if err := walletRepo.Credit(revenueWallet.ID, parent.Fee); err != nil {
+		return err
+	}
+	return transactionRepo.CreateTransaction(&models.Transaction{
+		SenderID:   &payerID,
+		ReceiverID: revenueID,
+		Amount:     parent.Fee,
+		Type:       "fee",
+		Status:     "success",
+		Reference:  parent.Reference + "_fee",
+		Mode:       parent.Mode,
+		ParentID:   &parent.ID,
+	})
 }
 
-func (s *walletService) GetTransactions(userID uuid.UUID) ([]models.Transaction, error) {
-	return s.transactionRepo.GetUserTransactions(userID)
+// GetTransactions returns the user's history in a mode, or with a client
+// reference, the transfer they tagged with it
+func (s *walletService) GetTransactions(userID uuid.UUID, mode, clientReference string) ([]models.Transaction, error) {
+	if clientReference == "" {
+		return s.transactionRepo.GetUserTransactions(userID, mode)
+	}
+	transaction, err := s.transactionRepo.GetTransactionByClientReference(userID, clientReference)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && transaction.Mode != mode) {
+		return []models.Transaction{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []models.Transaction{*transaction}, nil
 }
 
-func (s *walletService) ProcessWebhook(payload []byte, signature string) error {
+// ProcessWebhook handles a Paystack webhook and returns the record kept of
+// it, which is nil when the signature doesn't check out
+func (s *walletService) ProcessWebhook(payload []byte, signature string) (*models.WebhookEvent, error) {
 	log.Printf("Processing webhook with signature: %s", signature)
 
-	// Validate signature
-	expectedSignature := hmac.New(sha512.New, []byte(s.paystackSecret))
-	expectedSignature.Write(payload)
-	expectedHex := hex.EncodeToString(expectedSignature.Sum(nil))
-	if !hmac.Equal([]byte(signature), []byte(expectedHex)) {
-		log.Printf("Invalid signature - expected: %s, got: %s", expectedHex, signature)
-		return errors.New("invalid signature")
+	// Validate signature. Paystack signs test-mode events with the test
+	// secret, so the matching provider also tells us which mode this is.
+	mode := ""
+	for _, m := range []string{models.ModeLive, models.ModeTest} {
+		if s.providers[m].VerifySignature(payload, signature) {
+			mode = m
+			break
+		}
+	}
+	if mode == "" {
+		log.Printf("Invalid webhook signature: %s", signature)
+		return nil, errors.New("invalid signature")
+	}
+
+	// Authentic events are kept for support, whatever the outcome
+	record := &models.WebhookEvent{
+		Provider: "paystack",
+		Mode:     mode,
+		Status:   models.WebhookEventProcessed,
+		Payload:  string(payload),
+	}
+	err := s.handleWebhookEvent(payload, mode, record)
+	if err != nil {
+		record.Status = models.WebhookEventFailed
+		record.Error = err.Error()
 	}
+	s.webhookRepo.CreateWebhookEvent(record)
+	return record, err
+}
 
+func (s *walletService) handleWebhookEvent(payload []byte, mode string, record *models.WebhookEvent) error {
 	var data map[string]interface{}
 	if err := json.Unmarshal(payload, &data); err != nil {
 		log.Printf("Failed to unmarshal webhook payload: %v", err)
@@ -200,9 +555,11 @@ func (s *walletService) ProcessWebhook(payload []byte, signature string) error {
 		log.Printf("Invalid event field")
 		return errors.New("invalid event")
 	}
+	record.Event = event
 
 	log.Printf("Webhook event: %s", event)
-	if event != "charge.success" {
+	if event != "charge.success" && event != "charge.failed" {
+		record.Status = models.WebhookEventIgnored
 		return nil // ignore other events
 	}
 
@@ -217,6 +574,7 @@ func (s *walletService) ProcessWebhook(payload []byte, signature string) error {
 		log.Printf("Invalid reference field")
 		return errors.New("invalid reference")
 	}
+	record.Reference = reference
 
 	log.Printf("Processing transaction with reference: %s", reference)
 
@@ -227,73 +585,358 @@ func (s *walletService) ProcessWebhook(payload []byte, signature string) error {
 		return err
 	}
 
+	if transaction.Mode != mode {
+		log.Printf("Webhook mode %s does not match transaction mode %s", mode, transaction.Mode)
+		return errors.New("transaction mode mismatch")
+	}
+
+	if event == "charge.failed" {
+		return s.failDeposit(transaction)
+	}
+	return s.completeDeposit(transaction)
+}
+
+// failDeposit marks a pending deposit whose payment failed. Deposits that
+// were already paid are left alone.
+func (s *walletService) failDeposit(transaction *models.Transaction) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		ok, err := repositories.NewTransactionRepository(tx).TransitionStatus(transaction.ID, []string{"pending"}, "failed")
+		if err != nil || !ok {
+			return err
+		}
+		return recordDepositEvents(repositories.NewOutboxRepository(tx), transaction, models.EventDepositFailed, models.DomainDepositFailed, "failed")
+	})
+}
+
+// recordDepositEvents tells the depositor about a change to their deposit
+// and records the matching domain event
+func recordDepositEvents(outboxRepo repositories.OutboxRepository, transaction *models.Transaction, eventType, domainType, status string) error {
+	data := depositEventData(transaction, status)
+	if eventType != "" {
+		if err := recordEvent(outboxRepo, transaction.ReceiverID, transaction.Mode, eventType, data); err != nil {
+			return err
+		}
+	}
+	data["receiver_id"] = transaction.ReceiverID
+	return recordDomainEvent(outboxRepo, domainType, "transaction", transaction.ID.String(), transaction.Mode, data)
+}
+
+func depositEventData(transaction *models.Transaction, status string) map[string]interface{} {
+	return map[string]interface{}{
+		"transaction_id": transaction.ID,
+		"reference":      transaction.Reference,
+		"amount":         transaction.Amount,
+		"fee":            transaction.Fee,
+		"status":         status,
+	}
+}
+
+// GetDepositStatus returns the status of the deposit with reference. Other
+// transactions are reported as not found.
+func (s *walletService) GetDepositStatus(reference string) (map[string]interface{}, error) {
+	transaction, err := s.transactionRepo.GetTransactionByReference(reference)
+	if err != nil {
+		return nil, err
+	}
+	if transaction.Type != "deposit" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return map[string]interface{}{
+		"reference": reference,
+		"status":    transaction.Status,
+		"amount":    transaction.Amount,
+	}, nil
+}
+
+// completeDeposit marks a pending or held deposit successful and credits the
+// wallet in the deposit's mode. Deposits to accounts that can't be credited
+// are held instead. Already-completed deposits are left untouched.
+func (s *walletService) completeDeposit(transaction *models.Transaction) error {
 	if transaction.Status == "success" {
 		log.Printf("Transaction already processed")
 		return nil
 	}
 
-	// Update status
-	err = s.transactionRepo.UpdateTransactionStatus(transaction.ID, "success")
+	log.Printf("Getting %s wallet for user ID: %s", transaction.Mode, transaction.ReceiverID)
+	wallet, err := s.getWallet(transaction.ReceiverID, transaction.Mode)
 	if err != nil {
-		log.Printf("Failed to update transaction status: %v", err)
+		log.Printf("Failed to get wallet: %v", err)
 		return err
 	}
-
-	// Credit wallet
-	log.Printf("Getting wallet for user ID: %s", transaction.ReceiverID)
-	wallet, err := s.walletRepo.GetWalletByUserID(transaction.ReceiverID)
+	user, err := s.userRepo.GetUserById(transaction.ReceiverID)
 	if err != nil {
-		log.Printf("Failed to get wallet: %v", err)
 		return err
 	}
 
-	log.Printf("Current wallet balance: %.2f, transaction amount: %.2f", wallet.Balance, transaction.Amount)
-	newBalance := wallet.Balance + transaction.Amount
-	log.Printf("Updating balance from %.2f to %.2f", wallet.Balance, newBalance)
+	// The payment went through, so keep the deposit until the account is
+	// unfrozen rather than failing it
+	if !canCredit(user, wallet) {
+		if transaction.Status == models.TransactionHeld {
+			return nil
+		}
+		log.Printf("SECURITY: holding deposit %s for user %s, account can't be credited", transaction.Reference, user.ID)
+		// Only hold a deposit that is still pending, so one a concurrent
+		// webhook has just credited isn't held and credited again on release
+		held := false
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			ok, err := repositories.NewTransactionRepository(tx).TransitionStatus(transaction.ID, []string{"pending"}, models.TransactionHeld)
+			if err != nil || !ok {
+				return err
+			}
+			held = true
+			return recordDepositEvents(repositories.NewOutboxRepository(tx), transaction, models.EventDepositHeld, models.DomainDepositHeld, models.TransactionHeld)
+		})
+		if err != nil {
+			return err
+		}
+		if !held {
+			log.Printf("Deposit %s was already processed, not holding it", transaction.Reference)
+			return nil
+		}
+		s.auditService.Record(SystemActor, models.AuditDepositHeld, "transaction", transaction.Reference,
+			map[string]interface{}{"status": transaction.Status},
+			map[string]interface{}{"status": models.TransactionHeld, "amount": transaction.Amount, "mode": transaction.Mode, "user_id": user.ID, "user_status": user.Status, "wallet_status": wallet.Status})
+		transaction.Status = models.TransactionHeld
+		s.depositPaid(transaction)
+		return nil
+	}
 
-	err = s.walletRepo.UpdateBalance(wallet.ID, newBalance)
+	// Mark the deposit successful and credit it, less its fee, in one go.
+	// Only the first of two concurrent webhooks gets past the status check.
+	fee := math.Min(transaction.Fee, transaction.Amount)
+	credited := roundKobo(transaction.Amount - fee)
+	transaction.Fee = fee
+	applied := false
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		walletRepo := repositories.NewWalletRepository(tx)
+		transactionRepo := repositories.NewTransactionRepository(tx)
+
+		ok, err := transactionRepo.TransitionStatus(transaction.ID, []string{"pending", models.TransactionHeld}, "success")
+		if err != nil || !ok {
+			return err
+		}
+		if err := walletRepo.Credit(wallet.ID, credited); err != nil {
+			return err
+		}
+		if err := chargeFee(s.feeService, walletRepo, transactionRepo, transaction, transaction.ReceiverID); err != nil {
+			return err
+		}
+		if err := recordDepositEvents(repositories.NewOutboxRepository(tx), transaction, models.EventDepositSucceeded, models.DomainDepositCredited, "success"); err != nil {
+			return err
+		}
+		applied = true
+		return nil
+	})
 	if err != nil {
-		log.Printf("Failed to update wallet balance: %v", err)
+		log.Printf("Failed to credit deposit %s: %v", transaction.Reference, err)
 		return err
 	}
+	if !applied {
+		log.Printf("Transaction already processed")
+		transaction.Status = "success"
+		return nil
+	}
 
-	log.Printf("Wallet balance updated successfully to %.2f", newBalance)
+	s.auditService.Record(SystemActor, models.AuditDepositCredited, "transaction", transaction.Reference,
+		map[string]interface{}{"status": transaction.Status, "balance": wallet.Balance},
+		map[string]interface{}{"status": "success", "balance": wallet.Balance + credited, "amount": transaction.Amount, "fee": fee, "mode": transaction.Mode, "user_id": user.ID})
+	transaction.Status = "success"
+	log.Printf("Credited %.2f to wallet %s for deposit %s", credited, wallet.ID, transaction.Reference)
+	s.depositPaid(transaction)
 	return nil
 }
 
-func (s *walletService) GetDepositStatus(reference string) (map[string]interface{}, error) {
-	transaction, err := s.transactionRepo.GetTransactionByReference(reference)
+func (s *walletService) depositPaid(transaction *models.Transaction) {
+	for _, listener := range s.depositListeners {
+		listener(transaction)
+	}
+}
+
+// ReleaseHeldDeposits credits deposits held while the user's account was
+// frozen. Deposits to wallets that still can't be credited stay held.
+func (s *walletService) ReleaseHeldDeposits(userID uuid.UUID) {
+	held, err := s.transactionRepo.GetHeldDeposits(userID)
+	if err != nil {
+		return
+	}
+	for i := range held {
+		if err := s.completeDeposit(&held[i]); err != nil {
+			log.Printf("Failed to release held deposit %s: %v", held[i].Reference, err)
+			continue
+		}
+		if held[i].Status == "success" {
+			log.Printf("Released held deposit %s for user %s", held[i].Reference, userID)
+		}
+	}
+}
+
+// ResolveRecipient shows who a transfer would reach, so the sender can check
+// before paying
+func (s *walletService) ResolveRecipient(recipient, mode string) (*dto.ResolveRecipientResponse, error) {
+	user, wallet, err := s.resolveRecipient(recipient, mode)
 	if err != nil {
 		return nil, err
 	}
 
-	return map[string]interface{}{
-		"reference": reference,
-		"status":    transaction.Status,
-		"amount":    transaction.Amount,
-	}, nil
+	response := &dto.ResolveRecipientResponse{
+		Name:          maskName(user.FirstName, user.LastName),
+		AccountNumber: wallet.AccountNumber,
+	}
+	if user.Handle != nil {
+		response.Handle = "@" + *user.Handle
+	}
+	return response, nil
 }
 
-func (s *walletService) callPaystack(endpoint string, payload map[string]interface{}) (map[string]interface{}, error) {
-	url := "https://api.paystack.co/" + endpoint
-	data, _ := json.Marshal(payload)
-	req, _ := http.NewRequest("POST", url, strings.NewReader(string(data)))
-	req.Header.Set("Authorization", "Bearer "+s.paystackSecret)
-	log.Println(s.paystackSecret)
-	req.Header.Set("Content-Type", "application/json")
+// SetHandle claims a handle for the user, replacing any they had, and
+// returns it as stored
+func (s *walletService) SetHandle(actor AuditActor, handle string) (string, error) {
+	handle = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+	if !validHandle(handle) {
+		return "", customErrors.ErrInvalidHandle
+	}
+	user, err := s.userRepo.GetUserById(actor.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	set, err := s.userRepo.SetHandle(actor.UserID, &handle)
+	if err != nil {
+		return "", err
+	}
+	if !set {
+		return "", customErrors.ErrHandleTaken
+	}
+	s.auditService.Record(actor, models.AuditHandleChanged, "user", actor.UserID.String(),
+		map[string]*string{"handle": user.Handle}, map[string]string{"handle": handle})
+	return handle, nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func (s *walletService) ClearHandle(actor AuditActor) error {
+	user, err := s.userRepo.GetUserById(actor.UserID)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if user.Handle == nil {
+		return nil
+	}
+
+	if _, err := s.userRepo.SetHandle(actor.UserID, nil); err != nil {
+		return err
+	}
+	s.auditService.Record(actor, models.AuditHandleChanged, "user", actor.UserID.String(),
+		map[string]*string{"handle": user.Handle}, map[string]*string{"handle": nil})
+	return nil
+}
+
+// resolveRecipient finds the user and wallet in the given mode that a
+// recipient refers to: a 10-digit account number, an @handle or a verified
+// email. User IDs aren't recipients, so they can't be used to look people up.
+func (s *walletService) resolveRecipient(recipient, mode string) (*models.User, *models.Wallet, error) {
+	recipient = strings.TrimSpace(recipient)
+
+	var user *models.User
+	var err error
+	switch {
+	case utils.IsValidAccountNumber(recipient):
+		wallet, err := s.walletRepo.GetWalletByAccountNumber(recipient)
+		if err != nil || wallet.Mode != mode {
+			return nil, nil, recipientLookupError(err)
+		}
+		user, err = s.userRepo.GetUserById(wallet.UserID)
+		if err != nil {
+			return nil, nil, recipientLookupError(err)
+		}
+		return user, wallet, nil
+	case strings.HasPrefix(recipient, "@"):
+		user, err = s.userRepo.GetUserByHandle(strings.ToLower(recipient[1:]))
+	case strings.Contains(recipient, "@"):
+		user, err = s.userRepo.GetUserByEmail(recipient)
+		// Only a verified address proves who the account belongs to
+		if err == nil && user.EmailVerifiedAt == nil {
+			return nil, nil, customErrors.ErrRecipientNotFound
+		}
+	default:
+		return nil, nil, customErrors.ErrRecipientNotFound
+	}
+	if err != nil {
+		return nil, nil, recipientLookupError(err)
+	}
+
+	wallet, err := s.getWallet(user.ID, mode)
+	if err != nil {
+		return nil, nil, recipientLookupError(err)
+	}
+	return user, wallet, nil
+}
+
+// recipientLookupError hides whether a lookup failed because nothing matched
+func recipientLookupError(err error) error {
+	if err == nil || errors.Is(err, gorm.ErrRecordNotFound) {
+		return customErrors.ErrRecipientNotFound
+	}
+	return err
+}
+
+// reservedHandles could be used to impersonate staff
+var reservedHandles = map[string]bool{
+	"admin": true, "administrator": true, "argent": true, "finance": true, "help": true,
+	"official": true, "paystack": true, "root": true, "security": true, "support": true, "system": true,
+}
+
+// validHandle accepts 3-20 lowercase letters, digits and underscores,
+// starting with a letter
+func validHandle(handle string) bool {
+	if len(handle) < 3 || len(handle) > 20 || reservedHandles[handle] {
+		return false
+	}
+	for i := 0; i < len(handle); i++ {
+		c := handle[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '_'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// maskName shows enough of a name to recognise it: "Adaeze Okafor" becomes
+// "Ad**** O."
+func maskName(firstName, lastName string) string {
+	first := []rune(strings.TrimSpace(firstName))
+	shown := 2
+	if len(first) < 4 {
+		shown = 1
 	}
-	defer resp.Body.Close()
+	masked := ""
+	if len(first) > 0 {
+		masked = string(first[:shown]) + strings.Repeat("*", len(first)-shown)
+	}
+	if last := []rune(strings.TrimSpace(lastName)); len(last) > 0 {
+		masked = strings.TrimSpace(masked + " " + string(last[0]) + ".")
+	}
+	return masked
+}
+
+// canDebit reports whether money may leave the wallet. The stricter of the
+// user's and the wallet's status applies.
+func canDebit(user *models.User, wallet *models.Wallet) bool {
+	return models.CanDebit(user.Status) && models.CanDebit(wallet.Status)
+}
+
+// canCredit reports whether money may arrive in the wallet
+func canCredit(user *models.User, wallet *models.Wallet) bool {
+	return models.CanCredit(user.Status) && models.CanCredit(wallet.Status)
+}
 
-	body, _ := io.ReadAll(resp.Body)
-	var result map[string]interface{}
-	json.Unmarshal(body, &result)
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("Paystack error: %v", result)
+// getWallet returns the user's wallet for the given mode. Sandbox wallets
+// are created on first use; live wallets are created at sign-up.
+func (s *walletService) getWallet(userID uuid.UUID, mode string) (*models.Wallet, error) {
+	if mode == models.ModeTest {
+		return s.walletRepo.GetOrCreateWallet(userID, mode)
 	}
-	return result, nil
+	return s.walletRepo.GetWalletByUserID(userID, mode)
 }
@@ -0,0 +1,568 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"whotterre/argent/internal/config"
+	"whotterre/argent/internal/customErrors"
+	"whotterre/argent/internal/dto"
+	"whotterre/argent/internal/models"
+	"whotterre/argent/internal/repositories"
+	"whotterre/argent/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+const (
+	webhookDispatchBatch = 100
+	webhookDeliverBatch  = 50
+	webhookDescMax       = 200
+	webhookResponseMax   = 1024 // bytes of an endpoint's reply kept in the log
+)
+
+// WebhookService lets users register endpoints that are sent signed events
+// from the outbox, retried with backoff until they are accepted
+type WebhookService interface {
+	CreateEndpoint(actor AuditActor, mode string, input dto.CreateWebhookEndpointRequest) (*models.WebhookEndpoint, string, error)
+	ListEndpoints(userID uuid.UUID, mode string) ([]models.WebhookEndpoint, error)
+	GetEndpoint(userID uuid.UUID, mode string, id uuid.UUID) (*models.WebhookEndpoint, error)
+	UpdateEndpoint(actor AuditActor, mode string, id uuid.UUID, input dto.UpdateWebhookEndpointRequest) (*models.WebhookEndpoint, error)
+	DeleteEndpoint(actor AuditActor, mode string, id uuid.UUID) error
+	RotateSecret(actor AuditActor, mode string, id uuid.UUID) (*models.WebhookEndpoint, string, error)
+	Ping(userID uuid.UUID, mode string, id uuid.UUID) (*models.WebhookDelivery, error)
+	ListDeliveries(userID uuid.UUID, mode string, endpointID uuid.UUID, filter dto.WebhookDeliveryFilter) ([]models.WebhookDelivery, int64, error)
+	Redeliver(actor AuditActor, mode string, endpointID, deliveryID uuid.UUID) (*models.WebhookDelivery, error)
+	DispatchEvents() error
+	DeliverDue() error
+}
+
+type webhookService struct {
+	endpointRepo repositories.WebhookEndpointRepository
+	outboxRepo   repositories.OutboxRepository
+	auditService AuditService
+	client       *http.Client
+	db           *gorm.DB
+	config       config.Config
+}
+
+func NewWebhookService(endpointRepo repositories.WebhookEndpointRepository, outboxRepo repositories.OutboxRepository, auditService AuditService, db *gorm.DB, cfg config.Config) WebhookService {
+	return &webhookService{
+		endpointRepo: endpointRepo,
+		outboxRepo:   outboxRepo,
+		auditService: auditService,
+		client:       newWebhookClient(cfg),
+		db:           db,
+		config:       cfg,
+	}
+}
+
+// newWebhookClient returns a client that doesn't follow redirects and,
+// unless insecure endpoints are allowed, refuses to connect to addresses
+// that aren't public, whatever the endpoint's hostname resolves to
+func newWebhookClient(cfg config.Config) *http.Client {
+	dialer := &net.Dialer{Timeout: cfg.WebhookTimeout}
+	if !cfg.WebhookAllowInsecure {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !utils.IsPublicIP(addrPort.Addr()) {
+				return fmt.Errorf("refusing to connect to %s", address)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &http.Client{
+		Timeout:   cfg.WebhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// CreateEndpoint registers an endpoint in mode. The signing secret is only
+// returned here and when it is rotated.
+func (s *webhookService) CreateEndpoint(actor AuditActor, mode string, input dto.CreateWebhookEndpointRequest) (*models.WebhookEndpoint, string, error) {
+	endpointURL, err := s.checkURL(input.URL)
+	if err != nil {
+		return nil, "", err
+	}
+	events, err := checkEvents(input.Events)
+	if err != nil {
+		return nil, "", err
+	}
+	description := strings.TrimSpace(input.Description)
+	if len([]rune(description)) > webhookDescMax {
+		return nil, "", customErrors.ErrDescriptionTooLong
+	}
+
+	count, err := s.endpointRepo.CountUserEndpoints(actor.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+	if int(count) >= s.config.WebhookMaxEndpoints {
+		return nil, "", fmt.Errorf("%w: at most %d", customErrors.ErrTooManyWebhookEndpoints, s.config.WebhookMaxEndpoints)
+	}
+
+	secret, encrypted, err := s.newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	endpoint := &models.WebhookEndpoint{
+		UserID:          actor.UserID,
+		Mode:            mode,
+		URL:             endpointURL,
+		Description:     description,
+		Events:          events,
+		EncryptedSecret: encrypted,
+		Active:          true,
+	}
+	if err := s.endpointRepo.CreateEndpoint(endpoint); err != nil {
+		return nil, "", err
+	}
+
+	s.auditService.Record(actor, models.AuditWebhookEndpoint, "webhook_endpoint", endpoint.ID.String(), nil, endpoint)
+	return endpoint, secret, nil
+}
+
+func (s *webhookService) ListEndpoints(userID uuid.UUID, mode string) ([]models.WebhookEndpoint, error) {
+	return s.endpointRepo.ListUserEndpoints(userID, mode)
+}
+
+func (s *webhookService) GetEndpoint(userID uuid.UUID, mode string, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	return s.endpointRepo.GetUserEndpoint(id, userID, mode)
+}
+
+func (s *webhookService) UpdateEndpoint(actor AuditActor, mode string, id uuid.UUID, input dto.UpdateWebhookEndpointRequest) (*models.WebhookEndpoint, error) {
+	endpoint, err := s.endpointRepo.GetUserEndpoint(id, actor.UserID, mode)
+	if err != nil {
+		return nil, err
+	}
+	before := *endpoint
+
+	updates := map[string]interface{}{}
+	if input.URL != nil {
+		endpointURL, err := s.checkURL(*input.URL)
+		if err != nil {
+			return nil, err
+		}
+		endpoint.URL = endpointURL
+		updates["url"] = endpointURL
+	}
+	if input.Events != nil {
+		events, err := checkEvents(input.Events)
+		if err != nil {
+			return nil, err
+		}
+		endpoint.Events = events
+		updates["events"] = pq.StringArray(events)
+	}
+	if input.Description != nil {
+		description := strings.TrimSpace(*input.Description)
+		if len([]rune(description)) > webhookDescMax {
+			return nil, customErrors.ErrDescriptionTooLong
+		}
+		endpoint.Description = description
+		updates["description"] = description
+	}
+	if input.Active != nil {
+		endpoint.Active = *input.Active
+		updates["active"] = *input.Active
+	}
+	if len(updates) == 0 {
+		return endpoint, nil
+	}
+	if err := s.endpointRepo.UpdateEndpoint(endpoint.ID, updates); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditWebhookEndpoint, "webhook_endpoint", endpoint.ID.String(), before, endpoint)
+	return endpoint, nil
+}
+
+// DeleteEndpoint removes the endpoint and its delivery log. Deliveries
+// still waiting are dropped.
+func (s *webhookService) DeleteEndpoint(actor AuditActor, mode string, id uuid.UUID) error {
+	endpoint, err := s.endpointRepo.GetUserEndpoint(id, actor.UserID, mode)
+	if err != nil {
+		return err
+	}
+	if err := s.endpointRepo.DeleteEndpoint(endpoint.ID); err != nil {
+		return err
+	}
+
+	s.auditService.Record(actor, models.AuditWebhookEndpoint, "webhook_endpoint", endpoint.ID.String(), endpoint, nil)
+	return nil
+}
+
+// RotateSecret replaces the endpoint's signing secret. Deliveries sent from
+// now on, including retries, are signed with the new one.
+func (s *webhookService) RotateSecret(actor AuditActor, mode string, id uuid.UUID) (*models.WebhookEndpoint, string, error) {
+	endpoint, err := s.endpointRepo.GetUserEndpoint(id, actor.UserID, mode)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, encrypted, err := s.newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.endpointRepo.UpdateEndpoint(endpoint.ID, map[string]interface{}{"encrypted_secret": encrypted}); err != nil {
+		return nil, "", err
+	}
+	endpoint.EncryptedSecret = encrypted
+
+	s.auditService.Record(actor, models.AuditWebhookEndpoint, "webhook_endpoint", endpoint.ID.String(), nil, map[string]interface{}{"secret_rotated": true})
+	return endpoint, secret, nil
+}
+
+// Ping queues a ping event for one endpoint so its owner can check it
+// receives and verifies deliveries
+func (s *webhookService) Ping(userID uuid.UUID, mode string, id uuid.UUID) (*models.WebhookDelivery, error) {
+	endpoint, err := s.endpointRepo.GetUserEndpoint(id, userID, mode)
+	if err != nil {
+		return nil, err
+	}
+	if !endpoint.Active {
+		return nil, customErrors.ErrWebhookEndpointInactive
+	}
+
+	now := time.Now().UTC()
+	var delivery *models.WebhookDelivery
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		event := &models.OutboxEvent{
+			UserID:       userID,
+			Mode:         mode,
+			Type:         models.EventPing,
+			Data:         map[string]interface{}{"endpoint_id": endpoint.ID},
+			DispatchedAt: &now,
+		}
+		if err := repositories.NewOutboxRepository(tx).CreateEvent(event); err != nil {
+			return err
+		}
+		delivery = &models.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+		}
+		return repositories.NewWebhookEndpointRepository(tx).CreateDeliveries([]models.WebhookDelivery{*delivery})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *webhookService) ListDeliveries(userID uuid.UUID, mode string, endpointID uuid.UUID, filter dto.WebhookDeliveryFilter) ([]models.WebhookDelivery, int64, error) {
+	endpoint, err := s.endpointRepo.GetUserEndpoint(endpointID, userID, mode)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.endpointRepo.ListDeliveries(endpoint.ID, filter.Status, filter.Limit, filter.Offset)
+}
+
+// Redeliver sends a delivery again straight away with a fresh set of
+// attempts, whether it succeeded or ran out of attempts
+func (s *webhookService) Redeliver(actor AuditActor, mode string, endpointID, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	endpoint, err := s.endpointRepo.GetUserEndpoint(endpointID, actor.UserID, mode)
+	if err != nil {
+		return nil, err
+	}
+	if !endpoint.Active {
+		return nil, customErrors.ErrWebhookEndpointInactive
+	}
+	delivery, err := s.endpointRepo.GetDelivery(deliveryID, endpoint.ID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status == models.DeliveryPending {
+		return nil, customErrors.ErrDeliveryAlreadyScheduled
+	}
+
+	now := time.Now().UTC()
+	if err := s.endpointRepo.UpdateDelivery(delivery.ID, map[string]interface{}{
+		"status":          models.DeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": now,
+	}); err != nil {
+		return nil, err
+	}
+	delivery.Status = models.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+
+	s.auditService.Record(actor, models.AuditWebhookRedeliver, "webhook_delivery", delivery.ID.String(), nil, map[string]interface{}{
+		"endpoint_id": endpoint.ID,
+		"event_id":    delivery.EventID,
+		"event_type":  delivery.EventType,
+	})
+	return delivery, nil
+}
+
+// DispatchEvents queues a delivery of each new outbox event to every active
+// endpoint of its user that subscribes to it. Each batch of events is
+// locked, fanned out and marked dispatched in one transaction, so replicas
+// don't dispatch the same event and none is skipped if one stops.
+func (s *webhookService) DispatchEvents() error {
+	for {
+		dispatched := 0
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			outboxRepo := repositories.NewOutboxRepository(tx)
+			endpointRepo := repositories.NewWebhookEndpointRepository(tx)
+
+			events, err := outboxRepo.LockUndispatched(webhookDispatchBatch)
+			if err != nil {
+				return err
+			}
+			now := time.Now().UTC()
+			endpoints := map[string][]models.WebhookEndpoint{}
+			var deliveries []models.WebhookDelivery
+			ids := make([]uuid.UUID, len(events))
+			for i, event := range events {
+				ids[i] = event.ID
+				key := event.UserID.String() + "/" + event.Mode
+				userEndpoints, ok := endpoints[key]
+				if !ok {
+					userEndpoints, err = endpointRepo.GetActiveEndpoints(event.UserID, event.Mode)
+					if err != nil {
+						return err
+					}
+					endpoints[key] = userEndpoints
+				}
+				for _, endpoint := range userEndpoints {
+					if !endpoint.Subscribes(event.Type) {
+						continue
+					}
+					deliveries = append(deliveries, models.WebhookDelivery{
+						EndpointID:    endpoint.ID,
+						EventID:       event.ID,
+						EventType:     event.Type,
+						Status:        models.DeliveryPending,
+						NextAttemptAt: now,
+					})
+				}
+			}
+			if err := endpointRepo.CreateDeliveries(deliveries); err != nil {
+				return err
+			}
+			dispatched = len(events)
+			return outboxRepo.MarkDispatched(ids, now)
+		})
+		if err != nil {
+			return err
+		}
+		if dispatched < webhookDispatchBatch {
+			return nil
+		}
+	}
+}
+
+// DeliverDue sends deliveries whose next attempt is due. Each is claimed
+// first so only one replica sends it.
+func (s *webhookService) DeliverDue() error {
+	now := time.Now().UTC()
+	deliveries, err := s.endpointRepo.GetDueDeliveries(now, webhookDeliverBatch)
+	if err != nil {
+		return err
+	}
+	for i := range deliveries {
+		// Hold the delivery for longer than a send can take
+		claimed, err := s.endpointRepo.ClaimDelivery(deliveries[i].ID, now, now.Add(s.config.WebhookTimeout+time.Minute))
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		if err := s.deliver(&deliveries[i]); err != nil {
+			log.Printf("Failed to deliver webhook %s: %v", deliveries[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// deliver makes one attempt at a delivery and records the outcome. An
+// error means the outcome couldn't be recorded; the claim then runs out
+// and the delivery is tried again.
+func (s *webhookService) deliver(delivery *models.WebhookDelivery) error {
+	endpoint, err := s.endpointRepo.GetEndpointByID(delivery.EndpointID)
+	if err != nil {
+		return err
+	}
+	if !endpoint.Active {
+		return s.endpointRepo.UpdateDelivery(delivery.ID, map[string]interface{}{
+			"status":     models.DeliveryFailed,
+			"last_error": customErrors.ErrWebhookEndpointInactive.Error(),
+		})
+	}
+	event, err := s.outboxRepo.GetEventByID(delivery.EventID)
+	if err != nil {
+		return err
+	}
+	secret, err := utils.DecryptSecret(s.config.SecretsEncryptionKey, endpoint.EncryptedSecret)
+	if err != nil {
+		return err
+	}
+
+	statusCode, response, sendErr := s.send(endpoint.URL, secret, delivery, event)
+	attempts := delivery.Attempts + 1
+	now := time.Now().UTC()
+	updates := map[string]interface{}{
+		"attempts":         attempts,
+		"last_status_code": statusCode,
+		"last_response":    response,
+		"last_error":       "",
+	}
+	switch {
+	case sendErr == nil && statusCode >= 200 && statusCode < 300:
+		updates["status"] = models.DeliverySucceeded
+		updates["delivered_at"] = now
+	default:
+		if sendErr != nil {
+			updates["last_error"] = sendErr.Error()
+		} else {
+			updates["last_error"] = fmt.Sprintf("endpoint answered %d", statusCode)
+		}
+		if attempts >= s.config.WebhookMaxAttempts {
+			updates["status"] = models.DeliveryFailed
+		} else {
+			updates["status"] = models.DeliveryPending
+			updates["next_attempt_at"] = now.Add(s.backoff(attempts))
+		}
+	}
+	return s.endpointRepo.UpdateDelivery(delivery.ID, updates)
+}
+
+// send posts the event to the endpoint, signed with its secret. It returns
+// the status code and the start of the reply.
+func (s *webhookService) send(endpointURL, secret string, delivery *models.WebhookDelivery, event *models.OutboxEvent) (int, string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"id":         event.ID,
+		"type":       event.Type,
+		"mode":       event.Mode,
+		"created_at": event.CreatedAt,
+		"data":       event.Data,
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.WebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Argent-Webhooks/1.0")
+	req.Header.Set("Argent-Event", event.Type)
+	req.Header.Set("Argent-Delivery", delivery.ID.String())
+	req.Header.Set("Argent-Signature", "t="+timestamp+",v1="+utils.SignRequest(secret, timestamp+"."+string(body)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseMax))
+	return resp.StatusCode, strings.ToValidUTF8(string(reply), ""), nil
+}
+
+// backoff is the wait after the given number of failed attempts, doubling
+// from WebhookRetryBase up to WebhookRetryMax
+func (s *webhookService) backoff(attempts int) time.Duration {
+	wait := s.config.WebhookRetryBase
+	for i := 1; i < attempts && wait < s.config.WebhookRetryMax; i++ {
+		wait *= 2
+	}
+	return min(wait, s.config.WebhookRetryMax)
+}
+
+// newSecret returns a signing secret and its encrypted form
+func (s *webhookService) newSecret() (string, string, error) {
+	secret := "whsec_" + utils.GenString(32)
+	encrypted, err := utils.EncryptSecret(s.config.SecretsEncryptionKey, secret)
+	if err != nil {
+		return "", "", err
+	}
+	return secret, encrypted, nil
+}
+
+// checkURL accepts an absolute https URL whose host isn't a private
+// address. Hostnames are checked again when each delivery connects.
+func (s *webhookService) checkURL(raw string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || parsed.Host == "" || parsed.User != nil {
+		return "", customErrors.ErrInvalidWebhookURL
+	}
+	if s.config.WebhookAllowInsecure {
+		if parsed.Scheme != "https" && parsed.Scheme != "http" {
+			return "", customErrors.ErrInvalidWebhookURL
+		}
+		return parsed.String(), nil
+	}
+	if parsed.Scheme != "https" {
+		return "", customErrors.ErrInvalidWebhookURL
+	}
+	host := parsed.Hostname()
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return "", customErrors.ErrInvalidWebhookURL
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !utils.IsPublicIP(addr) {
+		return "", customErrors.ErrInvalidWebhookURL
+	}
+	return parsed.String(), nil
+}
+
+// checkEvents accepts known event types, families of them such as
+// "escrow.*", and "*", dropping repeats
+func checkEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, customErrors.ErrInvalidWebhookEvents
+	}
+	checked := make([]string, 0, len(events))
+	for _, event := range events {
+		event = strings.TrimSpace(event)
+		if !isKnownEvent(event) {
+			return nil, customErrors.ErrInvalidWebhookEvents
+		}
+		if !slices.Contains(checked, event) {
+			checked = append(checked, event)
+		}
+	}
+	return checked, nil
+}
+
+func isKnownEvent(event string) bool {
+	if event == "*" {
+		return true
+	}
+	family, isFamily := strings.CutSuffix(event, ".*")
+	for _, known := range models.WebhookEventTypes {
+		if known == event || (isFamily && strings.HasPrefix(known, family+".")) {
+			return true
+		}
+	}
+	return false
+}
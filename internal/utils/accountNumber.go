@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// nubanWeights are the NUBAN check digit weights for a 9-digit serial
+var nubanWeights = [9]int{3, 7, 3, 3, 7, 3, 3, 7, 3}
+
+// GenAccountNumber returns a random 10-digit, NUBAN-style account number: a
+// 9-digit serial followed by a check digit. The serial never starts with 0
+// so numbers keep their length in spreadsheets.
+func GenAccountNumber() string {
+	serial, _ := rand.Int(rand.Reader, big.NewInt(900_000_000))
+	digits := []byte(big.NewInt(0).Add(serial, big.NewInt(100_000_000)).String())
+	return string(append(digits, accountCheckDigit(digits)))
+}
+
+// IsValidAccountNumber reports whether s is 10 digits with a correct check
+// digit, which catches any single mistyped digit
+func IsValidAccountNumber(s string) bool {
+	if len(s) != 10 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return accountCheckDigit([]byte(s[:9])) == s[9]
+}
+
+func accountCheckDigit(serial []byte) byte {
+	sum := 0
+	for i, d := range serial {
+		sum += int(d-'0') * nubanWeights[i]
+	}
+	return byte('0' + (10-sum%10)%10)
+}
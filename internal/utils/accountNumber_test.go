@@ -0,0 +1,57 @@
+package utils
+
+import "testing"
+
+// Check digits worked out by hand with the NUBAN weights 3,7,3,3,7,3,3,7,3
+var validAccountNumbers = []string{"1000000007", "3020471151", "4829301751", "9999999999"}
+
+func TestIsValidAccountNumber(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"1000000007", true},
+		{"3020471151", true},
+		{"4829301751", true},
+		{"9999999999", true},
+		{"1000000000", false},
+		{"3020471158", false},
+		{"", false},
+		{"100000000", false},
+		{"10000000070", false},
+		{"10000a0007", false},
+		{"-100000007", false},
+		{" 100000007", false},
+	}
+	for _, tt := range tests {
+		if got := IsValidAccountNumber(tt.number); got != tt.want {
+			t.Errorf("IsValidAccountNumber(%q) = %v, want %v", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestIsValidAccountNumberCatchesSingleDigitTypos(t *testing.T) {
+	for _, number := range validAccountNumbers {
+		for i := 0; i < len(number); i++ {
+			for d := byte('0'); d <= '9'; d++ {
+				if d == number[i] {
+					continue
+				}
+				typo := []byte(number)
+				typo[i] = d
+				if IsValidAccountNumber(string(typo)) {
+					t.Errorf("%s accepted as a typo of %s", typo, number)
+				}
+			}
+		}
+	}
+}
+
+func TestGenAccountNumber(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		number := GenAccountNumber()
+		if !IsValidAccountNumber(number) || number[0] == '0' {
+			t.Fatalf("GenAccountNumber() = %q", number)
+		}
+	}
+}
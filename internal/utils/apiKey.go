@@ -2,39 +2,99 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func GenerateNewAPIKeyString() string {
-	prefix := "sk_live_"
+func GenerateNewAPIKeyString(mode string) string {
+	prefix := "sk_" + mode + "_"
 
 	end := GenString(45)
 
 	return prefix + end
 }
 
-func ExpiryStringToTimestamp(expiryStr string) (time.Time, error) {
-	now := time.Now()
-	switch expiryStr {
-	case "1H":
-		return now.Add(time.Hour), nil
-	case "1D":
-		return now.AddDate(0, 0, 1), nil 
-	case "1M":
-		return now.AddDate(0, 1, 0), nil 
-	case "1Y":
-		return now.AddDate(1, 0, 0), nil 
-	default:
-		return time.Time{}, errors.New("invalid expiry string") 
-	}
+// APIKeyLookupHash identifies a key by the SHA-256 of its secret, so it can
+// be found with one indexed lookup before its bcrypt hash is checked. Keys
+// are random enough that a fast hash gives nothing away.
+func APIKeyLookupHash(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
 }
 
+// ExpiryNever is the expiry value for keys that never expire
+const ExpiryNever = "never"
+
+var (
+	shorthandExpiry   = regexp.MustCompile(`^(\d+)([HDWMY])$`)
+	iso8601Duration   = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+	errInvalidExpiry  = errors.New("invalid expiry string")
+	errExpiryNotAfter = errors.New("expiry must be in the future")
+)
+
+// ExpiryStringToTimestamp converts an expiry into an absolute timestamp
+// relative to now. A nil timestamp means the key never expires. Accepted forms:
+//   - shorthand: "12H", "30D", "2W", "6M", "1Y"
+//   - Go durations: "90m", "36h"
+//   - ISO-8601 durations: "P30D", "PT12H", "P1Y2M"
+//   - ISO-8601 dates: "2026-12-31" or "2026-12-31T18:00:00Z"
+//   - "never"
+func ExpiryStringToTimestamp(expiryStr string, now time.Time) (*time.Time, error) {
+	expiryStr = strings.TrimSpace(expiryStr)
+	if expiryStr == "" {
+		return nil, errInvalidExpiry
+	}
+	if strings.EqualFold(expiryStr, ExpiryNever) {
+		return nil, nil
+	}
+
+	var expiresAt time.Time
+	if m := shorthandExpiry.FindStringSubmatch(expiryStr); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "H":
+			expiresAt = now.Add(time.Duration(n) * time.Hour)
+		case "D":
+			expiresAt = now.AddDate(0, 0, n)
+		case "W":
+			expiresAt = now.AddDate(0, 0, 7*n)
+		case "M":
+			expiresAt = now.AddDate(0, n, 0)
+		case "Y":
+			expiresAt = now.AddDate(n, 0, 0)
+		}
+	} else if m := iso8601Duration.FindStringSubmatch(expiryStr); m != nil && expiryStr != "P" && !strings.HasSuffix(expiryStr, "T") {
+		parts := make([]int, len(m)-1)
+		for i, v := range m[1:] {
+			parts[i], _ = strconv.Atoi(v)
+		}
+		expiresAt = now.AddDate(parts[0], parts[1], 7*parts[2]+parts[3]).
+			Add(time.Duration(parts[4])*time.Hour + time.Duration(parts[5])*time.Minute + time.Duration(parts[6])*time.Second)
+	} else if d, err := time.ParseDuration(expiryStr); err == nil {
+		expiresAt = now.Add(d)
+	} else if t, err := time.Parse(time.RFC3339, expiryStr); err == nil {
+		expiresAt = t
+	} else if t, err := time.Parse(time.DateOnly, expiryStr); err == nil {
+		expiresAt = t
+	} else {
+		return nil, errInvalidExpiry
+	}
+
+	if !expiresAt.After(now) {
+		return nil, errExpiryNotAfter
+	}
+	return &expiresAt, nil
+}
 
 func GenString(length int) string {
 	bytes := make([]byte, length)
 	rand.Read(bytes)
 	result := base64.RawURLEncoding.EncodeToString(bytes)
 	return result
-}
\ No newline at end of file
+}
@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrences a schedule can use besides a cron expression. They repeat at
+// the start time's time of day; monthly schedules started on the 29th-31st
+// fall on the last day of shorter months.
+const (
+	RecurrenceOnce    = "once"
+	RecurrenceDaily   = "daily"
+	RecurrenceWeekly  = "weekly"
+	RecurrenceMonthly = "monthly"
+)
+
+var errInvalidCron = errors.New("invalid cron expression")
+
+// cronSpec is a parsed five-field cron expression, evaluated in UTC
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64 // bit sets
+	domAny, dowAny                bool
+}
+
+// ValidRecurrence reports whether recurrence can be used for a schedule.
+// Cron expressions must name a single minute so nothing runs more than
+// hourly.
+func ValidRecurrence(recurrence string) bool {
+	switch recurrence {
+	case "", RecurrenceOnce, RecurrenceDaily, RecurrenceWeekly, RecurrenceMonthly:
+		return true
+	}
+	_, err := parseCron(recurrence)
+	return err == nil
+}
+
+// NextOccurrence returns the first occurrence of a schedule starting at
+// start that is after after. It reports false when there are none left.
+func NextOccurrence(recurrence string, start, after time.Time) (time.Time, bool) {
+	start = start.UTC()
+	after = after.UTC()
+	switch recurrence {
+	case "", RecurrenceOnce:
+		return start, start.After(after)
+	case RecurrenceDaily:
+		return nextByPeriod(start, after, 24*time.Hour), true
+	case RecurrenceWeekly:
+		return nextByPeriod(start, after, 7*24*time.Hour), true
+	case RecurrenceMonthly:
+		return nextMonthly(start, after), true
+	}
+
+	spec, err := parseCron(recurrence)
+	if err != nil {
+		return time.Time{}, false
+	}
+	// Cron schedules run from the start time on, like the others
+	if after.Before(start) {
+		after = start.Add(-time.Minute)
+	}
+	return spec.next(after)
+}
+
+func nextByPeriod(start, after time.Time, period time.Duration) time.Time {
+	if after.Before(start) {
+		return start
+	}
+	periods := after.Sub(start)/period + 1
+	return start.Add(periods * period)
+}
+
+func nextMonthly(start, after time.Time) time.Time {
+	months := 0
+	if after.After(start) {
+		months = (after.Year()-start.Year())*12 + int(after.Month()-start.Month()) - 1
+		if months < 0 {
+			months = 0
+		}
+	}
+	for ; ; months++ {
+		candidate := monthlyOccurrence(start, months)
+		if candidate.After(after) {
+			return candidate
+		}
+	}
+}
+
+// monthlyOccurrence returns start moved forward by months, clamping the day
+// to the end of shorter months
+func monthlyOccurrence(start time.Time, months int) time.Time {
+	firstOfMonth := time.Date(start.Year(), start.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	day := start.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(firstOfMonth.Year(), firstOfMonth.Month(), day, start.Hour(), start.Minute(), start.Second(), 0, time.UTC)
+}
+
+// parseCron parses "minute hour day-of-month month day-of-week". Fields take
+// *, numbers, ranges (1-5), lists (1,15) and steps (*/2, 1-10/3).
+func parseCron(expr string) (*cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errInvalidCron
+	}
+	if _, err := strconv.Atoi(fields[0]); err != nil {
+		return nil, errInvalidCron
+	}
+
+	spec := &cronSpec{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := [5]*uint64{&spec.minute, &spec.hour, &spec.dom, &spec.month, &spec.dow}
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, err
+		}
+		*sets[i] = set
+	}
+	// Sunday is both 0 and 7
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1
+	}
+	return spec, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if rangePart, stepPart, ok := strings.Cut(part, "/"); ok {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, errInvalidCron
+			}
+			part = rangePart
+		}
+
+		low, high := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			lowPart, highPart, _ := strings.Cut(part, "-")
+			var errLow, errHigh error
+			low, errLow = strconv.Atoi(lowPart)
+			high, errHigh = strconv.Atoi(highPart)
+			if errLow != nil || errHigh != nil {
+				return 0, errInvalidCron
+			}
+		default:
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, errInvalidCron
+			}
+			low, high = value, value
+		}
+		if low < min || high > max || low > high {
+			return 0, errInvalidCron
+		}
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// next finds the first matching minute after after, searching up to five
+// years ahead so impossible dates like 31 February end the search
+func (s *cronSpec) next(after time.Time) (time.Time, bool) {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// dayMatches follows cron: when both day fields are restricted, either one
+// matching is enough
+func (s *cronSpec) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
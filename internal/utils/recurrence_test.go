@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func utc(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestNextOccurrenceMonthlyClampsToMonthEnd(t *testing.T) {
+	start := utc(2025, time.January, 31, 10, 0)
+	tests := []struct {
+		name  string
+		after time.Time
+		want  time.Time
+	}{
+		{"before start", utc(2025, time.January, 1, 0, 0), start},
+		{"short month", start, utc(2025, time.February, 28, 10, 0)},
+		{"back to the 31st", utc(2025, time.February, 28, 10, 0), utc(2025, time.March, 31, 10, 0)},
+		{"30-day month", utc(2025, time.March, 31, 10, 0), utc(2025, time.April, 30, 10, 0)},
+		{"later the same day", utc(2025, time.April, 30, 9, 59), utc(2025, time.April, 30, 10, 0)},
+		{"across a year", utc(2025, time.December, 31, 10, 0), utc(2026, time.January, 31, 10, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NextOccurrence(RecurrenceMonthly, start, tt.after)
+			if !ok || !got.Equal(tt.want) {
+				t.Errorf("NextOccurrence(monthly, %s) = %s, %v; want %s", tt.after, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextOccurrenceMonthlyLeapYear(t *testing.T) {
+	start := utc(2024, time.January, 30, 8, 0)
+	got, _ := NextOccurrence(RecurrenceMonthly, start, start)
+	if want := utc(2024, time.February, 29, 8, 0); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// 2025-06-02 and 2025-06-09 are Mondays
+func TestNextOccurrenceCronDays(t *testing.T) {
+	start := utc(2025, time.June, 1, 0, 0)
+	tests := []struct {
+		name  string
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{"day of month only", "0 9 10 * *", utc(2025, time.June, 3, 0, 0), utc(2025, time.June, 10, 9, 0)},
+		{"day of week only", "0 9 * * 1", utc(2025, time.June, 9, 9, 0), utc(2025, time.June, 16, 9, 0)},
+		{"both match either, weekday first", "0 9 10 * 1", utc(2025, time.June, 3, 0, 0), utc(2025, time.June, 9, 9, 0)},
+		{"both match either, day of month first", "0 9 10 * 1", utc(2025, time.June, 9, 9, 0), utc(2025, time.June, 10, 9, 0)},
+		{"sunday as 7", "30 6 * * 7", utc(2025, time.June, 2, 0, 0), utc(2025, time.June, 8, 6, 30)},
+		{"hour range with step", "15 8-18/5 * * *", utc(2025, time.June, 3, 9, 0), utc(2025, time.June, 3, 13, 15)},
+		{"runs from start", "0 9 * * *", utc(2025, time.May, 1, 0, 0), utc(2025, time.June, 1, 9, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NextOccurrence(tt.expr, start, tt.after)
+			if !ok || !got.Equal(tt.want) {
+				t.Errorf("NextOccurrence(%q, %s) = %s, %v; want %s", tt.expr, tt.after, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextOccurrenceCronSearchLimit(t *testing.T) {
+	start := utc(2025, time.March, 1, 0, 0)
+	tests := []struct {
+		expr   string
+		wantOK bool
+		want   time.Time
+	}{
+		{"0 0 31 2 *", false, time.Time{}},
+		{"0 0 30 2 *", false, time.Time{}},
+		{"0 0 29 2 *", true, utc(2028, time.February, 29, 0, 0)},
+	}
+	for _, tt := range tests {
+		got, ok := NextOccurrence(tt.expr, start, start)
+		if ok != tt.wantOK || !got.Equal(tt.want) {
+			t.Errorf("NextOccurrence(%q) = %s, %v; want %s, %v", tt.expr, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestValidRecurrence(t *testing.T) {
+	tests := []struct {
+		recurrence string
+		want       bool
+	}{
+		{"", true},
+		{RecurrenceOnce, true},
+		{RecurrenceDaily, true},
+		{RecurrenceWeekly, true},
+		{RecurrenceMonthly, true},
+		{"0 * * * *", true},
+		{"30 9 1,15 * 1-5", true},
+		{"* * * * *", false},
+		{"*/5 * * * *", false},
+		{"0-30 * * * *", false},
+		{"0,30 * * * *", false},
+		{"60 * * * *", false},
+		{"0 24 * * *", false},
+		{"0 0 0 * *", false},
+		{"0 0 * 13 *", false},
+		{"0 0 * * 8", false},
+		{"0 0 * * */0", false},
+		{"0 0 5-1 * *", false},
+		{"0 0 * *", false},
+		{"hourly", false},
+	}
+	for _, tt := range tests {
+		if got := ValidRecurrence(tt.recurrence); got != tt.want {
+			t.Errorf("ValidRecurrence(%q) = %v, want %v", tt.recurrence, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// CanonicalRequest builds the string clients sign for signed requests:
+// method, path (with query string), unix timestamp, nonce and the hex
+// SHA-256 of the body, separated by newlines.
+func CanonicalRequest(method, path, timestamp, nonce, bodyHash string) string {
+	return strings.Join([]string{strings.ToUpper(method), path, timestamp, nonce, bodyHash}, "\n")
+}
+
+// HashBody returns the hex SHA-256 of a request body
+func HashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// SignRequest returns the hex HMAC-SHA256 of a canonical request
+func SignRequest(secret, canonical string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
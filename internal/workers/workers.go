@@ -0,0 +1,22 @@
+package workers
+
+import (
+	"log"
+	"time"
+)
+
+// Every runs job in a background goroutine, once at startup and then on
+// every interval. Jobs must be safe to run concurrently on several replicas;
+// they are expected to claim rows atomically rather than rely on a single runner.
+func Every(interval time.Duration, name string, job func() error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := job(); err != nil {
+				log.Printf("Worker %s failed: %v", name, err)
+			}
+			<-ticker.C
+		}
+	}()
+}